@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pereztr5/cyboard/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ApplyCmd = &cobra.Command{
+		Use:   "apply -f <event.yaml>",
+		Short: "Create & update teams, services, and challenges to match an event file",
+		Args:  cobra.NoArgs,
+		Run:   applyRun,
+	}
+)
+
+func init() {
+	flags := ApplyCmd.Flags()
+	flags.StringP("file", "f", "", "event definition file, in YAML ('-' reads stdin)")
+	flags.Bool("dry-run", false, "only print the planned changes")
+	ApplyCmd.MarkFlagRequired("file")
+}
+
+func applyRun(cmd *cobra.Command, args []string) {
+	c := loadToolConfig()
+
+	flags := cmd.Flags()
+	path, _ := flags.GetString("file")
+	dryRun, _ := flags.GetBool("dry-run")
+
+	if err := server.ApplyEventSpec(c, path, dryRun, os.Stdout); err != nil {
+		fmt.Println("Apply failed:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pereztr5/cyboard/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	ExportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Save the event (teams, services, challenges, files) to an archive",
+		Args:  cobra.NoArgs,
+		Run:   exportRun,
+	}
+	ImportCmd = &cobra.Command{
+		Use:   "import <archive.tar.gz>",
+		Short: "Load an event archive made with `cyboard export`",
+		Args:  cobra.ExactArgs(1),
+		Run:   importRun,
+	}
+)
+
+func init() {
+	flags := ExportCmd.Flags()
+	flags.StringP("output", "o", "", "archive file to write (default cyboard-<date>.tar.gz)")
+	flags.Bool("with-hashes", false, "include team & player password hashes")
+	flags.Bool("with-scores", false, "include the scoring history")
+
+	flags = ImportCmd.Flags()
+	flags.String("on-conflict", string(server.ConflictFail),
+		"what to do with teams, services, challenges & files that already exist: fail, skip, or overwrite")
+	flags.Bool("with-scores", false, "load the scoring history (only into an event with no scores yet)")
+}
+
+func loadToolConfig() *server.Configuration {
+	c := new(server.Configuration)
+	toolConfig := viper.New()
+
+	initConfig(toolConfig, "config")
+
+	mustUnmarshal(toolConfig, c)
+	mustValidate(c)
+	return c
+}
+
+func exportRun(cmd *cobra.Command, args []string) {
+	c := loadToolConfig()
+
+	flags := cmd.Flags()
+	dest, _ := flags.GetString("output")
+	if dest == "" {
+		dest = fmt.Sprintf("cyboard-%s.tar.gz", time.Now().Format("2006-01-02"))
+	}
+	opts := server.ExportOptions{}
+	opts.WithHashes, _ = flags.GetBool("with-hashes")
+	opts.WithScores, _ = flags.GetBool("with-scores")
+
+	if err := server.ExportArchive(c, dest, opts); err != nil {
+		fmt.Println("Export failed:", err)
+		os.Exit(1)
+	}
+}
+
+func importRun(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	opts := server.ImportOptions{}
+	policy, _ := flags.GetString("on-conflict")
+	opts.OnConflict = server.ConflictPolicy(policy)
+	opts.WithScores, _ = flags.GetBool("with-scores")
+	if !opts.OnConflict.Valid() {
+		fmt.Printf("Invalid --on-conflict %q (must be fail, skip, or overwrite)\n", policy)
+		os.Exit(1)
+	}
+
+	c := loadToolConfig()
+	if err := server.ImportArchive(c, args[0], opts); err != nil {
+		fmt.Println("Import failed:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pereztr5/cyboard/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	MigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Set up or update the database schema",
+		Long: "Set up or update the database schema, using the migrations built into cyboard.\n" +
+			"Migrations create roles & extensions, so connect as a postgres superuser.",
+	}
+	migrateUpCmd = &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending migrations",
+		Args:  cobra.NoArgs,
+		Run:   migrateUpRun,
+	}
+	migrateDownCmd = &cobra.Command{
+		Use:   "down",
+		Short: "Revert the most recent migration(s)",
+		Args:  cobra.NoArgs,
+		Run:   migrateDownRun,
+	}
+	migrateStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "List migrations, and whether each has been applied",
+		Args:  cobra.NoArgs,
+		Run:   migrateStatusRun,
+	}
+)
+
+func init() {
+	flags := migrateUpCmd.Flags()
+	flags.IntP("steps", "n", 0, "apply at most this many migrations (default all)")
+	flags.Int("baseline", 0, "for databases set up by hand: mark migrations up to this version as already applied")
+
+	flags = migrateDownCmd.Flags()
+	flags.IntP("steps", "n", 1, "revert this many migrations")
+
+	MigrateCmd.AddCommand(migrateUpCmd, migrateDownCmd, migrateStatusCmd)
+}
+
+func newMigrator() *server.Migrator {
+	c := loadToolConfig()
+	server.SetupToolLogger(&c.Log)
+
+	m, err := server.NewMigrator(c.Database.URI, os.Stdout)
+	if err != nil {
+		fmt.Println("Failed to connect to postgres:", err)
+		os.Exit(1)
+	}
+	return m
+}
+
+func exitOnMigrateErr(err error) {
+	if err != nil {
+		fmt.Println("Migration failed:", err)
+		os.Exit(1)
+	}
+}
+
+func migrateUpRun(cmd *cobra.Command, args []string) {
+	steps, _ := cmd.Flags().GetInt("steps")
+	baseline, _ := cmd.Flags().GetInt("baseline")
+
+	m := newMigrator()
+	defer m.Close()
+	exitOnMigrateErr(m.Up(steps, baseline))
+}
+
+func migrateDownRun(cmd *cobra.Command, args []string) {
+	steps, _ := cmd.Flags().GetInt("steps")
+
+	m := newMigrator()
+	defer m.Close()
+	exitOnMigrateErr(m.Down(steps))
+}
+
+func migrateStatusRun(cmd *cobra.Command, args []string) {
+	m := newMigrator()
+	defer m.Close()
+
+	statuses, err := m.Status()
+	exitOnMigrateErr(err)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC1123)
+		}
+		fmt.Fprintf(tw, "%03d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	tw.Flush()
+}
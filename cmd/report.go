@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pereztr5/cyboard/server"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ReportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Generate the final results report (rankings, uptime, solves, bonuses)",
+		Args:  cobra.NoArgs,
+		Run:   reportRun,
+	}
+)
+
+func init() {
+	flags := ReportCmd.Flags()
+	flags.StringP("output", "o", "", "file to write the report to (default stdout)")
+	flags.StringP("format", "f", "", "report format: html, csv, or json (default from the output file extension, or html)")
+}
+
+func reportRun(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	dest, _ := flags.GetString("output")
+	format, _ := flags.GetString("format")
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(dest), ".")
+	}
+	if format == "" {
+		format = "html"
+	}
+
+	c := loadToolConfig()
+	if err := server.WriteReport(c, dest, format); err != nil {
+		fmt.Println("Report failed:", err)
+		os.Exit(1)
+	}
+}
@@ -31,7 +31,7 @@ func init() {
 		"Connection string for PostgreSQL. Also configured with the environment var: `CY_POSTGRES_URI`")
 	flags.BoolP("stdout", "s", false, "Log to standard out")
 
-	RootCmd.AddCommand(ServerCmd, CheckCmd)
+	RootCmd.AddCommand(ServerCmd, CheckCmd, RunnerCmd, ExportCmd, ImportCmd, ApplyCmd, MigrateCmd, ReportCmd)
 }
 
 // initConfig loads the config file from disk, searching in order:
@@ -52,7 +52,10 @@ func initConfig(v *viper.Viper, configName string) {
 	v.SetDefault("server.rate_limit", true)
 	v.SetDefault("server.compress", true)
 	v.SetDefault("server.ctf_file_dir", "data/ctf")
+	v.SetDefault("server.evidence_dir", "data/evidence")
+	v.SetDefault("server.inject_dir", "data/injects")
 	v.SetDefault("service_monitor.checks_dir", "data/scripts")
+	v.SetDefault("event.tiebreakers", []string{"earliest", "ctf_solves", "uptime"})
 	v.SetDefault("log.level", "info")
 
 	path := v.GetString("configPath")
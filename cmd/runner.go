@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pereztr5/cyboard/server"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	RunnerCmd = &cobra.Command{
+		Use:   "runner",
+		Short: "Run the checks the server assigns from here, and report the results back to it",
+		Long: `Runs service checks from a different vantage point than "cyboard checks", like inside a
+team's network. The runner fetches its assigned checks from the server, runs them with the
+scripts in service_monitor.checks_dir, and sends the results back, authenticating with
+the token made for it on the admin check runners page.
+
+Only the [runner], [log], and service_monitor.checks_dir settings are used. The runner
+doesn't need a database connection.`,
+		Run: startRunner,
+	}
+)
+
+func startRunner(cmd *cobra.Command, args []string) {
+	c := new(server.Configuration)
+	{
+		runnerConfig := viper.New()
+
+		// The token can be kept out of the config file
+		runnerConfig.BindEnv("runner.token", "CY_RUNNER_TOKEN")
+		initConfig(runnerConfig, "config")
+
+		mustUnmarshal(runnerConfig, c)
+		if err := c.Runner.Validate(); err != nil {
+			fmt.Println("Config file validation failed:", err)
+			os.Exit(1)
+		}
+	}
+	server.RunnerRun(c)
+}
@@ -36,5 +36,6 @@ require (
 	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
 	google.golang.org/appengine v1.6.4 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0-20170531160350-a96e63847dc3
+	gopkg.in/yaml.v2 v2.2.2
 	nhooyr.io/websocket v1.7.1
 )
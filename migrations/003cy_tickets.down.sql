@@ -0,0 +1,9 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DROP TABLE IF EXISTS ticket_message;
+DROP TABLE IF EXISTS ticket;
+DROP TYPE IF EXISTS ticket_category;
+
+COMMIT;
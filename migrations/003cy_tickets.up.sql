@@ -0,0 +1,52 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+------------------
+-- Support Tickets
+------------------
+
+/*
+Blue teams open tickets to ask staff for help during the event: resetting a broken box,
+reporting a challenge that can't be solved, or disputing the result of a service check.
+
+A ticket is a thread of messages. The first message is written by the team when the ticket
+is opened, and every reply after that (from staff or the team) is another row in `ticket_message`.
+*/
+CREATE TYPE ticket_category AS ENUM (
+      'box_reset'
+    , 'broken_challenge'
+    , 'check_dispute'
+    , 'other'
+);
+
+CREATE TABLE ticket (
+      id           INT             PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , team_id      INT             NOT NULL REFERENCES team(id) ON DELETE CASCADE
+    , category     ticket_category NOT NULL
+    , subject      TEXT            NOT NULL
+    , assignee_id  INT             NULL REFERENCES team(id) ON DELETE SET NULL -- staff member handling it
+
+    , created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , modified_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , closed_at   TIMESTAMPTZ NULL -- open tickets have no close time
+);
+
+CREATE INDEX ticket_fkey_idx_team ON ticket (team_id);
+
+CREATE TRIGGER mdt_ticket
+    BEFORE UPDATE ON ticket
+    FOR EACH ROW
+    EXECUTE PROCEDURE moddatetime (modified_at);
+
+CREATE TABLE ticket_message (
+      id          INT         PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , ticket_id   INT         NOT NULL REFERENCES ticket(id) ON DELETE CASCADE
+    , author_id   INT         NOT NULL REFERENCES team(id) ON DELETE CASCADE
+    , body        TEXT        NOT NULL
+    , created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ticket_message_fkey_idx_ticket ON ticket_message (ticket_id);
+
+COMMIT;
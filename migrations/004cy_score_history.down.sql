@@ -0,0 +1,8 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DROP VIEW IF EXISTS service_check_tally;
+DROP VIEW IF EXISTS service_check_1m CASCADE;
+
+COMMIT;
@@ -0,0 +1,56 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+----------------
+-- Score History
+----------------
+
+/*
+service_check_1m is the roll-up of `service_check` that was hinted at in the initial schema.
+It's a TimescaleDB continuous aggregate, which counts the checks for every team's service in
+one minute buckets, and is kept up to date in the background as new checks come in.
+
+Drawing the score history (see `TeamsScoreHistory` in the models package) reads from here,
+through `service_check_tally` below, rather than re-summing every single check on each request.
+
+Points are not stored here, because `service.points` may be adjusted during the event.
+The aggregate is grouped by `status` instead of filtering for passing checks, as continuous
+aggregates are picky about what they support.
+*/
+CREATE VIEW service_check_1m
+    WITH (timescaledb.continuous,
+          timescaledb.refresh_interval = '30s',
+          timescaledb.refresh_lag = '0s')
+    AS SELECT time_bucket('1 minute', created_at) AS bucket
+        , team_id
+        , service_id
+        , status
+        , count(*) AS checks
+    FROM service_check
+    GROUP BY bucket, team_id, service_id, status;
+
+/*
+service_check_tally is every check, counted in the same one minute buckets. Buckets before the
+aggregate's completed threshold come from `service_check_1m`, and the checks at or past it,
+which the background job hasn't rolled up (or has only partly rolled up), are counted from the
+`service_check` hypertable itself. Splitting on the threshold counts each check exactly once.
+
+Timescale reports the threshold as text, and it's -infinity before the first refresh.
+*/
+CREATE VIEW service_check_tally (bucket, team_id, service_id, status, checks)
+    AS WITH watermark (threshold) AS (
+        SELECT COALESCE(max(completed_threshold::timestamptz), '-infinity')
+        FROM timescaledb_information.continuous_aggregate_stats
+        WHERE view_name = 'service_check_1m'::regclass
+    )
+    SELECT bucket, team_id, service_id, status, checks
+    FROM service_check_1m
+    WHERE bucket < (SELECT threshold FROM watermark)
+    UNION ALL
+    SELECT time_bucket('1 minute', created_at), team_id, service_id, status, count(*)
+    FROM service_check
+    WHERE created_at >= (SELECT threshold FROM watermark)
+    GROUP BY 1, 2, 3, 4;
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+ALTER TABLE ctf_solve DROP COLUMN IF EXISTS player_id;
+DROP TRIGGER IF EXISTS team_name_uni ON team;
+DROP TABLE IF EXISTS player;
+DROP FUNCTION IF EXISTS player_name_not_team();
+DROP FUNCTION IF EXISTS team_name_not_player();
+
+COMMIT;
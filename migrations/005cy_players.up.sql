@@ -0,0 +1,67 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+-----------------
+-- Player Accounts
+-----------------
+
+/*
+A team used to be a single login, with a password shared by everyone on it. Players are
+individual logins that belong to a team, so each person has their own credentials, and
+CTF solves can be credited to whoever submitted the flag.
+
+A player acts with the permissions of their team's role: players on a staff team
+(admin, ctf_creator) are staff accounts. The team's shared login keeps working, too.
+
+Players & teams log in through the same form, so a player can't take a team's name,
+and a team can't take a player's.
+*/
+CREATE TABLE player (
+      id          INT          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , team_id     INT          NOT NULL REFERENCES team(id) ON DELETE CASCADE
+    , name        TEXT         NOT NULL UNIQUE
+    , hash        BYTEA        NOT NULL
+    , disabled    BOOL         NOT NULL DEFAULT false
+    , created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX player_fkey_idx_team ON player (team_id);
+
+CREATE OR REPLACE FUNCTION player_name_not_team() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM team WHERE name = NEW.name) THEN
+        RAISE EXCEPTION 'player name "%" is already used by a team', NEW.name
+            USING ERRCODE = 'unique_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER player_name_uni
+    BEFORE INSERT OR UPDATE OF name ON player
+    FOR EACH ROW
+    EXECUTE PROCEDURE player_name_not_team();
+
+CREATE OR REPLACE FUNCTION team_name_not_player() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM player WHERE name = NEW.name) THEN
+        RAISE EXCEPTION 'team name "%" is already used by a player', NEW.name
+            USING ERRCODE = 'unique_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER team_name_uni
+    BEFORE INSERT OR UPDATE OF name ON team
+    FOR EACH ROW
+    EXECUTE PROCEDURE team_name_not_player();
+
+-- The player who submitted the flag. Null for solves made with the team's shared login.
+ALTER TABLE ctf_solve
+    ADD COLUMN player_id INT NULL REFERENCES player(id) ON DELETE SET NULL;
+
+CREATE INDEX ctf_solve_fkey_idx_player ON ctf_solve (player_id);
+
+COMMIT;
@@ -0,0 +1,33 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+------------------
+-- Password Resets
+------------------
+
+/*
+One-time tokens, generated by an admin, that let a team or player set a new password
+without knowing the old one. Only a sha256 of the token is kept, so a leaked database
+can't be used to take over accounts.
+
+A token is for exactly one account: either a team's shared login, or a player.
+*/
+CREATE TABLE password_reset (
+      token_hash  BYTEA        PRIMARY KEY
+    , team_id     INT          NULL REFERENCES team(id) ON DELETE CASCADE
+    , player_id   INT          NULL REFERENCES player(id) ON DELETE CASCADE
+    , created_by  INT          NULL REFERENCES team(id) ON DELETE SET NULL -- the admin who made it
+
+    , created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , expires_at  TIMESTAMPTZ  NOT NULL
+    , used_at     TIMESTAMPTZ  NULL
+
+    , CONSTRAINT reset_one_account
+        CHECK ((team_id IS NULL) != (player_id IS NULL))
+);
+
+CREATE INDEX password_reset_fkey_idx_team   ON password_reset (team_id);
+CREATE INDEX password_reset_fkey_idx_player ON password_reset (player_id);
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DROP TRIGGER IF EXISTS player_disabled_revoke_sessions ON player;
+DROP TRIGGER IF EXISTS team_disabled_revoke_sessions ON team;
+DROP FUNCTION IF EXISTS revoke_disabled_sessions();
+DROP TABLE IF EXISTS session;
+
+COMMIT;
@@ -0,0 +1,64 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+------------------
+-- Login Sessions
+------------------
+
+/*
+Server-side storage for login sessions. The browser's cookie only holds the random `token`,
+so deleting a row here logs that browser out immediately.
+
+`data` is the encoded session (owned by the session library). The team & player it's logged in
+as are copied out of it into their own columns, so sessions can be listed & revoked per account.
+Deleting a team or player deletes its sessions, too.
+*/
+CREATE TABLE session (
+      id          INT          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , token       TEXT         NOT NULL UNIQUE
+    , data        BYTEA        NOT NULL
+    , expiry      TIMESTAMPTZ  NOT NULL
+    , team_id     INT          NULL REFERENCES team(id) ON DELETE CASCADE
+    , player_id   INT          NULL REFERENCES player(id) ON DELETE CASCADE
+    , created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , modified_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX session_idx_expiry      ON session (expiry);
+CREATE INDEX session_fkey_idx_team   ON session (team_id);
+CREATE INDEX session_fkey_idx_player ON session (player_id);
+
+CREATE TRIGGER mdt_session
+    BEFORE UPDATE ON session
+    FOR EACH ROW
+    EXECUTE PROCEDURE moddatetime (modified_at);
+
+-- Disabling a team or player logs them out everywhere.
+CREATE OR REPLACE FUNCTION revoke_disabled_sessions() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'team' THEN
+        DELETE FROM session WHERE team_id = NEW.id;
+    ELSE
+        DELETE FROM session WHERE player_id = NEW.id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER team_disabled_revoke_sessions
+    AFTER UPDATE OF disabled ON team
+    FOR EACH ROW
+    WHEN (NEW.disabled AND NOT OLD.disabled)
+    EXECUTE PROCEDURE revoke_disabled_sessions();
+
+CREATE TRIGGER player_disabled_revoke_sessions
+    AFTER UPDATE OF disabled ON player
+    FOR EACH ROW
+    WHEN (NEW.disabled AND NOT OLD.disabled)
+    EXECUTE PROCEDURE revoke_disabled_sessions();
+
+-- Sessions replace the signing key for the old cookie-only sessions
+DELETE FROM config WHERE key = 'session.config';
+
+COMMIT;
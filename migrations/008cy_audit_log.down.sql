@@ -0,0 +1,7 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DROP TABLE IF EXISTS audit_log;
+
+COMMIT;
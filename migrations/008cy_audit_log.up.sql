@@ -0,0 +1,33 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+------------
+-- Audit Log
+------------
+
+/*
+A record of every change made through the staff & admin APIs: who did it, what they did,
+and what the thing they changed looked like before & after. Judges use this to settle
+disputes, e.g. when a team asks why they got a deduction.
+
+The actor's name is copied in, so the log still makes sense after their account is deleted.
+`action` is the route (e.g. "PUT /api/admin/teams/{id}"), and `target` is the actual path.
+*/
+CREATE TABLE audit_log (
+      id          BIGINT       PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , actor_id    INT          NULL REFERENCES team(id) ON DELETE SET NULL
+    , actor       TEXT         NOT NULL
+    , player      TEXT         NULL -- set when the actor logged in as one of the team's players
+    , action      TEXT         NOT NULL
+    , target      TEXT         NOT NULL
+    , status      SMALLINT     NOT NULL -- http response code
+    , request     JSONB        NULL     -- request body, with passwords removed
+    , before      JSONB        NULL
+    , after       JSONB        NULL
+);
+
+CREATE INDEX audit_log_idx_created_at ON audit_log (created_at DESC);
+
+COMMIT;
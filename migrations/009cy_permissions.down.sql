@@ -0,0 +1,46 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DROP TABLE role_permission, permission;
+
+-- Any whiteteam or redteam accounts must be removed (or given another role) first,
+-- or the type change below fails.
+DROP VIEW service_score, ctf_score, other_score, blueteam;
+
+ALTER TYPE team_role RENAME TO team_role_new;
+CREATE TYPE team_role AS ENUM (
+      'admin'
+    , 'ctf_creator'
+    , 'blueteam' -- contestants, students
+);
+ALTER TABLE team ALTER COLUMN role_name TYPE team_role USING role_name::text::team_role;
+DROP TYPE team_role_new;
+
+CREATE VIEW blueteam (id, name, blueteam_ip)
+    AS SELECT team.id, team.name, blueteam_ip
+    FROM team
+    WHERE team.role_name = 'blueteam'
+      AND team.disabled = false;
+
+CREATE VIEW service_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(service.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN service_check AS sc ON team.id = sc.team_id AND sc.status = 'pass'
+        LEFT JOIN service ON sc.service_id = service.id
+    GROUP BY team.id;
+
+CREATE VIEW ctf_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(ch.total), 0)
+    FROM blueteam AS team
+        LEFT JOIN ctf_solve ON team.id = ctf_solve.team_id
+        LEFT JOIN challenge AS ch ON ctf_solve.challenge_id = ch.id
+    GROUP BY team.id;
+
+CREATE VIEW other_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(o.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN other_points AS o ON team.id = o.team_id
+    GROUP BY team.id;
+
+COMMIT;
@@ -0,0 +1,106 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+--------------
+-- Permissions
+--------------
+
+/*
+Two more staff roles:
+* 'whiteteam' are the judges. They settle disputes, and award or dock points.
+* 'redteam' are the attackers.
+
+Postgres 11 can't add values to an enum inside a transaction, so `team_role` is swapped
+out for a new type instead. The views built on `team.role_name` have to go while that happens.
+Blue teams sort last, so that `ORDER BY role_name DESC` still lists them first.
+*/
+DROP VIEW service_score, ctf_score, other_score, blueteam;
+
+ALTER TYPE team_role RENAME TO team_role_old;
+CREATE TYPE team_role AS ENUM (
+      'admin'
+    , 'ctf_creator'
+    , 'whiteteam' -- judges
+    , 'redteam'
+    , 'blueteam'  -- contestants, students
+);
+ALTER TABLE team ALTER COLUMN role_name TYPE team_role USING role_name::text::team_role;
+DROP TYPE team_role_old;
+
+CREATE VIEW blueteam (id, name, blueteam_ip)
+    AS SELECT team.id, team.name, blueteam_ip
+    FROM team
+    WHERE team.role_name = 'blueteam'
+      AND team.disabled = false;
+
+CREATE VIEW service_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(service.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN service_check AS sc ON team.id = sc.team_id AND sc.status = 'pass'
+        LEFT JOIN service ON sc.service_id = service.id
+    GROUP BY team.id;
+
+CREATE VIEW ctf_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(ch.total), 0)
+    FROM blueteam AS team
+        LEFT JOIN ctf_solve ON team.id = ctf_solve.team_id
+        LEFT JOIN challenge AS ch ON ctf_solve.challenge_id = ch.id
+    GROUP BY team.id;
+
+CREATE VIEW other_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(o.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN other_points AS o ON team.id = o.team_id
+    GROUP BY team.id;
+
+/*
+The "yet-to-be-designed" permissions table from the initial schema. Each staff role is
+granted some named permissions, which are checked instead of the role itself.
+
+Admins implicitly have every permission, so they can't lock themselves out.
+Blue teams never get any; what they can do is decided by being a blue team.
+*/
+CREATE TABLE permission (
+      name         TEXT  PRIMARY KEY
+    , description  TEXT  NOT NULL
+);
+
+INSERT INTO permission (name, description) VALUES
+      ('grant_bonus',           'Award or dock points')
+    , ('manage_services',       'Add, edit, and remove service checks')
+    , ('run_scripts',           'View and test run service check scripts')
+    , ('view_logs',             'Read the server''s log files')
+    , ('manage_ctf',            'Add, edit, and remove CTF challenges')
+    , ('view_flags',            'See CTF challenges, their flags, and who solved them')
+    , ('manage_tickets',        'Answer blue teams'' support tickets')
+    , ('view_uptime',           'See every team''s service uptime')
+    , ('view_audit_log',        'Read the audit log of staff changes')
+    , ('view_live_scoreboard',  'See the live scores while the public scoreboard is frozen')
+    , ('ignore_schedule',       'Use the blue team pages before the event, during breaks, and after it ends');
+
+CREATE TABLE role_permission (
+      role_name   team_role  NOT NULL CHECK (role_name NOT IN ('admin', 'blueteam'))
+    , permission  TEXT       NOT NULL REFERENCES permission(name) ON DELETE CASCADE
+    , PRIMARY KEY (role_name, permission)
+);
+
+-- CTF creators keep what they could already do. Judges and the red team get a start.
+INSERT INTO role_permission (role_name, permission) VALUES
+      ('ctf_creator', 'manage_ctf')
+    , ('ctf_creator', 'view_flags')
+    , ('ctf_creator', 'view_logs')
+    , ('ctf_creator', 'manage_tickets')
+    , ('ctf_creator', 'view_uptime')
+    , ('ctf_creator', 'view_audit_log')
+    , ('ctf_creator', 'view_live_scoreboard')
+    , ('ctf_creator', 'ignore_schedule')
+    , ('whiteteam',   'grant_bonus')
+    , ('whiteteam',   'manage_tickets')
+    , ('whiteteam',   'view_uptime')
+    , ('whiteteam',   'view_audit_log')
+    , ('whiteteam',   'view_live_scoreboard')
+    , ('whiteteam',   'ignore_schedule')
+    , ('redteam',     'view_uptime');
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DELETE FROM permission WHERE name IN ('file_compromises', 'review_compromises');
+DROP TABLE compromise_report;
+DROP TYPE compromise_severity;
+
+COMMIT;
@@ -0,0 +1,55 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+------------------------------
+-- Red Team Compromise Reports
+------------------------------
+
+/*
+The red team files a report for each blue team box they get into, with evidence:
+what they did, and any screenshots or loot. The white team (judges) approves or rejects it.
+An approved report docks the blue team points, as a row in `other_points`.
+
+Blue teams can read the approved reports against them, to see what they missed.
+*/
+CREATE TYPE compromise_severity AS ENUM (
+      'low'
+    , 'medium'
+    , 'high'
+    , 'critical'
+);
+
+CREATE TABLE compromise_report (
+      id           INT                  PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , team_id      INT                  NOT NULL REFERENCES team(id) ON DELETE CASCADE -- the blue team compromised
+    , reporter_id  INT                  NULL REFERENCES team(id) ON DELETE SET NULL
+    , service_id   INT                  NULL REFERENCES service(id) ON DELETE SET NULL
+    , host         TEXT                 NOT NULL DEFAULT ''
+    , title        TEXT                 NOT NULL
+    , evidence     TEXT                 NOT NULL
+    , severity     compromise_severity  NOT NULL
+    , created_at   TIMESTAMPTZ          NOT NULL DEFAULT CURRENT_TIMESTAMP
+
+    -- Filled in by the white team. A pending report hasn't been approved or rejected yet.
+    , approved     BOOL                 NULL
+    , points       REAL                 NULL -- docked from the team, if approved
+    , reviewer_id  INT                  NULL REFERENCES team(id) ON DELETE SET NULL
+    , review_note  TEXT                 NOT NULL DEFAULT ''
+    , reviewed_at  TIMESTAMPTZ          NULL
+
+    , CONSTRAINT approved_report_has_points
+        CHECK (coalesce(approved, false) = (points IS NOT NULL))
+);
+
+CREATE INDEX compromise_report_fkey_idx_team ON compromise_report (team_id);
+
+INSERT INTO permission (name, description) VALUES
+      ('file_compromises',   'File red team compromise reports against blue teams')
+    , ('review_compromises', 'Approve or reject red team compromise reports');
+
+INSERT INTO role_permission (role_name, permission) VALUES
+      ('redteam',   'file_compromises')
+    , ('whiteteam', 'review_compromises');
+
+COMMIT;
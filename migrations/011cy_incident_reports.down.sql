@@ -0,0 +1,11 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DELETE FROM permission WHERE name = 'score_incidents';
+DROP VIEW incident_score;
+DROP TABLE incident_rubric_score;
+DROP TABLE incident_report;
+DROP TABLE incident_rubric;
+
+COMMIT;
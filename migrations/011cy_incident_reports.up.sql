@@ -0,0 +1,71 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+----------------------------
+-- Incident Response Reports
+----------------------------
+
+/*
+Blue teams write up each incident they catch: a timeline, the indicators of compromise,
+and how they cleaned up. Judges (the white team) score each report against a rubric,
+and the points are a scoring category of their own, next to services, ctf, and other.
+
+A report is scored once a judge has given it points in every part of the rubric.
+Judges may re-score a report, which replaces its old scores. The points still count
+from when the report was first scored, so `scored_at` is kept, and `rescored_at` is
+when it was last re-scored.
+*/
+CREATE TABLE incident_rubric (
+      id           INT   PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , name         TEXT  NOT NULL UNIQUE
+    , description  TEXT  NOT NULL DEFAULT ''
+    , max_points   REAL  NOT NULL CHECK (max_points >= 0)
+);
+
+INSERT INTO incident_rubric (name, description, max_points) VALUES
+      ('Timeline',    'When the attack started, how it was found, and what was done about it, in order', 10)
+    , ('Indicators',  'Addresses, accounts, files, and processes the attacker used', 10)
+    , ('Remediation', 'How the attacker was removed, and what stops them coming back', 10)
+    , ('Clarity',     'Could management follow it?', 5);
+
+CREATE TABLE incident_report (
+      id           INT          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , team_id      INT          NOT NULL REFERENCES team(id) ON DELETE CASCADE
+    , player_id    INT          NULL REFERENCES player(id) ON DELETE SET NULL -- who wrote it, if not the team login
+    , title        TEXT         NOT NULL
+    , timeline     TEXT         NOT NULL
+    , indicators   TEXT         NOT NULL
+    , remediation  TEXT         NOT NULL
+    , created_at   TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+
+    -- Filled in by the judge. Points are summed from `incident_rubric_score`.
+    , judge_id     INT          NULL REFERENCES team(id) ON DELETE SET NULL
+    , feedback     TEXT         NOT NULL DEFAULT ''
+    , scored_at    TIMESTAMPTZ  NULL
+    , rescored_at  TIMESTAMPTZ  NULL
+);
+
+CREATE INDEX incident_report_fkey_idx_team ON incident_report (team_id);
+
+CREATE TABLE incident_rubric_score (
+      report_id  INT   NOT NULL REFERENCES incident_report(id) ON DELETE CASCADE
+    , rubric_id  INT   NOT NULL REFERENCES incident_rubric(id) ON DELETE RESTRICT
+    , points     REAL  NOT NULL CHECK (points >= 0)
+    , PRIMARY KEY (report_id, rubric_id)
+);
+
+CREATE VIEW incident_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(s.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN incident_report AS ir ON team.id = ir.team_id AND ir.scored_at IS NOT NULL
+        LEFT JOIN incident_rubric_score AS s ON ir.id = s.report_id
+    GROUP BY team.id;
+
+INSERT INTO permission (name, description) VALUES
+    ('score_incidents', 'Score blue team incident response reports');
+
+INSERT INTO role_permission (role_name, permission) VALUES
+    ('whiteteam', 'score_incidents');
+
+COMMIT;
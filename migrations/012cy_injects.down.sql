@@ -0,0 +1,10 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DELETE FROM permission WHERE name IN ('manage_injects', 'grade_injects');
+DROP VIEW inject_score;
+DROP TABLE inject_submission;
+DROP TABLE inject;
+
+COMMIT;
@@ -0,0 +1,82 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+----------
+-- Injects
+----------
+
+/*
+Injects are timed business tasks (write a policy, set up a user, answer the boss' email)
+handed to the blue teams during the event. Each is hidden until `release_at`, and is due
+by `due_at`. The description is markdown, and any attachments are kept on disk, under
+`inject_dir/<inject id>/attachments`.
+
+Teams answer with some text, and any files, which go in `inject_dir/<inject id>/teams/<team id>`.
+A team has one submission per inject, which they may replace until it's graded.
+
+Submissions after the due time are marked late. If the inject doesn't accept late work,
+they're turned away instead. Otherwise, judges grade late work as usual, and the team
+is awarded its points less the `late_penalty` (a fraction: 0.25 knocks off a quarter).
+Judges may re-grade a submission. Its points still count from when it was first graded,
+so `graded_at` is kept, and `regraded_at` is when it was last re-graded.
+*/
+CREATE TABLE inject (
+      id            INT          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , title         TEXT         NOT NULL UNIQUE -- injects are matched up by title in event archives
+    , description   TEXT         NOT NULL DEFAULT ''
+    , max_points    REAL         NOT NULL CHECK (max_points >= 0)
+    , release_at    TIMESTAMPTZ  NOT NULL
+    , due_at        TIMESTAMPTZ  NOT NULL
+    , accept_late   BOOL         NOT NULL DEFAULT true
+    , late_penalty  REAL         NOT NULL DEFAULT 0 CHECK (late_penalty BETWEEN 0 AND 1)
+
+    , created_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , modified_at   TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+
+    , CONSTRAINT inject_due_after_release CHECK (due_at > release_at)
+);
+
+CREATE TRIGGER mdt_inject
+    BEFORE UPDATE ON inject
+    FOR EACH ROW
+    EXECUTE PROCEDURE moddatetime (modified_at);
+
+CREATE TABLE inject_submission (
+      id            INT          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , inject_id     INT          NOT NULL REFERENCES inject(id) ON DELETE CASCADE
+    , team_id       INT          NOT NULL REFERENCES team(id) ON DELETE CASCADE
+    , player_id     INT          NULL REFERENCES player(id) ON DELETE SET NULL -- who sent it, if not the team login
+    , body          TEXT         NOT NULL DEFAULT ''
+    , submitted_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , late          BOOL         NOT NULL DEFAULT false
+
+    -- Filled in by the judge. `points` are before any late penalty.
+    , points        REAL         NULL CHECK (points >= 0)
+    , judge_id      INT          NULL REFERENCES team(id) ON DELETE SET NULL
+    , feedback      TEXT         NOT NULL DEFAULT ''
+    , graded_at     TIMESTAMPTZ  NULL
+    , regraded_at   TIMESTAMPTZ  NULL
+
+    , UNIQUE (inject_id, team_id)
+);
+
+CREATE INDEX inject_submission_fkey_idx_team ON inject_submission (team_id);
+
+CREATE VIEW inject_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(
+        CASE WHEN s.late THEN s.points * (1 - inject.late_penalty) ELSE s.points END), 0)
+    FROM blueteam AS team
+        LEFT JOIN inject_submission AS s ON team.id = s.team_id AND s.graded_at IS NOT NULL
+        LEFT JOIN inject ON s.inject_id = inject.id
+    GROUP BY team.id;
+
+INSERT INTO permission (name, description) VALUES
+      ('manage_injects', 'Create & edit injects, and their attachments')
+    , ('grade_injects',  'Grade blue team inject submissions');
+
+INSERT INTO role_permission (role_name, permission) VALUES
+      ('whiteteam', 'manage_injects')
+    , ('whiteteam', 'grade_injects');
+
+COMMIT;
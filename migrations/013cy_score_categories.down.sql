@@ -0,0 +1,46 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DROP VIEW point_award;
+
+CREATE VIEW service_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(service.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN service_check AS sc ON team.id = sc.team_id AND sc.status = 'pass'
+        LEFT JOIN service ON sc.service_id = service.id
+    GROUP BY team.id;
+
+CREATE VIEW ctf_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(ch.total), 0)
+    FROM blueteam AS team
+        LEFT JOIN ctf_solve ON team.id = ctf_solve.team_id
+        LEFT JOIN challenge AS ch ON ctf_solve.challenge_id = ch.id
+    GROUP BY team.id;
+
+CREATE VIEW incident_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(s.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN incident_report AS ir ON team.id = ir.team_id AND ir.scored_at IS NOT NULL
+        LEFT JOIN incident_rubric_score AS s ON ir.id = s.report_id
+    GROUP BY team.id;
+
+CREATE VIEW inject_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(
+        CASE WHEN s.late THEN s.points * (1 - inject.late_penalty) ELSE s.points END), 0)
+    FROM blueteam AS team
+        LEFT JOIN inject_submission AS s ON team.id = s.team_id AND s.graded_at IS NOT NULL
+        LEFT JOIN inject ON s.inject_id = inject.id
+    GROUP BY team.id;
+
+-- Points in the admin-added categories are folded back into plain bonus points
+CREATE VIEW other_score (team_id, points)
+    AS SELECT team.id, COALESCE(sum(o.points), 0)
+    FROM blueteam AS team
+        LEFT JOIN other_points AS o ON team.id = o.team_id
+    GROUP BY team.id;
+
+ALTER TABLE other_points DROP COLUMN category;
+DROP TABLE score_category;
+
+COMMIT;
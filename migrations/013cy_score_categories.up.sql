@@ -0,0 +1,75 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+-------------------
+-- Score Categories
+-------------------
+
+/*
+Each team's score is broken down into categories, which the scoreboard shows as stacked bars.
+A category's points are multiplied by its `weight` before they're added to the score, so an
+event that cares most about uptime can count services ×1.5, or turn a category off with 0.
+
+The builtin categories are fed by their own tables (service checks, ctf solves, etc.), and
+can be re-weighted, but not removed. Admins can add more, and grant points to them through
+`other_points`, without any schema changes.
+
+Categories are ordered by `position` on the scoreboard & reports.
+*/
+CREATE TABLE score_category (
+      id        INT   PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , name      TEXT  NOT NULL UNIQUE CHECK (name ~ '^[a-z][a-z0-9_]*$') -- the key in score api responses
+    , label     TEXT  NOT NULL
+    , weight    REAL  NOT NULL DEFAULT 1 CHECK (weight >= 0)
+    , position  INT   NOT NULL DEFAULT 0
+    , builtin   BOOL  NOT NULL DEFAULT false
+);
+
+INSERT INTO score_category (name, label, position, builtin) VALUES
+      ('service',  'Service',  1, true)
+    , ('ctf',      'CTF',      2, true)
+    , ('incident', 'Incident', 3, true)
+    , ('inject',   'Inject',   4, true)
+    , ('other',    'Other',    5, true);
+
+ALTER TABLE other_points
+    ADD COLUMN category TEXT NOT NULL DEFAULT 'other' REFERENCES score_category(name);
+
+/*
+Every point awarded to (or docked from) a team, from all the scoring tables, tagged with its
+category. Points here are before the category's weight is applied.
+
+This replaces the per-category `*_score` views, which each had to be joined in by hand.
+
+Service points come from `service_check_tally`, one row per minute instead of one per check.
+They're dated at the end of their minute, so counting up to a whole minute never takes in a
+check made after it.
+*/
+DROP VIEW service_score, ctf_score, incident_score, inject_score, other_score;
+
+CREATE VIEW point_award (team_id, category, points, created_at)
+    AS SELECT t.team_id, 'service', t.checks * service.points, t.bucket + interval '1 minute'
+    FROM service_check_tally AS t
+        JOIN service ON t.service_id = service.id
+    WHERE t.status = 'pass'
+    UNION ALL
+    SELECT cs.team_id, 'ctf', ch.total, cs.created_at
+    FROM ctf_solve AS cs
+        JOIN challenge AS ch ON cs.challenge_id = ch.id
+    UNION ALL
+    SELECT ir.team_id, 'incident', s.points, ir.scored_at
+    FROM incident_report AS ir
+        JOIN incident_rubric_score AS s ON ir.id = s.report_id
+    WHERE ir.scored_at IS NOT NULL
+    UNION ALL
+    SELECT s.team_id, 'inject',
+        CASE WHEN s.late THEN s.points * (1 - inject.late_penalty) ELSE s.points END, s.graded_at
+    FROM inject_submission AS s
+        JOIN inject ON s.inject_id = inject.id
+    WHERE s.graded_at IS NOT NULL
+    UNION ALL
+    SELECT o.team_id, o.category, o.points, o.created_at
+    FROM other_points AS o;
+
+COMMIT;
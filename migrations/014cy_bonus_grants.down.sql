@@ -0,0 +1,47 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+-- Revoked grants are kept, as an opposite entry, so scores don't change
+INSERT INTO other_points (created_at, team_id, points, reason, category)
+    SELECT revoked_at, team_id, -points, 'Revoked: ' || reason, category
+    FROM other_points
+    WHERE revoked_at IS NOT NULL;
+
+DROP VIEW point_award;
+
+CREATE VIEW point_award (team_id, category, points, created_at)
+    AS SELECT sc.team_id, 'service', service.points, sc.created_at
+    FROM service_check AS sc
+        JOIN service ON sc.service_id = service.id
+    WHERE sc.status = 'pass'
+    UNION ALL
+    SELECT cs.team_id, 'ctf', ch.total, cs.created_at
+    FROM ctf_solve AS cs
+        JOIN challenge AS ch ON cs.challenge_id = ch.id
+    UNION ALL
+    SELECT ir.team_id, 'incident', s.points, ir.scored_at
+    FROM incident_report AS ir
+        JOIN incident_rubric_score AS s ON ir.id = s.report_id
+    WHERE ir.scored_at IS NOT NULL
+    UNION ALL
+    SELECT s.team_id, 'inject',
+        CASE WHEN s.late THEN s.points * (1 - inject.late_penalty) ELSE s.points END, s.graded_at
+    FROM inject_submission AS s
+        JOIN inject ON s.inject_id = inject.id
+    WHERE s.graded_at IS NOT NULL
+    UNION ALL
+    SELECT o.team_id, o.category, o.points, o.created_at
+    FROM other_points AS o;
+
+DROP INDEX other_points_id_idx;
+ALTER TABLE other_points
+      DROP COLUMN id
+    , DROP COLUMN kind
+    , DROP COLUMN granter_id
+    , DROP COLUMN note
+    , DROP COLUMN revoked_at
+    , DROP COLUMN revoker_id;
+DROP TYPE bonus_kind;
+
+COMMIT;
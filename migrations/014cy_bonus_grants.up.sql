@@ -0,0 +1,71 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+---------------
+-- Bonus Grants
+---------------
+
+/*
+Each row of `other_points` becomes a grant that can be looked up, and taken back.
+
+A grant has a `kind`, for what it was for, the staff member who gave it, and an optional `note`
+that the team sees alongside the reason. Revoking a grant doesn't delete it: `revoked_at` is set,
+and `point_award` counts the points back out as of that time, so the score history stays true.
+
+`other_points` is a hypertable, so its ids come from a plain sequence, and are only unique
+together with `created_at`.
+*/
+CREATE TYPE bonus_kind AS ENUM (
+      'bonus'
+    , 'penalty'
+    , 'redteam'
+    , 'inject'
+);
+
+CREATE SEQUENCE other_points_id_seq AS INT;
+
+ALTER TABLE other_points
+      ADD COLUMN id          INT          NOT NULL DEFAULT nextval('other_points_id_seq')
+    , ADD COLUMN kind        bonus_kind   NOT NULL DEFAULT 'bonus'
+    , ADD COLUMN granter_id  INT          NULL REFERENCES team(id) ON DELETE SET NULL
+    , ADD COLUMN note        TEXT         NOT NULL DEFAULT ''
+    , ADD COLUMN revoked_at  TIMESTAMPTZ  NULL
+    , ADD COLUMN revoker_id  INT          NULL REFERENCES team(id) ON DELETE SET NULL;
+
+ALTER SEQUENCE other_points_id_seq OWNED BY other_points.id;
+CREATE UNIQUE INDEX other_points_id_idx ON other_points (id, created_at);
+
+UPDATE other_points SET kind = 'penalty' WHERE points < 0;
+UPDATE other_points SET kind = 'redteam' WHERE reason LIKE 'Red team compromise #%';
+
+CREATE OR REPLACE VIEW point_award (team_id, category, points, created_at)
+    AS SELECT t.team_id, 'service', t.checks * service.points, t.bucket + interval '1 minute'
+    FROM service_check_tally AS t
+        JOIN service ON t.service_id = service.id
+    WHERE t.status = 'pass'
+    UNION ALL
+    SELECT cs.team_id, 'ctf', ch.total, cs.created_at
+    FROM ctf_solve AS cs
+        JOIN challenge AS ch ON cs.challenge_id = ch.id
+    UNION ALL
+    SELECT ir.team_id, 'incident', s.points, ir.scored_at
+    FROM incident_report AS ir
+        JOIN incident_rubric_score AS s ON ir.id = s.report_id
+    WHERE ir.scored_at IS NOT NULL
+    UNION ALL
+    SELECT s.team_id, 'inject',
+        CASE WHEN s.late THEN s.points * (1 - inject.late_penalty) ELSE s.points END, s.graded_at
+    FROM inject_submission AS s
+        JOIN inject ON s.inject_id = inject.id
+    WHERE s.graded_at IS NOT NULL
+    UNION ALL
+    SELECT o.team_id, o.category, o.points, o.created_at
+    FROM other_points AS o
+    UNION ALL
+    -- A revoked grant is undone when it was revoked, not when it was given
+    SELECT o.team_id, o.category, -o.points, o.revoked_at
+    FROM other_points AS o
+    WHERE o.revoked_at IS NOT NULL;
+
+COMMIT;
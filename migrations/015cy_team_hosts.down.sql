@@ -0,0 +1,8 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+ALTER TABLE service DROP COLUMN host_role;
+DROP TABLE team_host;
+
+COMMIT;
@@ -0,0 +1,28 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+-------------
+-- Team Hosts
+-------------
+
+/*
+Each team's inventory of hosts on the competition network, by role (web, mail, dc, ...).
+The address is an IPv4 or IPv6 address, or a hostname, and is handed to the service checks
+in place of the `{HOST:<role>}` argument.
+
+A service may name the `host_role` it targets, which fills in a bare `{HOST}` argument.
+Teams without a host in that role aren't checked for the service.
+*/
+CREATE TABLE team_host (
+      id       INT   PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , team_id  INT   NOT NULL REFERENCES team(id) ON DELETE CASCADE
+    , role     TEXT  NOT NULL CHECK (role ~ '^[a-z][a-z0-9_-]*$')
+    , address  TEXT  NOT NULL CHECK (address <> '')
+
+    , UNIQUE (team_id, role)
+);
+
+ALTER TABLE service ADD COLUMN host_role TEXT NULL CHECK (host_role ~ '^[a-z][a-z0-9_-]*$');
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+DROP VIEW check_runner_assignment;
+DROP TABLE runner_check;
+DROP TABLE check_runner;
+
+COMMIT;
@@ -0,0 +1,63 @@
+BEGIN;
+
+SET search_path = cyboard, "$user", public;
+
+----------------
+-- Check Runners
+----------------
+
+/*
+Remote service monitors, which run checks from another vantage point (e.g. inside a team's
+network) and report results back to the server over its API, authenticating with a token.
+Only the sha256 of the token is kept.
+
+A runner is assigned the checks for the teams in `team_ids` and the services in `service_ids`,
+where an empty array means all of them. A `scoring` runner's results are saved as the
+team's `service_check` rows, and the central `cyboard checks` monitor stops running those checks,
+for as long as the runner keeps checking in (going by `last_seen_at`).
+If more than one scoring runner is assigned a check, the one with the lowest id that's still
+checking in scores it. How long a runner may go quiet is up to the server's config, so that's
+decided when the assignments are queried, from the `scoring` & `last_seen_at` columns of
+`check_runner_assignment`.
+Every other result is kept in `runner_check`, to cross-check the scored results against.
+*/
+CREATE TABLE check_runner (
+      id            INT          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY
+    , name          TEXT         NOT NULL UNIQUE CHECK (name <> '')
+    , token_hash    BYTEA        NOT NULL UNIQUE
+    , team_ids      INT[]        NOT NULL DEFAULT '{}'
+    , service_ids   INT[]        NOT NULL DEFAULT '{}'
+    , scoring       BOOLEAN      NOT NULL DEFAULT false
+    , disabled      BOOLEAN      NOT NULL DEFAULT false
+    , created_at    TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , last_seen_at  TIMESTAMPTZ  NULL
+);
+
+-- Reload the central service monitor when checks are handed to, or taken from, a scoring runner.
+CREATE TRIGGER check_runner_notify
+    AFTER INSERT OR UPDATE OF team_ids, service_ids, scoring, disabled OR DELETE ON check_runner
+    FOR EACH STATEMENT
+    EXECUTE PROCEDURE simple_notify();
+
+CREATE TABLE runner_check (
+      created_at  TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+    , runner_id   INT          NOT NULL REFERENCES check_runner(id) ON DELETE CASCADE
+    , team_id     INT          NOT NULL REFERENCES team(id)
+    , service_id  INT          NOT NULL REFERENCES service(id)
+    , status      exit_status  NOT NULL
+    , exit_code   SMALLINT     NOT NULL
+);
+
+SELECT create_hypertable('runner_check', 'created_at');
+
+-- Every check each enabled runner is assigned, and whether the runner is a scoring one.
+CREATE VIEW check_runner_assignment (runner_id, team_id, service_id, scoring, last_seen_at)
+AS SELECT r.id, t.id, s.id, r.scoring, r.last_seen_at
+    FROM check_runner AS r
+        CROSS JOIN blueteam AS t
+        CROSS JOIN service AS s
+    WHERE NOT r.disabled AND NOT s.disabled
+        AND (cardinality(r.team_ids) = 0 OR t.id = ANY(r.team_ids))
+        AND (cardinality(r.service_ids) = 0 OR s.id = ANY(r.service_ids));
+
+COMMIT;
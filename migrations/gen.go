@@ -0,0 +1,94 @@
+//go:build ignore
+// +build ignore
+
+// gen.go bundles the *.sql migrations into bundled.go. Run it with `go generate`.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration files are named like "002cy_initialize_schema.up.sql"
+var migrationFile = regexp.MustCompile(`^(\d+)cy_(\w+)\.(up|down)\.sql$`)
+
+type migration struct {
+	version  int
+	name     string
+	up, down string
+}
+
+func main() {
+	files, err := filepath.Glob("*.sql")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	byVersion := map[int]*migration{}
+	for _, f := range files {
+		m := migrationFile.FindStringSubmatch(f)
+		if m == nil {
+			log.Fatalf("unexpected migration file name: %q", f)
+		}
+		version, _ := strconv.Atoi(m[1])
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &migration{version: version, name: m[2]}
+			byVersion[version] = mig
+		} else if mig.name != m[2] {
+			log.Fatalf("migration %d has two names: %q and %q", version, mig.name, m[2])
+		}
+		if m[3] == "up" {
+			mig.up = string(b)
+		} else {
+			mig.down = string(b)
+		}
+	}
+
+	versions := []int{}
+	for v, mig := range byVersion {
+		if mig.up == "" || mig.down == "" {
+			log.Fatalf("migration %d (%s) needs both an up and a down file", v, mig.name)
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\npackage migrations\n\nvar bundled = []Migration{\n")
+	for _, v := range versions {
+		mig := byVersion[v]
+		fmt.Fprintf(buf, "\t{\n\t\tVersion: %d,\n\t\tName: %q,\n\t\tUp: %s,\n\t\tDown: %s,\n\t},\n",
+			mig.version, mig.name, quote(mig.up), quote(mig.down))
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = ioutil.WriteFile("bundled.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// quote keeps the SQL readable as a raw string, when it can.
+func quote(s string) string {
+	if strings.Contains(s, "`") || strings.Contains(s, "\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
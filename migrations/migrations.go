@@ -0,0 +1,50 @@
+// Package migrations bundles the SQL schema migrations into the cyboard binary,
+// to be run with `cyboard migrate`.
+//
+// The SQL files in this directory are the source of truth. After adding or
+// changing one, regenerate the bundled copy with `go generate ./migrations`.
+package migrations
+
+//go:generate go run gen.go
+
+import (
+	"sort"
+	"strings"
+)
+
+// Migration is a numbered change to the database schema, along with
+// the SQL to apply it (Up) and to revert it (Down).
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// All returns every migration, ordered from first to last.
+func All() []Migration {
+	ms := make([]Migration, len(bundled))
+	copy(ms, bundled)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms
+}
+
+// Body strips the BEGIN & COMMIT a migration file is wrapped in, so it can be run
+// inside a larger transaction. The files keep them, so they still work when run
+// by hand with psql. SQL without the wrapper is returned as is.
+func Body(sql string) string {
+	s := strings.TrimSpace(sql)
+	if !strings.HasPrefix(s, "BEGIN;") || !strings.HasSuffix(s, "COMMIT;") {
+		return sql
+	}
+	return strings.TrimSpace(s[len("BEGIN;"):len(s)-len("COMMIT;")]) + "\n"
+}
+
+// Latest is the version of the newest migration.
+func Latest() int {
+	ms := All()
+	if len(ms) == 0 {
+		return 0
+	}
+	return ms[len(ms)-1].Version
+}
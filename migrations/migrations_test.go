@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test_Bundled catches SQL files that were changed without running `go generate`.
+func Test_Bundled(t *testing.T) {
+	ms := All()
+	require.NotEmpty(t, ms)
+
+	for i, m := range ms {
+		if i > 0 {
+			assert.True(t, ms[i-1].Version < m.Version, "Migrations are ordered")
+		}
+		for dir, sql := range map[string]string{"up": m.Up, "down": m.Down} {
+			path := fmt.Sprintf("%03dcy_%s.%s.sql", m.Version, m.Name, dir)
+			b, err := ioutil.ReadFile(path)
+			if assert.NoError(t, err) {
+				assert.Equal(t, string(b), sql, "%s is out of date, run `go generate ./migrations`", path)
+			}
+		}
+	}
+	assert.Equal(t, ms[len(ms)-1].Version, Latest())
+}
+
+func Test_Body(t *testing.T) {
+	assert.Equal(t, "CREATE TABLE x ();\n", Body("BEGIN;\n\nCREATE TABLE x ();\n\nCOMMIT;\n"))
+	assert.Equal(t, "DROP SCHEMA x;\n", Body("DROP SCHEMA x;\n"), "Unwrapped SQL is left alone")
+
+	for _, m := range All() {
+		for dir, sql := range map[string]string{"up": m.Up, "down": m.Down} {
+			body := Body(sql)
+			assert.NotContains(t, body, "BEGIN;", "%03d %s.%s would still commit on its own", m.Version, m.Name, dir)
+			assert.NotContains(t, body, "COMMIT;", "%03d %s.%s would still commit on its own", m.Version, m.Name, dir)
+		}
+	}
+}
@@ -152,15 +152,99 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetScores(w http.ResponseWriter, r *http.Request) {
-	scores, err := models.TeamsScores(db)
+	scores, err := teamsScoresFor(r)
 	ApiQuery(w, r, scores, err)
 }
 
+// GetScoreHistory charts every team's score over the course of the event, in buckets
+// of `?bucket=5m` (the default). While the scoreboard is frozen, the public history
+// stops at the freeze time.
+func GetScoreHistory(w http.ResponseWriter, r *http.Request) {
+	bucket := 5 * time.Minute
+	if b := r.URL.Query().Get("bucket"); b != "" {
+		d, err := time.ParseDuration(b)
+		if err != nil || d < time.Minute || d%time.Minute != 0 {
+			render.Render(w, r, ErrInvalidBecause(fmt.Sprintf(
+				"invalid bucket size: bucket=%q (wanted whole minutes, e.g. \"5m\")", b)))
+			return
+		}
+		bucket = d
+	}
+
+	start, end := appCfg.Event.Start, time.Now()
+	if end.After(appCfg.Event.End) {
+		end = appCfg.Event.End
+	}
+	if scoreboardFrozenFor(r) {
+		end = appCfg.Event.FreezeAt
+	}
+
+	history, err := models.TeamsScoreHistory(db, bucket, start, end)
+	ApiQuery(w, r, history, err)
+}
+
 func GetServicesStatuses(w http.ResponseWriter, r *http.Request) {
 	services, err := models.TeamServiceStatuses(db)
 	ApiQuery(w, r, services, err)
 }
 
+// GetServicesHistory is like GetServicesStatuses, but includes each team's statuses
+// from the last `?rounds=12` (the default) runs of the service monitor.
+func GetServicesHistory(w http.ResponseWriter, r *http.Request) {
+	rounds := serviceHistoryRounds
+	if rs := r.URL.Query().Get("rounds"); rs != "" {
+		n, err := strconv.Atoi(rs)
+		if err != nil || n < 1 || n > 100 {
+			render.Render(w, r, ErrInvalidBecause(fmt.Sprintf(
+				"invalid number of rounds: rounds=%q (wanted 1-100)", rs)))
+			return
+		}
+		rounds = n
+	}
+	services, err := models.TeamServiceStatusesWithHistory(db, rounds, appCfg.ServiceMonitor.Intervals)
+	ApiQuery(w, r, services, err)
+}
+
+// parseTimeWindow reads the optional `start` & `end` query params (RFC3339),
+// which default to the start of the event and right now.
+func parseTimeWindow(r *http.Request) (start, end time.Time, err error) {
+	start, end = appCfg.Event.Start, time.Now()
+	q := r.URL.Query()
+	if s := q.Get("start"); s != "" {
+		if start, err = time.Parse(time.RFC3339, s); err != nil {
+			return start, end, fmt.Errorf("invalid timestamp: start=%q (wanted RFC3339 format)", s)
+		}
+	}
+	if e := q.Get("end"); e != "" {
+		if end, err = time.Parse(time.RFC3339, e); err != nil {
+			return start, end, fmt.Errorf("invalid timestamp: end=%q (wanted RFC3339 format)", e)
+		}
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("time window ends before it starts: start=%v, end=%v", start, end)
+	}
+	return start, end, nil
+}
+
+// GetServiceUptimes summarizes every team's service history, over the whole event
+// or the window given by the `start` & `end` query params. Uptime follows the service
+// points, so while the scoreboard is frozen, the public window stops at the freeze time.
+func GetServiceUptimes(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parseTimeWindow(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidBecause(err.Error()))
+		return
+	}
+	if freezeAt := appCfg.Event.FreezeAt; scoreboardFrozenFor(r) && end.After(freezeAt) {
+		end = freezeAt
+		if start.After(end) {
+			start = end
+		}
+	}
+	uptimes, err := models.ServiceUptimes(db, start, end)
+	ApiQuery(w, r, uptimes, err)
+}
+
 func GetChallengeCapturesByTime(w http.ResponseWriter, r *http.Request) {
 	var cutoffTime time.Time
 	var err error
@@ -178,7 +262,12 @@ func GetChallengeCapturesByTime(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	solves, err := models.ChallengeCapturesByTime(db, cutoffTime)
+	var solves []models.CtfSolveResult
+	if scoreboardFrozenFor(r) {
+		solves, err = models.ChallengeCapturesBetween(db, cutoffTime, appCfg.Event.FreezeAt)
+	} else {
+		solves, err = models.ChallengeCapturesByTime(db, cutoffTime)
+	}
 	ApiQuery(w, r, solves, err)
 }
 
@@ -190,6 +279,17 @@ func GetPublicChallenges(w http.ResponseWriter, r *http.Request) {
 	ApiQuery(w, r, chals, err)
 }
 
+// GetTeamServiceUptimes is like GetServiceUptimes, for just the requesting team.
+func GetTeamServiceUptimes(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parseTimeWindow(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidBecause(err.Error()))
+		return
+	}
+	uptimes, err := models.TeamServiceUptimes(db, getCtxTeam(r).ID, start, end)
+	ApiQuery(w, r, uptimes, err)
+}
+
 func GetChallengeDescription(w http.ResponseWriter, r *http.Request) {
 	flagID := getCtxIdParam(r)
 	desc, err := models.GetPublicChallengeDescription(db, flagID)
@@ -209,13 +309,16 @@ func SubmitFlag(w http.ResponseWriter, r *http.Request) {
 	}
 	anon := guess.Name == ""
 
-	team := getCtxTeam(r)
+	team, player := getCtxTeam(r), getCtxPlayer(r)
 	logFields := logrus.Fields{"challenge": guess.Name, "guess": guess.Flag, "team": team.Name}
 	if anon {
 		logFields["challenge"] = "<anonymous>"
 	}
+	if player != nil {
+		logFields["player"] = player.Name
+	}
 
-	flagState, err := models.CheckFlagSubmission(db, r.Context(), team, guess)
+	flagState, err := models.CheckFlagSubmission(db, r.Context(), team, player, guess)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			CaptFlagsLogger.WithFields(logFields).Println("Bad guess")
@@ -380,50 +483,101 @@ func DeleteTeam(w http.ResponseWriter, r *http.Request) {
 	ApiDelete(w, r, team)
 }
 
-// Bonus Points API
-// Allows arbitrary point awards/deductions, stored in the "other_points" db table.
-// JSON input should give a list of team ids, point value, and a reason string.
+// Login sessions (admin-only):
 //
-// There is no formal way to `undo` these, just do another bonus with
-// the negated point value and the reason "reverting bonus because of 'thing'".
+// Each team's active sessions can be listed, and revoked to log them out right away.
+// Deleting or disabling a team or player revokes their sessions automatically.
 
-type BonusPointsRequest struct {
-	*models.OtherPoints
-	TeamIDs []int `json:"teams"`
+// SessionsRevokedResponse is how many sessions got logged out.
+type SessionsRevokedResponse struct {
+	Revoked int64 `json:"revoked"`
 }
 
-func GrantBonusPoints(w http.ResponseWriter, r *http.Request) {
-	batch := &BonusPointsRequest{}
-	if err := render.Decode(r, batch); err != nil {
-		render.Render(w, r, ErrInvalidRequest(err))
+func GetTeamSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := models.TeamSessions(db, getCtxIdParam(r))
+	ApiQuery(w, r, sessions, err)
+}
+
+func RevokeTeamSessions(w http.ResponseWriter, r *http.Request) {
+	n, err := models.RevokeTeamSessions(db, getCtxIdParam(r))
+	ApiQuery(w, r, &SessionsRevokedResponse{Revoked: n}, err)
+}
+
+func RevokePlayerSessions(w http.ResponseWriter, r *http.Request) {
+	n, err := models.RevokePlayerSessions(db, getCtxIdParam(r))
+	ApiQuery(w, r, &SessionsRevokedResponse{Revoked: n}, err)
+}
+
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	if err := models.RevokeSession(db, getCtxIdParam(r)); err != nil {
+		RenderQueryErr(w, r, err)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Player management (admin-only):
+//
+// Players are individual logins that belong to a team. As with teams,
+// JSON "password" fields will be saved as a hash+salt.
 
-	now := time.Now()
-	cnt := len(batch.TeamIDs)
-	bonus := make(models.OtherPointsSlice, cnt, cnt)
-	for idx, teamID := range batch.TeamIDs {
-		bonus[idx] = *batch.OtherPoints
-		bonus[idx].TeamID = teamID
-		bonus[idx].CreatedAt = now
+func GetAllPlayers(w http.ResponseWriter, r *http.Request) {
+	players, err := models.AllPlayers(db)
+	ApiQuery(w, r, players, err)
+}
+
+func GetPlayerByID(w http.ResponseWriter, r *http.Request) {
+	id := getCtxIdParam(r)
+	player, err := models.PlayerByID(db, id)
+	ApiQuery(w, r, player, err)
+}
+
+type PlayerModRequest struct {
+	*models.Player
+	Password *string `json:"password,omitempty"` // Becomes the `Hash` column
+}
+
+// Bind validates a new or updated player. Like teams, "PUT" reqs only change
+// the player's password if one is given.
+func (pr *PlayerModRequest) Bind(r *http.Request) error {
+	if pr.Player == nil {
+		return errors.New("missing required player fields: 'name', 'team_id'")
+	} else if pr.Name == "" {
+		return errors.New(`empty field: 'name'`)
+	} else if pr.TeamID == 0 {
+		return errors.New(`empty/zero field: 'team_id'`)
 	}
-	if err := bonus.Insert(db); err != nil {
-		render.Render(w, r, ErrInternal(err))
-		return
+	pr.Player.Hash = nil
+
+	hasPW := pr.Password != nil
+	if (r.Method != "PUT" && !hasPW) || (hasPW && *pr.Password == "") {
+		return errors.New("empty field: 'password'")
+	}
+
+	if hasPW {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*pr.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		pr.Player.Hash = hash
+		pr.Password = nil
 	}
+	return nil
+}
 
-	CaptFlagsLogger.WithFields(logrus.Fields{
-		"teams":  batch.TeamIDs,
-		"reason": batch.Reason,
-		"points": batch.Points,
-	}).Infoln("Bonus awarded!")
+func AddPlayer(w http.ResponseWriter, r *http.Request) {
+	player := &PlayerModRequest{}
+	ApiCreate(w, r, player)
+}
 
-	w.WriteHeader(http.StatusCreated)
+func UpdatePlayer(w http.ResponseWriter, r *http.Request) {
+	player := &PlayerModRequest{}
+	ApiUpdate(w, r, player)
 }
 
-func GetBonusPoints(w http.ResponseWriter, r *http.Request) {
-	bonus, err := models.AllBonusPoints(db)
-	ApiQuery(w, r, bonus, err)
+func DeletePlayer(w http.ResponseWriter, r *http.Request) {
+	player := &models.Player{}
+	ApiDelete(w, r, player)
 }
 
 // CTF Configuration
@@ -515,6 +669,12 @@ func (sr *ServiceRequest) Bind(r *http.Request) error {
 		return errors.New(`missing required 'service' fields`)
 	}
 
+	if sr.HostRole != nil && *sr.HostRole == "" {
+		sr.HostRole = nil
+	} else if sr.HostRole != nil && !models.ValidHostRole(*sr.HostRole) {
+		return errors.Errorf(`invalid host role %q`, *sr.HostRole)
+	}
+
 	if _, ok := r.URL.Query()["rawpoints"]; !ok {
 		pts := CalcPointsPerCheck(sr.Service, &appCfg.Event, appCfg.ServiceMonitor.Intervals)
 		sr.Points = &pts
@@ -574,6 +734,16 @@ func getFileList(path string) ([]FileInfo, error) {
 	return infos, nil
 }
 
+// listFiles is like getFileList, for pages that list attachments. A directory
+// that doesn't exist (nothing was ever uploaded) has no files.
+func listFiles(path string) []FileInfo {
+	files, err := getFileList(path)
+	if err != nil {
+		return nil
+	}
+	return files
+}
+
 func (cm FSContentManager) GetFileList(w http.ResponseWriter, r *http.Request) {
 	infos, err := getFileList(cm.pathBuilder(r))
 	if err != nil {
@@ -605,17 +775,6 @@ func (cm FSContentManager) GetFile(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cm FSContentManager) SaveFile(w http.ResponseWriter, r *http.Request) {
-	dir := cm.pathBuilder(r)
-
-	if _, err := os.Stat(dir); err != nil {
-		err = os.MkdirAll(dir, 0755)
-		if err != nil {
-			render.Render(w, r, ErrInternal(
-				errors.WithMessage(err, "unable to make internal content dir")))
-			return
-		}
-	}
-
 	err := r.ParseMultipartForm(cm.maxSize)
 	if err != nil {
 		render.Render(w, r, ErrInvalidRequest(err))
@@ -627,6 +786,22 @@ func (cm FSContentManager) SaveFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err = cm.saveUploads(r, cm.pathBuilder(r), fhs); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// saveUploads writes each uploaded file into dir, which is made if it doesn't exist yet.
+func (cm FSContentManager) saveUploads(r *http.Request, dir string, fhs []*multipart.FileHeader) error {
+	if _, err := os.Stat(dir); err != nil {
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			return errors.WithMessage(err, "unable to make internal content dir")
+		}
+	}
+
 	processFile := func(fh *multipart.FileHeader) error {
 		f, err := fh.Open()
 		if err != nil {
@@ -656,12 +831,10 @@ func (cm FSContentManager) SaveFile(w http.ResponseWriter, r *http.Request) {
 		fh := fhs[i-1]
 		Logger.Debugf("Processing file [%d of %d] %q", i, len(fhs), fh.Filename)
 		if err := processFile(fh); err != nil {
-			render.Render(w, r, ErrInternal(
-				errors.WithMessage(err, fmt.Sprintf("file #%d, name=%q", i, fh.Filename))))
-			return
+			return errors.WithMessage(err, fmt.Sprintf("file #%d, name=%q", i, fh.Filename))
 		}
 	}
-	w.WriteHeader(http.StatusCreated)
+	return nil
 }
 
 func (cm FSContentManager) DeleteFile(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Support Tickets API
+//
+// Blue teams open tickets (box resets, broken challenges, check disputes) and
+// staff work through them in a queue. Either side may reply to a ticket's thread,
+// but only staff may assign, close, or reopen tickets.
+
+// TicketOpenRequest is a blue team's new ticket, along with the first message.
+type TicketOpenRequest struct {
+	Category models.TicketCategory `json:"category"`
+	Subject  string                `json:"subject"`
+	Message  string                `json:"message"`
+}
+
+func (tr *TicketOpenRequest) Bind(r *http.Request) error {
+	tr.Subject, tr.Message = strings.TrimSpace(tr.Subject), strings.TrimSpace(tr.Message)
+	if tr.Category == models.TicketCategoryUnspecified {
+		return errors.New(`empty field: 'category'`)
+	} else if tr.Subject == "" {
+		return errors.New(`empty field: 'subject'`)
+	} else if tr.Message == "" {
+		return errors.New(`empty field: 'message'`)
+	}
+	return nil
+}
+
+// TicketReplyRequest is a new message on a ticket's thread.
+type TicketReplyRequest struct {
+	Body string `json:"body"`
+}
+
+func (tr *TicketReplyRequest) Bind(r *http.Request) error {
+	tr.Body = strings.TrimSpace(tr.Body)
+	if tr.Body == "" {
+		return errors.New(`empty field: 'body'`)
+	}
+	return nil
+}
+
+// TicketAssignRequest hands a ticket to a staff member, or unassigns it with a null id.
+type TicketAssignRequest struct {
+	AssigneeID *int `json:"assignee_id"`
+}
+
+// TicketThread is a ticket along with all of its messages.
+type TicketThread struct {
+	*models.Ticket
+	Messages []models.TicketMessageView `json:"messages"`
+}
+
+func ticketLogger(r *http.Request, ticket *models.Ticket) *logrus.Entry {
+	return Logger.WithFields(logrus.Fields{
+		"ticket": ticket.ID,
+		"team":   ticket.TeamID,
+		"by":     getCtxTeam(r).Name,
+	})
+}
+
+// getViewableTicket fetches the ticket given by the URL's id param. Blue teams
+// may only see their own tickets; anyone else's tickets are reported as not found.
+// On failure, an error is rendered to the user and nil is returned.
+func getViewableTicket(w http.ResponseWriter, r *http.Request) *models.Ticket {
+	ticket, err := models.TicketByID(db, getCtxIdParam(r))
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return nil
+	}
+
+	if viewer := getCtxTeam(r); !hasPermission(viewer, models.PermManageTickets) && ticket.TeamID != viewer.ID {
+		render.Render(w, r, ErrNotFound)
+		return nil
+	}
+	return ticket
+}
+
+func GetTeamTickets(w http.ResponseWriter, r *http.Request) {
+	team := getCtxTeam(r)
+	tickets, err := models.TeamTickets(db, team.ID)
+	ApiQuery(w, r, tickets, err)
+}
+
+func OpenTicket(w http.ResponseWriter, r *http.Request) {
+	req := &TicketOpenRequest{}
+	if err := render.Bind(r, req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	team := getCtxTeam(r)
+	ticket := &models.Ticket{TeamID: team.ID, Category: req.Category, Subject: req.Subject}
+	msg := &models.TicketMessage{Body: req.Message}
+	if err := ticket.Open(db, msg); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	ticketLogger(r, ticket).WithField("category", ticket.Category).Info("Ticket opened")
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, &TicketThread{Ticket: ticket, Messages: []models.TicketMessageView{{
+		TicketMessage: *msg, AuthorName: team.Name, AuthorRole: team.RoleName,
+	}}})
+}
+
+func GetTicketThread(w http.ResponseWriter, r *http.Request) {
+	ticket := getViewableTicket(w, r)
+	if ticket == nil {
+		return
+	}
+
+	msgs, err := models.TicketMessages(db, ticket.ID)
+	ApiQuery(w, r, &TicketThread{Ticket: ticket, Messages: msgs}, err)
+}
+
+func ReplyToTicket(w http.ResponseWriter, r *http.Request) {
+	ticket := getViewableTicket(w, r)
+	if ticket == nil {
+		return
+	}
+
+	team := getCtxTeam(r)
+	if ticket.Closed() && !hasPermission(team, models.PermManageTickets) {
+		render.Render(w, r, ErrInvalidBecause("This ticket is closed. Open a new one if you still need help."))
+		return
+	}
+
+	req := &TicketReplyRequest{}
+	if err := render.Bind(r, req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	msg := &models.TicketMessage{TicketID: ticket.ID, AuthorID: team.ID, Body: req.Body}
+	if err := msg.Insert(db); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, &models.TicketMessageView{
+		TicketMessage: *msg, AuthorName: team.Name, AuthorRole: team.RoleName,
+	})
+}
+
+// Staff-only ticket management
+
+func GetTicketQueue(w http.ResponseWriter, r *http.Request) {
+	_, includeClosed := r.URL.Query()["closed"]
+	tickets, err := models.AllTickets(db, includeClosed)
+	ApiQuery(w, r, tickets, err)
+}
+
+func AssignTicket(w http.ResponseWriter, r *http.Request) {
+	ticket := getViewableTicket(w, r)
+	if ticket == nil {
+		return
+	}
+
+	req := &TicketAssignRequest{}
+	if err := render.Decode(r, req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if req.AssigneeID != nil {
+		assignee, err := models.TeamByID(db, *req.AssigneeID)
+		if err != nil {
+			RenderQueryErr(w, r, err)
+			return
+		} else if !hasPermission(assignee, models.PermManageTickets) {
+			render.Render(w, r, ErrInvalidBecause("tickets may only be assigned to staff who manage tickets"))
+			return
+		}
+	}
+
+	if err := models.AssignTicket(db, ticket.ID, req.AssigneeID); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	ticketLogger(r, ticket).WithField("assignee", req.AssigneeID).Info("Ticket assigned")
+	render.NoContent(w, r)
+}
+
+func CloseTicket(w http.ResponseWriter, r *http.Request) {
+	ticket := getViewableTicket(w, r)
+	if ticket == nil {
+		return
+	}
+
+	if err := models.CloseTicket(db, ticket.ID); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	ticketLogger(r, ticket).Info("Ticket closed")
+	render.NoContent(w, r)
+}
+
+func ReopenTicket(w http.ResponseWriter, r *http.Request) {
+	ticket := getViewableTicket(w, r)
+	if ticket == nil {
+		return
+	}
+
+	if err := models.ReopenTicket(db, ticket.ID); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	ticketLogger(r, ticket).Info("Ticket reopened")
+	render.NoContent(w, r)
+}
@@ -0,0 +1,579 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// EventSpec is the declarative definition of an event, kept in a YAML file,
+// which `cyboard apply` makes the database match.
+//
+// A section that is left out of the file entirely is not touched. Otherwise,
+// rows that are missing from a section are disabled (or hidden, for challenges),
+// never deleted, so no scores are lost. The exception is a blue team's hosts,
+// which hold no scores: when a team lists any, the ones it leaves out are deleted.
+type EventSpec struct {
+	Blueteams  []BlueteamSpec  `yaml:"blueteams"`
+	Staff      []StaffSpec     `yaml:"staff"`
+	Services   []ServiceSpec   `yaml:"services"`
+	Challenges []ChallengeSpec `yaml:"challenges"`
+}
+
+type BlueteamSpec struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"` // Plain text, or a bcrypt hash. Left empty, a random one is made.
+	IP       int16  `yaml:"ip"`       // The significant octet of the team's addresses
+	Disabled bool   `yaml:"disabled"`
+
+	Hosts map[string]string `yaml:"hosts"` // role → address, for {HOST:<role>} check args
+}
+
+type StaffSpec struct {
+	Name     string `yaml:"name"`
+	Role     string `yaml:"role"` // admin, ctf_creator, whiteteam, or redteam
+	Password string `yaml:"password"`
+	Disabled bool   `yaml:"disabled"`
+}
+
+type ServiceSpec struct {
+	Name        string    `yaml:"name"`
+	Category    string    `yaml:"category"`
+	Description string    `yaml:"description"`
+	TotalPoints float32   `yaml:"total_points"`
+	Points      *float32  `yaml:"points"`
+	Script      string    `yaml:"script"`
+	Args        []string  `yaml:"args"`
+	HostRole    string    `yaml:"host_role"` // The team host it checks, filling in {HOST}
+	StartsAt    time.Time `yaml:"starts_at"` // Defaults to the event start
+	Disabled    bool      `yaml:"disabled"`
+}
+
+type ChallengeSpec struct {
+	Name     string  `yaml:"name"`
+	Category string  `yaml:"category"`
+	Designer string  `yaml:"designer"`
+	Flag     string  `yaml:"flag"`
+	Total    float32 `yaml:"total"`
+	Body     string  `yaml:"body"`
+	Hidden   bool    `yaml:"hidden"`
+}
+
+// LoadEventSpec reads & checks an event definition.
+func LoadEventSpec(r io.Reader) (*EventSpec, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	spec := &EventSpec{}
+	if err = yaml.UnmarshalStrict(b, spec); err != nil {
+		return nil, err
+	}
+	return spec, spec.Validate()
+}
+
+// Validate checks for missing fields & duplicate names.
+func (spec *EventSpec) Validate() error {
+	dupes := func(kind string, names []string) error {
+		seen := make(map[string]bool, len(names))
+		for idx, name := range names {
+			if name == "" {
+				return fmt.Errorf("%s [%d]: empty field: 'name'", kind, idx)
+			} else if seen[name] {
+				return fmt.Errorf("%s %q: listed more than once", kind, name)
+			}
+			seen[name] = true
+		}
+		return nil
+	}
+
+	// Blue teams & staff share the one team table, so names must be unique across both
+	teamNames := []string{}
+	ips := map[int16]string{}
+	for _, t := range spec.Blueteams {
+		teamNames = append(teamNames, t.Name)
+		if t.IP == 0 {
+			return fmt.Errorf("blueteam %q: empty/zero field: 'ip'", t.Name)
+		} else if other, ok := ips[t.IP]; ok {
+			return fmt.Errorf("blueteam %q: ip %d is already used by %q", t.Name, t.IP, other)
+		}
+		ips[t.IP] = t.Name
+		for role, addr := range t.Hosts {
+			if err := (&models.TeamHost{Role: role, Address: addr}).Validate(); err != nil {
+				return fmt.Errorf("blueteam %q: host %q: %v", t.Name, role, err)
+			}
+		}
+	}
+	for _, t := range spec.Staff {
+		teamNames = append(teamNames, t.Name)
+		var role models.TeamRole
+		if err := role.UnmarshalText([]byte(t.Role)); err != nil || role == models.TeamRoleBlueteam {
+			return fmt.Errorf("staff %q: role must be admin, ctf_creator, whiteteam, or redteam (got %q)", t.Name, t.Role)
+		}
+	}
+	if err := dupes("team", teamNames); err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, s := range spec.Services {
+		names = append(names, s.Name)
+		if s.Script == "" {
+			return fmt.Errorf("service %q: empty field: 'script'", s.Name)
+		} else if s.HostRole != "" && !models.ValidHostRole(s.HostRole) {
+			return fmt.Errorf("service %q: invalid host_role %q", s.Name, s.HostRole)
+		}
+	}
+	if err := dupes("service", names); err != nil {
+		return err
+	}
+
+	names = []string{}
+	flags := map[string]string{}
+	for _, c := range spec.Challenges {
+		names = append(names, c.Name)
+		if c.Flag == "" {
+			return fmt.Errorf("challenge %q: empty field: 'flag'", c.Name)
+		} else if other, ok := flags[c.Flag]; ok {
+			return fmt.Errorf("challenge %q: flag is the same as %q", c.Name, other)
+		}
+		flags[c.Flag] = c.Name
+	}
+	return dupes("challenge", names)
+}
+
+// PlanAction is what apply will do to a single row.
+type PlanAction string
+
+const (
+	PlanCreate  PlanAction = "create"
+	PlanUpdate  PlanAction = "update"
+	PlanDisable PlanAction = "disable"
+	PlanDelete  PlanAction = "delete"
+)
+
+// PlanStep is a single change to the database, needed to match the event spec.
+type PlanStep struct {
+	Action  PlanAction
+	Kind    string   // team, host, service, or challenge
+	Name    string   // name of the team, service, or challenge. Hosts are "<team>/<role>"
+	Changed []string // fields that will be updated
+
+	apply     func(db models.DB) error
+	generated string // random password made for a new team, shown once applied
+}
+
+func (s PlanStep) String() string {
+	sym := map[PlanAction]string{PlanCreate: "+", PlanUpdate: "~", PlanDisable: "-", PlanDelete: "-"}[s.Action]
+	str := fmt.Sprintf("%s %s %q", sym, s.Kind, s.Name)
+	if len(s.Changed) > 0 {
+		str += " (" + strings.Join(s.Changed, ", ") + ")"
+	}
+	return str
+}
+
+// EventPlan is every change needed for the database to match the event spec.
+type EventPlan []PlanStep
+
+// Apply runs the whole plan in a single transaction.
+func (plan EventPlan) Apply(db models.TXer) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, step := range plan {
+		if err = step.apply(tx); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("%s %s %q", step.Action, step.Kind, step.Name))
+		}
+	}
+	return tx.Commit()
+}
+
+// PlanEvent diffs the spec against the database.
+func PlanEvent(db models.DB, spec *EventSpec, eventStart time.Time) (EventPlan, error) {
+	plan := EventPlan{}
+
+	if spec.Blueteams != nil || spec.Staff != nil {
+		steps, err := planTeams(db, spec)
+		if err != nil {
+			return nil, errors.WithMessage(err, "plan teams")
+		}
+		plan = append(plan, steps...)
+
+		if steps, err = planTeamHosts(db, spec.Blueteams); err != nil {
+			return nil, errors.WithMessage(err, "plan team hosts")
+		}
+		plan = append(plan, steps...)
+	}
+	if spec.Services != nil {
+		steps, err := planServices(db, spec.Services, eventStart)
+		if err != nil {
+			return nil, errors.WithMessage(err, "plan services")
+		}
+		plan = append(plan, steps...)
+	}
+	if spec.Challenges != nil {
+		steps, err := planChallenges(db, spec.Challenges)
+		if err != nil {
+			return nil, errors.WithMessage(err, "plan challenges")
+		}
+		plan = append(plan, steps...)
+	}
+	return plan, nil
+}
+
+// diffFields lists the names of fields that differ between `want` and `have`,
+// which must be structs of the same type.
+func diffFields(want, have interface{}, fields ...string) []string {
+	w, h := reflect.ValueOf(want), reflect.ValueOf(have)
+	changed := []string{}
+	for _, f := range fields {
+		if !reflect.DeepEqual(w.FieldByName(f).Interface(), h.FieldByName(f).Interface()) {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+// specPassword turns the password in the event spec into a hash. If `existing` is
+// already a hash of the same password, nil is returned, meaning "leave it alone".
+// When no password is given for a new team, a random one is generated.
+func specPassword(password string, existing []byte) (hash []byte, generated string, err error) {
+	switch {
+	case password == "" && existing != nil:
+		return nil, "", nil
+	case password == "":
+		generated, hash, err = generatePassword()
+		return hash, generated, err
+	case strings.HasPrefix(password, "$2a$") || strings.HasPrefix(password, "$2b$"):
+		if string(existing) == password {
+			return nil, "", nil
+		}
+		return []byte(password), "", nil
+	case existing != nil && bcrypt.CompareHashAndPassword(existing, []byte(password)) == nil:
+		return nil, "", nil
+	}
+	hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return hash, "", err
+}
+
+func planTeams(db models.DB, spec *EventSpec) ([]PlanStep, error) {
+	teams, err := models.AllTeams(db)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]models.Team, len(teams))
+	for _, t := range teams {
+		existing[t.Name] = t
+	}
+
+	wanted := []models.Team{}
+	passwords := []string{}
+	for _, t := range spec.Blueteams {
+		ip := t.IP
+		wanted = append(wanted, models.Team{Name: t.Name, RoleName: models.TeamRoleBlueteam, Disabled: t.Disabled, BlueteamIP: &ip})
+		passwords = append(passwords, t.Password)
+	}
+	for _, t := range spec.Staff {
+		var role models.TeamRole
+		role.UnmarshalText([]byte(t.Role)) // Already checked by Validate
+		wanted = append(wanted, models.Team{Name: t.Name, RoleName: role, Disabled: t.Disabled})
+		passwords = append(passwords, t.Password)
+	}
+
+	steps := []PlanStep{}
+	listed := map[string]bool{}
+	for idx := range wanted {
+		want := wanted[idx]
+		listed[want.Name] = true
+
+		have, ok := existing[want.Name]
+		if !ok {
+			var generated string
+			if want.Hash, generated, err = specPassword(passwords[idx], nil); err != nil {
+				return nil, err
+			}
+			steps = append(steps, PlanStep{Action: PlanCreate, Kind: "team", Name: want.Name, apply: want.Insert, generated: generated})
+			continue
+		}
+
+		want.ID = have.ID
+		if passwords[idx] != "" {
+			full, err := models.TeamByName(db, want.Name)
+			if err != nil {
+				return nil, err
+			}
+			if want.Hash, _, err = specPassword(passwords[idx], full.Hash); err != nil {
+				return nil, err
+			}
+		}
+
+		changed := diffFields(want, have, "RoleName", "Disabled", "BlueteamIP")
+		if want.Hash != nil {
+			changed = append(changed, "Password")
+		}
+		if len(changed) > 0 {
+			steps = append(steps, PlanStep{Action: PlanUpdate, Kind: "team", Name: want.Name, Changed: changed, apply: want.Update})
+		}
+	}
+
+	for _, t := range teams {
+		if t.Disabled || listed[t.Name] {
+			continue
+		}
+		// Only prune the kinds of teams the spec lists
+		if (t.RoleName == models.TeamRoleBlueteam && spec.Blueteams == nil) ||
+			(t.RoleName != models.TeamRoleBlueteam && spec.Staff == nil) {
+			continue
+		}
+		t := t
+		t.Disabled = true
+		steps = append(steps, PlanStep{Action: PlanDisable, Kind: "team", Name: t.Name, apply: t.Update})
+	}
+
+	return steps, nil
+}
+
+// planTeamHosts matches up the hosts of each blue team that lists any. Hosts of new
+// teams are added after the team is, in the same transaction, so the team's id is
+// looked up when the step is applied.
+func planTeamHosts(db models.DB, specs []BlueteamSpec) ([]PlanStep, error) {
+	hosts, err := models.AllTeamHosts(db)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]map[string]models.TeamHost{}
+	for _, h := range hosts {
+		if existing[h.Team] == nil {
+			existing[h.Team] = map[string]models.TeamHost{}
+		}
+		existing[h.Team][h.Role] = h.TeamHost
+	}
+
+	steps := []PlanStep{}
+	for _, t := range specs {
+		if t.Hosts == nil {
+			continue
+		}
+		have := existing[t.Name]
+		roles, gone := []string{}, []string{}
+		for role := range t.Hosts {
+			roles = append(roles, role)
+		}
+		for role := range have {
+			if _, ok := t.Hosts[role]; !ok {
+				gone = append(gone, role)
+			}
+		}
+		sort.Strings(roles)
+		sort.Strings(gone)
+
+		for _, role := range roles {
+			name := t.Name + "/" + role
+			h, ok := have[role]
+			if !ok {
+				teamName, want := t.Name, models.TeamHost{Role: role, Address: t.Hosts[role]}
+				insert := func(db models.DB) error {
+					team, err := models.TeamByName(db, teamName)
+					if err != nil {
+						return err
+					}
+					want.TeamID = team.ID
+					return want.Insert(db)
+				}
+				steps = append(steps, PlanStep{Action: PlanCreate, Kind: "host", Name: name, apply: insert})
+			} else if h.Address != t.Hosts[role] {
+				h.Address = t.Hosts[role]
+				steps = append(steps, PlanStep{Action: PlanUpdate, Kind: "host", Name: name, Changed: []string{"Address"}, apply: h.Update})
+			}
+		}
+
+		for _, role := range gone {
+			h := have[role]
+			steps = append(steps, PlanStep{Action: PlanDelete, Kind: "host", Name: t.Name + "/" + role, apply: h.Delete})
+		}
+	}
+
+	return steps, nil
+}
+
+func planServices(db models.DB, specs []ServiceSpec, eventStart time.Time) ([]PlanStep, error) {
+	services, err := models.AllServices(db)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]models.Service, len(services))
+	for _, s := range services {
+		existing[s.Name] = s
+	}
+
+	steps := []PlanStep{}
+	listed := map[string]bool{}
+	for _, ss := range specs {
+		listed[ss.Name] = true
+		want := models.Service{
+			Name:        ss.Name,
+			Category:    ss.Category,
+			Description: ss.Description,
+			TotalPoints: ss.TotalPoints,
+			Points:      ss.Points,
+			Script:      ss.Script,
+			Args:        ss.Args,
+			Disabled:    ss.Disabled,
+			StartsAt:    ss.StartsAt,
+		}
+		if ss.HostRole != "" {
+			role := ss.HostRole
+			want.HostRole = &role
+		}
+		if want.Args == nil {
+			want.Args = []string{}
+		}
+		if want.StartsAt.IsZero() {
+			want.StartsAt = eventStart
+		}
+
+		have, ok := existing[want.Name]
+		if !ok {
+			steps = append(steps, PlanStep{Action: PlanCreate, Kind: "service", Name: want.Name, apply: want.Insert})
+			continue
+		}
+		if have.Args == nil {
+			have.Args = []string{}
+		}
+
+		want.ID = have.ID
+		changed := diffFields(want, have, "Category", "Description", "TotalPoints", "Points", "Script", "Args", "HostRole", "Disabled")
+		if !want.StartsAt.Equal(have.StartsAt) {
+			changed = append(changed, "StartsAt")
+		}
+		if len(changed) > 0 {
+			steps = append(steps, PlanStep{Action: PlanUpdate, Kind: "service", Name: want.Name, Changed: changed, apply: want.Update})
+		}
+	}
+
+	for _, s := range services {
+		if s.Disabled || listed[s.Name] {
+			continue
+		}
+		s := s
+		s.Disabled = true
+		steps = append(steps, PlanStep{Action: PlanDisable, Kind: "service", Name: s.Name, apply: s.Update})
+	}
+
+	return steps, nil
+}
+
+func planChallenges(db models.DB, specs []ChallengeSpec) ([]PlanStep, error) {
+	challenges, err := models.ArchiveChallenges(db)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]models.Challenge, len(challenges))
+	for _, c := range challenges {
+		existing[c.Name] = c
+	}
+
+	steps := []PlanStep{}
+	listed := map[string]bool{}
+	for _, cs := range specs {
+		listed[cs.Name] = true
+		want := models.Challenge{
+			Name:     cs.Name,
+			Category: cs.Category,
+			Designer: cs.Designer,
+			Flag:     cs.Flag,
+			Total:    cs.Total,
+			Body:     cs.Body,
+			Hidden:   cs.Hidden,
+		}
+
+		have, ok := existing[want.Name]
+		if !ok {
+			steps = append(steps, PlanStep{Action: PlanCreate, Kind: "challenge", Name: want.Name, apply: want.Insert})
+			continue
+		}
+
+		want.ID = have.ID
+		changed := diffFields(want, have, "Category", "Designer", "Flag", "Total", "Body", "Hidden")
+		if len(changed) > 0 {
+			steps = append(steps, PlanStep{Action: PlanUpdate, Kind: "challenge", Name: want.Name, Changed: changed, apply: want.Update})
+		}
+	}
+
+	for _, c := range challenges {
+		if c.Hidden || listed[c.Name] {
+			continue
+		}
+		c := c
+		c.Hidden = true
+		steps = append(steps, PlanStep{Action: PlanDisable, Kind: "challenge", Name: c.Name, apply: c.Update})
+	}
+
+	return steps, nil
+}
+
+// ApplyEventSpec makes the database match the event spec file at `path`.
+// With `dryRun`, the plan is only printed out.
+func ApplyEventSpec(cfg *Configuration, path string, dryRun bool, out io.Writer) error {
+	SetupToolLogger(&cfg.Log)
+	SetupPostgres(cfg.Database.URI)
+
+	f, err := openSpecFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	spec, err := LoadEventSpec(f)
+	if err != nil {
+		return errors.WithMessage(err, path)
+	}
+
+	plan, err := PlanEvent(db, spec, cfg.Event.Start)
+	if err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		fmt.Fprintln(out, "Nothing to do, the database already matches", path)
+		return nil
+	}
+
+	for _, step := range plan {
+		fmt.Fprintln(out, step)
+	}
+	if dryRun {
+		fmt.Fprintf(out, "Dry run: %d change(s) planned, nothing applied.\n", len(plan))
+		return nil
+	}
+	if err = plan.Apply(db); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Applied %d change(s).\n", len(plan))
+
+	for _, step := range plan {
+		if step.generated != "" {
+			fmt.Fprintf(out, "  team %q => password: %s\n", step.Name, step.generated)
+		}
+	}
+	return nil
+}
+
+// openSpecFile opens the event spec, or stdin when `path` is "-".
+func openSpecFile(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(path)
+}
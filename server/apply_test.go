@@ -0,0 +1,96 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pereztr5/cyboard/server/apptest"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eventTestPath = "testdata/event_tests"
+
+func loadTestEventSpec(t *testing.T, name string) (*EventSpec, error) {
+	f, err := os.Open(filepath.Join(eventTestPath, name+".yaml"))
+	require.NoError(t, err)
+	defer f.Close()
+	return LoadEventSpec(f)
+}
+
+func Test_LoadEventSpec(t *testing.T) {
+	cases := []struct {
+		specfile        string
+		expectedErrText string
+	}{
+		{"duplicate_team", `team "team1": listed more than once`},
+		{"duplicate_ip", "ip 1 is already used"},
+		{"bad_role", "role must be admin, ctf_creator, whiteteam, or redteam"},
+		{"missing_script", "empty field: 'script'"},
+		{"bad_host", `host "web": host address "http://10.0.1.5/" is not an IP address or hostname`},
+		{"unknown_field", "colour"},
+		{"valid", ""},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.specfile, func(t *testing.T) {
+			_, err := loadTestEventSpec(t, tt.specfile)
+			if tt.expectedErrText == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.expectedErrText)
+			}
+		})
+	}
+}
+
+func Test_PlanEvent(t *testing.T) {
+	apptest.PrepDatabase(t)
+
+	spec, err := loadTestEventSpec(t, "valid")
+	require.NoError(t, err)
+
+	plan, err := PlanEvent(db, spec, time.Now())
+	require.NoError(t, err)
+
+	got := []string{}
+	for _, step := range plan {
+		got = append(got, step.String())
+	}
+	assert.Equal(t, []string{
+		`~ team "team2" (BlueteamIP)`,
+		`+ team "team4"`,
+		`- team "secondfiddle"`,
+		`- host "team1/mail"`,
+		`+ host "team2/dc"`,
+		`~ host "team2/web" (Address)`,
+		`+ host "team4/web"`,
+		`~ challenge "Totally Rad Challenge" (Total)`,
+		`+ challenge "Brand New"`,
+	}, got)
+
+	require.NoError(t, plan.Apply(db))
+
+	team, err := models.TeamByName(db, "team4")
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.TeamRoleBlueteam, team.RoleName)
+		assert.NotEmpty(t, team.Hash)
+	}
+	team, err = models.TeamByName(db, "secondfiddle")
+	if assert.NoError(t, err) {
+		assert.True(t, team.Disabled)
+	}
+	hosts, err := models.TeamHostMaps(db)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"web": "10.0.1.5"}, hosts[1])
+		assert.Equal(t, map[string]string{"web": "10.0.12.5", "dc": "dc.team2.example"}, hosts[2])
+		assert.Equal(t, 3, len(hosts), "team4 has its host")
+	}
+
+	plan, err = PlanEvent(db, spec, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, plan, "Applying twice changes nothing")
+}
@@ -33,7 +33,9 @@ func testFixtureFiles(testdataPath string) []string {
 	// The order of the files in the array is the order they will be loaded into
 	// the database before each test.
 	// Be careful changing this! The testfixtures library may swallow INSERT stmt errors.
-	files := []string{"team", "challenge", "ctf_solve", "service", "service_check", "other_points"}
+	files := []string{"config", "team", "team_host", "player", "challenge", "ctf_solve", "service", "check_runner", "service_check", "runner_check", "score_category", "other_points",
+		"ticket", "ticket_message", "compromise_report", "incident_rubric", "incident_report",
+		"incident_rubric_score", "inject", "inject_submission"}
 	for i, filename := range files {
 		files[i] = fmt.Sprintf("%s/%s.yml", testdataPath, filename)
 	}
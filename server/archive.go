@@ -0,0 +1,659 @@
+package server
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ArchiveVersion is bumped whenever the layout of an event archive changes
+// in a way older versions of cyboard can't read.
+const ArchiveVersion = 1
+
+const (
+	archiveEventFile = "event.json"
+	archiveCtfDir    = "ctf_files"
+	archiveScriptDir = "scripts"
+	archiveInjectDir = "injects"
+)
+
+// EventArchive is everything needed to re-create an event, saved as `event.json`
+// at the top of the archive. CTF files, check scripts, & inject files follow it in the tarball.
+//
+// Layout of the archive (a .tar.gz):
+//
+//	event.json
+//	ctf_files/<url escaped challenge name>/<file>
+//	scripts/<file>
+//	injects/<url escaped inject title>/attachments/<file>
+//	injects/<url escaped inject title>/teams/<url escaped team name>/<file> (only with scores)
+type EventArchive struct {
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	EventStart time.Time `json:"event_start"`
+	WithHashes bool      `json:"with_hashes"`
+
+	Teams           []models.ArchiveTeam    `json:"teams"`
+	Players         []models.ArchivePlayer  `json:"players,omitempty"`
+	Services        []models.Service        `json:"services"`
+	Challenges      []models.Challenge      `json:"challenges"`
+	ScoreCategories []models.ScoreCategory  `json:"score_categories,omitempty"`
+	IncidentRubric  []models.IncidentRubric `json:"incident_rubric,omitempty"`
+	Injects         []models.Inject         `json:"injects,omitempty"`
+	Scores          *models.ArchiveScores   `json:"scores,omitempty"`
+}
+
+// ConflictPolicy decides what import does with a team, service, challenge,
+// or file that already exists.
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"      // Abort the import, changing nothing
+	ConflictSkip      ConflictPolicy = "skip"      // Keep what's already there
+	ConflictOverwrite ConflictPolicy = "overwrite" // Replace it with what's in the archive
+)
+
+func (p ConflictPolicy) Valid() bool {
+	switch p {
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+		return true
+	}
+	return false
+}
+
+type ExportOptions struct {
+	WithHashes bool // Include team & player password hashes
+	WithScores bool // Include all service checks, ctf solves, bonus points, incident reports, and inject submissions
+}
+
+type ImportOptions struct {
+	OnConflict ConflictPolicy
+	WithScores bool // Load the scoring history, if the archive has it
+}
+
+// ExportArchive saves the event in the database & on disk to a gzipped tarball at `dest`.
+func ExportArchive(cfg *Configuration, dest string, opts ExportOptions) error {
+	SetupToolLogger(&cfg.Log)
+	SetupPostgres(cfg.Database.URI)
+
+	ev := &EventArchive{
+		Version:    ArchiveVersion,
+		CreatedAt:  time.Now(),
+		EventStart: cfg.Event.Start,
+		WithHashes: opts.WithHashes,
+	}
+
+	var err error
+	if ev.Teams, err = models.ArchiveTeams(db, opts.WithHashes); err != nil {
+		return errors.WithMessage(err, "export teams")
+	}
+	if ev.Players, err = models.ArchivePlayers(db, opts.WithHashes); err != nil {
+		return errors.WithMessage(err, "export players")
+	}
+	if ev.Services, err = models.AllServices(db); err != nil {
+		return errors.WithMessage(err, "export services")
+	}
+	if ev.Challenges, err = models.ArchiveChallenges(db); err != nil {
+		return errors.WithMessage(err, "export challenges")
+	}
+	if ev.ScoreCategories, err = models.AllScoreCategories(db); err != nil {
+		return errors.WithMessage(err, "export score categories")
+	}
+	if ev.IncidentRubric, err = models.AllIncidentRubric(db); err != nil {
+		return errors.WithMessage(err, "export incident rubric")
+	}
+	if ev.Injects, err = models.AllInjects(db); err != nil {
+		return errors.WithMessage(err, "export injects")
+	}
+	if opts.WithScores {
+		if ev.Scores, err = models.ArchiveScoringHistory(db); err != nil {
+			return errors.WithMessage(err, "export scores")
+		}
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	evJSON, err := json.MarshalIndent(ev, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: archiveEventFile, Mode: 0644, Size: int64(len(evJSON)), ModTime: ev.CreatedAt}
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err = tw.Write(evJSON); err != nil {
+		return err
+	}
+
+	for _, chal := range ev.Challenges {
+		src := filepath.Join(cfg.Server.CtfFileDir, strconv.Itoa(chal.ID))
+		prefix := path.Join(archiveCtfDir, url.PathEscape(chal.Name))
+		if err = tarDir(tw, src, prefix); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("export ctf files (challenge=%q)", chal.Name))
+		}
+	}
+	if err = tarDir(tw, cfg.ServiceMonitor.ChecksDir, archiveScriptDir); err != nil {
+		return errors.WithMessage(err, "export check scripts")
+	}
+	if err = tarInjectFiles(tw, cfg.Server.InjectDir, ev.Injects, opts.WithScores); err != nil {
+		return errors.WithMessage(err, "export inject files")
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	Logger.WithField("archive", dest).Infof("Exported %d teams, %d players, %d services, %d challenges, %d injects",
+		len(ev.Teams), len(ev.Players), len(ev.Services), len(ev.Challenges), len(ev.Injects))
+	return f.Close()
+}
+
+// tarInjectFiles adds each inject's attachments to the archive. The files teams
+// sent with their submissions are only added along with the scores.
+func tarInjectFiles(tw *tar.Writer, injectDir string, injects []models.Inject, withSubmissions bool) error {
+	var teams []models.Team
+	if withSubmissions {
+		var err error
+		if teams, err = models.AllTeams(db); err != nil {
+			return err
+		}
+	}
+
+	for _, inject := range injects {
+		dir := filepath.Join(injectDir, strconv.Itoa(inject.ID))
+		prefix := path.Join(archiveInjectDir, url.PathEscape(inject.Title))
+		if err := tarDir(tw, filepath.Join(dir, "attachments"), path.Join(prefix, "attachments")); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("inject=%q", inject.Title))
+		}
+		for _, t := range teams {
+			src := filepath.Join(dir, "teams", strconv.Itoa(t.ID))
+			if err := tarDir(tw, src, path.Join(prefix, "teams", url.PathEscape(t.Name))); err != nil {
+				return errors.WithMessage(err, fmt.Sprintf("inject=%q team=%q", inject.Title, t.Name))
+			}
+		}
+	}
+	return nil
+}
+
+// tarDir adds every regular file under `dir` to the archive, beneath `prefix`.
+// A missing `dir` is skipped, since most challenges don't have any files.
+func tarDir(tw *tar.Writer, dir, prefix string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err = tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// ImportArchive loads an event archive made by ExportArchive. Teams, services,
+// and challenges are matched up with existing ones by name. All database changes are
+// made in a single transaction, before any files get unpacked.
+func ImportArchive(cfg *Configuration, src string, opts ImportOptions) error {
+	SetupToolLogger(&cfg.Log)
+	SetupPostgres(cfg.Database.URI)
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return errors.WithMessage(err, "read archive")
+	} else if hdr.Name != archiveEventFile {
+		return fmt.Errorf("not an event archive: expected %q first, found %q", archiveEventFile, hdr.Name)
+	}
+	ev := &EventArchive{}
+	if err = json.NewDecoder(tr).Decode(ev); err != nil {
+		return errors.WithMessage(err, "decode "+archiveEventFile)
+	}
+	if ev.Version != ArchiveVersion {
+		return fmt.Errorf("unsupported archive version %d (this cyboard reads version %d)", ev.Version, ArchiveVersion)
+	}
+
+	// Failing on a conflict changes nothing, so the files are checked before the records are saved
+	var checkFiles func(*archiveIDs) error
+	if opts.OnConflict == ConflictFail {
+		checkFiles = func(ids *archiveIDs) error {
+			return checkArchiveFiles(cfg, src, ids)
+		}
+	}
+	ids, err := importEventRecords(ev, opts, checkFiles)
+	if err != nil {
+		return err
+	}
+
+	for {
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.WithMessage(err, "read archive")
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		dest, err := archiveFileDest(cfg, hdr.Name, ids)
+		if err != nil {
+			return err
+		} else if dest == "" {
+			continue
+		}
+		if err = unpackFile(tr, hdr, dest, opts.OnConflict); err != nil {
+			return errors.WithMessage(err, "unpack "+hdr.Name)
+		}
+	}
+
+	Logger.WithField("archive", src).Infof("Imported %d teams, %d players, %d services, %d challenges, %d injects",
+		len(ev.Teams), len(ev.Players), len(ev.Services), len(ev.Challenges), len(ev.Injects))
+	return nil
+}
+
+// archiveIDs are the database IDs of what the archive's files belong to, by name.
+type archiveIDs struct {
+	challenges map[string]int
+	injects    map[string]int
+	teams      map[string]int // Only set when importing scores, to place the files sent with inject submissions
+}
+
+// importEventRecords saves the teams, players, services, challenges, score categories, incident rubric,
+// injects, and scores into the database. Returns the database IDs needed to place the archive's files.
+// If given, beforeCommit gets the IDs too, and can cancel the import with an error.
+func importEventRecords(ev *EventArchive, opts ImportOptions, beforeCommit func(*archiveIDs) error) (*archiveIDs, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := &archiveIDs{
+		challenges: make(map[string]int, len(ev.Challenges)),
+		injects:    make(map[string]int, len(ev.Injects)),
+	}
+	teamIDs := make(map[string]int, len(ev.Teams))
+	for _, at := range ev.Teams {
+		t := &models.Team{Name: at.Name, RoleName: at.RoleName, Hash: at.Hash, Disabled: at.Disabled, BlueteamIP: at.BlueteamIP}
+		existing, err := models.TeamByName(tx, at.Name)
+		switch {
+		case err == pgx.ErrNoRows:
+			if t.Hash == nil {
+				pass, hash, err := generatePassword()
+				if err != nil {
+					return nil, err
+				}
+				t.Hash = hash
+				fmt.Printf("  team %q => password: %s\n", t.Name, pass)
+			}
+			if err = t.Insert(tx); err == nil && at.Hosts != nil {
+				err = models.SetTeamHosts(tx, t.ID, at.Hosts)
+			}
+		case err != nil:
+		case opts.OnConflict == ConflictFail:
+			err = archiveConflict("team", at.Name)
+		case opts.OnConflict == ConflictOverwrite:
+			// A nil Hash keeps the team's current password
+			t.ID = existing.ID
+			if err = t.Update(tx); err == nil && at.Hosts != nil {
+				err = models.SetTeamHosts(tx, t.ID, at.Hosts)
+			}
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("import team %q", at.Name))
+		}
+		if t.ID == 0 {
+			t.ID = existing.ID // skipped
+		}
+		teamIDs[at.Name] = t.ID
+	}
+
+	for _, ap := range ev.Players {
+		teamID, ok := teamIDs[ap.Team]
+		if !ok {
+			return nil, fmt.Errorf("import player %q: unknown team %q", ap.Name, ap.Team)
+		}
+		p := &models.Player{TeamID: teamID, Name: ap.Name, Hash: ap.Hash, Disabled: ap.Disabled}
+		existing, err := models.PlayerByName(tx, ap.Name)
+		switch {
+		case err == pgx.ErrNoRows:
+			if p.Hash == nil {
+				pass, hash, err := generatePassword()
+				if err != nil {
+					return nil, err
+				}
+				p.Hash = hash
+				fmt.Printf("  player %q => password: %s\n", p.Name, pass)
+			}
+			err = p.Insert(tx)
+		case err != nil:
+		case opts.OnConflict == ConflictFail:
+			err = archiveConflict("player", ap.Name)
+		case opts.OnConflict == ConflictOverwrite:
+			// A nil Hash keeps the player's current password
+			p.ID = existing.ID
+			err = p.Update(tx)
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("import player %q", ap.Name))
+		}
+	}
+
+	for _, s := range ev.Services {
+		existing, err := models.ServiceByName(tx, s.Name)
+		switch {
+		case err == pgx.ErrNoRows:
+			err = s.Insert(tx)
+		case err != nil:
+		case opts.OnConflict == ConflictFail:
+			err = archiveConflict("service", s.Name)
+		case opts.OnConflict == ConflictOverwrite:
+			s.ID = existing.ID
+			err = s.Update(tx)
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("import service %q", s.Name))
+		}
+	}
+
+	for _, c := range ev.Challenges {
+		existing, err := models.ChallengeByName(tx, c.Name)
+		switch {
+		case err == pgx.ErrNoRows:
+			err = c.Insert(tx)
+		case err != nil:
+		case opts.OnConflict == ConflictFail:
+			err = archiveConflict("challenge", c.Name)
+		default:
+			c.ID = existing.ID
+			if opts.OnConflict == ConflictOverwrite {
+				err = c.Update(tx)
+			}
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("import challenge %q", c.Name))
+		}
+		ids.challenges[c.Name] = c.ID
+	}
+
+	// The builtin categories are always there, so they only conflict if they've been re-weighted
+	for _, sc := range ev.ScoreCategories {
+		existing, err := models.ScoreCategoryByName(tx, sc.Name)
+		switch {
+		case err == pgx.ErrNoRows:
+			err = sc.Insert(tx)
+		case err != nil:
+		default:
+			sc.ID, sc.Builtin = existing.ID, existing.Builtin
+			switch {
+			case sc == *existing:
+			case opts.OnConflict == ConflictFail:
+				err = archiveConflict("score category", sc.Name)
+			case opts.OnConflict == ConflictOverwrite:
+				err = sc.Update(tx)
+			}
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("import score category %q", sc.Name))
+		}
+	}
+
+	// Like the builtin categories, the default rubric is always there
+	for _, ir := range ev.IncidentRubric {
+		existing, err := models.IncidentRubricByName(tx, ir.Name)
+		switch {
+		case err == pgx.ErrNoRows:
+			err = ir.Insert(tx)
+		case err != nil:
+		default:
+			ir.ID = existing.ID
+			switch {
+			case ir == *existing:
+			case opts.OnConflict == ConflictFail:
+				err = archiveConflict("incident rubric", ir.Name)
+			case opts.OnConflict == ConflictOverwrite:
+				err = ir.Update(tx)
+			}
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("import incident rubric %q", ir.Name))
+		}
+	}
+
+	for _, inj := range ev.Injects {
+		existing, err := models.InjectByTitle(tx, inj.Title)
+		switch {
+		case err == pgx.ErrNoRows:
+			err = inj.Insert(tx)
+		case err != nil:
+		case opts.OnConflict == ConflictFail:
+			err = archiveConflict("inject", inj.Title)
+		default:
+			inj.ID = existing.ID
+			if opts.OnConflict == ConflictOverwrite {
+				err = inj.Update(tx)
+			}
+		}
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("import inject %q", inj.Title))
+		}
+		ids.injects[inj.Title] = inj.ID
+	}
+
+	if opts.WithScores && ev.Scores != nil {
+		// Mixing two events' worth of points together would make a mess of the scoreboard.
+		scored, err := models.HasScoringHistory(tx)
+		if err != nil {
+			return nil, err
+		} else if scored {
+			return nil, errors.New("import scores: database already has a scoring history")
+		}
+		if err = ev.Scores.Insert(tx); err != nil {
+			return nil, errors.WithMessage(err, "import scores")
+		}
+		ids.teams = teamIDs
+	}
+
+	if beforeCommit != nil {
+		if err = beforeCommit(ids); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// archiveConflict is the error for something in the archive that already exists, under ConflictFail.
+func archiveConflict(kind, name string) error {
+	return fmt.Errorf("%s %q already exists (use a different conflict policy to skip or overwrite it)", kind, name)
+}
+
+// checkArchiveFiles reads through the archive's files, failing on the first one that already exists.
+func checkArchiveFiles(cfg *Configuration, src string, ids *archiveIDs) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.WithMessage(err, "read archive")
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Name == archiveEventFile {
+			continue
+		}
+
+		dest, err := archiveFileDest(cfg, hdr.Name, ids)
+		if err != nil {
+			return err
+		} else if dest == "" {
+			continue
+		}
+		if _, err = os.Stat(dest); err == nil {
+			return archiveConflict("file", dest)
+		}
+	}
+}
+
+// archiveFileDest maps a file in the archive to where it belongs on disk. The files sent with
+// inject submissions map to "" (to be left out) unless the scores are being imported.
+func archiveFileDest(cfg *Configuration, name string, ids *archiveIDs) (string, error) {
+	name = path.Clean(name)
+	parts := strings.SplitN(name, "/", 3)
+
+	switch {
+	case len(parts) == 3 && parts[0] == archiveCtfDir:
+		chalName, err := url.PathUnescape(parts[1])
+		if err != nil {
+			return "", errors.WithMessage(err, "bad ctf file path "+name)
+		}
+		id, ok := ids.challenges[chalName]
+		if !ok {
+			return "", fmt.Errorf("ctf file %q belongs to an unknown challenge", name)
+		}
+		return safeJoin(filepath.Join(cfg.Server.CtfFileDir, strconv.Itoa(id)), parts[2])
+	case len(parts) >= 2 && parts[0] == archiveScriptDir:
+		return safeJoin(cfg.ServiceMonitor.ChecksDir, strings.TrimPrefix(name, archiveScriptDir+"/"))
+	case len(parts) == 3 && parts[0] == archiveInjectDir:
+		title, err := url.PathUnescape(parts[1])
+		if err != nil {
+			return "", errors.WithMessage(err, "bad inject file path "+name)
+		}
+		id, ok := ids.injects[title]
+		if !ok {
+			return "", fmt.Errorf("inject file %q belongs to an unknown inject", name)
+		}
+		dir := filepath.Join(cfg.Server.InjectDir, strconv.Itoa(id))
+
+		rest := strings.SplitN(parts[2], "/", 2)
+		switch {
+		case len(rest) == 2 && rest[0] == "attachments":
+			return safeJoin(filepath.Join(dir, "attachments"), rest[1])
+		case len(rest) == 2 && rest[0] == "teams":
+			if ids.teams == nil {
+				return "", nil
+			}
+			team := strings.SplitN(rest[1], "/", 2)
+			if len(team) != 2 {
+				break
+			}
+			teamName, err := url.PathUnescape(team[0])
+			if err != nil {
+				return "", errors.WithMessage(err, "bad inject file path "+name)
+			}
+			teamID, ok := ids.teams[teamName]
+			if !ok {
+				return "", fmt.Errorf("inject file %q belongs to an unknown team", name)
+			}
+			return safeJoin(filepath.Join(dir, "teams", strconv.Itoa(teamID)), team[1])
+		}
+	}
+	return "", fmt.Errorf("unexpected file in archive: %q", name)
+}
+
+// safeJoin joins the relative path onto base, refusing to go outside of base.
+func safeJoin(base, rel string) (string, error) {
+	rel = filepath.FromSlash(rel)
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to unpack outside of %s: %q", base, rel)
+	}
+	return filepath.Join(base, rel), nil
+}
+
+// unpackFile writes out one file from the archive. Existing files are only
+// replaced when overwriting, and left alone (with a warning) when skipping.
+func unpackFile(r io.Reader, hdr *tar.Header, dest string, policy ConflictPolicy) error {
+	if _, err := os.Stat(dest); err == nil {
+		switch policy {
+		case ConflictFail:
+			return archiveConflict("file", dest)
+		case ConflictSkip:
+			Logger.WithField("file", dest).Warn("File already exists, not unpacking it")
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// generatePassword makes a random password for a team or player imported without their hash.
+func generatePassword() (string, []byte, error) {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	pass := base64.RawURLEncoding.EncodeToString(b)
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	return pass, hash, err
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_unpackFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cyboard-unpack")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	dest := filepath.Join(dir, "checks", "ping.sh")
+	unpack := func(body string, policy ConflictPolicy) error {
+		hdr := &tar.Header{Name: "scripts/ping.sh", Mode: 0755, Size: int64(len(body))}
+		return unpackFile(strings.NewReader(body), hdr, dest, policy)
+	}
+	contents := func() string {
+		b, _ := ioutil.ReadFile(dest)
+		return string(b)
+	}
+
+	require.Nil(t, unpack("v1", ConflictFail), "Nothing there yet")
+	assert.Equal(t, "v1", contents())
+
+	assert.Error(t, unpack("v2", ConflictFail), "The file already exists")
+	assert.Nil(t, unpack("v2", ConflictSkip))
+	assert.Equal(t, "v1", contents(), "Skipped files are left alone")
+	assert.Nil(t, unpack("v3", ConflictOverwrite))
+	assert.Equal(t, "v3", contents())
+}
+
+func Test_archiveFileDest(t *testing.T) {
+	cfg := &Configuration{}
+	cfg.Server.CtfFileDir = "/srv/ctf"
+	cfg.Server.InjectDir = "/srv/injects"
+	cfg.ServiceMonitor.ChecksDir = "/srv/checks"
+	ids := &archiveIDs{
+		challenges: map[string]int{"Rad Challenge": 4},
+		injects:    map[string]int{"Password policy": 7},
+	}
+
+	cases := []struct {
+		name, dest string
+		fails      bool
+	}{
+		{name: "ctf_files/Rad%20Challenge/notes.txt", dest: "/srv/ctf/4/notes.txt"},
+		{name: "scripts/lib/ping.sh", dest: "/srv/checks/lib/ping.sh"},
+		{name: "injects/Password%20policy/attachments/template.docx", dest: "/srv/injects/7/attachments/template.docx"},
+		{name: "injects/Password%20policy/teams/team1/policy.pdf", dest: ""}, // Not importing scores
+		{name: "injects/Firewall%20audit/attachments/rules.txt", fails: true},
+		{name: "injects/Password%20policy/attachments/../../../../etc/passwd", fails: true},
+		{name: "README", fails: true},
+	}
+	for _, c := range cases {
+		dest, err := archiveFileDest(cfg, c.name, ids)
+		if c.fails {
+			assert.Error(t, err, c.name)
+		} else if assert.Nil(t, err, c.name) {
+			assert.Equal(t, filepath.FromSlash(c.dest), dest, c.name)
+		}
+	}
+
+	ids.teams = map[string]int{"team1": 1}
+	dest, err := archiveFileDest(cfg, "injects/Password%20policy/teams/team1/policy.pdf", ids)
+	if assert.Nil(t, err) {
+		assert.Equal(t, filepath.FromSlash("/srv/injects/7/teams/1/policy.pdf"), dest)
+	}
+	_, err = archiveFileDest(cfg, "injects/Password%20policy/teams/nobody/policy.pdf", ids)
+	assert.Error(t, err, "Files for unknown teams are an error")
+}
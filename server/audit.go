@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/urfave/negroni"
+)
+
+// maxAuditBody caps how much of a request body gets copied into the audit log.
+const maxAuditBody = 1 << 20
+
+// auditSnapshot fetches a record, as it is right now, for the audit log's before & after.
+type auditSnapshot struct {
+	path  *regexp.Regexp // First submatch must be the record ID
+	fetch func(id int) (interface{}, error)
+}
+
+var auditSnapshots = []auditSnapshot{
+	{regexp.MustCompile(`^/api/admin/teams/(\d+)/?$`), func(id int) (interface{}, error) { return models.TeamByID(db, id) }},
+	{regexp.MustCompile(`^/api/admin/players/(\d+)/?$`), func(id int) (interface{}, error) { return models.PlayerByID(db, id) }},
+	{regexp.MustCompile(`^/api/admin/services/(\d+)/?$`), func(id int) (interface{}, error) { return models.ServiceByID(db, id) }},
+	{regexp.MustCompile(`^/api/admin/score_categories/(\d+)/?$`), func(id int) (interface{}, error) { return models.ScoreCategoryByID(db, id) }},
+	{regexp.MustCompile(`^/admin/scoring/(\d+)(/delete)?$`), func(id int) (interface{}, error) { return models.ScoreCategoryByID(db, id) }},
+	{regexp.MustCompile(`^/api/admin/hosts/(\d+)/?$`), func(id int) (interface{}, error) { return models.TeamHostByID(db, id) }},
+	{regexp.MustCompile(`^/admin/hosts/(\d+)(/delete)?$`), func(id int) (interface{}, error) { return models.TeamHostByID(db, id) }},
+	{regexp.MustCompile(`^/api/admin/runners/(\d+)(/token)?/?$`), func(id int) (interface{}, error) { return models.CheckRunnerByID(db, id) }},
+	{regexp.MustCompile(`^/admin/runners/(\d+)(/token|/delete)?$`), func(id int) (interface{}, error) { return models.CheckRunnerByID(db, id) }},
+	{regexp.MustCompile(`^/api/admin/bonus/(\d+)/revoke$`), func(id int) (interface{}, error) { return models.BonusPointsByID(db, id) }},
+	{regexp.MustCompile(`^/api/ctf/flags/(\d+)(/activate)?/?$`), func(id int) (interface{}, error) { return models.ChallengeByID(db, id) }},
+	{regexp.MustCompile(`^/api/staff/tickets/(\d+)/(assign|close|reopen)$`), func(id int) (interface{}, error) { return models.TicketByID(db, id) }},
+	{regexp.MustCompile(`^/staff/compromises/(\d+)/review$`), func(id int) (interface{}, error) { return models.CompromiseReportByID(db, id) }},
+	{regexp.MustCompile(`^/staff/incidents/(\d+)/score$`), func(id int) (interface{}, error) { return models.IncidentReportByID(db, id) }},
+	{regexp.MustCompile(`^/staff/injects/(\d+)$`), func(id int) (interface{}, error) { return models.InjectByID(db, id) }},
+	{regexp.MustCompile(`^/api/staff/injects/(\d+)/?$`), func(id int) (interface{}, error) { return models.InjectByID(db, id) }},
+	{regexp.MustCompile(`^/staff/injects/submissions/(\d+)/grade$`), func(id int) (interface{}, error) { return models.InjectSubmissionByID(db, id) }},
+}
+
+// snapshotFor finds the record a request targets, and encodes it as JSON, with secrets redacted.
+// Returns nil for requests that don't target a single record, or if it's gone.
+func snapshotFor(path string) json.RawMessage {
+	for _, snap := range auditSnapshots {
+		m := snap.path.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+		id, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil
+		}
+		record, err := snap.fetch(id)
+		if err != nil {
+			if err != pgx.ErrNoRows {
+				Logger.WithError(err).WithField("path", path).Error("audit log: failed to snapshot record")
+			}
+			return nil
+		}
+		b, err := json.Marshal(record)
+		if err != nil {
+			return nil
+		}
+		return redactJSON(b)
+	}
+	return nil
+}
+
+// redactSecrets blanks out anything that looks like a password or a ctf flag in a decoded
+// JSON value. Reading the audit log doesn't take the permission to see the flags.
+func redactSecrets(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, val := range x {
+			if lk := strings.ToLower(k); strings.Contains(lk, "password") || lk == "hash" || lk == "flag" {
+				x[k] = "[redacted]"
+			} else {
+				x[k] = redactSecrets(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range x {
+			x[i] = redactSecrets(val)
+		}
+	}
+	return v
+}
+
+// auditRequestBody copies a JSON request body for the audit log, with secrets redacted,
+// and puts the body back so the handler can still read it. Other bodies aren't kept.
+func auditRequestBody(r *http.Request) json.RawMessage {
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAuditBody+1))
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(b), r.Body))
+	if err != nil || len(b) > maxAuditBody {
+		return nil
+	}
+	return redactJSON(b)
+}
+
+// redactJSON re-encodes a JSON document with its secrets redacted.
+// Returns nil if it isn't valid JSON.
+func redactJSON(b []byte) json.RawMessage {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+	redacted, err := json.Marshal(redactSecrets(v))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// AuditLog records every change made through the routes it wraps: who did it,
+// what they sent, and what the record looked like before & after. Reads aren't logged.
+func AuditLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		entry := &models.AuditEntry{Target: r.URL.Path}
+		if team := getCtxTeam(r); team != nil {
+			entry.ActorID, entry.Actor = &team.ID, team.Name
+		}
+		if player := getCtxPlayer(r); player != nil {
+			entry.Player = &player.Name
+		}
+		entry.Request = auditRequestBody(r)
+		entry.Before = snapshotFor(r.URL.Path)
+
+		rw, ok := w.(negroni.ResponseWriter)
+		if !ok {
+			rw = negroni.NewResponseWriter(w)
+		}
+		next.ServeHTTP(rw, r)
+
+		entry.Status = int16(rw.Status())
+		entry.After = snapshotFor(r.URL.Path)
+		entry.Action = r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+
+		if err := entry.Insert(db); err != nil {
+			Logger.WithError(err).WithField("action", entry.Action).WithField("target", entry.Target).
+				Error("audit log: failed to save entry")
+		}
+	})
+}
+
+// auditFilterFrom reads the audit log's search params: `actor`, `action`, `target`,
+// and the time window `since` & `until` (RFC3339).
+func auditFilterFrom(r *http.Request) (models.AuditFilter, error) {
+	q := r.URL.Query()
+	f := models.AuditFilter{
+		Actor:  q.Get("actor"),
+		Action: q.Get("action"),
+		Target: q.Get("target"),
+	}
+	var err error
+	if s := q.Get("since"); s != "" {
+		if f.Since, err = time.Parse(time.RFC3339, s); err != nil {
+			return f, fmt.Errorf("invalid timestamp: since=%q (wanted RFC3339 format)", s)
+		}
+	}
+	if u := q.Get("until"); u != "" {
+		if f.Until, err = time.Parse(time.RFC3339, u); err != nil {
+			return f, fmt.Errorf("invalid timestamp: until=%q (wanted RFC3339 format)", u)
+		}
+	}
+	if l := q.Get("limit"); l != "" {
+		if f.Limit, err = strconv.Atoi(l); err != nil {
+			return f, fmt.Errorf("invalid limit: %q", l)
+		}
+	}
+	return f, nil
+}
+
+// GetAuditLog searches the audit log, newest first.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	f, err := auditFilterFrom(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidBecause(err.Error()))
+		return
+	}
+	entries, err := models.AuditEntries(db, f)
+	ApiQuery(w, r, entries, err)
+}
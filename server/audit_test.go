@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pereztr5/cyboard/server/apptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_auditRequestBody(t *testing.T) {
+	body := `{"name":"team1","password":"hunter22","nested":[{"new_password":"x","hash":"y","flag":"z","ok":1}]}`
+	r := httptest.NewRequest("PUT", "/api/admin/teams/1", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+
+	logged := auditRequestBody(r)
+	assert.JSONEq(t, `{"name":"team1","password":"[redacted]","nested":[{"new_password":"[redacted]","hash":"[redacted]","flag":"[redacted]","ok":1}]}`,
+		string(logged))
+
+	b, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(b), "Handlers still get the whole body")
+
+	r = httptest.NewRequest("POST", "/api/ctf/flags/1/files", strings.NewReader("file contents"))
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	assert.Nil(t, auditRequestBody(r), "Only JSON bodies are logged")
+}
+
+func Test_snapshotFor(t *testing.T) {
+	apptest.PrepDatabase(t)
+
+	snap := snapshotFor("/api/ctf/flags/1")
+	require.NotNil(t, snap)
+	var chal map[string]interface{}
+	require.NoError(t, json.Unmarshal(snap, &chal))
+	assert.Equal(t, float64(1), chal["id"])
+	assert.Equal(t, "[redacted]", chal["flag"], "Audit readers can't see the flags")
+
+	assert.Nil(t, snapshotFor("/api/ctf/flags/999"), "Gone")
+	assert.Nil(t, snapshotFor("/api/ctf/flags"), "Not a single record")
+}
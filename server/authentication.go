@@ -1,13 +1,12 @@
 package server
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/alexedwards/scs"
-	"github.com/alexedwards/scs/stores/cookiestore"
 	"github.com/jackc/pgx"
 	"github.com/pereztr5/cyboard/server/models"
 	"golang.org/x/crypto/bcrypt"
@@ -17,18 +16,21 @@ const (
 	formCredsTeam = "teamname"
 	formCredsPass = "password"
 
-	sessionIDKey       = "id"
-	sessionPGConfigKey = "session.config"
+	sessionIDKey     = "id"
+	sessionPlayerKey = "player"
 )
 
 var sessionManager *scs.Manager
 
 // CreateStore initializes the global Session Manager, used to authenticate
-// users across requests. If secure is true, the generated browser cookies
+// users across requests. Sessions are kept in postgres, and the browser's cookie
+// only holds a random token. If secure is true, the generated browser cookies
 // will only be shared over HTTPS.
 func CreateStore(secure bool) {
-	key := getSigningKey()
-	sessionManager = scs.NewManager(cookiestore.New(key))
+	store := newPGSessionStore(db)
+	go store.cleanup(sessionCleanupInterval)
+
+	sessionManager = scs.NewManager(store)
 	sessionManager.Name("session")
 	sessionManager.Lifetime(7 * 24 * time.Hour)
 	sessionManager.Persist(true)
@@ -38,26 +40,61 @@ func CreateStore(secure bool) {
 }
 
 // CheckCreds authenticates users based on username/password form values contained
-// in the request. If the credentials all match, the team's ID will be saved to a
-// cookie in the browser. If there are any errors, they will get logged and this
+// in the request. The name may be either a player's, or a team's shared login.
+// If the credentials all match, the team's ID (and the player's) will be saved to
+// a cookie in the browser. If there are any errors, they will get logged and this
 // will return false.
 func CheckCreds(w http.ResponseWriter, r *http.Request) bool {
-	teamname, password := r.FormValue(formCredsTeam), r.FormValue(formCredsPass)
+	name, password := r.FormValue(formCredsTeam), r.FormValue(formCredsPass)
 
-	t, err := models.TeamByName(db, teamname)
-	if err != nil {
+	var (
+		teamID int
+		player *models.Player
+		hash   []byte
+	)
+	p, err := models.PlayerByName(db, name)
+	if err == nil {
+		if p.Disabled {
+			return false
+		}
+		t, err := models.TeamByID(db, p.TeamID)
+		if err != nil || t.Disabled {
+			return false
+		}
+		player, teamID, hash = p, t.ID, p.Hash
+	} else if err == pgx.ErrNoRows {
+		t, err := models.TeamByName(db, name)
+		if err != nil || t.Disabled {
+			return false
+		}
+		teamID, hash = t.ID, t.Hash
+	} else {
+		Logger.WithError(err).Error("CheckCreds: failed to look up player")
 		return false
 	}
 
-	if err = bcrypt.CompareHashAndPassword([]byte(t.Hash), []byte(password)); err != nil {
+	if err = bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
 		if err != bcrypt.ErrMismatchedHashAndPassword {
 			Logger.Error(err)
 		}
 		return false
 	}
 
+	// Logging in gets a brand new session token, so one planted before login is useless
 	session := sessionManager.Load(r)
-	err = session.PutInt(w, sessionIDKey, t.ID)
+	err = session.RenewToken(w)
+	if err != nil {
+		Logger.Error("Error renewing session: ", err)
+		return false
+	}
+	if player != nil {
+		err = session.PutInt(w, sessionPlayerKey, player.ID)
+	} else {
+		err = session.Remove(w, sessionPlayerKey)
+	}
+	if err == nil {
+		err = session.PutInt(w, sessionIDKey, teamID)
+	}
 	if err != nil {
 		Logger.Error("Error saving session: ", err)
 	}
@@ -67,13 +104,20 @@ func CheckCreds(w http.ResponseWriter, r *http.Request) bool {
 // CheckSessionID is a middleware that authenticates users based on a cookie
 // their browser supplies with each request that has their team's ID. This does
 // a query against the database and sticks the matching models.Team values onto
-// the request context, under the "team" key.
+// the request context, under the "team" key. If they logged in as a player, the
+// models.Player is also put in the context, under the "player" key.
 //
-// If the user hasn't logged in, has tampered with their cookie, or there's
-// some internal server error, the "team" key in the context will be nil.
+// If the user hasn't logged in, has tampered with their cookie, their team is
+// disabled, or there's some internal server error, the "team" key in the context
+// will be nil.
+// The same goes for a player who was disabled, or moved to another team,
+// after they logged in.
 func CheckSessionID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var team *models.Team
+		var (
+			team   *models.Team
+			player *models.Player
+		)
 
 		session := sessionManager.Load(r)
 		hasID, err := session.Exists(sessionIDKey)
@@ -88,50 +132,43 @@ func CheckSessionID(next http.Handler) http.Handler {
 				if err != nil {
 					Logger.WithError(err).WithField("teamID", teamID).
 						Error("CheckSessionID: GetTeamById failed")
-				} else {
+				} else if !t.Disabled {
 					team = t
 				}
 			}
 		}
+
+		if team != nil {
+			player, err = sessionPlayer(session, team.ID)
+			if err != nil {
+				Logger.WithError(err).WithField("teamID", team.ID).
+					Error("CheckSessionID: failed to load player")
+				team = nil
+			}
+		}
+
 		ctx := saveCtxTeam(r, team)
+		ctx = context.WithValue(ctx, ctxPlayer, player)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// getSigningKey fetches the session signing secret from the database, or
-// creates a new one if one doesn't exist and saves that to the database.
-func getSigningKey() []byte {
-	// The signing key is stored in the db config table as a base64 string, and then decoded to its bytes
-	var (
-		s string
-		b []byte
-	)
-
-	err := db.QueryRow(`SELECT value FROM config WHERE key = $1`, sessionPGConfigKey).Scan(&s)
+// sessionPlayer loads the player logged in to the session, if any. A player who
+// can no longer act for the team is an error.
+func sessionPlayer(session *scs.Session, teamID int) (*models.Player, error) {
+	hasPlayer, err := session.Exists(sessionPlayerKey)
+	if err != nil || !hasPlayer {
+		return nil, err
+	}
+	playerID, err := session.GetInt(sessionPlayerKey)
 	if err != nil {
-		if err != pgx.ErrNoRows {
-			Logger.WithError(err).Fatal("getSigningKey: failed to fetch from postgres")
-		}
-
-		Logger.Info("Generating new session signing key")
-
-		b = make([]byte, 32)
-		_, err := rand.Read(b)
-		if err != nil {
-			Logger.WithError(err).Fatal("getSigningKey: failed to generate session signing key")
-		}
-
-		s = base64.StdEncoding.EncodeToString(b)
-
-		_, err = db.Exec(`INSERT INTO config (key, value) VALUES ($1,$2)`, sessionPGConfigKey, s)
-		if err != nil {
-			Logger.WithError(err).Fatal("getSigningKey: failed to insert new key into postgres")
-		}
+		return nil, err
 	}
-	b, err = base64.StdEncoding.DecodeString(s)
+	p, err := models.PlayerByID(db, playerID)
 	if err != nil {
-		Logger.WithError(err).Fatal("getSigningKey: failed to decode key from postgres")
+		return nil, err
+	} else if p.Disabled || p.TeamID != teamID {
+		return nil, fmt.Errorf("player %q is disabled or no longer on the team", p.Name)
 	}
-
-	return b
+	return p, nil
 }
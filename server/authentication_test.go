@@ -43,6 +43,18 @@ func TestCheckCreds(t *testing.T) {
 			},
 			expect: false,
 		},
+		"player": {
+			formPrep: func(f *url.Values) {
+				f.Set(formCredsTeam, "alice")
+			},
+			expect: true,
+		},
+		"disabled player": {
+			formPrep: func(f *url.Values) {
+				f.Set(formCredsTeam, "carol")
+			},
+			expect: false,
+		},
 	}
 	for name, tt := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Bonus Points API
+// Allows arbitrary point awards/deductions, stored in the "other_points" db table.
+// JSON input should give a list of team ids, point value, and a reason string.
+// The points go in the "other" score category, unless another `category` is named.
+//
+// Each team gets its own grant, with an id, a `kind` (bonus, penalty, redteam, inject),
+// the staff member who gave it, and an optional `note` the team can read. A grant is
+// undone by revoking it, which keeps it on record, but stops it counting from then on.
+//
+// Attachments (screenshots, the write-up that earned the points) go in
+// evidence_dir/bonus/<grant id>, and are shown to the team along with the note.
+
+var BonusFileMgr = FSContentManager{
+	maxSize: 32 << 20, // Accept up to 32MB files
+	mode:    0644,
+	pathBuilder: func(r *http.Request) string {
+		return bonusDir(getCtxIdParam(r))
+	},
+}
+
+func bonusDir(grantID int) string {
+	return filepath.Join(appCfg.Server.EvidenceDir, "bonus", strconv.Itoa(grantID))
+}
+
+// bonusListing is a grant, with its attachments.
+type bonusListing struct {
+	models.OtherPointsView
+	Files []FileInfo
+}
+
+func bonusListings(bonuses []models.OtherPointsView) []bonusListing {
+	listings := make([]bonusListing, len(bonuses))
+	for i, b := range bonuses {
+		listings[i] = bonusListing{OtherPointsView: b, Files: listFiles(bonusDir(b.ID))}
+	}
+	return listings
+}
+
+type BonusPointsRequest struct {
+	*models.OtherPoints
+	TeamIDs []int `json:"teams"`
+}
+
+func GrantBonusPoints(w http.ResponseWriter, r *http.Request) {
+	batch := &BonusPointsRequest{}
+	if err := render.Decode(r, batch); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	} else if batch.OtherPoints == nil {
+		render.Render(w, r, ErrInvalidBecause("missing required 'bonus points' fields"))
+		return
+	}
+	if batch.Category != "" {
+		if _, err := models.ScoreCategoryByName(db, batch.Category); err == pgx.ErrNoRows {
+			render.Render(w, r, ErrInvalidBecause(fmt.Sprintf("no such score category: %q", batch.Category)))
+			return
+		} else if err != nil {
+			render.Render(w, r, ErrInternal(err))
+			return
+		}
+	}
+
+	granter := getCtxTeam(r)
+	now := time.Now()
+	cnt := len(batch.TeamIDs)
+	bonus := make(models.OtherPointsSlice, cnt, cnt)
+	for idx, teamID := range batch.TeamIDs {
+		bonus[idx] = models.OtherPoints{
+			CreatedAt: now,
+			TeamID:    teamID,
+			Points:    batch.Points,
+			Reason:    batch.Reason,
+			Category:  batch.Category,
+			Kind:      batch.Kind,
+			GranterID: &granter.ID,
+			Note:      batch.Note,
+		}
+	}
+	if err := bonus.Insert(db); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	ids := make([]int, cnt)
+	for idx := range bonus {
+		ids[idx] = bonus[idx].ID
+	}
+	CaptFlagsLogger.WithFields(logrus.Fields{
+		"teams":    batch.TeamIDs,
+		"grants":   ids,
+		"reason":   batch.Reason,
+		"points":   batch.Points,
+		"category": batch.Category,
+		"kind":     batch.Kind,
+		"granter":  granter.Name,
+	}).Infoln("Bonus awarded!")
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, ids)
+}
+
+// RevokeBonusPoints takes back a grant. It stays listed, marked as revoked.
+func RevokeBonusPoints(w http.ResponseWriter, r *http.Request) {
+	id, revoker := getCtxIdParam(r), getCtxTeam(r)
+	if err := models.RevokeBonusPoints(db, id, revoker.ID); err == models.ErrRevokeCompromise {
+		render.Render(w, r, ErrInvalidBecause(err.Error()))
+		return
+	} else if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	}
+
+	CaptFlagsLogger.WithFields(logrus.Fields{"grant": id, "revoker": revoker.Name}).Infoln("Bonus revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetBonusPoints(w http.ResponseWriter, r *http.Request) {
+	bonus, err := models.AllBonusPoints(db)
+	ApiQuery(w, r, bonus, err)
+}
+
+// GetBonusAttachment serves one of a grant's attachments, to the staff who give out
+// bonuses, and the team it was given to.
+func GetBonusAttachment(w http.ResponseWriter, r *http.Request) {
+	op, err := models.BonusPointsByID(db, getCtxIdParam(r))
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	}
+	t := getCtxTeam(r)
+	if t.ID != op.TeamID && !hasPermission(t, models.PermGrantBonus) {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	BonusFileMgr.GetFile(w, r)
+}
@@ -0,0 +1,469 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+/* Check Runner API, used by `cyboard runner` */
+
+// runnerClockSkew is how far in the future a runner's results may be timestamped.
+const runnerClockSkew = time.Minute
+
+// runnerRoundGap is how far apart a check's scored results must be, to count as separate
+// rounds: the check interval, less the check timeout. A timeout as long as the interval
+// would leave no gap at all, so it's never less than half the interval.
+func runnerRoundGap(interval, timeout time.Duration) time.Duration {
+	if gap := interval - timeout; gap > interval/2 {
+		return gap
+	}
+	return interval / 2
+}
+
+// runnerStaleIntervals is how many check intervals a runner can go without checking in,
+// before it's flagged on the admin page, and the central monitor runs its scored checks.
+const runnerStaleIntervals = 3
+
+// RequireCheckRunner lets through requests from an enabled check runner, identified by
+// the bearer token in the `Authorization` header, and marks the runner as seen.
+func RequireCheckRunner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "Bearer "
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, prefix) || len(auth) == len(prefix) {
+			render.Render(w, r, ErrUnauthorized)
+			return
+		}
+
+		// Runner tokens are made & kept just like password reset tokens
+		runner, err := models.CheckRunnerByToken(db, hashResetToken(auth[len(prefix):]))
+		if err == pgx.ErrNoRows {
+			render.Render(w, r, ErrUnauthorized)
+			return
+		} else if err != nil {
+			render.Render(w, r, ErrInternal(errors.WithMessage(err, "check runner by token")))
+			return
+		}
+
+		if err = runner.Seen(db); err != nil {
+			Logger.WithError(err).WithField("runner", runner.Name).Error("failed to mark check runner as seen")
+		}
+		next.ServeHTTP(w, r.WithContext(saveCtxCheckRunner(r, runner)))
+	})
+}
+
+// RunnerChecksResponse tells a check runner which checks to run, and how.
+type RunnerChecksResponse struct {
+	Runner          string                      `json:"runner"`
+	Checks          []models.MonitorTeamService `json:"checks"`
+	AddressTemplate string                      `json:"address_template"`
+	Intervals       time.Duration               `json:"intervals"`
+	Timeout         time.Duration               `json:"timeout"`
+
+	// Underway is unset before the event, during breaks, and after it's over,
+	// when no checks should run. Over is set once the event has ended.
+	Underway bool `json:"underway"`
+	Over     bool `json:"over"`
+}
+
+// GetRunnerChecks sends a check runner its assigned checks, with the service monitor's settings.
+func GetRunnerChecks(w http.ResponseWriter, r *http.Request) {
+	runner := getCtxCheckRunner(r)
+	checks, err := models.RunnerTeamsAndServices(db, runner.ID)
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	render.JSON(w, r, RunnerChecksResponse{
+		Runner:          runner.Name,
+		Checks:          checks,
+		AddressTemplate: appCfg.ServiceMonitor.TeamAddressTemplate(),
+		Intervals:       appCfg.ServiceMonitor.Intervals,
+		Timeout:         appCfg.ServiceMonitor.Timeout,
+		Underway:        appCfg.Event.Underway(now),
+		Over:            !now.Before(appCfg.Event.End),
+	})
+}
+
+// RunnerResultsRequest is a batch of check results from a runner.
+type RunnerResultsRequest struct {
+	Results []models.ServiceCheck `json:"results"`
+}
+
+func (rr *RunnerResultsRequest) Bind(r *http.Request) error {
+	if rr.Results == nil {
+		return errors.New(`missing required 'results' field`)
+	}
+	for i, res := range rr.Results {
+		if res.Status.String() == "" {
+			return errors.Errorf("result #%d has no status", i)
+		}
+	}
+	return nil
+}
+
+// RunnerResultsResponse counts what became of a runner's results.
+// Duplicates are scored results for a check that was already scored that round.
+type RunnerResultsResponse struct {
+	Scored       int `json:"scored"`
+	CrossChecked int `json:"cross_checked"`
+	Ignored      int `json:"ignored"`
+	Duplicates   int `json:"duplicates"`
+}
+
+// sortRunnerResults splits a runner's results into the ones that are scored, and the ones
+// kept to cross-check the scored results against. Results for checks the runner isn't
+// assigned, from outside the event, or older than `maxAge` are ignored, so a runner
+// can't backfill rounds it missed.
+func sortRunnerResults(runnerID int, results []models.ServiceCheck, assigned []models.RunnerAssignment,
+	event *EventSettings, now time.Time, maxAge time.Duration) (models.ServiceCheckSlice, models.RunnerCheckSlice, int) {
+
+	type teamService struct{ team, service int }
+	scores := make(map[teamService]bool, len(assigned))
+	for _, a := range assigned {
+		scores[teamService{a.TeamID, a.ServiceID}] = a.Scores
+	}
+
+	scored, crossChecks, ignored := models.ServiceCheckSlice{}, models.RunnerCheckSlice{}, 0
+	for _, res := range results {
+		score, ok := scores[teamService{res.TeamID, res.ServiceID}]
+		switch {
+		case !ok, !event.Underway(res.CreatedAt), res.CreatedAt.After(now.Add(runnerClockSkew)),
+			res.CreatedAt.Before(now.Add(-maxAge)):
+			ignored++
+		case score:
+			scored = append(scored, res)
+		default:
+			crossChecks = append(crossChecks, models.RunnerCheck{
+				CreatedAt: res.CreatedAt,
+				RunnerID:  runnerID,
+				TeamID:    res.TeamID,
+				ServiceID: res.ServiceID,
+				Status:    res.Status,
+				ExitCode:  res.ExitCode,
+			})
+		}
+	}
+	return scored, crossChecks, ignored
+}
+
+// SubmitRunnerResults saves a batch of results from a check runner. The results of checks
+// the runner scores are saved like the central service monitor's, once per check each round,
+// and the rest are kept for cross-checking. Results more than a round old are ignored.
+func SubmitRunnerResults(w http.ResponseWriter, r *http.Request) {
+	runner := getCtxCheckRunner(r)
+	req := &RunnerResultsRequest{}
+	if err := render.Bind(r, req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	interval := appCfg.ServiceMonitor.Intervals
+	assigned, err := models.CheckRunnerAssignments(db, runner.ID, runnerStaleIntervals*interval)
+	if err != nil {
+		render.Render(w, r, ErrInternal(errors.WithMessage(err, "check runner assignments")))
+		return
+	}
+
+	scored, crossChecks, ignored := sortRunnerResults(runner.ID, req.Results, assigned, &appCfg.Event,
+		time.Now(), interval+runnerClockSkew)
+	if ignored > 0 {
+		Logger.WithFields(logrus.Fields{"runner": runner.Name, "ignored": ignored}).
+			Warn("Check runner sent results for checks it isn't assigned, from outside the event, or too long ago")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	defer tx.Rollback()
+
+	saved := 0
+	if len(scored) > 0 {
+		gap := runnerRoundGap(interval, appCfg.ServiceMonitor.Timeout)
+		if saved, err = scored.InsertOncePerRound(tx, gap); err != nil {
+			render.Render(w, r, ErrInternal(errors.WithMessage(err, "insert scored runner results")))
+			return
+		}
+	}
+	if len(crossChecks) > 0 {
+		if err = crossChecks.Insert(tx); err != nil {
+			render.Render(w, r, ErrInternal(errors.WithMessage(err, "insert runner cross-checks")))
+			return
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	render.JSON(w, r, RunnerResultsResponse{Scored: saved, CrossChecked: len(crossChecks), Ignored: ignored,
+		Duplicates: len(scored) - saved})
+}
+
+/* Check Runners Admin API */
+
+type CheckRunnerRequest struct {
+	*models.CheckRunner
+}
+
+func (cr *CheckRunnerRequest) Bind(r *http.Request) error {
+	if cr.CheckRunner == nil {
+		return errors.New(`missing required 'check runner' fields`)
+	}
+	cr.Name = strings.TrimSpace(cr.Name)
+	return cr.Validate()
+}
+
+// CheckRunnerTokenResponse is a runner, with its new token. The token can't be
+// looked up again later, only replaced.
+type CheckRunnerTokenResponse struct {
+	*models.CheckRunner
+	Token string `json:"token"`
+}
+
+// createCheckRunner saves a new runner, with a new token, returning the token.
+func createCheckRunner(runner *models.CheckRunner) (string, error) {
+	token, hash, err := newResetToken()
+	if err != nil {
+		return "", err
+	}
+	runner.TokenHash = hash
+	return token, runner.Insert(db)
+}
+
+// rotateCheckRunnerToken gives a runner a new token, and returns it.
+// The runner's old token stops working.
+func rotateCheckRunnerToken(runner *models.CheckRunner) (string, error) {
+	token, hash, err := newResetToken()
+	if err != nil {
+		return "", err
+	}
+	return token, runner.SetToken(db, hash)
+}
+
+// crossCheckSince is how far back the runners' results are compared with the scored ones.
+func crossCheckSince() time.Time {
+	return time.Now().Add(-4 * appCfg.ServiceMonitor.Intervals)
+}
+
+func GetCheckRunners(w http.ResponseWriter, r *http.Request) {
+	runners, err := models.AllCheckRunners(db)
+	ApiQuery(w, r, runners, err)
+}
+
+func AddCheckRunner(w http.ResponseWriter, r *http.Request) {
+	req := &CheckRunnerRequest{}
+	if err := render.Bind(r, req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	token, err := createCheckRunner(req.CheckRunner)
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, CheckRunnerTokenResponse{CheckRunner: req.CheckRunner, Token: token})
+}
+
+func UpdateCheckRunner(w http.ResponseWriter, r *http.Request) {
+	ApiUpdate(w, r, &CheckRunnerRequest{})
+}
+
+func DeleteCheckRunner(w http.ResponseWriter, r *http.Request) {
+	ApiDelete(w, r, &models.CheckRunner{})
+}
+
+// RotateCheckRunnerToken replaces a runner's token, responding with the new one.
+func RotateCheckRunnerToken(w http.ResponseWriter, r *http.Request) {
+	runner, err := models.CheckRunnerByID(db, getCtxIdParam(r))
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	}
+
+	token, err := rotateCheckRunnerToken(runner)
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	render.JSON(w, r, CheckRunnerTokenResponse{CheckRunner: runner, Token: token})
+}
+
+// GetRunnerDisagreements lists the checks where a runner's latest result differs from the scored one.
+func GetRunnerDisagreements(w http.ResponseWriter, r *http.Request) {
+	xs, err := models.RunnerDisagreements(db, crossCheckSince())
+	ApiQuery(w, r, xs, err)
+}
+
+/* Admin Page */
+
+// checkRunnerListing is a runner on the admin page, with its assignment as sets, for the form.
+type checkRunnerListing struct {
+	models.CheckRunner
+	HasTeam    map[int]bool
+	HasService map[int]bool
+	// Stale is set when a runner hasn't been heard from in a few check intervals.
+	// The central monitor runs a stale scoring runner's checks, until it's back.
+	Stale bool
+}
+
+func checkRunnerListings(runners []models.CheckRunner, now time.Time, interval time.Duration) []checkRunnerListing {
+	ls := make([]checkRunnerListing, len(runners))
+	for i, cr := range runners {
+		l := checkRunnerListing{CheckRunner: cr, HasTeam: map[int]bool{}, HasService: map[int]bool{}}
+		for _, id := range cr.TeamIDs {
+			l.HasTeam[int(id)] = true
+		}
+		for _, id := range cr.ServiceIDs {
+			l.HasService[int(id)] = true
+		}
+		l.Stale = !cr.Disabled && (cr.LastSeenAt == nil || now.Sub(*cr.LastSeenAt) > runnerStaleIntervals*interval)
+		ls[i] = l
+	}
+	return ls
+}
+
+// checkRunnerFrom reads a runner from the admin page's form.
+func checkRunnerFrom(r *http.Request) (*models.CheckRunner, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	cr := &models.CheckRunner{
+		Name:       strings.TrimSpace(r.FormValue("name")),
+		TeamIDs:    []int32{},
+		ServiceIDs: []int32{},
+		Scoring:    r.FormValue("scoring") != "",
+		Disabled:   r.FormValue("disabled") != "",
+	}
+	for field, ids := range map[string]*[]int32{"team_ids": &cr.TeamIDs, "service_ids": &cr.ServiceIDs} {
+		for _, s := range r.Form[field] {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, errors.Errorf("bad %s: %q", field, s)
+			}
+			*ids = append(*ids, int32(id))
+		}
+	}
+
+	if err := cr.Validate(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// ShowCheckRunnersConfig is where admins set up remote check runners, hand out their
+// tokens, and see where their results disagree with the scored ones.
+func ShowCheckRunnersConfig(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_runners", "Check Runners")
+	renderCheckRunnersConfig(w, page)
+}
+
+func renderCheckRunnersConfig(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	runners, err := models.AllCheckRunners(db)
+	page.checkErr(err, "check runners")
+	page.Data["Runners"] = checkRunnerListings(runners, time.Now(), appCfg.ServiceMonitor.Intervals)
+
+	page.Data["Teams"], err = models.AllBlueteams(db)
+	page.checkErr(err, "blueteams")
+
+	page.Data["Services"], err = models.AllServices(db)
+	page.checkErr(err, "services")
+
+	page.Data["Disagreements"], err = models.RunnerDisagreements(db, crossCheckSince())
+	page.checkErr(err, "runner disagreements")
+
+	renderTemplate(w, page)
+}
+
+// saveCheckRunner adds a new runner, or updates the one in the url, from the admin page's form.
+func saveCheckRunner(w http.ResponseWriter, r *http.Request, update bool) {
+	page := getPage(r, "admin_runners", "Check Runners")
+	page.Data = make(map[string]interface{})
+
+	cr, err := checkRunnerFrom(r)
+	if err != nil {
+		page.Data["Problem"] = err.Error()
+		renderCheckRunnersConfig(w, page)
+		return
+	}
+
+	var token string
+	if update {
+		cr.ID = getCtxIdParam(r)
+		err = cr.Update(db)
+	} else {
+		token, err = createCheckRunner(cr)
+	}
+	if err != nil {
+		page.checkErr(err, "save check runner")
+		renderCheckRunnersConfig(w, page)
+		return
+	}
+
+	Logger.WithFields(logrus.Fields{"admin": page.T.Name, "runner": cr.Name, "scoring": cr.Scoring, "disabled": cr.Disabled}).
+		Info("Check runner saved")
+	page.Data["Saved"] = cr.Name
+	if token != "" {
+		page.Data["Token"] = CheckRunnerTokenResponse{CheckRunner: cr, Token: token}
+	}
+	renderCheckRunnersConfig(w, page)
+}
+
+// AddCheckRunnerFromForm sets up a new runner from the admin page, showing its token.
+func AddCheckRunnerFromForm(w http.ResponseWriter, r *http.Request) {
+	saveCheckRunner(w, r, false)
+}
+
+// UpdateCheckRunnerFromForm changes a runner's name or assignment from the admin page.
+func UpdateCheckRunnerFromForm(w http.ResponseWriter, r *http.Request) {
+	saveCheckRunner(w, r, true)
+}
+
+// RotateCheckRunnerTokenFromForm gives a runner a new token from the admin page, showing it.
+func RotateCheckRunnerTokenFromForm(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_runners", "Check Runners")
+	page.Data = make(map[string]interface{})
+
+	cr, err := models.CheckRunnerByID(db, getCtxIdParam(r))
+	if err == nil {
+		var token string
+		if token, err = rotateCheckRunnerToken(cr); err == nil {
+			Logger.WithFields(logrus.Fields{"admin": page.T.Name, "runner": cr.Name}).Info("Check runner token replaced")
+			page.Data["Token"] = CheckRunnerTokenResponse{CheckRunner: cr, Token: token}
+		}
+	}
+	page.checkErr(err, "rotate check runner token")
+	renderCheckRunnersConfig(w, page)
+}
+
+// DeleteCheckRunnerFromForm removes a runner from the admin page.
+func DeleteCheckRunnerFromForm(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_runners", "Check Runners")
+	page.Data = make(map[string]interface{})
+
+	cr := &models.CheckRunner{ID: getCtxIdParam(r)}
+	if err := cr.Delete(db); err != nil {
+		page.checkErr(err, "delete check runner")
+	} else {
+		Logger.WithFields(logrus.Fields{"admin": page.T.Name, "runner": cr.ID}).Info("Check runner deleted")
+	}
+	renderCheckRunnersConfig(w, page)
+}
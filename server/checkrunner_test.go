@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sortRunnerResults(t *testing.T) {
+	start := time.Date(2017, 11, 4, 9, 0, 0, 0, time.UTC)
+	event := &EventSettings{
+		Start:  start,
+		End:    start.Add(10 * time.Hour),
+		Breaks: []ScheduledBreak{{StartsAt: start.Add(3 * time.Hour), GoesFor: time.Hour}},
+	}
+	now := start.Add(time.Hour)
+	assigned := []models.RunnerAssignment{
+		{TeamID: 1, ServiceID: 1, Scores: true},
+		{TeamID: 2, ServiceID: 1, Scores: false},
+	}
+
+	result := func(team int, at time.Time) models.ServiceCheck {
+		return models.ServiceCheck{CreatedAt: at, TeamID: team, ServiceID: 1, Status: models.ExitStatusFail, ExitCode: 2}
+	}
+	results := []models.ServiceCheck{
+		result(1, now),
+		result(2, now),
+		result(3, now),                     // not assigned
+		result(1, start.Add(-time.Minute)), // before the event
+		result(1, start.Add(3*time.Hour+time.Minute)),   // on break
+		result(1, now.Add(runnerClockSkew+time.Second)), // from the future
+		result(1, now.Add(runnerClockSkew/2)),           // a bit fast
+		result(1, now.Add(-2*time.Minute)),              // backdated, beyond a round
+		result(2, now.Add(-2*time.Minute)),              // backdated cross-check
+		result(1, now.Add(-30*time.Second)),             // sent late, within the round
+	}
+
+	scored, crossChecks, ignored := sortRunnerResults(7, results, assigned, event, now, time.Minute)
+	assert.Equal(t, models.ServiceCheckSlice{results[0], results[6], results[9]}, scored)
+	assert.Equal(t, models.RunnerCheckSlice{{CreatedAt: now, RunnerID: 7, TeamID: 2, ServiceID: 1,
+		Status: models.ExitStatusFail, ExitCode: 2}}, crossChecks)
+	assert.Equal(t, 6, ignored)
+}
+
+func Test_runnerRoundGap(t *testing.T) {
+	assert.Equal(t, 50*time.Second, runnerRoundGap(time.Minute, 10*time.Second))
+	assert.Equal(t, 30*time.Second, runnerRoundGap(time.Minute, 45*time.Second), "Never less than half a round")
+	assert.Equal(t, 30*time.Second, runnerRoundGap(time.Minute, 2*time.Minute))
+}
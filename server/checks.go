@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -52,15 +54,98 @@ type checkArgKey struct {
 	arg    string
 }
 
-func prepareChecks(teamsAndServices []models.MonitorTeamService, scriptsDir, baseIP string) []Check {
+// hostArg matches the `{HOST:<role>}` and `{HOST_URL:<role>}` placeholders in check args.
+var hostArg = regexp.MustCompile(`\{HOST(_URL)?:([^{}]*)\}`)
+
+// urlHost brackets an IPv6 address, so it can go in a url or before a ":port".
+// IPv4 addresses and hostnames are left as is.
+func urlHost(addr string) string {
+	if strings.Contains(addr, ":") && net.ParseIP(addr) != nil {
+		return "[" + addr + "]"
+	}
+	return addr
+}
+
+// bareHostArgs fills in the service's host role for a bare `{HOST}` or `{HOST_URL}`.
+func bareHostArgs(s, role string) string {
+	s = strings.Replace(s, "{HOST}", "{HOST:"+role+"}", -1)
+	return strings.Replace(s, "{HOST_URL}", "{HOST_URL:"+role+"}", -1)
+}
+
+// expandCheckArg substitutes a team's IP, hosts, Name, and ID into a check argument,
+// using simple string replacement. A bare `{HOST}` is the host in the service's host role.
+// The `_URL` forms, `{IP_URL}` and `{HOST_URL}`, bracket IPv6 addresses for use in urls.
+// Returns an error if the team doesn't have a host the argument asks for, or the
+// argument uses the team's address, and the template doesn't give it a valid one.
+func expandCheckArg(arg string, tas *models.MonitorTeamService, addrTemplate string) (string, error) {
+	teamIDstr := strconv.FormatInt(int64(tas.Team.ID), 10)
+	teamSigIPOctet := strconv.FormatInt(int64(tas.Team.IP), 10)
+	// addrTemplate is from config.toml, and looks like "10.{TEAM_ID}.1.5" or
+	// "192.168.0.{TEAM_4TH_OCTET}", which get filled in with the team's id & octet
+	// from the `cyboard.team` table, giving the full ip. E.G. "192.168.0.7"
+	ip := resolveTeamAddress(addrTemplate, tas.Team.ID, tas.Team.IP)
+	if (strings.Contains(arg, "{IP}") || strings.Contains(arg, "{IP_URL}")) && net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("the address template gives the team %q, which is not an IP address", ip)
+	}
+	s := strings.Replace(arg, "{IP}", ip, -1)
+	s = strings.Replace(s, "{IP_URL}", urlHost(ip), -1)
+	s = strings.Replace(s, "{TEAM_4TH_OCTET}", teamSigIPOctet, -1)
+	s = strings.Replace(s, "{TEAM_NAME}", tas.Team.Name, -1)
+	s = strings.Replace(s, "{TEAM_ID}", teamIDstr, -1)
+
+	if strings.Contains(s, "{HOST}") || strings.Contains(s, "{HOST_URL}") {
+		if tas.Service.HostRole == nil {
+			return "", fmt.Errorf("arg %q uses {HOST}, but the service has no host role", arg)
+		}
+		s = bareHostArgs(s, *tas.Service.HostRole)
+	}
+
+	var missing string
+	s = hostArg.ReplaceAllStringFunc(s, func(m string) string {
+		sm := hostArg.FindStringSubmatch(m)
+		addr, ok := tas.Team.Hosts[sm[2]]
+		if !ok && missing == "" {
+			missing = sm[2]
+		}
+		if sm[1] != "" {
+			return urlHost(addr)
+		}
+		return addr
+	})
+	if missing != "" {
+		return "", fmt.Errorf("team has no %q host", missing)
+	}
+	return s, nil
+}
+
+func prepareChecks(teamsAndServices []models.MonitorTeamService, scriptsDir, addrTemplate string) []Check {
 	checks := []Check{}
 
 	// argCache saves a few cpu cycles doing the same argument variable substitution
 	argCache := map[checkArgKey]string{}
+	// checkedAddrs has each team whose address was already validated
+	checkedAddrs := map[int]bool{}
 
+nextCheck:
 	for i := range teamsAndServices {
 		tas := &teamsAndServices[i]
 
+		if !checkedAddrs[tas.Team.ID] {
+			checkedAddrs[tas.Team.ID] = true
+			if addr := resolveTeamAddress(addrTemplate, tas.Team.ID, tas.Team.IP); net.ParseIP(addr) == nil {
+				Logger.Errorf("team %q: the address template %q gives %q, which is not an IP address. "+
+					"Checks using {IP} will skip this team", tas.Team.Name, addrTemplate, addr)
+			}
+		}
+
+		if role := tas.Service.HostRole; role != nil {
+			if _, ok := tas.Team.Hosts[*role]; !ok {
+				Logger.Warnf("check.%d (name=%q): SKIPPING team %q, which has no %q host",
+					tas.Service.ID, tas.Service.Name, tas.Team.Name, *role)
+				continue
+			}
+		}
+
 		path := filepath.Join(scriptsDir, tas.Service.Script)
 		script, err := getScript(path)
 		if err != nil {
@@ -70,24 +155,24 @@ func prepareChecks(teamsAndServices []models.MonitorTeamService, scriptsDir, bas
 		}
 		script.Dir = scriptsDir
 
-		// Substitute args with team's IP, Name, and ID using simple string replace on each argument
+		// Substitute args with team's IP, hosts, Name, and ID
 		script.Args = make([]string, 1, len(tas.Service.Args)+1)
 		script.Args[0] = script.Path
 		for _, arg := range tas.Service.Args {
 			cacheKey := checkArgKey{teamID: tas.Team.ID, arg: arg}
+			if tas.Service.HostRole != nil {
+				// A bare {HOST} depends on the service, not just the team
+				cacheKey.arg = bareHostArgs(arg, *tas.Service.HostRole)
+			}
 			s, ok := argCache[cacheKey]
 			if !ok {
 				s = arg
 				if strings.IndexByte(s, '{') != -1 {
-					teamIDstr := strconv.FormatInt(int64(tas.Team.ID), 10)
-					teamSigIPOctet := strconv.FormatInt(int64(tas.Team.IP), 10)
-					// baseIP is a config.toml option that looks like "192.168.0." which
-					// gets the last octet from the `cyboard.team` table, giving the
-					// full ip. E.G. "192.168.0.7"
-					s = strings.Replace(s, "{IP}", baseIP+teamSigIPOctet, -1)
-					s = strings.Replace(s, "{TEAM_4TH_OCTET}", teamSigIPOctet, -1)
-					s = strings.Replace(s, "{TEAM_NAME}", tas.Team.Name, -1)
-					s = strings.Replace(s, "{TEAM_ID}", teamIDstr, -1)
+					if s, err = expandCheckArg(arg, tas, addrTemplate); err != nil {
+						Logger.Warnf("check.%d (name=%q): SKIPPING team %q: %v",
+							tas.Service.ID, tas.Service.Name, tas.Team.Name, err)
+						continue nextCheck
+					}
 				}
 				argCache[cacheKey] = s
 			}
@@ -203,6 +288,12 @@ type Monitor struct {
 	Checks    []Check
 	Unstarted []Check
 
+	// runnerStaleAfter is how long a scoring check runner can go without checking in,
+	// before the monitor runs its checks instead. liveRunners are the scoring runners
+	// that were checking in as of the last reload.
+	runnerStaleAfter time.Duration
+	liveRunners      []int
+
 	breaktimeC chan time.Duration
 	done       chan struct{}
 
@@ -210,12 +301,13 @@ type Monitor struct {
 	*sync.Mutex
 }
 
-func NewMonitor() *Monitor {
+func NewMonitor(runnerStaleAfter time.Duration) *Monitor {
 	return &Monitor{
-		Mutex:      new(sync.Mutex),
-		breaktimeC: make(chan time.Duration),
-		done:       make(chan struct{}),
-		rando:      rand.New(rand.NewSource(time.Now().UTC().UnixNano())),
+		Mutex:            new(sync.Mutex),
+		runnerStaleAfter: runnerStaleAfter,
+		breaktimeC:       make(chan time.Duration),
+		done:             make(chan struct{}),
+		rando:            rand.New(rand.NewSource(time.Now().UTC().UnixNano())),
 	}
 }
 
@@ -223,17 +315,25 @@ func (m *Monitor) Stop() {
 	close(m.done)
 }
 
-func (m *Monitor) ReloadServicesAndTeams(checksDir, baseIP string) {
+func (m *Monitor) ReloadServicesAndTeams(checksDir, addrTemplate string) {
 	m.Lock()
 	defer m.Unlock()
 
-	teamsAndServices, err := models.MonitorTeamsAndServices(db)
-	if err != nil {
-		err = monitorRetryWithBackoff(func() error {
-			var err error
-			teamsAndServices, err = models.MonitorTeamsAndServices(db)
+	var (
+		liveRunners      []int
+		teamsAndServices []models.MonitorTeamService
+	)
+	load := func() error {
+		var err error
+		if liveRunners, err = models.LiveScoringRunners(db, m.runnerStaleAfter); err != nil {
 			return err
-		})
+		}
+		teamsAndServices, err = models.MonitorTeamsAndServices(db, m.runnerStaleAfter)
+		return err
+	}
+	err := load()
+	if err != nil {
+		err = monitorRetryWithBackoff(load)
 		if err != nil {
 			// Postgres is busted, someone is going to have to look at this, resolve it by hand
 			Logger.WithError(err).Fatal("failed to get teams & services for service monitor")
@@ -241,7 +341,8 @@ func (m *Monitor) ReloadServicesAndTeams(checksDir, baseIP string) {
 		}
 	}
 
-	checks := prepareChecks(teamsAndServices, checksDir, baseIP)
+	m.liveRunners = liveRunners
+	checks := prepareChecks(teamsAndServices, checksDir, addrTemplate)
 	// Realloc check slices. Anticipate most checks will be started, so alloc accordingly.
 	m.Checks = make([]Check, 0, len(checks))
 	m.Unstarted = make([]Check, 0)
@@ -260,6 +361,48 @@ func (m *Monitor) ReloadServicesAndTeams(checksDir, baseIP string) {
 	}
 }
 
+// runnersChanged checks whether any scoring check runner has stopped, or started,
+// checking in since the last reload, meaning its checks should move to another scoring
+// runner or the monitor, or be handed back. Runners checking in doesn't notify the monitor, so this is polled.
+func (m *Monitor) runnersChanged() bool {
+	live, err := models.LiveScoringRunners(db, m.runnerStaleAfter)
+	if err != nil {
+		Logger.WithError(err).Error("failed to get the live check runners")
+		return false
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	stale, back := []int{}, []int{}
+	for _, id := range m.liveRunners {
+		if !containsInt(live, id) {
+			stale = append(stale, id)
+		}
+	}
+	for _, id := range live {
+		if !containsInt(m.liveRunners, id) {
+			back = append(back, id)
+		}
+	}
+	if len(stale) > 0 {
+		Logger.WithField("runners", stale).Warnf("Scoring check runners haven't checked in for %v, "+
+			"their checks go to the next scoring runner, or the monitor", m.runnerStaleAfter)
+	}
+	if len(back) > 0 {
+		Logger.WithField("runners", back).Info("Scoring check runners are checking in, handing their checks back")
+	}
+	return len(stale) > 0 || len(back) > 0
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *Monitor) Run(event *EventSettings, srvmon *ServiceMonitorSettings) {
 	log := Logger.WithField("thread", "monitor_checks")
 
@@ -308,6 +451,10 @@ func (m *Monitor) Run(event *EventSettings, srvmon *ServiceMonitorSettings) {
 		jitter := time.Duration(m.rando.Int63n(freeTime))
 		<-time.After(jitter)
 
+		if m.runnersChanged() {
+			m.ReloadServicesAndTeams(srvmon.ChecksDir, srvmon.TeamAddressTemplate())
+		}
+
 		// m.Checks needs protection from concurrent use.
 		// The PG Listen thread updates them whenever the DB changes.
 		m.Lock()
@@ -370,7 +517,7 @@ func (m *Monitor) Run(event *EventSettings, srvmon *ServiceMonitorSettings) {
 	checkTicker.Stop()
 }
 
-func (m *Monitor) ListenForConfigUpdatesFromPG(ctx context.Context, checksDir, baseIP string) {
+func (m *Monitor) ListenForConfigUpdatesFromPG(ctx context.Context, checksDir, addrTemplate string) {
 	log := Logger.WithField("thread", "monitor_pg-listener")
 
 	conn, err := rawDB.Acquire()
@@ -398,7 +545,7 @@ func (m *Monitor) ListenForConfigUpdatesFromPG(ctx context.Context, checksDir, b
 
 		log.WithField("notif", notification).Debug("update received")
 
-		m.ReloadServicesAndTeams(checksDir, baseIP)
+		m.ReloadServicesAndTeams(checksDir, addrTemplate)
 		log.Info("Settings reloaded!")
 	}
 }
@@ -456,7 +603,7 @@ func ChecksRun(checkCfg *Configuration) {
 	signal.Notify(sigtermC, os.Interrupt)
 
 	checksDir := checkCfg.ServiceMonitor.ChecksDir
-	baseIP := checkCfg.ServiceMonitor.BaseIP
+	addrTemplate := checkCfg.ServiceMonitor.TeamAddressTemplate()
 	event := checkCfg.Event
 
 	/* lifecycle cases to handle:
@@ -468,20 +615,21 @@ func ChecksRun(checkCfg *Configuration) {
 	- startup after the event is over -> immediately stop
 	- end of event -> cancel everything and clean up
 	- update to db -> reload teams & services
+	- scoring check runner stops (or starts) checking in -> take over (or hand back) its checks
 	- database errors -> retry a few times, then just straight die
 	- magically dying goroutines -> cosmic anomaly, lose hope
 	*/
-	monitor := NewMonitor()
+	monitor := NewMonitor(runnerStaleIntervals * checkCfg.ServiceMonitor.Intervals)
 	defer monitor.Stop()
 
-	monitor.ReloadServicesAndTeams(checksDir, baseIP)
+	monitor.ReloadServicesAndTeams(checksDir, addrTemplate)
 
 	// Create control ctx, to let the pg-listener goroutine be stopped on demand.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Separate thread listens for updates from the DB and automatically reloads.
-	go monitor.ListenForConfigUpdatesFromPG(ctx, checksDir, baseIP)
+	go monitor.ListenForConfigUpdatesFromPG(ctx, checksDir, addrTemplate)
 
 	if time.Now().Before(event.Start) {
 		Logger.Infof("Waiting until the event starts [%v]...",
@@ -1,5 +1,12 @@
 package server
 
+import (
+	"testing"
+
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+)
+
 /*
 func init() {
 	SetupCheckServiceLogger(&LogSettings{Level: "warn", Stdout: true})
@@ -75,3 +82,64 @@ func Test_runCmd(t *testing.T) {
 	}
 }
 */
+
+func Test_expandCheckArg(t *testing.T) {
+	web := "web"
+	tas := &models.MonitorTeamService{}
+	tas.Team.ID, tas.Team.Name, tas.Team.IP = 1, "team1", 7
+	tas.Team.Hosts = map[string]string{"web": "10.0.1.5", "mail": "fd00:1::25"}
+
+	cases := []struct {
+		arg, expected string
+		hostRole      *string
+	}{
+		{"{IP}", "10.0.0.7", nil},
+		{"--team={TEAM_NAME}:{TEAM_ID}", "--team=team1:1", nil},
+		{"{HOST:mail}", "fd00:1::25", nil},
+		{"http://{HOST}/", "http://10.0.1.5/", &web},
+		{"{HOST:web},{HOST:mail}", "10.0.1.5,fd00:1::25", nil},
+		{"{IP_URL}:22", "10.0.0.7:22", nil},
+		{"http://{HOST_URL:mail}:8080/", "http://[fd00:1::25]:8080/", nil},
+		{"http://{HOST_URL}/", "http://10.0.1.5/", &web},
+	}
+	for _, tt := range cases {
+		tas.Service.HostRole = tt.hostRole
+		s, err := expandCheckArg(tt.arg, tas, "10.0.0.{TEAM_4TH_OCTET}")
+		if assert.NoError(t, err, tt.arg) {
+			assert.Equal(t, tt.expected, s)
+		}
+	}
+
+	tas.Service.HostRole = nil
+	_, err := expandCheckArg("{HOST}", tas, "10.0.0.{TEAM_4TH_OCTET}")
+	assert.Error(t, err, "No host role to fill in a bare {HOST}")
+	_, err = expandCheckArg("{HOST:dc}", tas, "10.0.0.{TEAM_4TH_OCTET}")
+	assert.Error(t, err, "team1 has no dc host")
+
+	_, err = expandCheckArg("{HOST_URL}", tas, "10.0.0.{TEAM_4TH_OCTET}")
+	assert.Error(t, err, "No host role to fill in a bare {HOST_URL}")
+
+	s, err := expandCheckArg("http://[{IP}]/", tas, "fd00:{TEAM_ID}::10")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://[fd00:1::10]/", s)
+	}
+	s, err = expandCheckArg("http://{IP_URL}:8080/ {IP}", tas, "fd00:{TEAM_ID}::10")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://[fd00:1::10]:8080/ fd00:1::10", s, "Only the _URL form is bracketed")
+	}
+
+	tas.Team.ID = 300
+	_, err = expandCheckArg("{IP}", tas, "10.{TEAM_ID}.1.5")
+	assert.Error(t, err, "10.300.1.5 is not an address")
+	_, err = expandCheckArg("{IP_URL}", tas, "10.{TEAM_ID}.1.5")
+	assert.Error(t, err)
+	s, err = expandCheckArg("{TEAM_NAME}", tas, "10.{TEAM_ID}.1.5")
+	assert.NoError(t, err, "Args that don't use the team's address still work")
+	tas.Team.ID = 1
+
+	tas.Team.Hosts["mail"] = "mail.team1.example"
+	s, err = expandCheckArg("{HOST_URL:mail}:25", tas, "10.0.0.{TEAM_4TH_OCTET}")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "mail.team1.example:25", s, "Hostnames are never bracketed")
+	}
+}
@@ -0,0 +1,255 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Red Team Compromise Reports
+//
+// The red team files a report for each blue team box it gets into, with evidence.
+// The white team approves (docking the blue team points) or rejects each one.
+// Blue teams see the approved reports against them on their dashboard.
+//
+// Evidence files (screenshots, loot) go in evidence_dir/<report id>.
+
+var EvidenceFileMgr = FSContentManager{
+	maxSize: 32 << 20, // Accept up to 32MB files
+	mode:    0644,
+	pathBuilder: func(r *http.Request) string {
+		return evidenceDir(getCtxIdParam(r))
+	},
+}
+
+func evidenceDir(reportID int) string {
+	return filepath.Join(appCfg.Server.EvidenceDir, strconv.Itoa(reportID))
+}
+
+// evidenceFiles lists the files attached to a report.
+func evidenceFiles(reportID int) []FileInfo {
+	return listFiles(evidenceDir(reportID))
+}
+
+// canSeeCompromise checks if the team may see a report, and its evidence: the white team,
+// whoever filed it, or the blue team it's against, once it's been approved.
+func canSeeCompromise(t *models.Team, cr *models.CompromiseReport) bool {
+	switch {
+	case t == nil:
+		return false
+	case hasPermission(t, models.PermReviewCompromises):
+		return true
+	case cr.ReporterID != nil && *cr.ReporterID == t.ID:
+		return true
+	default:
+		return cr.TeamID == t.ID && cr.Status() == "approved"
+	}
+}
+
+// GetCompromiseEvidence serves one of a report's evidence files, to those allowed to see it.
+func GetCompromiseEvidence(w http.ResponseWriter, r *http.Request) {
+	cr, err := models.CompromiseReportByID(db, getCtxIdParam(r))
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	} else if !canSeeCompromise(getCtxTeam(r), cr) {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	EvidenceFileMgr.GetFile(w, r)
+}
+
+// GetTeamCompromiseReports lists the approved reports against the logged in blue team.
+func GetTeamCompromiseReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := models.TeamCompromiseReports(db, getCtxTeam(r).ID)
+	ApiQuery(w, r, reports, err)
+}
+
+// GetCompromiseReports lists every report, for the white team's review queue.
+func GetCompromiseReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := models.AllCompromiseReports(db)
+	ApiQuery(w, r, reports, err)
+}
+
+/* Red Team Page */
+
+// ShowRedteamPage is where the red team files compromise reports, and follows up on them.
+func ShowRedteamPage(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_redteam", "Red Team")
+	renderRedteamPage(w, page)
+}
+
+func renderRedteamPage(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	var err error
+	page.Data["Teams"], err = models.AllBlueteams(db)
+	page.checkErr(err, "all blueteams")
+	page.Data["Services"], err = models.AllServices(db)
+	page.checkErr(err, "all services")
+	page.Data["Severities"] = models.CompromiseSeverities
+
+	reports, err := models.ReporterCompromiseReports(db, page.T.ID)
+	page.checkErr(err, "filed compromise reports")
+	page.Data["Reports"] = withEvidence(reports)
+
+	renderTemplate(w, page)
+}
+
+// compromiseListing is a report as shown on a page, with its evidence files.
+type compromiseListing struct {
+	models.CompromiseReportView
+	Files []FileInfo
+}
+
+func withEvidence(reports []models.CompromiseReportView) []compromiseListing {
+	listings := make([]compromiseListing, len(reports))
+	for i, cr := range reports {
+		listings[i] = compromiseListing{CompromiseReportView: cr, Files: evidenceFiles(cr.ID)}
+	}
+	return listings
+}
+
+// compromiseReportFrom reads a report from the red team's form.
+func compromiseReportFrom(r *http.Request) (*models.CompromiseReport, error) {
+	cr := &models.CompromiseReport{
+		Host:     strings.TrimSpace(r.FormValue("host")),
+		Title:    strings.TrimSpace(r.FormValue("title")),
+		Evidence: strings.TrimSpace(r.FormValue("evidence")),
+	}
+
+	var err error
+	if cr.TeamID, err = strconv.Atoi(r.FormValue("team_id")); err != nil {
+		return nil, errors.New("pick the team that was compromised")
+	}
+	if s := r.FormValue("service_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.New("invalid service")
+		}
+		cr.ServiceID = &id
+	}
+	if err = cr.Severity.UnmarshalText([]byte(r.FormValue("severity"))); err != nil {
+		return nil, err
+	}
+
+	if cr.Title == "" {
+		return nil, errors.New("the report needs a title")
+	} else if cr.Evidence == "" {
+		return nil, errors.New("describe what was done, as evidence")
+	} else if cr.ServiceID == nil && cr.Host == "" {
+		return nil, errors.New("say which service or host was compromised")
+	}
+	return cr, nil
+}
+
+// FileCompromiseReport saves a report from the red team, along with any evidence files
+// uploaded under the "evidence_files" field.
+func FileCompromiseReport(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_redteam", "Red Team")
+	page.Data = make(map[string]interface{})
+
+	if err := r.ParseMultipartForm(EvidenceFileMgr.maxSize); err != nil {
+		page.Data["Problem"] = err.Error()
+		renderRedteamPage(w, page)
+		return
+	}
+	cr, err := compromiseReportFrom(r)
+	if err != nil {
+		page.Data["Problem"] = err.Error()
+		renderRedteamPage(w, page)
+		return
+	}
+	cr.ReporterID = &page.T.ID
+
+	if err = cr.Insert(db); err != nil {
+		page.checkErr(err, "insert compromise report")
+		renderRedteamPage(w, page)
+		return
+	}
+	if fhs := r.MultipartForm.File["evidence_files"]; len(fhs) > 0 {
+		err = EvidenceFileMgr.saveUploads(r, evidenceDir(cr.ID), fhs)
+		page.checkErr(err, "save evidence files")
+	}
+
+	Logger.WithFields(logrus.Fields{
+		"reporter": page.T.Name,
+		"report":   cr.ID,
+		"team_id":  cr.TeamID,
+		"severity": cr.Severity,
+	}).Info("Compromise report filed")
+	page.Data["Filed"] = cr.ID
+	renderRedteamPage(w, page)
+}
+
+/* White Team Page */
+
+// ShowCompromiseQueue lists every report for the white team to review, pending ones first.
+func ShowCompromiseQueue(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_compromises", "Compromise Reports")
+	renderCompromiseQueue(w, page)
+}
+
+func renderCompromiseQueue(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	reports, err := models.AllCompromiseReports(db)
+	page.checkErr(err, "all compromise reports")
+	page.Data["Reports"] = withEvidence(reports)
+
+	renderTemplate(w, page)
+}
+
+// ReviewCompromiseReport approves or rejects a pending report, from the form's "action".
+// Approving needs the points to dock from the blue team.
+func ReviewCompromiseReport(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_compromises", "Compromise Reports")
+	page.Data = make(map[string]interface{})
+
+	id := getCtxIdParam(r)
+	note := strings.TrimSpace(r.FormValue("note"))
+
+	var err error
+	switch action := r.FormValue("action"); action {
+	case "approve":
+		var points float64
+		points, err = strconv.ParseFloat(r.FormValue("points"), 32)
+		if err != nil || points < 0 {
+			page.Data["Problem"] = "Points to deduct must be a number, zero or more."
+			renderCompromiseQueue(w, page)
+			return
+		}
+		err = models.ApproveCompromiseReport(db, id, page.T.ID, float32(points), note)
+	case "reject":
+		err = models.RejectCompromiseReport(db, id, page.T.ID, note)
+	default:
+		page.Data["Problem"] = "Unknown review action: " + action
+		renderCompromiseQueue(w, page)
+		return
+	}
+
+	if err == pgx.ErrNoRows {
+		page.Data["Problem"] = "That report was already reviewed, or doesn't exist."
+	} else if err != nil {
+		page.checkErr(err, "review compromise report")
+	} else {
+		Logger.WithFields(logrus.Fields{
+			"reviewer": page.T.Name,
+			"report":   id,
+			"action":   r.FormValue("action"),
+		}).Info("Compromise report reviewed")
+		page.Data["Reviewed"] = id
+	}
+	renderCompromiseQueue(w, page)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pereztr5/cyboard/server/apptest"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_canSeeCompromise(t *testing.T) {
+	apptest.PrepDatabase(t)
+	resetRolePermissions()
+
+	yes, no, reporterID := true, false, 50
+	report := func(approved *bool) *models.CompromiseReport {
+		return &models.CompromiseReport{TeamID: 1, ReporterID: &reporterID, Approved: approved}
+	}
+	blueteam := &models.Team{ID: 1, Name: "team1", RoleName: models.TeamRoleBlueteam}
+	otherBlueteam := &models.Team{ID: 2, Name: "team2", RoleName: models.TeamRoleBlueteam}
+	reporter := &models.Team{ID: reporterID, Name: "redteam", RoleName: models.TeamRoleRedteam}
+	otherRedteam := &models.Team{ID: 51, Name: "redteam2", RoleName: models.TeamRoleRedteam}
+	whiteteam := &models.Team{ID: 60, Name: "whiteteam", RoleName: models.TeamRoleWhiteteam}
+
+	cases := []struct {
+		team   *models.Team
+		report *models.CompromiseReport
+		can    bool
+	}{
+		{whiteteam, report(nil), true},
+		{reporter, report(nil), true},
+		{otherRedteam, report(nil), false},
+		{blueteam, report(nil), false},
+		{blueteam, report(&no), false},
+		{blueteam, report(&yes), true},
+		{otherBlueteam, report(&yes), false},
+		{nil, report(&yes), false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.can, canSeeCompromise(c.team, c.report), "%v: %s", c.team, c.report.Status())
+	}
+}
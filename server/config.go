@@ -3,9 +3,14 @@ package server
 import (
 	"fmt"
 	"net"
+	"net/url"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/pereztr5/cyboard/server/models"
 )
 
 type Configuration struct {
@@ -14,6 +19,7 @@ type Configuration struct {
 	Event          EventSettings
 	Server         ServerSettings
 	ServiceMonitor ServiceMonitorSettings `mapstructure:"service_monitor"`
+	Runner         RunnerSettings
 }
 
 type ScheduledBreak struct {
@@ -38,13 +44,34 @@ type EventSettings struct {
 	Start  time.Time
 	End    time.Time
 	Breaks []ScheduledBreak
+	// FreezeAt stops the public scoreboard from changing past this time.
+	// Left zero, the scoreboard never freezes.
+	FreezeAt time.Time `mapstructure:"freeze_at"`
+	// Tiebreakers order teams with the same score, trying each rule in turn.
+	// Teams still tied share a rank.
+	Tiebreakers []models.Tiebreaker
 	// OnBreak bool `mapstructure:"on_break"`
 }
 
+// Underway reports whether the event is running at time t: it has started, isn't over,
+// and isn't on a break.
+func (es *EventSettings) Underway(t time.Time) bool {
+	if t.Before(es.Start) || !t.Before(es.End) {
+		return false
+	}
+	for _, br := range es.Breaks {
+		if !t.Before(br.StartsAt) && t.Before(br.End()) {
+			return false
+		}
+	}
+	return true
+}
+
 func (es EventSettings) String() string {
 	return fmt.Sprintf(
-		`Event{start=%v, end=%v, breaks=%v}`,
-		es.Start.Format(time.Stamp), es.End.Format(time.Stamp), es.Breaks)
+		`Event{start=%v, end=%v, breaks=%v, freeze_at=%v, tiebreakers=%v}`,
+		es.Start.Format(time.Stamp), es.End.Format(time.Stamp), es.Breaks,
+		es.FreezeAt.Format(time.Stamp), es.Tiebreakers)
 }
 
 type LogSettings struct {
@@ -60,22 +87,49 @@ type ServerSettings struct {
 	CertPath    string `mapstructure:"cert"`
 	CertKeyPath string `mapstructure:"key"`
 
-	Compress   bool
-	RateLimit  bool   `mapstructure:"rate_limit"`
-	CtfFileDir string `mapstructure:"ctf_file_dir"`
+	Compress    bool
+	RateLimit   bool   `mapstructure:"rate_limit"`
+	CtfFileDir  string `mapstructure:"ctf_file_dir"`
+	EvidenceDir string `mapstructure:"evidence_dir"`
+	InjectDir   string `mapstructure:"inject_dir"`
 }
 
 type ServiceMonitorSettings struct {
-	BaseIP    string `mapstructure:"base_ip_prefix"`
-	Intervals time.Duration
-	Timeout   time.Duration
+	BaseIP          string `mapstructure:"base_ip_prefix"`
+	AddressTemplate string `mapstructure:"address_template"`
+	Intervals       time.Duration
+	Timeout         time.Duration
 
 	ChecksDir string `mapstructure:"checks_dir"`
 }
 
+// RunnerSettings are for the "runner" command, which runs the checks assigned to it
+// away from the server, and reports the results back.
+type RunnerSettings struct {
+	CollectorURL string `mapstructure:"collector_url"`
+	Token        string
+	// CACert is a PEM file to trust the server's certificate with, if it's self-signed.
+	CACert string `mapstructure:"ca_cert"`
+}
+
+// Validate checks that the runner knows where the server is, and has a token to talk to it with.
+func (rs *RunnerSettings) Validate() error {
+	u, err := url.Parse(rs.CollectorURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Collector must be an http(s) url, like \"https://cyboard.example:8081\": "+
+			"runner.collector_url=%q", rs.CollectorURL)
+	}
+	if rs.Token == "" {
+		return fmt.Errorf("Runner needs a token, made by an admin on the check runners page: " +
+			"runner.token (or the env var CY_RUNNER_TOKEN)")
+	}
+	return nil
+}
+
 // Validate checks for constraints on the config, including: Event start is after event end,
 // negative times (interval, timeout), breaks out of order, overlapping breaks,
-// break occurs before/after event starts/ends, and base_ip is a 3-octet IP prefix.
+// break occurs before/after event starts/ends, scoreboard freezes outside the event, unknown tiebreakers,
+// and the teams' address_template (or else base_ip, a 3-octet IP prefix) makes IP addresses.
 func (cfg *Configuration) Validate() error {
 	event, mon := cfg.Event, cfg.ServiceMonitor
 
@@ -83,6 +137,19 @@ func (cfg *Configuration) Validate() error {
 		return fmt.Errorf("Event starts after it ends: event=%v", &event)
 	}
 
+	if !event.FreezeAt.IsZero() &&
+		(event.FreezeAt.Before(event.Start) || event.FreezeAt.After(event.End)) {
+		return fmt.Errorf("Scoreboard must freeze during the event: event.freeze_at=%v, event=%v",
+			event.FreezeAt, &event)
+	}
+
+	for _, tb := range event.Tiebreakers {
+		if !tb.Valid() {
+			return fmt.Errorf("Unknown tiebreaker: event.tiebreakers=%v (must be some of %v)",
+				event.Tiebreakers, models.Tiebreakers)
+		}
+	}
+
 	if mon.Intervals < 1 {
 		return fmt.Errorf("Check interval must be positive: service_monitor.intervals=%v",
 			mon.Intervals)
@@ -125,7 +192,12 @@ func (cfg *Configuration) Validate() error {
 		}
 	}
 
-	if !IPish(mon.BaseIP) {
+	if mon.AddressTemplate != "" {
+		if err := checkAddressTemplate(mon.AddressTemplate); err != nil {
+			return fmt.Errorf("Bad address template: service_monitor.address_template=%q: %v",
+				mon.AddressTemplate, err)
+		}
+	} else if !IPish(mon.BaseIP) {
 		return fmt.Errorf("3 octet IP Prefix should have the form \"192.168.0.\" "+
 			"but got the following instead: event.base_ip_prefix=%q", mon.BaseIP)
 	}
@@ -133,6 +205,53 @@ func (cfg *Configuration) Validate() error {
 	return nil
 }
 
+// TeamAddressTemplate is the template for the address of each team, from which the
+// `{IP}` check arg is made. Without an address_template, it's the base_ip_prefix
+// followed by the team's significant octet.
+func (mon *ServiceMonitorSettings) TeamAddressTemplate() string {
+	if mon.AddressTemplate != "" {
+		return mon.AddressTemplate
+	}
+	return mon.BaseIP + "{TEAM_4TH_OCTET}"
+}
+
+// TeamAddress resolves the address template for a team.
+func (mon *ServiceMonitorSettings) TeamAddress(teamID int, octet int16) string {
+	return resolveTeamAddress(mon.TeamAddressTemplate(), teamID, octet)
+}
+
+// resolveTeamAddress fills in a team's id & significant octet in an address template.
+func resolveTeamAddress(tmpl string, teamID int, octet int16) string {
+	s := strings.Replace(tmpl, "{TEAM_ID}", strconv.Itoa(teamID), -1)
+	return strings.Replace(s, "{TEAM_4TH_OCTET}", strconv.Itoa(int(octet)), -1)
+}
+
+// addressPlaceholder matches anything in an address template that looks like a placeholder.
+var addressPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// checkAddressTemplate makes sure a template gives each team its own IPv4 or IPv6 address.
+// It must use {TEAM_ID} or {TEAM_4TH_OCTET}, and nothing else in braces.
+func checkAddressTemplate(tmpl string) error {
+	used := false
+	for _, p := range addressPlaceholder.FindAllString(tmpl, -1) {
+		if p != "{TEAM_ID}" && p != "{TEAM_4TH_OCTET}" {
+			return fmt.Errorf("unknown placeholder %s (use {TEAM_ID} or {TEAM_4TH_OCTET})", p)
+		}
+		used = true
+	}
+	if !used {
+		return fmt.Errorf("every team would get the same address; use {TEAM_ID} or {TEAM_4TH_OCTET}")
+	}
+
+	// Try a few teams, to catch templates that only work for some numbers
+	for _, n := range []int{1, 99, 254} {
+		if addr := resolveTeamAddress(tmpl, n, int16(n)); net.ParseIP(addr) == nil {
+			return fmt.Errorf("team #%d would get %q, which is not an IP address", n, addr)
+		}
+	}
+	return nil
+}
+
 // IPish tests whether a string looks like the first 3 octets of an IPv4 address.
 func IPish(ip_prefix string) bool {
 	test_ip := ip_prefix + "0"
@@ -3,6 +3,7 @@ package server
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -22,6 +23,10 @@ func Test_Configuration_Validate(t *testing.T) {
 		{"negative_breaktime", "Breaks must go for a positive amount of time"},
 		{"break_before_event", "Breaks must start after the event has started"},
 		{"break_after_event", "Breaks must end before the event has ended"},
+		{"freeze_after_event", "Scoreboard must freeze during the event"},
+		{"bad_tiebreaker", "Unknown tiebreaker"},
+		{"bad_address_template", "Bad address template"},
+		{"address_template", ""},
 		{"valid", ""},
 	}
 
@@ -74,3 +79,91 @@ func TestIPish(t *testing.T) {
 		})
 	}
 }
+
+func Test_checkAddressTemplate(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		errText  string
+	}{
+		{"ipv4 octet", "192.168.0.{TEAM_4TH_OCTET}", ""},
+		{"ipv4 team net", "10.{TEAM_ID}.1.5", ""},
+		{"ipv6", "fd00:{TEAM_ID}::10", ""},
+
+		{"same for everyone", "10.0.0.5", "same address"},
+		{"unknown placeholder", "10.{TEAM}.1.5", "unknown placeholder {TEAM}"},
+		{"hostname", "team{TEAM_ID}.example", "not an IP address"},
+		{"octet too big", "10.{TEAM_ID}00.1.5", "not an IP address"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAddressTemplate(tt.template)
+			if tt.errText == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.errText)
+			}
+		})
+	}
+}
+
+func Test_ServiceMonitorSettings_TeamAddress(t *testing.T) {
+	mon := &ServiceMonitorSettings{BaseIP: "192.168.0."}
+	assert.Equal(t, "192.168.0.7", mon.TeamAddress(3, 7), "The base ip prefix, without a template")
+
+	mon.AddressTemplate = "fd00:{TEAM_ID}::{TEAM_4TH_OCTET}"
+	assert.Equal(t, "fd00:3::7", mon.TeamAddress(3, 7))
+}
+
+func Test_EventSettings_Underway(t *testing.T) {
+	start := time.Date(2017, 11, 4, 9, 0, 0, 0, time.UTC)
+	event := &EventSettings{
+		Start:  start,
+		End:    start.Add(10 * time.Hour),
+		Breaks: []ScheduledBreak{{StartsAt: start.Add(3 * time.Hour), GoesFor: time.Hour}},
+	}
+
+	cases := []struct {
+		name     string
+		at       time.Duration
+		underway bool
+	}{
+		{"before", -time.Minute, false},
+		{"start", 0, true},
+		{"morning", time.Hour, true},
+		{"break starts", 3 * time.Hour, false},
+		{"on break", 3*time.Hour + 30*time.Minute, false},
+		{"break ends", 4 * time.Hour, true},
+		{"end", 10 * time.Hour, false},
+		{"after", 11 * time.Hour, false},
+	}
+	for _, tt := range cases {
+		assert.Equal(t, tt.underway, event.Underway(start.Add(tt.at)), tt.name)
+	}
+}
+
+func Test_RunnerSettings_Validate(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings RunnerSettings
+		errText  string
+	}{
+		{"valid", RunnerSettings{CollectorURL: "https://cyboard.example:8081", Token: "tok"}, ""},
+		{"http", RunnerSettings{CollectorURL: "http://10.0.0.2:8080/", Token: "tok"}, ""},
+
+		{"no url", RunnerSettings{Token: "tok"}, "Collector must be an http(s) url"},
+		{"no scheme", RunnerSettings{CollectorURL: "cyboard.example:8081", Token: "tok"}, "Collector must be an http(s) url"},
+		{"no token", RunnerSettings{CollectorURL: "https://cyboard.example"}, "Runner needs a token"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.Validate()
+			if tt.errText == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.errText)
+			}
+		})
+	}
+}
@@ -12,8 +12,10 @@ type CtxKey int8
 
 const (
 	ctxTeam = CtxKey(iota)
+	ctxPlayer
 	ctxOwnedChallenges
 	ctxErrorMsgFields
+	ctxCheckRunner
 
 	ctxUrlParamPrefix = "cyboard.param."
 )
@@ -29,6 +31,23 @@ func saveCtxTeam(r *http.Request, team *models.Team) context.Context {
 	return context.WithValue(r.Context(), ctxTeam, team)
 }
 
+// getCtxPlayer is the player logged in, or nil if they used their team's shared login.
+func getCtxPlayer(r *http.Request) *models.Player {
+	if p := r.Context().Value(ctxPlayer); p != nil {
+		return p.(*models.Player)
+	}
+	return nil
+}
+
+// getCtxCheckRunner is the check runner that authenticated with its token.
+func getCtxCheckRunner(r *http.Request) *models.CheckRunner {
+	return r.Context().Value(ctxCheckRunner).(*models.CheckRunner)
+}
+
+func saveCtxCheckRunner(r *http.Request, runner *models.CheckRunner) context.Context {
+	return context.WithValue(r.Context(), ctxCheckRunner, runner)
+}
+
 func getCtxOwnedChallenges(r *http.Request) []models.Challenge {
 	return r.Context().Value(ctxOwnedChallenges).([]models.Challenge)
 }
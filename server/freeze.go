@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/tevino/abool"
+)
+
+// scoreboardUnfrozen is set by an admin during the awards ceremony, to reveal the
+// final scores, even though the configured `event.freeze_at` time has passed.
+// It's saved in the db config table, under scoreboardUnfrozenKey, so a restart
+// doesn't freeze the scoreboard again.
+var scoreboardUnfrozen = abool.New()
+
+const scoreboardUnfrozenKey = "scoreboard_unfrozen"
+
+// loadScoreboardUnfrozen restores the admin's choice to unfreeze the scoreboard.
+func loadScoreboardUnfrozen(db models.DB) error {
+	value, err := models.ConfigValue(db, scoreboardUnfrozenKey)
+	if err == pgx.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	unfrozen, err := strconv.ParseBool(value)
+	if unfrozen {
+		scoreboardUnfrozen.Set()
+	}
+	return err
+}
+
+// setScoreboardUnfrozen saves the admin's choice, before revealing or hiding the live scores.
+func setScoreboardUnfrozen(db models.DB, unfrozen bool) error {
+	if err := models.SetConfigValue(db, scoreboardUnfrozenKey, strconv.FormatBool(unfrozen)); err != nil {
+		return err
+	}
+	if unfrozen {
+		scoreboardUnfrozen.Set()
+	} else {
+		scoreboardUnfrozen.UnSet()
+	}
+	return nil
+}
+
+// scoreboardFrozen checks if the public scoreboard should be held at `event.freeze_at`.
+func scoreboardFrozen() bool {
+	freezeAt := appCfg.Event.FreezeAt
+	return !freezeAt.IsZero() && time.Now().After(freezeAt) && !scoreboardUnfrozen.IsSet()
+}
+
+// scoreboardFrozenFor checks if the requester should be shown the frozen scoreboard.
+// Staff with the view_live_scoreboard permission always see the live scores.
+func scoreboardFrozenFor(r *http.Request) bool {
+	return scoreboardFrozen() && !hasPermission(getCtxTeam(r), models.PermViewLiveScoreboard)
+}
+
+// teamsScoresFor fetches the scores the requester is allowed to see.
+func teamsScoresFor(r *http.Request) ([]models.TeamsScoresResponse, error) {
+	if scoreboardFrozenFor(r) {
+		return rankScores(models.TeamsScoresAsOf(db, appCfg.Event.FreezeAt))
+	}
+	return rankScores(models.TeamsScores(db))
+}
+
+// LiveOrPublic sends staff allowed to see the live scores to one handler, and everyone else
+// to another. Used to keep them on the live score feed while the public one is frozen.
+func LiveOrPublic(live, public http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasPermission(getCtxTeam(r), models.PermViewLiveScoreboard) {
+			live.ServeHTTP(w, r)
+		} else {
+			public.ServeHTTP(w, r)
+		}
+	})
+}
+
+func getScoreboardFreezeStatus() M {
+	var freezeAt *time.Time
+	if fa := appCfg.Event.FreezeAt; !fa.IsZero() {
+		freezeAt = &fa
+	}
+	return M{
+		"frozen":    scoreboardFrozen(),
+		"freeze_at": freezeAt,
+		"unfrozen":  scoreboardUnfrozen.IsSet(),
+	}
+}
+
+func GetScoreboardFreeze(w http.ResponseWriter, r *http.Request) {
+	status := getScoreboardFreezeStatus()
+	render.JSON(w, r, &status)
+}
+
+// UnfreezeScoreboard reveals the live scores to everyone (e.g. at the awards ceremony).
+func UnfreezeScoreboard(w http.ResponseWriter, r *http.Request) {
+	if err := setScoreboardUnfrozen(db, true); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	Logger.WithField("admin", getCtxTeam(r).Name).Info("scoreboard unfrozen")
+	status := getScoreboardFreezeStatus()
+	render.JSON(w, r, &status)
+}
+
+// RefreezeScoreboard undoes UnfreezeScoreboard, in case the reveal went out too early.
+func RefreezeScoreboard(w http.ResponseWriter, r *http.Request) {
+	if err := setScoreboardUnfrozen(db, false); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	Logger.WithField("admin", getCtxTeam(r).Name).Info("scoreboard refrozen")
+	status := getScoreboardFreezeStatus()
+	render.JSON(w, r, &status)
+}
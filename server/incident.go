@@ -0,0 +1,160 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Incident Response Reports
+//
+// Blue teams write up the incidents they catch from their dashboard. Judges score
+// each report with the rubric, and the points are their own scoring category.
+
+// GetTeamIncidentReports lists the reports the logged in blue team has submitted.
+func GetTeamIncidentReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := models.TeamIncidentReports(db, getCtxTeam(r).ID)
+	ApiQuery(w, r, reports, err)
+}
+
+// GetIncidentReports lists every report, for the judges.
+func GetIncidentReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := models.AllIncidentReports(db)
+	ApiQuery(w, r, reports, err)
+}
+
+// incidentReportFrom reads a report from the blue team's dashboard form.
+func incidentReportFrom(r *http.Request) (*models.IncidentReport, error) {
+	ir := &models.IncidentReport{
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Timeline:    strings.TrimSpace(r.FormValue("timeline")),
+		Indicators:  strings.TrimSpace(r.FormValue("indicators")),
+		Remediation: strings.TrimSpace(r.FormValue("remediation")),
+	}
+	if ir.Title == "" || ir.Timeline == "" || ir.Indicators == "" || ir.Remediation == "" {
+		return nil, errors.New("an incident report needs a title, timeline, indicators, and remediation")
+	}
+	return ir, nil
+}
+
+// SubmitIncidentReport saves an incident report from a blue team's dashboard,
+// then sends them back to it.
+func SubmitIncidentReport(w http.ResponseWriter, r *http.Request) {
+	team := getCtxTeam(r)
+	if !isBlueteam(team) {
+		render.Render(w, r, ErrForbiddenBecause("Only blue teams submit incident reports"))
+		return
+	}
+	ir, err := incidentReportFrom(r)
+	if err != nil {
+		page := getPage(r, "dashboard", "Dashboard")
+		page.Data = M{"IncidentProblem": err.Error()}
+		renderTeamDashboard(w, r, page)
+		return
+	}
+
+	ir.TeamID = team.ID
+	if p := getCtxPlayer(r); p != nil {
+		ir.PlayerID = &p.ID
+	}
+	if err = ir.Insert(db); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	Logger.WithFields(logrus.Fields{"team": team.Name, "report": ir.ID}).Info("Incident report submitted")
+	http.Redirect(w, r, "/dashboard#incidents", http.StatusSeeOther)
+}
+
+/* Judges' Page */
+
+// ShowIncidentQueue lists every incident report for the judges, unscored ones first.
+func ShowIncidentQueue(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_incidents", "Incident Reports")
+	renderIncidentQueue(w, page)
+}
+
+func renderIncidentQueue(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	var err error
+	page.Data["Reports"], err = models.AllIncidentReports(db)
+	page.checkErr(err, "all incident reports")
+	page.Data["Rubric"], err = models.AllIncidentRubric(db)
+	page.checkErr(err, "incident rubric")
+
+	renderTemplate(w, page)
+}
+
+// incidentScoresFrom reads the points for each part of the rubric, sent as
+// form values named "rubric_<id>".
+func incidentScoresFrom(r *http.Request) (map[int]float32, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	scores := map[int]float32{}
+	for key, values := range r.PostForm {
+		if !strings.HasPrefix(key, "rubric_") || len(values) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(key, "rubric_"))
+		if err != nil {
+			return nil, errors.Errorf("invalid rubric field %q", key)
+		}
+		points, err := strconv.ParseFloat(values[0], 32)
+		if err != nil {
+			return nil, errors.Errorf("points must be a number, not %q", values[0])
+		}
+		scores[id] = float32(points)
+	}
+	return scores, nil
+}
+
+// ScoreIncident saves a judge's rubric scores & feedback for a report.
+func ScoreIncident(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_incidents", "Incident Reports")
+	page.Data = make(map[string]interface{})
+
+	id := getCtxIdParam(r)
+	scores, err := incidentScoresFrom(r)
+	if err == nil {
+		err = models.ScoreIncidentReport(db, id, page.T.ID, scores, strings.TrimSpace(r.FormValue("feedback")))
+	}
+
+	if err == pgx.ErrNoRows {
+		page.Data["Problem"] = "That report doesn't exist."
+	} else if err != nil {
+		page.Data["Problem"] = err.Error()
+	} else {
+		Logger.WithFields(logrus.Fields{"judge": page.T.Name, "report": id}).Info("Incident report scored")
+		page.Data["Scored"] = id
+	}
+	renderIncidentQueue(w, page)
+}
+
+/* Rubric Configuration */
+
+func GetIncidentRubric(w http.ResponseWriter, r *http.Request) {
+	rubric, err := models.AllIncidentRubric(db)
+	ApiQuery(w, r, rubric, err)
+}
+
+func AddIncidentRubric(w http.ResponseWriter, r *http.Request) {
+	ApiCreate(w, r, &models.IncidentRubric{})
+}
+
+func UpdateIncidentRubric(w http.ResponseWriter, r *http.Request) {
+	ApiUpdate(w, r, &models.IncidentRubric{})
+}
+
+func DeleteIncidentRubric(w http.ResponseWriter, r *http.Request) {
+	ApiDelete(w, r, &models.IncidentRubric{})
+}
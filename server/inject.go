@@ -0,0 +1,445 @@
+package server
+
+import (
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Injects
+//
+// Injects are business tasks (write a policy, set up a user) handed to the blue teams
+// during the event, each with a deadline. Teams answer from the injects page, with
+// text & files, and judges grade their work. The points are their own scoring category.
+//
+// Attachments go in inject_dir/<inject id>/attachments, and each team's
+// submission files go in inject_dir/<inject id>/teams/<team id>.
+
+var InjectFileMgr = FSContentManager{
+	maxSize: 32 << 20, // Accept up to 32MB files
+	mode:    0644,
+	pathBuilder: func(r *http.Request) string {
+		return injectAttachmentDir(getCtxIdParam(r))
+	},
+}
+
+func injectAttachmentDir(injectID int) string {
+	return filepath.Join(appCfg.Server.InjectDir, strconv.Itoa(injectID), "attachments")
+}
+
+func injectSubmissionDir(injectID, teamID int) string {
+	return filepath.Join(appCfg.Server.InjectDir, strconv.Itoa(injectID), "teams", strconv.Itoa(teamID))
+}
+
+// replaceSubmissionFiles saves a team's uploaded files for an inject. Unless keep is set,
+// the files from the team's earlier submission are swapped out for the new ones.
+// The new files are written aside first, so a failed upload leaves the old ones in place.
+func replaceSubmissionFiles(r *http.Request, dir string, fhs []*multipart.FileHeader, keep bool) error {
+	if keep {
+		if len(fhs) == 0 {
+			return nil
+		}
+		return InjectFileMgr.saveUploads(r, dir, fhs)
+	}
+
+	next := dir + ".next"
+	if err := os.RemoveAll(next); err != nil {
+		return err
+	}
+	if len(fhs) > 0 {
+		if err := InjectFileMgr.saveUploads(r, next, fhs); err != nil {
+			os.RemoveAll(next)
+			return err
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if len(fhs) == 0 {
+		return nil
+	}
+	return os.Rename(next, dir)
+}
+
+// serveFileFrom serves the file named in the url from dir.
+func serveFileFrom(dir string, w http.ResponseWriter, r *http.Request) {
+	FSContentManager{pathBuilder: func(*http.Request) string { return dir }}.GetFile(w, r)
+}
+
+// GetReleasedInjects lists the injects the blue teams can see.
+func GetReleasedInjects(w http.ResponseWriter, r *http.Request) {
+	injects, err := models.ReleasedInjects(db)
+	ApiQuery(w, r, injects, err)
+}
+
+// GetTeamInjectSubmissions lists the logged in blue team's submissions, by inject id.
+func GetTeamInjectSubmissions(w http.ResponseWriter, r *http.Request) {
+	subs, err := models.TeamInjectSubmissions(db, getCtxTeam(r).ID)
+	ApiQuery(w, r, subs, err)
+}
+
+/* Blue Team Page */
+
+// ShowInjects lists the released injects, with the team's submission for each.
+func ShowInjects(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "injects", "Injects")
+	renderInjectsPage(w, page)
+}
+
+// injectListing is an inject as shown to a blue team, with its attachments,
+// and what the team has turned in for it.
+type injectListing struct {
+	models.Inject
+	Attachments     []FileInfo
+	Submission      *models.InjectSubmissionView
+	SubmissionFiles []FileInfo
+}
+
+// CanSubmit reports if the team may turn in (or replace) their work at time `t`.
+func (l *injectListing) CanSubmit(t time.Time) bool {
+	return l.Open(t) && (l.Submission == nil || l.Submission.GradedAt == nil)
+}
+
+// LatePenaltyPercent is the share of points late work loses, for display.
+func (l *injectListing) LatePenaltyPercent() int {
+	return int(l.LatePenalty*100 + 0.5)
+}
+
+func renderInjectsPage(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	injects, err := models.ReleasedInjects(db)
+	page.checkErr(err, "released injects")
+	subs, err := models.TeamInjectSubmissions(db, page.T.ID)
+	page.checkErr(err, "team inject submissions")
+
+	listings := make([]injectListing, len(injects))
+	for i, inject := range injects {
+		listings[i] = injectListing{Inject: inject, Attachments: listFiles(injectAttachmentDir(inject.ID))}
+		if sub, ok := subs[inject.ID]; ok {
+			listings[i].Submission = &sub
+			listings[i].SubmissionFiles = listFiles(injectSubmissionDir(inject.ID, page.T.ID))
+		}
+	}
+	page.Data["Injects"] = listings
+	page.Data["Now"] = time.Now()
+
+	renderTemplate(w, page)
+}
+
+// SubmitInject saves a blue team's work for an inject: the text in "body", and any
+// files uploaded under "submission_files". A resubmission replaces the earlier files,
+// unless "keep_files" is checked. Then it sends them back to the inject.
+func SubmitInject(w http.ResponseWriter, r *http.Request) {
+	team := getCtxTeam(r)
+	if !isBlueteam(team) {
+		render.Render(w, r, ErrForbiddenBecause("Only blue teams submit work for injects"))
+		return
+	}
+	page := getPage(r, "injects", "Injects")
+	page.Data = make(map[string]interface{})
+
+	if err := r.ParseMultipartForm(InjectFileMgr.maxSize); err != nil {
+		page.Data["Problem"] = err.Error()
+		renderInjectsPage(w, page)
+		return
+	}
+	fhs := r.MultipartForm.File["submission_files"]
+	keepFiles := r.FormValue("keep_files") != ""
+	sub := &models.InjectSubmission{
+		InjectID: getCtxIdParam(r),
+		TeamID:   team.ID,
+		Body:     strings.TrimSpace(r.FormValue("body")),
+	}
+	if sub.Body == "" && len(fhs) == 0 && !keepFiles {
+		page.Data["Problem"] = "Write an answer, or attach a file."
+		renderInjectsPage(w, page)
+		return
+	}
+	if p := getCtxPlayer(r); p != nil {
+		sub.PlayerID = &p.ID
+	}
+
+	if err := sub.Submit(db); err == pgx.ErrNoRows {
+		page.Data["Problem"] = "That inject doesn't exist."
+		renderInjectsPage(w, page)
+		return
+	} else if err != nil {
+		page.Data["Problem"] = err.Error()
+		renderInjectsPage(w, page)
+		return
+	}
+	if err := replaceSubmissionFiles(r, injectSubmissionDir(sub.InjectID, team.ID), fhs, keepFiles); err != nil {
+		page.checkErr(err, "save inject submission files")
+		renderInjectsPage(w, page)
+		return
+	}
+
+	Logger.WithFields(logrus.Fields{
+		"team":   team.Name,
+		"inject": sub.InjectID,
+		"late":   sub.Late,
+		"files":  len(fhs),
+		"kept":   keepFiles,
+	}).Info("Inject submitted")
+	http.Redirect(w, r, "/injects#inject-"+strconv.Itoa(sub.InjectID), http.StatusSeeOther)
+}
+
+// GetInjectAttachment serves one of an inject's attachments, once the inject is released.
+func GetInjectAttachment(w http.ResponseWriter, r *http.Request) {
+	inject, err := models.InjectByID(db, getCtxIdParam(r))
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	} else if t := getCtxTeam(r); !inject.Released(time.Now()) &&
+		!hasPermission(t, models.PermManageInjects) && !hasPermission(t, models.PermGradeInjects) {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	InjectFileMgr.GetFile(w, r)
+}
+
+// GetInjectSubmissionFile serves one of the files the logged in team turned in for an inject.
+func GetInjectSubmissionFile(w http.ResponseWriter, r *http.Request) {
+	serveFileFrom(injectSubmissionDir(getCtxIdParam(r), getCtxTeam(r).ID), w, r)
+}
+
+/* Inject Configuration */
+
+// injectFrom reads an inject from the staff page's form. Times are entered
+// as a date & time, in the server's time zone.
+func injectFrom(r *http.Request) (*models.Inject, error) {
+	inject := &models.Inject{
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+		AcceptLate:  r.FormValue("accept_late") != "",
+	}
+
+	parseTime := func(field string) (time.Time, error) {
+		s := r.FormValue(field+"_date") + " " + r.FormValue(field+"_time")
+		t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			return t, errors.Errorf("invalid %s time: %q", field, s)
+		}
+		return t, nil
+	}
+	var err error
+	if inject.ReleaseAt, err = parseTime("release"); err != nil {
+		return nil, err
+	}
+	if inject.DueAt, err = parseTime("due"); err != nil {
+		return nil, err
+	}
+
+	points, err := strconv.ParseFloat(r.FormValue("max_points"), 32)
+	if err != nil {
+		return nil, errors.New("max points must be a number")
+	}
+	inject.MaxPoints = float32(points)
+	if s := r.FormValue("late_penalty"); s != "" {
+		penalty, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, errors.New("the late penalty must be a number")
+		}
+		inject.LatePenalty = float32(penalty)
+	}
+
+	if err = inject.Validate(); err != nil {
+		return nil, err
+	}
+	return inject, nil
+}
+
+// ShowInjectsConfig is where staff write injects, and attach files to them.
+func ShowInjectsConfig(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_injects", "Injects")
+	renderInjectsConfig(w, page)
+}
+
+func renderInjectsConfig(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	injects, err := models.AllInjects(db)
+	page.checkErr(err, "all injects")
+	listings := make([]injectListing, len(injects))
+	for i, inject := range injects {
+		listings[i] = injectListing{Inject: inject, Attachments: listFiles(injectAttachmentDir(inject.ID))}
+	}
+	page.Data["Injects"] = listings
+
+	// The new inject form starts out released next hour, and due an hour after that.
+	now := time.Now()
+	release := now.Truncate(time.Hour).Add(time.Hour)
+	page.Data["Now"] = now
+	page.Data["NewInject"] = models.Inject{ReleaseAt: release, DueAt: release.Add(time.Hour), AcceptLate: true}
+
+	renderTemplate(w, page)
+}
+
+// saveInject adds a new inject, or updates the one in the url, from the staff page's form.
+// Files uploaded under "attachments" are added to the inject.
+func saveInject(w http.ResponseWriter, r *http.Request, update bool) {
+	page := getPage(r, "staff_injects", "Injects")
+	page.Data = make(map[string]interface{})
+
+	if err := r.ParseMultipartForm(InjectFileMgr.maxSize); err != nil {
+		page.Data["Problem"] = err.Error()
+		renderInjectsConfig(w, page)
+		return
+	}
+	inject, err := injectFrom(r)
+	if err != nil {
+		page.Data["Problem"] = err.Error()
+		renderInjectsConfig(w, page)
+		return
+	}
+
+	if update {
+		inject.ID = getCtxIdParam(r)
+		err = inject.Update(db)
+	} else {
+		err = inject.Insert(db)
+	}
+	if err != nil {
+		page.checkErr(err, "save inject")
+		renderInjectsConfig(w, page)
+		return
+	}
+	if fhs := r.MultipartForm.File["attachments"]; len(fhs) > 0 {
+		err = InjectFileMgr.saveUploads(r, injectAttachmentDir(inject.ID), fhs)
+		page.checkErr(err, "save inject attachments")
+	}
+
+	Logger.WithFields(logrus.Fields{"staff": page.T.Name, "inject": inject.ID}).Info("Inject saved")
+	page.Data["Saved"] = inject.ID
+	renderInjectsConfig(w, page)
+}
+
+// AddInjectFromForm adds a new inject from the staff page.
+func AddInjectFromForm(w http.ResponseWriter, r *http.Request) {
+	saveInject(w, r, false)
+}
+
+// UpdateInjectFromForm edits an inject from the staff page.
+func UpdateInjectFromForm(w http.ResponseWriter, r *http.Request) {
+	saveInject(w, r, true)
+}
+
+type InjectRequest struct {
+	*models.Inject
+}
+
+func (ir *InjectRequest) Bind(r *http.Request) error {
+	if ir.Inject == nil {
+		return errors.New(`missing required 'inject' fields`)
+	}
+	return ir.Validate()
+}
+
+func GetAllInjects(w http.ResponseWriter, r *http.Request) {
+	injects, err := models.AllInjects(db)
+	ApiQuery(w, r, injects, err)
+}
+
+func GetInjectByID(w http.ResponseWriter, r *http.Request) {
+	inject, err := models.InjectByID(db, getCtxIdParam(r))
+	ApiQuery(w, r, inject, err)
+}
+
+func AddInject(w http.ResponseWriter, r *http.Request) {
+	ApiCreate(w, r, &InjectRequest{})
+}
+
+func UpdateInject(w http.ResponseWriter, r *http.Request) {
+	ApiUpdate(w, r, &InjectRequest{})
+}
+
+func DeleteInject(w http.ResponseWriter, r *http.Request) {
+	ApiDelete(w, r, &models.Inject{})
+}
+
+/* Judges' Page */
+
+// GetInjectSubmissions lists every submission, for the judges.
+func GetInjectSubmissions(w http.ResponseWriter, r *http.Request) {
+	subs, err := models.AllInjectSubmissions(db)
+	ApiQuery(w, r, subs, err)
+}
+
+// ShowInjectSubmissions lists every submission for the judges, ungraded ones first.
+func ShowInjectSubmissions(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_inject_submissions", "Inject Submissions")
+	renderInjectSubmissions(w, page)
+}
+
+// injectSubmissionListing is a submission as shown to the judges, with its files.
+type injectSubmissionListing struct {
+	models.InjectSubmissionView
+	Files []FileInfo
+}
+
+func renderInjectSubmissions(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	subs, err := models.AllInjectSubmissions(db)
+	page.checkErr(err, "all inject submissions")
+	listings := make([]injectSubmissionListing, len(subs))
+	for i, sub := range subs {
+		listings[i] = injectSubmissionListing{InjectSubmissionView: sub,
+			Files: listFiles(injectSubmissionDir(sub.InjectID, sub.TeamID))}
+	}
+	page.Data["Submissions"] = listings
+
+	renderTemplate(w, page)
+}
+
+// GradeInject saves a judge's points & feedback for a submission.
+func GradeInject(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_inject_submissions", "Inject Submissions")
+	page.Data = make(map[string]interface{})
+
+	id := getCtxIdParam(r)
+	points, err := strconv.ParseFloat(r.FormValue("points"), 32)
+	if err != nil {
+		page.Data["Problem"] = "Points must be a number."
+		renderInjectSubmissions(w, page)
+		return
+	}
+
+	err = models.GradeInjectSubmission(db, id, page.T.ID, float32(points), strings.TrimSpace(r.FormValue("feedback")))
+	if err == pgx.ErrNoRows {
+		page.Data["Problem"] = "That submission doesn't exist."
+	} else if err != nil {
+		page.Data["Problem"] = err.Error()
+	} else {
+		Logger.WithFields(logrus.Fields{"judge": page.T.Name, "submission": id}).Info("Inject submission graded")
+		page.Data["Graded"] = id
+	}
+	renderInjectSubmissions(w, page)
+}
+
+// GetInjectSubmissionFileByID serves one of the files turned in with a submission, for the judges.
+func GetInjectSubmissionFileByID(w http.ResponseWriter, r *http.Request) {
+	sub, err := models.InjectSubmissionByID(db, getCtxIdParam(r))
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	}
+	serveFileFrom(injectSubmissionDir(sub.InjectID, sub.TeamID), w, r)
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_injectListing_CanSubmit(t *testing.T) {
+	now := time.Now()
+	inject := func(releaseIn, dueIn time.Duration, acceptLate bool) models.Inject {
+		return models.Inject{ReleaseAt: now.Add(releaseIn), DueAt: now.Add(dueIn), AcceptLate: acceptLate}
+	}
+	ungraded := &models.InjectSubmissionView{}
+	graded := &models.InjectSubmissionView{InjectSubmission: models.InjectSubmission{GradedAt: &now}}
+
+	cases := []struct {
+		name    string
+		listing injectListing
+		can     bool
+	}{
+		{"open", injectListing{Inject: inject(-time.Hour, time.Hour, false)}, true},
+		{"not released", injectListing{Inject: inject(time.Hour, 2*time.Hour, true)}, false},
+		{"late, accepted", injectListing{Inject: inject(-2*time.Hour, -time.Hour, true)}, true},
+		{"late, turned away", injectListing{Inject: inject(-2*time.Hour, -time.Hour, false)}, false},
+		{"replacing", injectListing{Inject: inject(-time.Hour, time.Hour, false), Submission: ungraded}, true},
+		{"already graded", injectListing{Inject: inject(-time.Hour, time.Hour, false), Submission: graded}, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.can, c.listing.CanSubmit(now), c.name)
+	}
+}
+
+func Test_replaceSubmissionFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "cyboard-inject")
+	require.NoError(t, err)
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "teams", "1")
+
+	upload := func(names ...string) []*multipart.FileHeader {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		for _, name := range names {
+			fw, err := mw.CreateFormFile("submission_files", name)
+			require.NoError(t, err)
+			fw.Write([]byte(name))
+		}
+		require.NoError(t, mw.Close())
+		r := httptest.NewRequest("POST", "/injects/1", body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		return r.MultipartForm.File["submission_files"]
+	}
+	files := func() []string {
+		names := []string{}
+		fis, _ := ioutil.ReadDir(dir)
+		for _, fi := range fis {
+			names = append(names, fi.Name())
+		}
+		return names
+	}
+	r := httptest.NewRequest("POST", "/injects/1", nil)
+
+	require.NoError(t, replaceSubmissionFiles(r, dir, upload("policy.docx", "notes.txt"), false))
+	assert.Equal(t, []string{"notes.txt", "policy.docx"}, files())
+
+	require.NoError(t, replaceSubmissionFiles(r, dir, upload("policy-v2.docx"), false))
+	assert.Equal(t, []string{"policy-v2.docx"}, files(), "A resubmission replaces the earlier files")
+
+	require.NoError(t, replaceSubmissionFiles(r, dir, upload("screenshot.png"), true))
+	assert.Equal(t, []string{"policy-v2.docx", "screenshot.png"}, files(), "Earlier files are kept when asked")
+
+	require.NoError(t, replaceSubmissionFiles(r, dir, nil, true))
+	assert.Equal(t, []string{"policy-v2.docx", "screenshot.png"}, files())
+
+	require.NoError(t, replaceSubmissionFiles(r, dir, nil, false))
+	assert.Empty(t, files(), "A text-only resubmission clears the files")
+	_, err = os.Stat(dir + ".next")
+	assert.True(t, os.IsNotExist(err), "Nothing is left set aside")
+}
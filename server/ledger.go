@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/pereztr5/cyboard/server/models"
+)
+
+// Score Ledger
+//
+// Shows a blue team every scoring event that went into their score: runs of passing
+// service checks, flag captures, graded reports & injects, and bonuses & deductions
+// with the staff's reasons. Stops at the scoreboard freeze, like the rest of the scores.
+
+// ledgerPageSize is how many entries the dashboard shows at a time.
+const ledgerPageSize = 25
+
+// ledgerFilterFor starts a filter that hides scoring past the freeze, if the requester shouldn't see it.
+func ledgerFilterFor(r *http.Request) models.LedgerFilter {
+	f := models.LedgerFilter{}
+	if scoreboardFrozenFor(r) {
+		freezeAt := appCfg.Event.FreezeAt
+		f.Until = &freezeAt
+	}
+	return f
+}
+
+// ledgerFilterFrom reads the ledger's params: `category`, and the paging `limit` & `offset`.
+func ledgerFilterFrom(r *http.Request) (models.LedgerFilter, error) {
+	q := r.URL.Query()
+	f := ledgerFilterFor(r)
+	f.Category = q.Get("category")
+
+	var err error
+	if l := q.Get("limit"); l != "" {
+		if f.Limit, err = strconv.Atoi(l); err != nil || f.Limit < 0 {
+			return f, fmt.Errorf("invalid limit: %q", l)
+		}
+	}
+	if o := q.Get("offset"); o != "" {
+		if f.Offset, err = strconv.Atoi(o); err != nil || f.Offset < 0 {
+			return f, fmt.Errorf("invalid offset: %q", o)
+		}
+	}
+	return f, nil
+}
+
+// GetTeamLedger lists the scoring events for the logged in blue team, newest first.
+func GetTeamLedger(w http.ResponseWriter, r *http.Request) {
+	f, err := ledgerFilterFrom(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidBecause(err.Error()))
+		return
+	}
+	entries, err := models.TeamLedger(db, getCtxTeam(r).ID, f)
+	ApiQuery(w, r, entries, err)
+}
+
+// ledgerSection is a page of the ledger, as shown on the dashboard.
+type ledgerSection struct {
+	Entries    []models.LedgerEntry
+	Categories []models.ScoreCategory
+	Category   string // filtered down to, if any
+	Page       int    // counting from 1
+	More       bool   // if there's another page after this one
+}
+
+// Label is a category's display name.
+func (ls *ledgerSection) Label(category string) string {
+	for _, c := range ls.Categories {
+		if c.Name == category {
+			return c.Label
+		}
+	}
+	return category
+}
+
+func (ls *ledgerSection) PrevPage() int { return ls.Page - 1 }
+func (ls *ledgerSection) NextPage() int { return ls.Page + 1 }
+
+// teamLedgerSection fetches the page of the team's ledger picked by the dashboard's
+// `ledger` (category) & `ledger_page` params.
+func teamLedgerSection(r *http.Request, teamID int) (*ledgerSection, error) {
+	q := r.URL.Query()
+	ls := &ledgerSection{Category: q.Get("ledger"), Page: 1}
+	if p, err := strconv.Atoi(q.Get("ledger_page")); err == nil && p > 1 {
+		ls.Page = p
+	}
+
+	var err error
+	if ls.Categories, err = models.AllScoreCategories(db); err != nil {
+		return nil, err
+	}
+
+	// Ask for one extra entry, to know if there's another page
+	f := ledgerFilterFor(r)
+	f.Category = ls.Category
+	f.Limit = ledgerPageSize + 1
+	f.Offset = (ls.Page - 1) * ledgerPageSize
+	if ls.Entries, err = models.TeamLedger(db, teamID, f); err != nil {
+		return nil, err
+	}
+	if len(ls.Entries) > ledgerPageSize {
+		ls.Entries, ls.More = ls.Entries[:ledgerPageSize], true
+	}
+	return ls, nil
+}
@@ -53,6 +53,17 @@ func SetupCheckServiceLogger(lc *LogSettings) {
 	Logger = LogManager.newLogger(checkServiceLogName)
 }
 
+// SetupToolLogger instantiates a global logger for the one-off admin
+// commands (e.g. export & import), which always log to the terminal.
+// Logs go to stderr, leaving stdout for the command's own output.
+func SetupToolLogger(lc *LogSettings) {
+	lc.Stdout = true
+	setupLogManager(lc)
+
+	Logger = LogManager.newLogger("")
+	Logger.Out = os.Stderr
+}
+
 func setupLogManager(lc *LogSettings) {
 	if LogManager == nil {
 		LogManager = &LoggerManager{
@@ -54,14 +54,13 @@ func RequireAdmin(next http.Handler) http.Handler {
 	})
 }
 
-func RequireCtfStaff(next http.Handler) http.Handler {
+// RequireStaff only lets through teams that aren't blue teams. What each
+// staff role can actually do is checked with RequirePermission.
+func RequireStaff(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		team := getCtxTeam(r)
-
-		switch team.RoleName {
-		case models.TeamRoleAdmin, models.TeamRoleCtfCreator:
+		if isStaff(getCtxTeam(r)) {
 			next.ServeHTTP(w, r)
-		default:
+		} else {
 			render.Render(w, r, ErrForbidden)
 		}
 	})
@@ -69,7 +68,7 @@ func RequireCtfStaff(next http.Handler) http.Handler {
 
 func RequireEventStarted(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if isCtfStaff(getCtxTeam(r)) || time.Now().After(appCfg.Event.Start) {
+		if hasPermission(getCtxTeam(r), models.PermIgnoreSchedule) || time.Now().After(appCfg.Event.Start) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -87,7 +86,7 @@ func RequireEventStarted(next http.Handler) http.Handler {
 
 func RequireEventNotOver(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if isCtfStaff(getCtxTeam(r)) || time.Now().Before(appCfg.Event.End) {
+		if hasPermission(getCtxTeam(r), models.PermIgnoreSchedule) || time.Now().Before(appCfg.Event.End) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -132,7 +131,7 @@ func RequireNotOnBreak() func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if isCtfStaff(getCtxTeam(r)) || !onBreak.IsSet() {
+			if hasPermission(getCtxTeam(r), models.PermIgnoreSchedule) || !onBreak.IsSet() {
 				next.ServeHTTP(w, r)
 				return
 			}
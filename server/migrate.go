@@ -0,0 +1,237 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/migrations"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+)
+
+// migrationTable tracks which schema migrations have been applied. It lives in the
+// cyboard schema (unlike golang-migrate's table), so it's dropped along with everything else.
+const migrationTable = "cyboard.schema_migration"
+
+// MigrationStatus is a bundled migration, and when it was applied to the database.
+type MigrationStatus struct {
+	migrations.Migration
+	AppliedAt *time.Time
+}
+
+// Migrator runs the bundled schema migrations over a single connection. Each migration
+// runs in one transaction, along with its row in the tracking table.
+type Migrator struct {
+	conn *pgx.Conn
+	out  io.Writer
+}
+
+// NewMigrator connects to postgres. Migrations usually need a superuser (or the schema owner).
+func NewMigrator(uri string, out io.Writer) (*Migrator, error) {
+	cfg, err := pgx.ParseConnectionString(uri)
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse postgres uri")
+	}
+	conn, err := pgx.Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{conn: conn, out: out}, nil
+}
+
+func (m *Migrator) Close() error {
+	return m.conn.Close()
+}
+
+func (m *Migrator) ensureTable() error {
+	_, err := m.conn.Exec(`CREATE SCHEMA IF NOT EXISTS cyboard;
+	CREATE TABLE IF NOT EXISTS ` + migrationTable + ` (
+		version    integer PRIMARY KEY,
+		name       text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	return errors.WithMessage(err, "create "+migrationTable)
+}
+
+func tableExists(db models.DB) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, migrationTable).Scan(&exists)
+	return exists, err
+}
+
+// Status lists every bundled migration, and whether it's been applied.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	all := migrations.All()
+	statuses := make([]MigrationStatus, len(all))
+	for i, mig := range all {
+		statuses[i].Migration = mig
+	}
+
+	if exists, err := tableExists(m.conn); err != nil || !exists {
+		return statuses, err
+	}
+
+	rows, err := m.conn.Query(`SELECT version, applied_at FROM ` + migrationTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var (
+			version int
+			at      time.Time
+		)
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range statuses {
+		if at, ok := applied[statuses[i].Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// run executes one migration, then `record`s it in the tracking table, all in one
+// transaction. The migration file's own BEGIN & COMMIT are stripped, so a failure in
+// either one leaves the database as it was.
+func (m *Migrator) run(mig migrations.Migration, sql string, record func(tx *pgx.Tx) error) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(migrations.Body(sql)); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("migration %03d %s", mig.Version, mig.Name))
+	}
+	if err = record(tx); err != nil {
+		return errors.WithMessage(err, fmt.Sprintf("record migration %03d", mig.Version))
+	}
+	return tx.Commit()
+}
+
+// Up applies up to `steps` pending migrations (all of them, if steps <= 0).
+//
+// A database set up by hand, before migrations were tracked, must be given a
+// `baseline`: the version it's already at. Those migrations are marked as
+// applied, without being run.
+func (m *Migrator) Up(steps, baseline int) error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	anyApplied := false
+	for _, s := range statuses {
+		anyApplied = anyApplied || s.AppliedAt != nil
+	}
+	if !anyApplied && baseline <= 0 {
+		var untracked bool
+		if err = m.conn.QueryRow(`SELECT to_regclass('cyboard.team') IS NOT NULL`).Scan(&untracked); err != nil {
+			return err
+		} else if untracked {
+			return errors.New("the cyboard schema already exists, but no migrations are recorded. " +
+				"Use `--baseline <version>` to mark the migrations already applied by hand")
+		}
+	}
+
+	if err = m.ensureTable(); err != nil {
+		return err
+	}
+
+	const insertstr = `INSERT INTO ` + migrationTable + ` (version, name) VALUES ($1, $2)`
+	ran := 0
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			continue
+		}
+		if s.Version <= baseline {
+			fmt.Fprintf(m.out, "Baseline: marking %03d %s as applied\n", s.Version, s.Name)
+			if _, err = m.conn.Exec(insertstr, s.Version, s.Name); err != nil {
+				return errors.WithMessage(err, fmt.Sprintf("record migration %03d", s.Version))
+			}
+			continue
+		}
+
+		if steps > 0 && ran >= steps {
+			break
+		}
+		fmt.Fprintf(m.out, "Applying %03d %s\n", s.Version, s.Name)
+		err = m.run(s.Migration, s.Up, func(tx *pgx.Tx) error {
+			_, err := tx.Exec(insertstr, s.Version, s.Name)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		ran++
+	}
+
+	if ran == 0 && baseline <= 0 {
+		fmt.Fprintln(m.out, "No migrations to apply, the database is up to date")
+	}
+	return nil
+}
+
+// Down reverts the `steps` most recently applied migrations.
+func (m *Migrator) Down(steps int) error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	for i := len(statuses) - 1; i >= 0 && steps > 0; i-- {
+		s := statuses[i]
+		if s.AppliedAt == nil {
+			continue
+		}
+
+		fmt.Fprintf(m.out, "Reverting %03d %s\n", s.Version, s.Name)
+		err = m.run(s.Migration, s.Down, func(tx *pgx.Tx) error {
+			// The earliest migrations drop the whole schema, tracking table included
+			if exists, err := tableExists(tx); err != nil || !exists {
+				return err
+			}
+			_, err := tx.Exec(`DELETE FROM `+migrationTable+` WHERE version = $1`, s.Version)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		steps--
+	}
+	return nil
+}
+
+// PendingMigrations counts the bundled migrations not yet applied to the database.
+// Returns -1 if the database does not track migrations at all.
+func PendingMigrations(db models.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, migrationTable).Scan(&exists); err != nil || !exists {
+		return -1, err
+	}
+
+	var latest int
+	err := db.QueryRow(`SELECT coalesce(max(version), 0) FROM ` + migrationTable).Scan(&latest)
+	if err != nil {
+		return -1, err
+	}
+	pending := 0
+	for _, mig := range migrations.All() {
+		if mig.Version > latest {
+			pending++
+		}
+	}
+	return pending, nil
+}
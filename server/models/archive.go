@@ -0,0 +1,473 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// ArchiveTeam is a team, as saved in an event archive. Unlike Team, the password
+// hash is exported, unless it was left out on purpose.
+type ArchiveTeam struct {
+	Name       string   `json:"name"`           // name
+	RoleName   TeamRole `json:"role_name"`      // role_name
+	Hash       []byte   `json:"hash,omitempty"` // hash
+	Disabled   bool     `json:"disabled"`       // disabled
+	BlueteamIP *int16   `json:"blueteam_ip"`    // blueteam_ip
+
+	Hosts map[string]string `json:"hosts,omitempty"` // team_host, role → address
+}
+
+// ArchiveTeams fetches every team, along with its hosts, to be saved in an event archive.
+func ArchiveTeams(db DB, withHashes bool) ([]ArchiveTeam, error) {
+	const sqlstr = `SELECT id, name, role_name, hash, disabled, blueteam_ip FROM team ORDER BY id`
+
+	hosts, err := TeamHostMaps(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ts := []ArchiveTeam{}
+	for rows.Next() {
+		t, id := ArchiveTeam{}, 0
+		if err = rows.Scan(&id, &t.Name, &t.RoleName, &t.Hash, &t.Disabled, &t.BlueteamIP); err != nil {
+			return nil, err
+		}
+		t.Hosts = hosts[id]
+		if !withHashes {
+			t.Hash = nil
+		}
+		ts = append(ts, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// ArchivePlayer is a player, as saved in an event archive, with their team referenced
+// by name. Like ArchiveTeam, the password hash is exported, unless it was left out on purpose.
+type ArchivePlayer struct {
+	Name     string `json:"name"`           // name
+	Team     string `json:"team"`           // team.name
+	Hash     []byte `json:"hash,omitempty"` // hash
+	Disabled bool   `json:"disabled"`       // disabled
+}
+
+// ArchivePlayers fetches every player, to be saved in an event archive.
+func ArchivePlayers(db DB, withHashes bool) ([]ArchivePlayer, error) {
+	const sqlstr = `SELECT p.name, t.name, p.hash, p.disabled
+	FROM player AS p
+		JOIN team AS t ON p.team_id = t.id
+	ORDER BY p.id`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []ArchivePlayer{}
+	for rows.Next() {
+		x := ArchivePlayer{}
+		if err = rows.Scan(&x.Name, &x.Team, &x.Hash, &x.Disabled); err != nil {
+			return nil, err
+		}
+		if !withHashes {
+			x.Hash = nil
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// ArchiveChallenges fetches every ctf challenge, including the body
+// (which AllChallenges leaves out), to be saved in an event archive.
+func ArchiveChallenges(db DB) ([]Challenge, error) {
+	const sqlstr = `SELECT ` +
+		`id, name, category, designer, flag, total, body, hidden, created_at, modified_at ` +
+		`FROM challenge ` +
+		`ORDER BY id`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []Challenge{}
+	for rows.Next() {
+		x := Challenge{}
+		err = rows.Scan(&x.ID, &x.Name, &x.Category, &x.Designer, &x.Flag, &x.Total,
+			&x.Body, &x.Hidden, &x.CreatedAt, &x.ModifiedAt)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// ArchiveServiceCheck is a row of 'cyboard.service_check', with the team & service
+// referenced by name, since IDs won't line up with the database it gets imported into.
+type ArchiveServiceCheck struct {
+	CreatedAt time.Time  `json:"created_at"` // created_at
+	Team      string     `json:"team"`       // team.name
+	Service   string     `json:"service"`    // service.name
+	Status    ExitStatus `json:"status"`     // status
+	ExitCode  int16      `json:"exit_code"`  // exit_code
+}
+
+// ArchiveCtfSolve is a row of 'cyboard.ctf_solve', referenced by name.
+type ArchiveCtfSolve struct {
+	CreatedAt time.Time `json:"created_at"` // created_at
+	Team      string    `json:"team"`       // team.name
+	Challenge string    `json:"challenge"`  // challenge.name
+	Player    *string   `json:"player"`     // player.name
+}
+
+// ArchiveOtherPoints is a row of 'cyboard.other_points', referenced by name.
+type ArchiveOtherPoints struct {
+	CreatedAt time.Time  `json:"created_at"` // created_at
+	Team      string     `json:"team"`       // team.name
+	Points    float32    `json:"points"`     // points
+	Reason    string     `json:"reason"`     // reason
+	Category  string     `json:"category"`   // category
+	Kind      BonusKind  `json:"kind"`       // kind
+	Granter   *string    `json:"granter"`    // granter's team.name
+	Note      string     `json:"note"`       // note
+	RevokedAt *time.Time `json:"revoked_at"` // revoked_at
+	Revoker   *string    `json:"revoker"`    // revoker's team.name
+}
+
+// ArchiveIncidentReport is a row of 'cyboard.incident_report', referenced by name,
+// along with the points it was given for each part of the rubric.
+type ArchiveIncidentReport struct {
+	CreatedAt   time.Time  `json:"created_at"`  // created_at
+	Team        string     `json:"team"`        // team.name
+	Player      *string    `json:"player"`      // player.name
+	Title       string     `json:"title"`       // title
+	Timeline    string     `json:"timeline"`    // timeline
+	Indicators  string     `json:"indicators"`  // indicators
+	Remediation string     `json:"remediation"` // remediation
+	Judge       *string    `json:"judge"`       // judge's team.name
+	Feedback    string     `json:"feedback"`    // feedback
+	ScoredAt    *time.Time `json:"scored_at"`   // scored_at
+	RescoredAt  *time.Time `json:"rescored_at"` // rescored_at
+
+	Scores map[string]float32 `json:"scores,omitempty"` // incident_rubric_score, rubric name → points
+}
+
+// ArchiveInjectSubmission is a row of 'cyboard.inject_submission', referenced by name.
+type ArchiveInjectSubmission struct {
+	Inject      string    `json:"inject"`       // inject.title
+	Team        string    `json:"team"`         // team.name
+	Player      *string   `json:"player"`       // player.name
+	Body        string    `json:"body"`         // body
+	SubmittedAt time.Time `json:"submitted_at"` // submitted_at
+	Late        bool      `json:"late"`         // late
+
+	Points     *float32   `json:"points"`      // points
+	Judge      *string    `json:"judge"`       // judge's team.name
+	Feedback   string     `json:"feedback"`    // feedback
+	GradedAt   *time.Time `json:"graded_at"`   // graded_at
+	RegradedAt *time.Time `json:"regraded_at"` // regraded_at
+}
+
+// ArchiveScores is the full scoring history of an event.
+type ArchiveScores struct {
+	ServiceChecks     []ArchiveServiceCheck     `json:"service_checks"`
+	CtfSolves         []ArchiveCtfSolve         `json:"ctf_solves"`
+	OtherPoints       []ArchiveOtherPoints      `json:"other_points"`
+	IncidentReports   []ArchiveIncidentReport   `json:"incident_reports"`
+	InjectSubmissions []ArchiveInjectSubmission `json:"inject_submissions"`
+}
+
+// ArchiveScoringHistory fetches every scoring event, oldest first, to be saved in an event archive.
+func ArchiveScoringHistory(db DB) (*ArchiveScores, error) {
+	s := &ArchiveScores{
+		ServiceChecks:     []ArchiveServiceCheck{},
+		CtfSolves:         []ArchiveCtfSolve{},
+		OtherPoints:       []ArchiveOtherPoints{},
+		IncidentReports:   []ArchiveIncidentReport{},
+		InjectSubmissions: []ArchiveInjectSubmission{},
+	}
+
+	const checksSQL = `SELECT sc.created_at, t.name, s.name, sc.status, sc.exit_code
+	FROM service_check AS sc
+		JOIN team AS t ON sc.team_id = t.id
+		JOIN service AS s ON sc.service_id = s.id
+	ORDER BY sc.created_at, t.id, s.id`
+	rows, err := db.Query(checksSQL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "service checks")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		x := ArchiveServiceCheck{}
+		if err = rows.Scan(&x.CreatedAt, &x.Team, &x.Service, &x.Status, &x.ExitCode); err != nil {
+			return nil, err
+		}
+		s.ServiceChecks = append(s.ServiceChecks, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const solvesSQL = `SELECT cs.created_at, t.name, c.name, p.name
+	FROM ctf_solve AS cs
+		JOIN team AS t ON cs.team_id = t.id
+		JOIN challenge AS c ON cs.challenge_id = c.id
+		LEFT JOIN player AS p ON cs.player_id = p.id
+	ORDER BY cs.created_at`
+	rows, err = db.Query(solvesSQL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "ctf solves")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		x := ArchiveCtfSolve{}
+		if err = rows.Scan(&x.CreatedAt, &x.Team, &x.Challenge, &x.Player); err != nil {
+			return nil, err
+		}
+		s.CtfSolves = append(s.CtfSolves, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const otherSQL = `SELECT o.created_at, t.name, o.points, o.reason, o.category,
+		o.kind, g.name, o.note, o.revoked_at, rv.name
+	FROM other_points AS o
+		JOIN team AS t ON o.team_id = t.id
+		LEFT JOIN team AS g ON o.granter_id = g.id
+		LEFT JOIN team AS rv ON o.revoker_id = rv.id
+	ORDER BY o.created_at, o.id`
+	rows, err = db.Query(otherSQL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "other points")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		x := ArchiveOtherPoints{}
+		if err = rows.Scan(&x.CreatedAt, &x.Team, &x.Points, &x.Reason, &x.Category,
+			&x.Kind, &x.Granter, &x.Note, &x.RevokedAt, &x.Revoker); err != nil {
+			return nil, err
+		}
+		s.OtherPoints = append(s.OtherPoints, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if s.IncidentReports, err = archiveIncidentReports(db); err != nil {
+		return nil, errors.WithMessage(err, "incident reports")
+	}
+
+	const submissionsSQL = `SELECT i.title, t.name, p.name, s.body, s.submitted_at, s.late,
+		s.points, j.name, s.feedback, s.graded_at, s.regraded_at
+	FROM inject_submission AS s
+		JOIN inject AS i ON s.inject_id = i.id
+		JOIN team AS t ON s.team_id = t.id
+		LEFT JOIN player AS p ON s.player_id = p.id
+		LEFT JOIN team AS j ON s.judge_id = j.id
+	ORDER BY s.submitted_at, s.id`
+	rows, err = db.Query(submissionsSQL)
+	if err != nil {
+		return nil, errors.WithMessage(err, "inject submissions")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		x := ArchiveInjectSubmission{}
+		if err = rows.Scan(&x.Inject, &x.Team, &x.Player, &x.Body, &x.SubmittedAt, &x.Late,
+			&x.Points, &x.Judge, &x.Feedback, &x.GradedAt, &x.RegradedAt); err != nil {
+			return nil, err
+		}
+		s.InjectSubmissions = append(s.InjectSubmissions, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// archiveIncidentReports fetches every incident report, with its rubric scores keyed by rubric name.
+func archiveIncidentReports(db DB) ([]ArchiveIncidentReport, error) {
+	const reportsSQL = `SELECT ir.id, ir.created_at, t.name, p.name, ir.title, ir.timeline,
+		ir.indicators, ir.remediation, j.name, ir.feedback, ir.scored_at, ir.rescored_at
+	FROM incident_report AS ir
+		JOIN team AS t ON ir.team_id = t.id
+		LEFT JOIN player AS p ON ir.player_id = p.id
+		LEFT JOIN team AS j ON ir.judge_id = j.id
+	ORDER BY ir.created_at, ir.id`
+	rows, err := db.Query(reportsSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []ArchiveIncidentReport{}
+	byID := map[int]int{} // report id → index in xs
+	for rows.Next() {
+		x, id := ArchiveIncidentReport{}, 0
+		err = rows.Scan(&id, &x.CreatedAt, &x.Team, &x.Player, &x.Title, &x.Timeline,
+			&x.Indicators, &x.Remediation, &x.Judge, &x.Feedback, &x.ScoredAt, &x.RescoredAt)
+		if err != nil {
+			return nil, err
+		}
+		byID[id] = len(xs)
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	const scoresSQL = `SELECT s.report_id, r.name, s.points
+	FROM incident_rubric_score AS s
+		JOIN incident_rubric AS r ON s.rubric_id = r.id`
+	rows, err = db.Query(scoresSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			reportID int
+			rubric   string
+			points   float32
+		)
+		if err = rows.Scan(&reportID, &rubric, &points); err != nil {
+			return nil, err
+		}
+		x := &xs[byID[reportID]]
+		if x.Scores == nil {
+			x.Scores = map[string]float32{}
+		}
+		x.Scores[rubric] = points
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// HasScoringHistory checks if any points have been scored yet.
+func HasScoringHistory(db DB) (bool, error) {
+	const sqlstr = `SELECT EXISTS (SELECT 1 FROM service_check)
+		OR EXISTS (SELECT 1 FROM ctf_solve)
+		OR EXISTS (SELECT 1 FROM other_points)
+		OR EXISTS (SELECT 1 FROM incident_report)
+		OR EXISTS (SELECT 1 FROM inject_submission)`
+	var scored bool
+	err := db.QueryRow(sqlstr).Scan(&scored)
+	return scored, err
+}
+
+// Insert the scoring history into the database. Teams, services, challenges, injects, and parts
+// of the incident rubric are looked up by name, and must already exist. Players are looked up
+// by name too, and left blank if missing. Meant to be run inside a transaction.
+func (s *ArchiveScores) Insert(db DB) error {
+	insert := func(what, sqlstr string, args ...interface{}) error {
+		tag, err := db.Exec(sqlstr, args...)
+		if err != nil {
+			return errors.WithMessage(err, "insert "+what)
+		} else if tag.RowsAffected() != 1 {
+			return fmt.Errorf("insert %s: no matching team/target for %v", what, args)
+		}
+		return nil
+	}
+
+	const checkSQL = `INSERT INTO service_check (created_at, team_id, service_id, status, exit_code)
+	SELECT $1, t.id, s.id, $4, $5 FROM team AS t, service AS s WHERE t.name = $2 AND s.name = $3`
+	for _, x := range s.ServiceChecks {
+		if err := insert("service check", checkSQL, x.CreatedAt, x.Team, x.Service, x.Status, x.ExitCode); err != nil {
+			return err
+		}
+	}
+
+	const solveSQL = `INSERT INTO ctf_solve (created_at, team_id, challenge_id, player_id)
+	SELECT $1, t.id, c.id, (SELECT id FROM player WHERE name = $4)
+	FROM team AS t, challenge AS c WHERE t.name = $2 AND c.name = $3`
+	for _, x := range s.CtfSolves {
+		if err := insert("ctf solve", solveSQL, x.CreatedAt, x.Team, x.Challenge, x.Player); err != nil {
+			return err
+		}
+	}
+
+	// The staff who granted & revoked points may not have been archived, so they're left blank if missing
+	const otherSQL = `INSERT INTO other_points (created_at, team_id, points, reason, category,
+		kind, granter_id, note, revoked_at, revoker_id)
+	SELECT $1, t.id, $3, $4, $5, $6, (SELECT id FROM team WHERE name = $7), $8, $9, (SELECT id FROM team WHERE name = $10)
+	FROM team AS t WHERE t.name = $2`
+	for _, x := range s.OtherPoints {
+		category := x.Category
+		if category == "" {
+			category = DefaultBonusCategory // archives from before score categories
+		}
+		op := OtherPoints{Points: x.Points, Kind: x.Kind} // archives from before bonus kinds
+		err := insert("other points", otherSQL, x.CreatedAt, x.Team, x.Points, x.Reason, category,
+			op.kind(), x.Granter, x.Note, x.RevokedAt, x.Revoker)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Reports are inserted one by one, to tie their rubric scores to the new report ids
+	const reportSQL = `INSERT INTO incident_report (created_at, team_id, player_id, title, timeline,
+		indicators, remediation, judge_id, feedback, scored_at, rescored_at)
+	SELECT $1, t.id, (SELECT id FROM player WHERE name = $3), $4, $5, $6, $7,
+		(SELECT id FROM team WHERE name = $8), $9, $10, $11
+	FROM team AS t WHERE t.name = $2
+	RETURNING id`
+	const rubricScoreSQL = `INSERT INTO incident_rubric_score (report_id, rubric_id, points)
+	SELECT $1, r.id, $3 FROM incident_rubric AS r WHERE r.name = $2`
+	for _, x := range s.IncidentReports {
+		var id int
+		err := db.QueryRow(reportSQL, x.CreatedAt, x.Team, x.Player, x.Title, x.Timeline,
+			x.Indicators, x.Remediation, x.Judge, x.Feedback, x.ScoredAt, x.RescoredAt).Scan(&id)
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("insert incident report: no matching team for %q", x.Team)
+		} else if err != nil {
+			return errors.WithMessage(err, "insert incident report")
+		}
+		for rubric, points := range x.Scores {
+			if err = insert("incident rubric score", rubricScoreSQL, id, rubric, points); err != nil {
+				return err
+			}
+		}
+	}
+
+	const submissionSQL = `INSERT INTO inject_submission (inject_id, team_id, player_id, body, submitted_at, late,
+		points, judge_id, feedback, graded_at, regraded_at)
+	SELECT i.id, t.id, (SELECT id FROM player WHERE name = $3), $4, $5, $6,
+		$7, (SELECT id FROM team WHERE name = $8), $9, $10, $11
+	FROM inject AS i, team AS t WHERE i.title = $1 AND t.name = $2`
+	for _, x := range s.InjectSubmissions {
+		err := insert("inject submission", submissionSQL, x.Inject, x.Team, x.Player, x.Body, x.SubmittedAt, x.Late,
+			x.Points, x.Judge, x.Feedback, x.GradedAt, x.RegradedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ArchiveTeams(t *testing.T) {
+	prepareTestDatabase(t)
+
+	teams, err := ArchiveTeams(db, false)
+	if assert.Nil(t, err) && assert.Equal(t, 5, len(teams)) {
+		assert.Equal(t, "team1", teams[0].Name)
+		assert.Nil(t, teams[0].Hash, "Hashes are left out unless asked for")
+		assert.True(t, teams[2].Disabled)
+		assert.Equal(t, map[string]string{"web": "fd00:2::5"}, teams[1].Hosts)
+		assert.Nil(t, teams[3].Hosts, "Staff don't have hosts")
+	}
+
+	teams, err = ArchiveTeams(db, true)
+	if assert.Nil(t, err) && assert.Equal(t, 5, len(teams)) {
+		assert.NotEmpty(t, teams[0].Hash)
+	}
+}
+
+func Test_ArchivePlayers(t *testing.T) {
+	prepareTestDatabase(t)
+
+	players, err := ArchivePlayers(db, false)
+	if assert.Nil(t, err) && assert.Equal(t, 3, len(players)) {
+		assert.Equal(t, ArchivePlayer{Name: "alice", Team: "team1"}, players[0], "Hashes are left out unless asked for")
+		assert.Equal(t, "team2", players[2].Team)
+		assert.True(t, players[2].Disabled)
+	}
+
+	players, err = ArchivePlayers(db, true)
+	if assert.Nil(t, err) && assert.Equal(t, 3, len(players)) {
+		assert.NotEmpty(t, players[0].Hash)
+	}
+}
+
+func Test_ArchiveChallenges(t *testing.T) {
+	prepareTestDatabase(t)
+
+	chals, err := ArchiveChallenges(db)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(chals)) {
+		assert.NotEmpty(t, chals[0].Body, "Archived challenges keep their body")
+		assert.Equal(t, "flag{its_ok_tobe_rad_sometimes}", chals[0].Flag)
+	}
+}
+
+func Test_ArchiveScores_RoundTrip(t *testing.T) {
+	prepareTestDatabase(t)
+
+	scores, err := ArchiveScoringHistory(db)
+	require.Nil(t, err)
+	assert.Equal(t, 6, len(scores.ServiceChecks))
+	if assert.Equal(t, 2, len(scores.CtfSolves)) {
+		assert.Equal(t, "alice", *scores.CtfSolves[0].Player)
+		assert.Nil(t, scores.CtfSolves[1].Player, "Solved with the team login")
+	}
+	if assert.Equal(t, 1, len(scores.OtherPoints)) {
+		assert.Equal(t, "team1", scores.OtherPoints[0].Team)
+	}
+	if assert.Equal(t, 2, len(scores.IncidentReports)) {
+		unscored, scored := scores.IncidentReports[0], scores.IncidentReports[1]
+		assert.Equal(t, "alice", *unscored.Player)
+		assert.Nil(t, unscored.Judge)
+		assert.Empty(t, unscored.Scores)
+		assert.Equal(t, "bigpoppa", *scored.Judge)
+		assert.Equal(t, map[string]float32{"Timeline": 4, "Remediation": 2}, scored.Scores)
+	}
+	if assert.Equal(t, 3, len(scores.InjectSubmissions)) {
+		first, ungraded := scores.InjectSubmissions[0], scores.InjectSubmissions[2]
+		assert.Equal(t, "Password policy", first.Inject)
+		assert.Equal(t, "alice", *first.Player)
+		assert.Equal(t, "bigpoppa", *first.Judge)
+		assert.Equal(t, "New hire account", ungraded.Inject)
+		assert.Nil(t, ungraded.GradedAt)
+	}
+
+	for _, table := range []string{"service_check", "ctf_solve", "other_points", "incident_report", "inject_submission"} {
+		_, err = db.Exec("DELETE FROM " + table)
+		require.Nil(t, err)
+	}
+	scored, err := HasScoringHistory(db)
+	require.Nil(t, err)
+	assert.False(t, scored)
+
+	require.Nil(t, scores.Insert(db))
+	scored, err = HasScoringHistory(db)
+	require.Nil(t, err)
+	assert.True(t, scored)
+
+	again, err := ArchiveScoringHistory(db)
+	require.Nil(t, err)
+	assert.Equal(t, scores, again)
+
+	bad := &ArchiveScores{CtfSolves: []ArchiveCtfSolve{{Team: "nobody", Challenge: "Totally Rad Challenge"}}}
+	assert.NotNil(t, bad.Insert(db), "Unknown team names are an error")
+
+	bad = &ArchiveScores{IncidentReports: []ArchiveIncidentReport{{Team: "team1", Scores: map[string]float32{"Vibes": 1}}}}
+	assert.NotNil(t, bad.Insert(db), "Unknown parts of the rubric are an error")
+
+	bad = &ArchiveScores{InjectSubmissions: []ArchiveInjectSubmission{{Inject: "Nope", Team: "team1"}}}
+	assert.NotNil(t, bad.Insert(db), "Unknown injects are an error")
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry represents a row from 'cyboard.audit_log'.
+type AuditEntry struct {
+	ID        int64           `json:"id"`         // id
+	CreatedAt time.Time       `json:"created_at"` // created_at
+	ActorID   *int            `json:"actor_id"`   // actor_id
+	Actor     string          `json:"actor"`      // actor
+	Player    *string         `json:"player"`     // player
+	Action    string          `json:"action"`     // action
+	Target    string          `json:"target"`     // target
+	Status    int16           `json:"status"`     // status
+	Request   json.RawMessage `json:"request"`    // request
+	Before    json.RawMessage `json:"before"`     // before
+	After     json.RawMessage `json:"after"`      // after
+}
+
+// jsonParam passes raw JSON to postgres, with empty JSON becoming a NULL.
+func jsonParam(b json.RawMessage) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+// Insert adds the AuditEntry to the log.
+func (e *AuditEntry) Insert(db DB) error {
+	const sqlstr = `INSERT INTO audit_log (` +
+		`actor_id, actor, player, action, target, status, request, before, after` +
+		`) VALUES (` +
+		`$1, $2, $3, $4, $5, $6, $7, $8, $9` +
+		`) RETURNING id, created_at`
+
+	return db.QueryRow(sqlstr, e.ActorID, e.Actor, e.Player, e.Action, e.Target, e.Status,
+		jsonParam(e.Request), jsonParam(e.Before), jsonParam(e.After)).Scan(&e.ID, &e.CreatedAt)
+}
+
+// AuditFilter narrows down the audit log. Text fields match anywhere, ignoring case.
+// Zero values match everything.
+type AuditFilter struct {
+	Actor  string    // actor or player name
+	Action string    // e.g. "bonus", "PUT", "/teams"
+	Target string    // e.g. "/api/admin/teams/5"
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+	Limit  int
+}
+
+// AuditEntries searches the audit log, newest first.
+func AuditEntries(db DB, f AuditFilter) ([]AuditEntry, error) {
+	where, args := []string{"true"}, []interface{}{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Actor != "" {
+		add("(strpos(lower(actor), lower($%[1]d)) > 0 OR strpos(lower(player), lower($%[1]d)) > 0)", f.Actor)
+	}
+	if f.Action != "" {
+		add("strpos(lower(action), lower($%d)) > 0", f.Action)
+	}
+	if f.Target != "" {
+		add("strpos(lower(target), lower($%d)) > 0", f.Target)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= $%d", f.Since)
+	}
+	if !f.Until.IsZero() {
+		add("created_at < $%d", f.Until)
+	}
+	if f.Limit <= 0 {
+		f.Limit = 500
+	}
+	args = append(args, f.Limit)
+
+	sqlstr := `SELECT ` +
+		`id, created_at, actor_id, actor, player, action, target, status, request, before, after ` +
+		`FROM audit_log ` +
+		`WHERE ` + strings.Join(where, " AND ") + ` ` +
+		fmt.Sprintf(`ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := db.Query(sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []AuditEntry{}
+	for rows.Next() {
+		x := AuditEntry{}
+		err = rows.Scan(&x.ID, &x.CreatedAt, &x.ActorID, &x.Actor, &x.Player, &x.Action, &x.Target, &x.Status,
+			&x.Request, &x.Before, &x.After)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AuditEntries(t *testing.T) {
+	prepareTestDatabase(t)
+
+	adminID, player := 1, "auditor"
+	start := time.Now().Add(-time.Second)
+	entries := []AuditEntry{
+		{ActorID: &adminID, Actor: "audit-admin", Action: "PUT /api/admin/teams/{id}/", Target: "/api/admin/teams/2",
+			Status: 200, Request: json.RawMessage(`{"name":"team2"}`), Before: json.RawMessage(`{"id":2}`)},
+		{ActorID: &adminID, Actor: "audit-admin", Player: &player, Action: "POST /api/admin/grant_bonus",
+			Target: "/api/admin/grant_bonus", Status: 400},
+	}
+	for i := range entries {
+		require.NoError(t, entries[i].Insert(db))
+		assert.NotZero(t, entries[i].ID)
+	}
+
+	found, err := AuditEntries(db, AuditFilter{Actor: "AUDIT-ADMIN", Since: start})
+	require.NoError(t, err)
+	if assert.Equal(t, 2, len(found)) {
+		assert.Equal(t, entries[1].ID, found[0].ID, "Newest first")
+		assert.Equal(t, "auditor", *found[0].Player)
+		assert.Nil(t, found[0].Request, "Empty JSON is saved as NULL")
+		assert.JSONEq(t, `{"id":2}`, string(found[1].Before))
+		assert.Nil(t, found[1].After)
+	}
+
+	found, err = AuditEntries(db, AuditFilter{Actor: "auditor", Action: "bonus", Since: start})
+	require.NoError(t, err)
+	if assert.Equal(t, 1, len(found)) {
+		assert.Equal(t, entries[1].ID, found[0].ID)
+	}
+
+	found, err = AuditEntries(db, AuditFilter{Target: "/teams/2", Since: start, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(found))
+
+	found, err = AuditEntries(db, AuditFilter{Actor: "audit-admin", Until: start})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
@@ -0,0 +1,98 @@
+// Package models contains the types for schema 'cyboard'.
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// BonusKind is the 'bonus_kind' enum type from schema 'cyboard'.
+type BonusKind uint16
+
+const (
+	// BonusKindUnspecified is an invalid BonusKind, likely bad user input.
+	BonusKindUnspecified = BonusKind(0)
+
+	// BonusKindBonus is the 'bonus' BonusKind.
+	BonusKindBonus = BonusKind(1)
+
+	// BonusKindPenalty is the 'penalty' BonusKind.
+	BonusKindPenalty = BonusKind(2)
+
+	// BonusKindRedteam is the 'redteam' BonusKind.
+	BonusKindRedteam = BonusKind(3)
+
+	// BonusKindInject is the 'inject' BonusKind.
+	BonusKindInject = BonusKind(4)
+)
+
+// BonusKinds lists every valid BonusKind.
+var BonusKinds = []BonusKind{
+	BonusKindBonus,
+	BonusKindPenalty,
+	BonusKindRedteam,
+	BonusKindInject,
+}
+
+// String returns the string value of the BonusKind.
+func (bk BonusKind) String() string {
+	var enumVal string
+
+	switch bk {
+	case BonusKindBonus:
+		enumVal = "bonus"
+
+	case BonusKindPenalty:
+		enumVal = "penalty"
+
+	case BonusKindRedteam:
+		enumVal = "redteam"
+
+	case BonusKindInject:
+		enumVal = "inject"
+	}
+
+	return enumVal
+}
+
+// MarshalText marshals BonusKind into text.
+func (bk BonusKind) MarshalText() ([]byte, error) {
+	return []byte(bk.String()), nil
+}
+
+// UnmarshalText unmarshals BonusKind from text.
+func (bk *BonusKind) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "bonus":
+		*bk = BonusKindBonus
+
+	case "penalty":
+		*bk = BonusKindPenalty
+
+	case "redteam":
+		*bk = BonusKindRedteam
+
+	case "inject":
+		*bk = BonusKindInject
+
+	default:
+		return fmt.Errorf("invalid BonusKind %q", text)
+	}
+
+	return nil
+}
+
+// Value satisfies the sql/driver.Valuer interface for BonusKind.
+func (bk BonusKind) Value() (driver.Value, error) {
+	return bk.String(), nil
+}
+
+// Scan satisfies the database/sql.Scanner interface for BonusKind.
+func (bk *BonusKind) Scan(src interface{}) error {
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("invalid BonusKind '%v'", src)
+	}
+
+	return bk.UnmarshalText([]byte(str))
+}
@@ -0,0 +1,288 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// CheckRunner represents a row from 'cyboard.check_runner': a remote service monitor,
+// which runs its assigned checks and reports the results back over the API.
+type CheckRunner struct {
+	ID         int        `json:"id"`           // id
+	Name       string     `json:"name"`         // name
+	TokenHash  []byte     `json:"-"`            // token_hash
+	TeamIDs    []int32    `json:"team_ids"`     // team_ids
+	ServiceIDs []int32    `json:"service_ids"`  // service_ids
+	Scoring    bool       `json:"scoring"`      // scoring
+	Disabled   bool       `json:"disabled"`     // disabled
+	CreatedAt  time.Time  `json:"created_at"`   // created_at
+	LastSeenAt *time.Time `json:"last_seen_at"` // last_seen_at
+}
+
+// Validate checks the runner's fields, before they're saved.
+func (cr *CheckRunner) Validate() error {
+	if cr.Name == "" {
+		return errors.New("a check runner needs a name")
+	}
+	return nil
+}
+
+// Insert a new check runner. The TokenHash must be set.
+func (cr *CheckRunner) Insert(db DB) error {
+	const sqlstr = `INSERT INTO check_runner (name, token_hash, team_ids, service_ids, scoring, disabled)
+	VALUES ($1, $2, COALESCE($3::int[], '{}'), COALESCE($4::int[], '{}'), $5, $6)
+	RETURNING id, team_ids, service_ids, created_at`
+	return db.QueryRow(sqlstr, cr.Name, cr.TokenHash, cr.TeamIDs, cr.ServiceIDs, cr.Scoring, cr.Disabled).
+		Scan(&cr.ID, &cr.TeamIDs, &cr.ServiceIDs, &cr.CreatedAt)
+}
+
+// Update a runner's name & assignment. The token is changed with SetToken.
+// Returns pgx.ErrNoRows if the runner doesn't exist.
+func (cr *CheckRunner) Update(db DB) error {
+	const sqlstr = `UPDATE check_runner
+	SET (name, team_ids, service_ids, scoring, disabled) = ($2, COALESCE($3::int[], '{}'), COALESCE($4::int[], '{}'), $5, $6)
+	WHERE id = $1
+	RETURNING team_ids, service_ids, created_at, last_seen_at`
+	return db.QueryRow(sqlstr, cr.ID, cr.Name, cr.TeamIDs, cr.ServiceIDs, cr.Scoring, cr.Disabled).
+		Scan(&cr.TeamIDs, &cr.ServiceIDs, &cr.CreatedAt, &cr.LastSeenAt)
+}
+
+// SetToken replaces the runner's token, so the old one stops working.
+func (cr *CheckRunner) SetToken(db DB, tokenHash []byte) error {
+	const sqlstr = `UPDATE check_runner SET token_hash = $2 WHERE id = $1`
+	res, err := db.Exec(sqlstr, cr.ID, tokenHash)
+	if err == nil && res.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	cr.TokenHash = tokenHash
+	return err
+}
+
+// Seen marks the runner as having just contacted the server.
+func (cr *CheckRunner) Seen(db DB) error {
+	const sqlstr = `UPDATE check_runner SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING last_seen_at`
+	return db.QueryRow(sqlstr, cr.ID).Scan(&cr.LastSeenAt)
+}
+
+// Delete a check runner, along with the results it reported for cross-checking.
+func (cr *CheckRunner) Delete(db DB) error {
+	const sqlstr = `DELETE FROM check_runner WHERE id = $1`
+	_, err := db.Exec(sqlstr, cr.ID)
+	return err
+}
+
+const checkRunnerColumns = `id, name, token_hash, team_ids, service_ids, scoring, disabled, created_at, last_seen_at`
+
+// CheckRunnerByID retrieves a runner by its id.
+func CheckRunnerByID(db DB, id int) (*CheckRunner, error) {
+	const sqlstr = `SELECT ` + checkRunnerColumns + ` FROM check_runner WHERE id = $1`
+	cr := CheckRunner{}
+	err := db.QueryRow(sqlstr, id).Scan(&cr.ID, &cr.Name, &cr.TokenHash, &cr.TeamIDs, &cr.ServiceIDs,
+		&cr.Scoring, &cr.Disabled, &cr.CreatedAt, &cr.LastSeenAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// CheckRunnerByToken retrieves the enabled runner with the token hash given.
+// Returns pgx.ErrNoRows if there is none.
+func CheckRunnerByToken(db DB, tokenHash []byte) (*CheckRunner, error) {
+	const sqlstr = `SELECT ` + checkRunnerColumns + ` FROM check_runner WHERE token_hash = $1 AND NOT disabled`
+	cr := CheckRunner{}
+	err := db.QueryRow(sqlstr, tokenHash).Scan(&cr.ID, &cr.Name, &cr.TokenHash, &cr.TeamIDs, &cr.ServiceIDs,
+		&cr.Scoring, &cr.Disabled, &cr.CreatedAt, &cr.LastSeenAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// AllCheckRunners fetches every check runner, ordered by id.
+func AllCheckRunners(db DB) ([]CheckRunner, error) {
+	const sqlstr = `SELECT ` + checkRunnerColumns + ` FROM check_runner ORDER BY id`
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []CheckRunner{}
+	for rows.Next() {
+		x := CheckRunner{}
+		err = rows.Scan(&x.ID, &x.Name, &x.TokenHash, &x.TeamIDs, &x.ServiceIDs,
+			&x.Scoring, &x.Disabled, &x.CreatedAt, &x.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// LiveScoringRunners fetches the ids of the enabled scoring runners that have checked
+// in within `staleAfter`, whose checks the central monitor leaves to them, ordered by id.
+func LiveScoringRunners(db DB, staleAfter time.Duration) ([]int, error) {
+	const sqlstr = `SELECT id FROM check_runner
+	WHERE scoring AND NOT disabled AND last_seen_at > CURRENT_TIMESTAMP - $1 * interval '1 second'
+	ORDER BY id`
+	rows, err := db.Query(sqlstr, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// RunnerAssignment is one check assigned to a runner, from the 'cyboard.check_runner_assignment' view.
+// Scores is set for the scoring runner with the lowest id, of those that checked in recently.
+type RunnerAssignment struct {
+	TeamID    int  `json:"team_id"`    // team_id
+	ServiceID int  `json:"service_id"` // service_id
+	Scores    bool `json:"scores"`     // scores
+}
+
+// CheckRunnerAssignments fetches the checks a runner is assigned, and whether its results are scored.
+// Scoring runners that haven't checked in within `staleAfter` are passed over, like in
+// MonitorTeamsAndServices, so the next scoring runner assigned their checks scores them.
+func CheckRunnerAssignments(db DB, runnerID int, staleAfter time.Duration) ([]RunnerAssignment, error) {
+	const sqlstr = `SELECT team_id, service_id, scores FROM (
+		SELECT runner_id, team_id, service_id,
+			live AND runner_id = min(runner_id) FILTER (WHERE live) OVER (PARTITION BY team_id, service_id) AS scores
+		FROM (SELECT runner_id, team_id, service_id,
+				scoring AND COALESCE(last_seen_at > CURRENT_TIMESTAMP - $2 * interval '1 second', false) AS live
+			FROM check_runner_assignment) AS a
+	) AS scored
+	WHERE runner_id = $1
+	ORDER BY team_id, service_id`
+	rows, err := db.Query(sqlstr, runnerID, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []RunnerAssignment{}
+	for rows.Next() {
+		x := RunnerAssignment{}
+		if err = rows.Scan(&x.TeamID, &x.ServiceID, &x.Scores); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// RunnerCheck represents a row from 'cyboard.runner_check': a result reported by
+// a runner that doesn't score the check, kept to cross-check the scored results.
+type RunnerCheck struct {
+	CreatedAt time.Time  `json:"created_at"` // created_at
+	RunnerID  int        `json:"runner_id"`  // runner_id
+	TeamID    int        `json:"team_id"`    // team_id
+	ServiceID int        `json:"service_id"` // service_id
+	Status    ExitStatus `json:"status"`     // status
+	ExitCode  int16      `json:"exit_code"`  // exit_code
+}
+
+var (
+	runnerCheckTableIdent   = pgx.Identifier{"cyboard", "runner_check"}
+	runnerCheckTableColumns = []string{
+		"created_at", "runner_id", "team_id", "service_id", "status", "exit_code",
+	}
+)
+
+// RunnerCheckSlice is an array of RunnerChecks, suitable to insert many of at once.
+type RunnerCheckSlice []RunnerCheck
+
+// Insert a batch of cross-check results efficiently into the database.
+func (rc RunnerCheckSlice) Insert(db DB) error {
+	rows := make([][]interface{}, len(rc))
+	for i, c := range rc {
+		rows[i] = []interface{}{c.CreatedAt, c.RunnerID, c.TeamID, c.ServiceID, c.Status, c.ExitCode}
+	}
+	_, err := db.CopyFrom(runnerCheckTableIdent, runnerCheckTableColumns, pgx.CopyFromRows(rows))
+	return err
+}
+
+// RunnerDisagreement is a check where a runner's latest result differs from the scored one.
+type RunnerDisagreement struct {
+	RunnerID     int        `json:"runner_id"`     // check_runner.id
+	RunnerName   string     `json:"runner_name"`   // check_runner.name
+	TeamID       int        `json:"team_id"`       // team.id
+	TeamName     string     `json:"team_name"`     // team.name
+	ServiceID    int        `json:"service_id"`    // service.id
+	ServiceName  string     `json:"service_name"`  // service.name
+	RunnerStatus ExitStatus `json:"runner_status"` // runner_check.status
+	ScoredStatus ExitStatus `json:"scored_status"` // service_check.status
+	CheckedAt    time.Time  `json:"checked_at"`    // runner_check.created_at
+}
+
+// RunnerDisagreements compares each runner's latest cross-check of a team's service
+// against the latest scored result, for the checks since the time given.
+func RunnerDisagreements(db DB, since time.Time) ([]RunnerDisagreement, error) {
+	const sqlstr = `
+	WITH runner_latest AS (
+		SELECT runner_id, team_id, service_id,
+			last(status, created_at) AS status, max(created_at) AS checked_at
+		FROM runner_check
+		WHERE created_at >= $1
+		GROUP BY runner_id, team_id, service_id
+	), scored_latest AS (
+		SELECT team_id, service_id, last(status, created_at) AS status
+		FROM service_check
+		WHERE created_at >= $1
+		GROUP BY team_id, service_id
+	)
+	SELECT r.id, r.name, t.id, t.name, s.id, s.name, rl.status, sl.status, rl.checked_at
+	FROM runner_latest AS rl
+		JOIN scored_latest AS sl ON rl.team_id = sl.team_id AND rl.service_id = sl.service_id
+		JOIN check_runner AS r ON rl.runner_id = r.id
+		JOIN team AS t ON rl.team_id = t.id
+		JOIN service AS s ON rl.service_id = s.id
+	WHERE rl.status <> sl.status
+	ORDER BY t.id, s.id, r.id`
+
+	rows, err := db.Query(sqlstr, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []RunnerDisagreement{}
+	for rows.Next() {
+		x := RunnerDisagreement{}
+		err = rows.Scan(&x.RunnerID, &x.RunnerName, &x.TeamID, &x.TeamName, &x.ServiceID, &x.ServiceName,
+			&x.RunnerStatus, &x.ScoredStatus, &x.CheckedAt)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CheckRunner_Validate(t *testing.T) {
+	assert.Nil(t, (&CheckRunner{Name: "team1-inside"}).Validate())
+	assert.Error(t, (&CheckRunner{}).Validate())
+}
+
+func Test_CheckRunner_Insert(t *testing.T) {
+	prepareTestDatabase(t)
+
+	cr := &CheckRunner{Name: "new", TokenHash: []byte{4}}
+	require.Nil(t, cr.Insert(db))
+	assert.NotZero(t, cr.ID)
+	assert.Equal(t, []int32{}, cr.TeamIDs, "No teams means all of them")
+	assert.False(t, cr.CreatedAt.IsZero())
+
+	dupe := &CheckRunner{Name: "new", TokenHash: []byte{5}}
+	assert.Error(t, dupe.Insert(db), "Runner names are unique")
+}
+
+func Test_CheckRunnerByToken(t *testing.T) {
+	prepareTestDatabase(t)
+
+	cr, err := CheckRunnerByToken(db, []byte{1})
+	require.Nil(t, err)
+	assert.Equal(t, "team1-inside", cr.Name)
+	assert.Equal(t, []int32{1}, cr.TeamIDs)
+
+	_, err = CheckRunnerByToken(db, []byte{3})
+	assert.Equal(t, pgx.ErrNoRows, err, "Disabled runners can't authenticate")
+
+	require.Nil(t, cr.SetToken(db, []byte{9}))
+	_, err = CheckRunnerByToken(db, []byte{1})
+	assert.Equal(t, pgx.ErrNoRows, err, "The old token stops working")
+	_, err = CheckRunnerByToken(db, []byte{9})
+	assert.Nil(t, err)
+
+	assert.Equal(t, pgx.ErrNoRows, (&CheckRunner{ID: 99}).SetToken(db, []byte{8}))
+}
+
+func Test_CheckRunnerAssignments(t *testing.T) {
+	prepareTestDatabase(t)
+
+	assigned, err := CheckRunnerAssignments(db, 1, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, []RunnerAssignment{{TeamID: 1, ServiceID: 1, Scores: false}}, assigned,
+		"Only enabled services, for the runner's teams")
+
+	assigned, err = CheckRunnerAssignments(db, 2, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, []RunnerAssignment{{TeamID: 1, ServiceID: 1}, {TeamID: 2, ServiceID: 1}}, assigned,
+		"Every active blueteam")
+
+	assigned, err = CheckRunnerAssignments(db, 3, time.Minute)
+	require.Nil(t, err)
+	assert.Empty(t, assigned, "Disabled runners aren't assigned anything")
+}
+
+func Test_CheckRunner_Scoring(t *testing.T) {
+	prepareTestDatabase(t)
+
+	// Both runners score, so the first one assigned each check scores it
+	for _, id := range []int{1, 2} {
+		cr, err := CheckRunnerByID(db, id)
+		require.Nil(t, err)
+		cr.Scoring = true
+		require.Nil(t, cr.Update(db))
+		require.Nil(t, cr.Seen(db))
+	}
+	live, err := LiveScoringRunners(db, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, live)
+
+	assigned, err := CheckRunnerAssignments(db, 2, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, []RunnerAssignment{{TeamID: 1, ServiceID: 1, Scores: false}, {TeamID: 2, ServiceID: 1, Scores: true}}, assigned)
+
+	tass, err := MonitorTeamsAndServices(db, time.Minute)
+	require.Nil(t, err)
+	assert.Empty(t, tass, "The central monitor leaves checks to the scoring runners")
+
+	_, err = db.Exec(`UPDATE check_runner SET last_seen_at = CURRENT_TIMESTAMP - interval '5 minutes' WHERE id = 1`)
+	require.Nil(t, err)
+	live, err = LiveScoringRunners(db, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, []int{2}, live)
+	assigned, err = CheckRunnerAssignments(db, 2, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, []RunnerAssignment{{TeamID: 1, ServiceID: 1, Scores: true}, {TeamID: 2, ServiceID: 1, Scores: true}}, assigned,
+		"The next scoring runner takes over a stale runner's checks")
+	assigned, err = CheckRunnerAssignments(db, 1, time.Minute)
+	require.Nil(t, err)
+	assert.Equal(t, []RunnerAssignment{{TeamID: 1, ServiceID: 1, Scores: false}}, assigned)
+	tass, err = MonitorTeamsAndServices(db, time.Minute)
+	require.Nil(t, err)
+	assert.Empty(t, tass)
+
+	_, err = db.Exec(`UPDATE check_runner SET last_seen_at = CURRENT_TIMESTAMP - interval '5 minutes' WHERE id = 2`)
+	require.Nil(t, err)
+	tass, err = MonitorTeamsAndServices(db, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tass), "The central monitor takes back checks with no scoring runner checking in")
+
+	tass, err = RunnerTeamsAndServices(db, 1)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(tass)) {
+		assert.Equal(t, "team1", tass[0].Team.Name)
+		assert.Equal(t, "ping", tass[0].Service.Name)
+		assert.Equal(t, map[string]string{"web": "10.0.1.5", "mail": "mail.team1.example"}, tass[0].Team.Hosts)
+	}
+}
+
+func Test_RunnerCheckSlice_Insert(t *testing.T) {
+	prepareTestDatabase(t)
+
+	now := time.Now()
+	checks := RunnerCheckSlice{
+		{CreatedAt: now, RunnerID: 2, TeamID: 1, ServiceID: 1, Status: ExitStatusPass},
+		{CreatedAt: now, RunnerID: 2, TeamID: 2, ServiceID: 1, Status: ExitStatusTimeout, ExitCode: 129},
+	}
+	require.Nil(t, checks.Insert(db))
+
+	var n int
+	require.Nil(t, db.QueryRow(`SELECT count(*) FROM runner_check WHERE created_at = $1`, now).Scan(&n))
+	assert.Equal(t, 2, n)
+
+	require.Nil(t, (&CheckRunner{ID: 2}).Delete(db))
+	require.Nil(t, db.QueryRow(`SELECT count(*) FROM runner_check WHERE runner_id = 2`).Scan(&n))
+	assert.Zero(t, n, "A runner's results go with it")
+}
+
+func Test_RunnerDisagreements(t *testing.T) {
+	prepareTestDatabase(t)
+
+	since := time.Date(2018, 7, 29, 9, 0, 0, 0, time.FixedZone("EDT", -4*60*60))
+	xs, err := RunnerDisagreements(db, since)
+	require.Nil(t, err)
+	if assert.Equal(t, 2, len(xs)) {
+		assert.Equal(t, "team1-inside", xs[0].RunnerName)
+		assert.Equal(t, "team1", xs[0].TeamName)
+		assert.Equal(t, ExitStatusFail, xs[0].RunnerStatus)
+		assert.Equal(t, ExitStatusPass, xs[0].ScoredStatus)
+
+		assert.Equal(t, "crosscheck", xs[1].RunnerName)
+		assert.Equal(t, "team2", xs[1].TeamName)
+		assert.Equal(t, ExitStatusFail, xs[1].RunnerStatus, "Latest result")
+		assert.Equal(t, ExitStatusPartial, xs[1].ScoredStatus)
+	}
+
+	xs, err = RunnerDisagreements(db, since.Add(time.Hour))
+	require.Nil(t, err)
+	assert.Empty(t, xs, "Nothing checked since")
+}
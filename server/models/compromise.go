@@ -0,0 +1,189 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// CompromiseReport represents a row from 'cyboard.compromise_report'.
+// Approved is nil while the report waits for the white team to review it.
+type CompromiseReport struct {
+	ID         int                `json:"id"`          // id
+	TeamID     int                `json:"team_id"`     // team_id
+	ReporterID *int               `json:"reporter_id"` // reporter_id
+	ServiceID  *int               `json:"service_id"`  // service_id
+	Host       string             `json:"host"`        // host
+	Title      string             `json:"title"`       // title
+	Evidence   string             `json:"evidence"`    // evidence
+	Severity   CompromiseSeverity `json:"severity"`    // severity
+	CreatedAt  time.Time          `json:"created_at"`  // created_at
+
+	Approved   *bool      `json:"approved"`    // approved
+	Points     *float32   `json:"points"`      // points
+	ReviewerID *int       `json:"reviewer_id"` // reviewer_id
+	ReviewNote string     `json:"review_note"` // review_note
+	ReviewedAt *time.Time `json:"reviewed_at"` // reviewed_at
+}
+
+// Status is where the report is in review: "pending", "approved", or "rejected".
+func (cr *CompromiseReport) Status() string {
+	switch {
+	case cr.Approved == nil:
+		return "pending"
+	case *cr.Approved:
+		return "approved"
+	default:
+		return "rejected"
+	}
+}
+
+// Insert files a new compromise report, filling in its ID & creation time.
+func (cr *CompromiseReport) Insert(db DB) error {
+	const sqlstr = `INSERT INTO compromise_report (` +
+		`team_id, reporter_id, service_id, host, title, evidence, severity` +
+		`) VALUES (` +
+		`$1, $2, $3, $4, $5, $6, $7` +
+		`) RETURNING id, created_at`
+
+	return db.QueryRow(sqlstr, cr.TeamID, cr.ReporterID, cr.ServiceID, cr.Host, cr.Title, cr.Evidence,
+		cr.Severity).Scan(&cr.ID, &cr.CreatedAt)
+}
+
+// CompromiseReportByID retrieves a row from 'cyboard.compromise_report' as a CompromiseReport.
+func CompromiseReportByID(db DB, id int) (*CompromiseReport, error) {
+	const sqlstr = `SELECT ` +
+		`id, team_id, reporter_id, service_id, host, title, evidence, severity, created_at, ` +
+		`approved, points, reviewer_id, review_note, reviewed_at ` +
+		`FROM compromise_report ` +
+		`WHERE id = $1`
+	cr := CompromiseReport{}
+	err := db.QueryRow(sqlstr, id).Scan(&cr.ID, &cr.TeamID, &cr.ReporterID, &cr.ServiceID, &cr.Host,
+		&cr.Title, &cr.Evidence, &cr.Severity, &cr.CreatedAt,
+		&cr.Approved, &cr.Points, &cr.ReviewerID, &cr.ReviewNote, &cr.ReviewedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cr, nil
+}
+
+// compromiseDeductionReason is what the deduction for an approved report says, in other_points.
+func compromiseDeductionReason(cr *CompromiseReport) string {
+	return fmt.Sprintf("Red team compromise #%d (%s): %s", cr.ID, cr.Severity, cr.Title)
+}
+
+// ApproveCompromiseReport accepts a pending report, docking its team `points`.
+// Returns pgx.ErrNoRows if the report doesn't exist, or was already reviewed.
+func ApproveCompromiseReport(db TXer, id, reviewerID int, points float32, note string) error {
+	if points < 0 {
+		return errors.New("points to deduct must not be negative")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const sqlstr = `UPDATE compromise_report SET ` +
+		`(approved, points, reviewer_id, review_note, reviewed_at) = (true, $2, $3, $4, CURRENT_TIMESTAMP) ` +
+		`WHERE id = $1 AND approved IS NULL ` +
+		`RETURNING team_id, title, severity`
+	cr := CompromiseReport{ID: id}
+	err = tx.QueryRow(sqlstr, id, points, reviewerID, note).Scan(&cr.TeamID, &cr.Title, &cr.Severity)
+	if err != nil {
+		return err
+	}
+
+	deduction := OtherPoints{TeamID: cr.TeamID, Points: -points, Reason: compromiseDeductionReason(&cr),
+		Kind: BonusKindRedteam, GranterID: &reviewerID, Note: note}
+	if err = deduction.Insert(tx); err != nil {
+		return errors.WithMessage(err, "insert deduction")
+	}
+	return tx.Commit()
+}
+
+// RejectCompromiseReport turns down a pending report, without docking any points.
+// Returns pgx.ErrNoRows if the report doesn't exist, or was already reviewed.
+func RejectCompromiseReport(db DB, id, reviewerID int, note string) error {
+	const sqlstr = `UPDATE compromise_report SET ` +
+		`(approved, reviewer_id, review_note, reviewed_at) = (false, $2, $3, CURRENT_TIMESTAMP) ` +
+		`WHERE id = $1 AND approved IS NULL`
+	tag, err := db.Exec(sqlstr, id, reviewerID, note)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+	}
+	return err
+}
+
+// CompromiseReportView is a compromise report, along with the names of everyone involved.
+type CompromiseReportView struct {
+	CompromiseReport
+	TeamName     string  `json:"team_name"`     // team.name
+	ServiceName  *string `json:"service_name"`  // service.name
+	ReporterName *string `json:"reporter_name"` // team.name
+	ReviewerName *string `json:"reviewer_name"` // team.name
+}
+
+const compromiseViewSelect = `SELECT ` +
+	`cr.id, cr.team_id, cr.reporter_id, cr.service_id, cr.host, cr.title, cr.evidence, cr.severity, cr.created_at, ` +
+	`cr.approved, cr.points, cr.reviewer_id, cr.review_note, cr.reviewed_at, ` +
+	`team.name, service.name, reporter.name, reviewer.name ` +
+	`FROM compromise_report AS cr ` +
+	`JOIN team ON cr.team_id = team.id ` +
+	`LEFT JOIN service ON cr.service_id = service.id ` +
+	`LEFT JOIN team AS reporter ON cr.reporter_id = reporter.id ` +
+	`LEFT JOIN team AS reviewer ON cr.reviewer_id = reviewer.id `
+
+func queryCompromiseViews(db DB, sqlstr string, args ...interface{}) ([]CompromiseReportView, error) {
+	rows, err := db.Query(sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []CompromiseReportView{}
+	for rows.Next() {
+		x := CompromiseReportView{}
+		err = rows.Scan(&x.ID, &x.TeamID, &x.ReporterID, &x.ServiceID, &x.Host, &x.Title, &x.Evidence,
+			&x.Severity, &x.CreatedAt, &x.Approved, &x.Points, &x.ReviewerID, &x.ReviewNote, &x.ReviewedAt,
+			&x.TeamName, &x.ServiceName, &x.ReporterName, &x.ReviewerName)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// AllCompromiseReports fetches the white team's review queue. Pending reports come
+// first, oldest first, followed by the reviewed ones, most recently reviewed first.
+func AllCompromiseReports(db DB) ([]CompromiseReportView, error) {
+	const sqlstr = compromiseViewSelect +
+		`ORDER BY cr.reviewed_at DESC NULLS FIRST, cr.created_at, cr.id`
+	return queryCompromiseViews(db, sqlstr)
+}
+
+// ReporterCompromiseReports fetches the reports filed by a red team account, newest first.
+func ReporterCompromiseReports(db DB, reporterID int) ([]CompromiseReportView, error) {
+	const sqlstr = compromiseViewSelect +
+		`WHERE cr.reporter_id = $1 ` +
+		`ORDER BY cr.created_at DESC, cr.id DESC`
+	return queryCompromiseViews(db, sqlstr, reporterID)
+}
+
+// TeamCompromiseReports fetches the approved reports against a blue team, newest first.
+// Pending & rejected reports are kept from the team.
+func TeamCompromiseReports(db DB, teamID int) ([]CompromiseReportView, error) {
+	const sqlstr = compromiseViewSelect +
+		`WHERE cr.team_id = $1 AND cr.approved ` +
+		`ORDER BY cr.created_at DESC, cr.id DESC`
+	return queryCompromiseViews(db, sqlstr, teamID)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/* compromise_report.yml has two reports, both filed by secondfiddle (id=101):
+Report 1 is against team1's ping service, and is waiting for review.
+Report 2 is against team2's web box, and was approved by bigpoppa (id=100) for 20 points.
+*/
+
+func Test_AllCompromiseReports(t *testing.T) {
+	prepareTestDatabase(t)
+
+	reports, err := AllCompromiseReports(db)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(reports)) {
+		assert.Equal(t, []int{1, 2}, []int{reports[0].ID, reports[1].ID}, "Pending reports sort first")
+		assert.Equal(t, "pending", reports[0].Status())
+		assert.Equal(t, "team1", reports[0].TeamName)
+		if assert.NotNil(t, reports[0].ServiceName) {
+			assert.Equal(t, "ping", *reports[0].ServiceName)
+		}
+		if assert.NotNil(t, reports[1].ReviewerName) {
+			assert.Equal(t, "bigpoppa", *reports[1].ReviewerName)
+		}
+	}
+
+	filed, err := ReporterCompromiseReports(db, 101)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 2, len(filed))
+	}
+}
+
+func Test_TeamCompromiseReports(t *testing.T) {
+	prepareTestDatabase(t)
+
+	reports, err := TeamCompromiseReports(db, 1)
+	if assert.Nil(t, err) {
+		assert.Empty(t, reports, "Pending reports are hidden from the blue team")
+	}
+
+	reports, err = TeamCompromiseReports(db, 2)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(reports)) {
+		assert.Equal(t, "approved", reports[0].Status())
+		assert.Equal(t, float32(20), *reports[0].Points)
+	}
+}
+
+func Test_ApproveCompromiseReport(t *testing.T) {
+	prepareTestDatabase(t)
+
+	cr := &CompromiseReport{TeamID: 1, Host: "10.0.1.9", Title: "read the db", Evidence: "dumped the users table",
+		Severity: CompromiseSeverityCritical}
+	require.Nil(t, cr.Insert(db))
+
+	require.Nil(t, ApproveCompromiseReport(db, cr.ID, 100, 35, "ouch"))
+	approved, err := CompromiseReportByID(db, cr.ID)
+	require.Nil(t, err)
+	assert.Equal(t, "approved", approved.Status())
+	assert.Equal(t, float32(35), *approved.Points)
+	assert.Equal(t, "ouch", approved.ReviewNote)
+
+	var points float32
+	var kind BonusKind
+	var granter int
+	err = db.QueryRow(`SELECT points, kind, granter_id FROM other_points WHERE team_id = 1 AND reason = $1`,
+		compromiseDeductionReason(cr)).Scan(&points, &kind, &granter)
+	if assert.Nil(t, err, "Approving a report docks the team") {
+		assert.Equal(t, float32(-35), points)
+		assert.Equal(t, BonusKindRedteam, kind)
+		assert.Equal(t, 100, granter, "Granted by the reviewer")
+	}
+
+	assert.Equal(t, pgx.ErrNoRows, ApproveCompromiseReport(db, cr.ID, 100, 35, ""),
+		"A report can only be reviewed once")
+}
+
+func Test_RejectCompromiseReport(t *testing.T) {
+	prepareTestDatabase(t)
+
+	require.Nil(t, RejectCompromiseReport(db, 1, 100, "that's just the scoring engine"))
+	rejected, err := CompromiseReportByID(db, 1)
+	require.Nil(t, err)
+	assert.Equal(t, "rejected", rejected.Status())
+	assert.Nil(t, rejected.Points)
+
+	assert.Equal(t, pgx.ErrNoRows, RejectCompromiseReport(db, 2, 100, ""), "Already approved")
+	assert.Equal(t, pgx.ErrNoRows, RejectCompromiseReport(db, 1000, 100, ""), "Doesn't exist")
+}
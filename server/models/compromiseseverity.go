@@ -0,0 +1,98 @@
+// Package models contains the types for schema 'cyboard'.
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// CompromiseSeverity is the 'compromise_severity' enum type from schema 'cyboard'.
+type CompromiseSeverity uint16
+
+const (
+	// CompromiseSeverityUnspecified is an invalid CompromiseSeverity, likely bad user input.
+	CompromiseSeverityUnspecified = CompromiseSeverity(0)
+
+	// CompromiseSeverityLow is the 'low' CompromiseSeverity.
+	CompromiseSeverityLow = CompromiseSeverity(1)
+
+	// CompromiseSeverityMedium is the 'medium' CompromiseSeverity.
+	CompromiseSeverityMedium = CompromiseSeverity(2)
+
+	// CompromiseSeverityHigh is the 'high' CompromiseSeverity.
+	CompromiseSeverityHigh = CompromiseSeverity(3)
+
+	// CompromiseSeverityCritical is the 'critical' CompromiseSeverity.
+	CompromiseSeverityCritical = CompromiseSeverity(4)
+)
+
+// CompromiseSeverities lists every valid CompromiseSeverity, from least to most severe.
+var CompromiseSeverities = []CompromiseSeverity{
+	CompromiseSeverityLow,
+	CompromiseSeverityMedium,
+	CompromiseSeverityHigh,
+	CompromiseSeverityCritical,
+}
+
+// String returns the string value of the CompromiseSeverity.
+func (cs CompromiseSeverity) String() string {
+	var enumVal string
+
+	switch cs {
+	case CompromiseSeverityLow:
+		enumVal = "low"
+
+	case CompromiseSeverityMedium:
+		enumVal = "medium"
+
+	case CompromiseSeverityHigh:
+		enumVal = "high"
+
+	case CompromiseSeverityCritical:
+		enumVal = "critical"
+	}
+
+	return enumVal
+}
+
+// MarshalText marshals CompromiseSeverity into text.
+func (cs CompromiseSeverity) MarshalText() ([]byte, error) {
+	return []byte(cs.String()), nil
+}
+
+// UnmarshalText unmarshals CompromiseSeverity from text.
+func (cs *CompromiseSeverity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*cs = CompromiseSeverityLow
+
+	case "medium":
+		*cs = CompromiseSeverityMedium
+
+	case "high":
+		*cs = CompromiseSeverityHigh
+
+	case "critical":
+		*cs = CompromiseSeverityCritical
+
+	default:
+		return fmt.Errorf("invalid CompromiseSeverity %q", text)
+	}
+
+	return nil
+}
+
+// Value satisfies the sql/driver.Valuer interface for CompromiseSeverity.
+func (cs CompromiseSeverity) Value() (driver.Value, error) {
+	return cs.String(), nil
+}
+
+// Scan satisfies the database/sql.Scanner interface for CompromiseSeverity.
+func (cs *CompromiseSeverity) Scan(src interface{}) error {
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("invalid CompromiseSeverity '%v'", src)
+	}
+
+	return cs.UnmarshalText([]byte(str))
+}
@@ -0,0 +1,18 @@
+package models
+
+// ConfigValue fetches a setting saved in the 'cyboard.config' table.
+// Returns pgx.ErrNoRows if the setting was never saved.
+func ConfigValue(db DB, key string) (string, error) {
+	const sqlstr = `SELECT value FROM config WHERE key = $1`
+	var value string
+	err := db.QueryRow(sqlstr, key).Scan(&value)
+	return value, err
+}
+
+// SetConfigValue saves a setting into the 'cyboard.config' table, replacing any earlier value.
+func SetConfigValue(db DB, key, value string) error {
+	const sqlstr = `INSERT INTO config (key, value) VALUES ($1, $2)
+	ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`
+	_, err := db.Exec(sqlstr, key, value)
+	return err
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ConfigValue(t *testing.T) {
+	prepareTestDatabase(t)
+
+	v, err := ConfigValue(db, "scoreboard_unfrozen")
+	require.Nil(t, err)
+	assert.Equal(t, "false", v)
+
+	_, err = ConfigValue(db, "nope")
+	assert.Equal(t, pgx.ErrNoRows, err)
+
+	require.Nil(t, SetConfigValue(db, "scoreboard_unfrozen", "true"))
+	require.Nil(t, SetConfigValue(db, "nope", "new"))
+	v, _ = ConfigValue(db, "scoreboard_unfrozen")
+	assert.Equal(t, "true", v, "Replaces the saved setting")
+	v, _ = ConfigValue(db, "nope")
+	assert.Equal(t, "new", v)
+}
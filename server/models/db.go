@@ -54,15 +54,33 @@ type Deleter interface {
 var (
 	// DatabaseTables is a list of every table for the schema 'cyboard'
 	DatabaseTables = []string{
+		"audit_log",
 		"challenge",
 		"challenge_category",
 		"challenge_file",
+		"check_runner",
+		"compromise_report",
+		"config",
 		"ctf_solve",
 		"exit_status",
+		"incident_report",
+		"incident_rubric",
+		"incident_rubric_score",
+		"inject",
+		"inject_submission",
 		"other_points",
+		"password_reset",
+		"player",
+		"runner_check",
+		"score_category",
 		"service",
 		"service_check",
+		"session",
 		"team",
+		"team_host",
 		"team_role",
+		"ticket",
+		"ticket_category",
+		"ticket_message",
 	}
 )
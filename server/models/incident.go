@@ -0,0 +1,271 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// IncidentRubric represents a row from 'cyboard.incident_rubric'.
+// Each is one part of the rubric judges score incident reports with.
+type IncidentRubric struct {
+	ID          int     `json:"id"`          // id
+	Name        string  `json:"name"`        // name
+	Description string  `json:"description"` // description
+	MaxPoints   float32 `json:"max_points"`  // max_points
+}
+
+// Insert adds a new part to the rubric.
+func (ir *IncidentRubric) Insert(db DB) error {
+	const sqlstr = `INSERT INTO incident_rubric (name, description, max_points) VALUES ($1, $2, $3) RETURNING id`
+	return db.QueryRow(sqlstr, ir.Name, ir.Description, ir.MaxPoints).Scan(&ir.ID)
+}
+
+// Update a part of the rubric. Reports already scored keep their points.
+func (ir *IncidentRubric) Update(db DB) error {
+	const sqlstr = `UPDATE incident_rubric SET (name, description, max_points) = ($2, $3, $4) WHERE id = $1`
+	_, err := db.Exec(sqlstr, ir.ID, ir.Name, ir.Description, ir.MaxPoints)
+	return err
+}
+
+// Delete a part of the rubric. This fails once any report has been scored with it.
+func (ir *IncidentRubric) Delete(db DB) error {
+	const sqlstr = `DELETE FROM incident_rubric WHERE id = $1`
+	_, err := db.Exec(sqlstr, ir.ID)
+	return err
+}
+
+// IncidentRubricByName retrieves a part of the rubric by its unique name.
+func IncidentRubricByName(db DB, name string) (*IncidentRubric, error) {
+	const sqlstr = `SELECT id, name, description, max_points FROM incident_rubric WHERE name = $1`
+	ir := IncidentRubric{}
+	err := db.QueryRow(sqlstr, name).Scan(&ir.ID, &ir.Name, &ir.Description, &ir.MaxPoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ir, nil
+}
+
+// AllIncidentRubric fetches every part of the rubric, in the order judges see them.
+func AllIncidentRubric(db DB) ([]IncidentRubric, error) {
+	const sqlstr = `SELECT id, name, description, max_points FROM incident_rubric ORDER BY id`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []IncidentRubric{}
+	for rows.Next() {
+		x := IncidentRubric{}
+		if err = rows.Scan(&x.ID, &x.Name, &x.Description, &x.MaxPoints); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// IncidentReport represents a row from 'cyboard.incident_report'.
+// ScoredAt is nil until a judge has scored the report, and RescoredAt until they score it again.
+type IncidentReport struct {
+	ID          int       `json:"id"`          // id
+	TeamID      int       `json:"team_id"`     // team_id
+	PlayerID    *int      `json:"player_id"`   // player_id
+	Title       string    `json:"title"`       // title
+	Timeline    string    `json:"timeline"`    // timeline
+	Indicators  string    `json:"indicators"`  // indicators
+	Remediation string    `json:"remediation"` // remediation
+	CreatedAt   time.Time `json:"created_at"`  // created_at
+
+	JudgeID    *int       `json:"judge_id"`    // judge_id
+	Feedback   string     `json:"feedback"`    // feedback
+	ScoredAt   *time.Time `json:"scored_at"`   // scored_at
+	RescoredAt *time.Time `json:"rescored_at"` // rescored_at
+}
+
+// Insert submits a new incident report, filling in its ID & creation time.
+func (ir *IncidentReport) Insert(db DB) error {
+	const sqlstr = `INSERT INTO incident_report (` +
+		`team_id, player_id, title, timeline, indicators, remediation` +
+		`) VALUES (` +
+		`$1, $2, $3, $4, $5, $6` +
+		`) RETURNING id, created_at`
+
+	return db.QueryRow(sqlstr, ir.TeamID, ir.PlayerID, ir.Title, ir.Timeline, ir.Indicators,
+		ir.Remediation).Scan(&ir.ID, &ir.CreatedAt)
+}
+
+// IncidentReportView is an incident report, with who wrote & judged it, and its points.
+type IncidentReportView struct {
+	IncidentReport
+	TeamName   string   `json:"team_name"`   // team.name
+	PlayerName *string  `json:"player_name"` // player.name
+	JudgeName  *string  `json:"judge_name"`  // team.name
+	Points     *float32 `json:"points"`      // sum of incident_rubric_score.points, if scored
+
+	// Scores are the points given for each part of the rubric, by rubric id.
+	Scores map[int]float32 `json:"scores"`
+}
+
+const incidentViewSelect = `SELECT ` +
+	`ir.id, ir.team_id, ir.player_id, ir.title, ir.timeline, ir.indicators, ir.remediation, ir.created_at, ` +
+	`ir.judge_id, ir.feedback, ir.scored_at, ir.rescored_at, ` +
+	`team.name, player.name, judge.name, ` +
+	`CASE WHEN ir.scored_at IS NOT NULL THEN ` +
+	`(SELECT COALESCE(sum(s.points), 0) FROM incident_rubric_score AS s WHERE s.report_id = ir.id) END ` +
+	`FROM incident_report AS ir ` +
+	`JOIN team ON ir.team_id = team.id ` +
+	`LEFT JOIN player ON ir.player_id = player.id ` +
+	`LEFT JOIN team AS judge ON ir.judge_id = judge.id `
+
+func queryIncidentViews(db DB, sqlstr string, args ...interface{}) ([]IncidentReportView, error) {
+	xs, err := scanIncidentViews(db, sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	return xs, fillIncidentScores(db, xs)
+}
+
+func scanIncidentViews(db DB, sqlstr string, args ...interface{}) ([]IncidentReportView, error) {
+	rows, err := db.Query(sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []IncidentReportView{}
+	for rows.Next() {
+		x := IncidentReportView{Scores: map[int]float32{}}
+		err = rows.Scan(&x.ID, &x.TeamID, &x.PlayerID, &x.Title, &x.Timeline, &x.Indicators, &x.Remediation,
+			&x.CreatedAt, &x.JudgeID, &x.Feedback, &x.ScoredAt, &x.RescoredAt,
+			&x.TeamName, &x.PlayerName, &x.JudgeName, &x.Points)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// fillIncidentScores looks up the rubric scores for each of the reports.
+func fillIncidentScores(db DB, reports []IncidentReportView) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	byID := make(map[int]*IncidentReportView, len(reports))
+	ids := make([]int32, len(reports))
+	for i := range reports {
+		byID[reports[i].ID] = &reports[i]
+		ids[i] = int32(reports[i].ID)
+	}
+
+	const sqlstr = `SELECT report_id, rubric_id, points FROM incident_rubric_score WHERE report_id = ANY($1)`
+	rows, err := db.Query(sqlstr, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			reportID, rubricID int
+			points             float32
+		)
+		if err = rows.Scan(&reportID, &rubricID, &points); err != nil {
+			return err
+		}
+		byID[reportID].Scores[rubricID] = points
+	}
+	return rows.Err()
+}
+
+// IncidentReportByID retrieves a report, with its points & rubric scores.
+func IncidentReportByID(db DB, id int) (*IncidentReportView, error) {
+	const sqlstr = incidentViewSelect +
+		`WHERE ir.id = $1`
+	xs, err := queryIncidentViews(db, sqlstr, id)
+	if err != nil {
+		return nil, err
+	} else if len(xs) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &xs[0], nil
+}
+
+// AllIncidentReports fetches every report for the judges. Reports yet to be scored come
+// first, oldest first, followed by the scored ones, most recently scored first.
+func AllIncidentReports(db DB) ([]IncidentReportView, error) {
+	const sqlstr = incidentViewSelect +
+		`ORDER BY ir.scored_at DESC NULLS FIRST, ir.created_at, ir.id`
+	return queryIncidentViews(db, sqlstr)
+}
+
+// TeamIncidentReports fetches the reports a blue team has submitted, newest first.
+func TeamIncidentReports(db DB, teamID int) ([]IncidentReportView, error) {
+	const sqlstr = incidentViewSelect +
+		`WHERE ir.team_id = $1 ` +
+		`ORDER BY ir.created_at DESC, ir.id DESC`
+	return queryIncidentViews(db, sqlstr, teamID)
+}
+
+// ScoreIncidentReport grades a report with the rubric. `scores` must give points for every
+// part of the rubric, by rubric id, within each part's max points. Any earlier scores for
+// the report are replaced, but still count from when it was first scored.
+// Returns pgx.ErrNoRows if the report doesn't exist.
+func ScoreIncidentReport(db TXer, reportID, judgeID int, scores map[int]float32, feedback string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rubric, err := AllIncidentRubric(tx)
+	if err != nil {
+		return err
+	}
+	if len(scores) != len(rubric) {
+		return fmt.Errorf("every part of the rubric must be scored (got %d of %d)", len(scores), len(rubric))
+	}
+	for _, part := range rubric {
+		points, ok := scores[part.ID]
+		if !ok {
+			return fmt.Errorf("missing a score for %q", part.Name)
+		} else if points < 0 || points > part.MaxPoints {
+			return fmt.Errorf("points for %q must be between 0 and %v", part.Name, part.MaxPoints)
+		}
+	}
+
+	const sqlstr = `UPDATE incident_report SET ` +
+		`(judge_id, feedback, scored_at, rescored_at) = ($2, $3, COALESCE(scored_at, CURRENT_TIMESTAMP), ` +
+		`CASE WHEN scored_at IS NOT NULL THEN CURRENT_TIMESTAMP END) ` +
+		`WHERE id = $1`
+	tag, err := tx.Exec(sqlstr, reportID, judgeID, feedback)
+	if err != nil {
+		return err
+	} else if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if _, err = tx.Exec(`DELETE FROM incident_rubric_score WHERE report_id = $1`, reportID); err != nil {
+		return err
+	}
+	const insertstr = `INSERT INTO incident_rubric_score (report_id, rubric_id, points) VALUES ($1, $2, $3)`
+	for rubricID, points := range scores {
+		if _, err = tx.Exec(insertstr, reportID, rubricID, points); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/* incident_report.yml has two reports, scored with incident_rubric.yml's Timeline (/10) & Remediation (/5):
+Report 1 was written by alice for team1, and hasn't been scored yet.
+Report 2 was written by team2, and was scored 4 + 2 by bigpoppa (id=100).
+*/
+
+func Test_AllIncidentReports(t *testing.T) {
+	prepareTestDatabase(t)
+
+	reports, err := AllIncidentReports(db)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(reports)) {
+		assert.Equal(t, []int{1, 2}, []int{reports[0].ID, reports[1].ID}, "Unscored reports sort first")
+		assert.Nil(t, reports[0].Points)
+		if assert.NotNil(t, reports[0].PlayerName) {
+			assert.Equal(t, "alice", *reports[0].PlayerName)
+		}
+		assert.Empty(t, reports[0].Scores)
+
+		if assert.NotNil(t, reports[1].Points) {
+			assert.Equal(t, float32(6), *reports[1].Points)
+		}
+		assert.Equal(t, map[int]float32{1: 4, 2: 2}, reports[1].Scores)
+	}
+}
+
+func Test_IncidentReport_Insert(t *testing.T) {
+	prepareTestDatabase(t)
+
+	ir := &IncidentReport{TeamID: 2, Title: "ssh keys", Timeline: "9:30 found a key", Indicators: "authorized_keys",
+		Remediation: "removed it"}
+	require.Nil(t, ir.Insert(db))
+
+	reports, err := TeamIncidentReports(db, 2)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(reports)) {
+		assert.Equal(t, ir.ID, reports[0].ID, "Newest report listed first")
+		assert.Nil(t, reports[0].ScoredAt)
+	}
+}
+
+func Test_ScoreIncidentReport(t *testing.T) {
+	prepareTestDatabase(t)
+
+	before, err := TeamsScores(db)
+	require.Nil(t, err)
+
+	assert.Error(t, ScoreIncidentReport(db, 1, 100, map[int]float32{1: 5}, ""), "Every part must be scored")
+	assert.Error(t, ScoreIncidentReport(db, 1, 100, map[int]float32{1: 5, 2: 6}, ""), "Over the max points")
+	assert.Error(t, ScoreIncidentReport(db, 1, 100, map[int]float32{1: 5, 3: 1}, ""), "Not in the rubric")
+	assert.Equal(t, pgx.ErrNoRows, ScoreIncidentReport(db, 1000, 100, map[int]float32{1: 5, 2: 5}, ""))
+
+	require.Nil(t, ScoreIncidentReport(db, 1, 100, map[int]float32{1: 7.5, 2: 5}, "nice"))
+	report, err := IncidentReportByID(db, 1)
+	require.Nil(t, err)
+	assert.NotNil(t, report.ScoredAt)
+	assert.Equal(t, "nice", report.Feedback)
+	if assert.NotNil(t, report.Points) {
+		assert.Equal(t, float32(12.5), *report.Points)
+	}
+
+	require.Nil(t, ScoreIncidentReport(db, 1, 100, map[int]float32{1: 10, 2: 5}, "re-scored"))
+	after, err := TeamsScores(db)
+	require.Nil(t, err)
+	assert.Equal(t, before[0].Categories["incident"]+15, after[0].Categories["incident"], "Re-scoring replaces the old scores")
+	assert.Equal(t, before[0].Score+15, after[0].Score)
+}
+
+func Test_ScoreIncidentReport_Rescore(t *testing.T) {
+	prepareTestDatabase(t)
+	// Report 2 was first scored at 09:12, for 6 points
+	asOf, _ := time.Parse(time.RFC3339, "2018-07-29T09:30:00.000-04:00")
+
+	require.Nil(t, ScoreIncidentReport(db, 2, 100, map[int]float32{1: 10, 2: 5}, "better on second look"))
+	report, err := IncidentReportByID(db, 2)
+	require.Nil(t, err)
+	if assert.NotNil(t, report.ScoredAt) {
+		assert.True(t, report.ScoredAt.Before(asOf), "Re-scoring keeps when the report was first scored")
+	}
+	if assert.NotNil(t, report.RescoredAt) {
+		assert.True(t, report.RescoredAt.After(asOf))
+	}
+
+	scores, err := TeamsScoresAsOf(db, asOf)
+	require.Nil(t, err)
+	for _, s := range scores {
+		if s.TeamID == 2 {
+			assert.Equal(t, 15, s.Categories["incident"], "The new points count from the first scoring")
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// Inject represents a row from 'cyboard.inject'.
+// Blue teams can't see an inject until it's released.
+type Inject struct {
+	ID          int       `json:"id"`           // id
+	Title       string    `json:"title"`        // title
+	Description string    `json:"description"`  // description (markdown)
+	MaxPoints   float32   `json:"max_points"`   // max_points
+	ReleaseAt   time.Time `json:"release_at"`   // release_at
+	DueAt       time.Time `json:"due_at"`       // due_at
+	AcceptLate  bool      `json:"accept_late"`  // accept_late
+	LatePenalty float32   `json:"late_penalty"` // late_penalty
+	CreatedAt   time.Time `json:"created_at"`   // created_at
+	ModifiedAt  time.Time `json:"modified_at"`  // modified_at
+}
+
+// Validate checks the inject's fields, before they're saved.
+func (i *Inject) Validate() error {
+	switch {
+	case i.Title == "":
+		return errors.New("an inject needs a title")
+	case i.MaxPoints < 0:
+		return errors.New("max points must not be negative")
+	case !i.DueAt.After(i.ReleaseAt):
+		return errors.New("an inject must be due after it's released")
+	case i.LatePenalty < 0 || i.LatePenalty > 1:
+		return errors.New("the late penalty must be between 0 and 1")
+	}
+	return nil
+}
+
+// Released reports if blue teams can see the inject at time `t`.
+func (i *Inject) Released(t time.Time) bool {
+	return !t.Before(i.ReleaseAt)
+}
+
+// Late reports if a submission made at time `t` is past due.
+func (i *Inject) Late(t time.Time) bool {
+	return t.After(i.DueAt)
+}
+
+// Open reports if teams may submit work for the inject at time `t`.
+func (i *Inject) Open(t time.Time) bool {
+	return i.Released(t) && (i.AcceptLate || !i.Late(t))
+}
+
+// Insert a new inject, filling in its ID & timestamps.
+func (i *Inject) Insert(db DB) error {
+	const sqlstr = `INSERT INTO inject (` +
+		`title, description, max_points, release_at, due_at, accept_late, late_penalty` +
+		`) VALUES (` +
+		`$1, $2, $3, $4, $5, $6, $7` +
+		`) RETURNING id, created_at, modified_at`
+
+	return db.QueryRow(sqlstr, i.Title, i.Description, i.MaxPoints, i.ReleaseAt, i.DueAt, i.AcceptLate,
+		i.LatePenalty).Scan(&i.ID, &i.CreatedAt, &i.ModifiedAt)
+}
+
+// Update an inject. Submissions already graded keep their points.
+func (i *Inject) Update(db DB) error {
+	const sqlstr = `UPDATE inject SET (` +
+		`title, description, max_points, release_at, due_at, accept_late, late_penalty` +
+		`) = (` +
+		`$2, $3, $4, $5, $6, $7, $8` +
+		`) WHERE id = $1`
+
+	_, err := db.Exec(sqlstr, i.ID, i.Title, i.Description, i.MaxPoints, i.ReleaseAt, i.DueAt, i.AcceptLate,
+		i.LatePenalty)
+	return err
+}
+
+// Delete an inject, along with every team's submission for it.
+func (i *Inject) Delete(db DB) error {
+	const sqlstr = `DELETE FROM inject WHERE id = $1`
+	_, err := db.Exec(sqlstr, i.ID)
+	return err
+}
+
+const injectSelect = `SELECT ` +
+	`id, title, description, max_points, release_at, due_at, accept_late, late_penalty, created_at, modified_at ` +
+	`FROM inject `
+
+func queryInjects(db DB, sqlstr string, args ...interface{}) ([]Inject, error) {
+	rows, err := db.Query(sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []Inject{}
+	for rows.Next() {
+		x := Inject{}
+		err = rows.Scan(&x.ID, &x.Title, &x.Description, &x.MaxPoints, &x.ReleaseAt, &x.DueAt,
+			&x.AcceptLate, &x.LatePenalty, &x.CreatedAt, &x.ModifiedAt)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// InjectByID retrieves a row from 'cyboard.inject' as an Inject.
+func InjectByID(db DB, id int) (*Inject, error) {
+	const sqlstr = injectSelect +
+		`WHERE id = $1`
+	xs, err := queryInjects(db, sqlstr, id)
+	if err != nil {
+		return nil, err
+	} else if len(xs) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &xs[0], nil
+}
+
+// InjectByTitle retrieves an inject by its unique title.
+func InjectByTitle(db DB, title string) (*Inject, error) {
+	const sqlstr = injectSelect +
+		`WHERE title = $1`
+	xs, err := queryInjects(db, sqlstr, title)
+	if err != nil {
+		return nil, err
+	} else if len(xs) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &xs[0], nil
+}
+
+// AllInjects fetches every inject, released or not, in the order they're released.
+func AllInjects(db DB) ([]Inject, error) {
+	const sqlstr = injectSelect +
+		`ORDER BY release_at, id`
+	return queryInjects(db, sqlstr)
+}
+
+// ReleasedInjects fetches the injects blue teams can see, the most recently released first.
+func ReleasedInjects(db DB) ([]Inject, error) {
+	const sqlstr = injectSelect +
+		`WHERE release_at <= CURRENT_TIMESTAMP ` +
+		`ORDER BY release_at DESC, id DESC`
+	return queryInjects(db, sqlstr)
+}
+
+// InjectSubmission represents a row from 'cyboard.inject_submission'.
+// GradedAt is nil until a judge has graded the submission, and RegradedAt until they grade it again.
+type InjectSubmission struct {
+	ID          int       `json:"id"`           // id
+	InjectID    int       `json:"inject_id"`    // inject_id
+	TeamID      int       `json:"team_id"`      // team_id
+	PlayerID    *int      `json:"player_id"`    // player_id
+	Body        string    `json:"body"`         // body
+	SubmittedAt time.Time `json:"submitted_at"` // submitted_at
+	Late        bool      `json:"late"`         // late
+
+	Points     *float32   `json:"points"`      // points, before any late penalty
+	JudgeID    *int       `json:"judge_id"`    // judge_id
+	Feedback   string     `json:"feedback"`    // feedback
+	GradedAt   *time.Time `json:"graded_at"`   // graded_at
+	RegradedAt *time.Time `json:"regraded_at"` // regraded_at
+}
+
+// Submit saves a team's work for an inject, replacing what they sent before.
+// It's marked late if it comes after the inject's due time. It's an error if the
+// inject isn't open for submissions, or the team's earlier submission was already graded.
+// Returns pgx.ErrNoRows if the inject doesn't exist.
+func (s *InjectSubmission) Submit(db TXer) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inject, err := InjectByID(tx, s.InjectID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !inject.Released(now) {
+		return pgx.ErrNoRows
+	} else if !inject.Open(now) {
+		return fmt.Errorf("%q was due at %s, and doesn't accept late work", inject.Title, inject.DueAt.Format(time.Kitchen))
+	}
+	s.Late = inject.Late(now)
+
+	const sqlstr = `INSERT INTO inject_submission (` +
+		`inject_id, team_id, player_id, body, submitted_at, late` +
+		`) VALUES (` +
+		`$1, $2, $3, $4, $5, $6` +
+		`) ON CONFLICT (inject_id, team_id) DO UPDATE SET ` +
+		`(player_id, body, submitted_at, late) = (EXCLUDED.player_id, EXCLUDED.body, EXCLUDED.submitted_at, EXCLUDED.late) ` +
+		`WHERE inject_submission.graded_at IS NULL ` +
+		`RETURNING id, submitted_at`
+
+	err = tx.QueryRow(sqlstr, s.InjectID, s.TeamID, s.PlayerID, s.Body, now, s.Late).Scan(&s.ID, &s.SubmittedAt)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("your submission for %q was already graded", inject.Title)
+	} else if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InjectSubmissionView is a team's submission, with who sent & graded it, and the points
+// awarded after any late penalty.
+type InjectSubmissionView struct {
+	InjectSubmission
+	InjectTitle string   `json:"inject_title"` // inject.title
+	MaxPoints   float32  `json:"max_points"`   // inject.max_points
+	TeamName    string   `json:"team_name"`    // team.name
+	PlayerName  *string  `json:"player_name"`  // player.name
+	JudgeName   *string  `json:"judge_name"`   // team.name
+	Awarded     *float32 `json:"awarded"`      // points, less the late penalty, if graded
+}
+
+const injectSubmissionViewSelect = `SELECT ` +
+	`s.id, s.inject_id, s.team_id, s.player_id, s.body, s.submitted_at, s.late, ` +
+	`s.points, s.judge_id, s.feedback, s.graded_at, s.regraded_at, ` +
+	`inject.title, inject.max_points, team.name, player.name, judge.name, ` +
+	`CASE WHEN s.late THEN s.points * (1 - inject.late_penalty) ELSE s.points END ` +
+	`FROM inject_submission AS s ` +
+	`JOIN inject ON s.inject_id = inject.id ` +
+	`JOIN team ON s.team_id = team.id ` +
+	`LEFT JOIN player ON s.player_id = player.id ` +
+	`LEFT JOIN team AS judge ON s.judge_id = judge.id `
+
+func queryInjectSubmissionViews(db DB, sqlstr string, args ...interface{}) ([]InjectSubmissionView, error) {
+	rows, err := db.Query(sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []InjectSubmissionView{}
+	for rows.Next() {
+		x := InjectSubmissionView{}
+		err = rows.Scan(&x.ID, &x.InjectID, &x.TeamID, &x.PlayerID, &x.Body, &x.SubmittedAt, &x.Late,
+			&x.Points, &x.JudgeID, &x.Feedback, &x.GradedAt, &x.RegradedAt,
+			&x.InjectTitle, &x.MaxPoints, &x.TeamName, &x.PlayerName, &x.JudgeName, &x.Awarded)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// InjectSubmissionByID retrieves a submission, with its awarded points.
+func InjectSubmissionByID(db DB, id int) (*InjectSubmissionView, error) {
+	const sqlstr = injectSubmissionViewSelect +
+		`WHERE s.id = $1`
+	xs, err := queryInjectSubmissionViews(db, sqlstr, id)
+	if err != nil {
+		return nil, err
+	} else if len(xs) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &xs[0], nil
+}
+
+// AllInjectSubmissions fetches every submission for the judges. Ungraded submissions come
+// first, oldest first, followed by the graded ones, most recently graded first.
+func AllInjectSubmissions(db DB) ([]InjectSubmissionView, error) {
+	const sqlstr = injectSubmissionViewSelect +
+		`ORDER BY s.graded_at DESC NULLS FIRST, s.submitted_at, s.id`
+	return queryInjectSubmissionViews(db, sqlstr)
+}
+
+// TeamInjectSubmissions fetches a blue team's submissions, by inject id.
+func TeamInjectSubmissions(db DB, teamID int) (map[int]InjectSubmissionView, error) {
+	const sqlstr = injectSubmissionViewSelect +
+		`WHERE s.team_id = $1`
+	xs, err := queryInjectSubmissionViews(db, sqlstr, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	byInject := make(map[int]InjectSubmissionView, len(xs))
+	for _, x := range xs {
+		byInject[x.InjectID] = x
+	}
+	return byInject, nil
+}
+
+// GradeInjectSubmission gives a submission its points, out of the inject's max points.
+// Any late penalty is taken off when the points are tallied. Grading a submission again
+// replaces its old grade, which still counts from when it was first graded.
+// Returns pgx.ErrNoRows if the submission doesn't exist.
+func GradeInjectSubmission(db TXer, id, judgeID int, points float32, feedback string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const maxstr = `SELECT inject.max_points FROM inject_submission AS s ` +
+		`JOIN inject ON s.inject_id = inject.id ` +
+		`WHERE s.id = $1`
+	var maxPoints float32
+	if err = tx.QueryRow(maxstr, id).Scan(&maxPoints); err != nil {
+		return err
+	}
+	if points < 0 || points > maxPoints {
+		return fmt.Errorf("points must be between 0 and %v", maxPoints)
+	}
+
+	const sqlstr = `UPDATE inject_submission SET ` +
+		`(points, judge_id, feedback, graded_at, regraded_at) = ($2, $3, $4, COALESCE(graded_at, CURRENT_TIMESTAMP), ` +
+		`CASE WHEN graded_at IS NOT NULL THEN CURRENT_TIMESTAMP END) ` +
+		`WHERE id = $1`
+	if _, err = tx.Exec(sqlstr, id, points, judgeID, feedback); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
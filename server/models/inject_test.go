@@ -0,0 +1,148 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/* inject.yml has four injects:
+1. "Password policy", worth 20, was due at 09:05. Late work loses half its points.
+2. "New hire account", worth 10, is open until 2099, and doesn't accept late work.
+3. "Firewall audit" isn't released until 2099.
+4. "Backup report" was due at 09:00, and doesn't accept late work.
+
+inject_submission.yml has team1's & team2's work for inject 1, both graded by bigpoppa (id=100).
+team1's was on time, for 16 points. team2's was late, for 10 points, halved to 5.
+team2's work for inject 2 hasn't been graded yet.
+*/
+
+func Test_Inject_Validate(t *testing.T) {
+	now := time.Now()
+	valid := Inject{Title: "memo", MaxPoints: 10, ReleaseAt: now, DueAt: now.Add(time.Hour), LatePenalty: 0.5}
+	assert.Nil(t, valid.Validate())
+
+	noTitle, dueFirst, badPenalty := valid, valid, valid
+	noTitle.Title = ""
+	dueFirst.DueAt = now.Add(-time.Hour)
+	badPenalty.LatePenalty = 1.5
+	assert.Error(t, noTitle.Validate())
+	assert.Error(t, dueFirst.Validate())
+	assert.Error(t, badPenalty.Validate())
+}
+
+func Test_ReleasedInjects(t *testing.T) {
+	prepareTestDatabase(t)
+
+	injects, err := ReleasedInjects(db)
+	if assert.Nil(t, err) && assert.Equal(t, 3, len(injects)) {
+		ids := []int{injects[0].ID, injects[1].ID, injects[2].ID}
+		assert.Equal(t, []int{2, 1, 4}, ids, "Most recently released first, and unreleased injects are hidden")
+	}
+}
+
+func Test_InjectByTitle(t *testing.T) {
+	prepareTestDatabase(t)
+
+	inject, err := InjectByTitle(db, "New hire account")
+	if assert.Nil(t, err) {
+		assert.Equal(t, 2, inject.ID)
+	}
+	_, err = InjectByTitle(db, "Not an inject")
+	assert.Equal(t, pgx.ErrNoRows, err)
+}
+
+func Test_AllInjectSubmissions(t *testing.T) {
+	prepareTestDatabase(t)
+
+	subs, err := AllInjectSubmissions(db)
+	if assert.Nil(t, err) && assert.Equal(t, 3, len(subs)) {
+		assert.Equal(t, []int{3, 1, 2}, []int{subs[0].ID, subs[1].ID, subs[2].ID}, "Ungraded submissions sort first")
+		assert.Nil(t, subs[0].Awarded)
+		if assert.NotNil(t, subs[2].Awarded) {
+			assert.Equal(t, float32(5), *subs[2].Awarded, "Late work loses the penalty")
+		}
+		if assert.NotNil(t, subs[1].PlayerName) {
+			assert.Equal(t, "alice", *subs[1].PlayerName)
+		}
+	}
+}
+
+func Test_InjectSubmission_Submit(t *testing.T) {
+	prepareTestDatabase(t)
+
+	sub := &InjectSubmission{InjectID: 2, TeamID: 1, Body: "made the intern's account"}
+	require.Nil(t, sub.Submit(db))
+	assert.False(t, sub.Late)
+
+	resub := &InjectSubmission{InjectID: 2, TeamID: 2, Body: "made it, and set a password"}
+	require.Nil(t, resub.Submit(db))
+	assert.Equal(t, 3, resub.ID, "Submitting again replaces the team's earlier work")
+	subs, err := TeamInjectSubmissions(db, 2)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "made it, and set a password", subs[2].Body)
+	}
+
+	assert.Error(t, (&InjectSubmission{InjectID: 1, TeamID: 1, Body: "v2"}).Submit(db), "Already graded")
+	assert.Error(t, (&InjectSubmission{InjectID: 4, TeamID: 1, Body: "late"}).Submit(db), "No late work accepted")
+	assert.Equal(t, pgx.ErrNoRows, (&InjectSubmission{InjectID: 3, TeamID: 1}).Submit(db), "Not released yet")
+	assert.Equal(t, pgx.ErrNoRows, (&InjectSubmission{InjectID: 1000, TeamID: 1}).Submit(db))
+
+	now := time.Now()
+	overdue := &Inject{Title: "overdue", MaxPoints: 10, ReleaseAt: now.Add(-time.Hour), DueAt: now.Add(-time.Minute),
+		AcceptLate: true, LatePenalty: 0.25}
+	require.Nil(t, overdue.Insert(db))
+	late := &InjectSubmission{InjectID: overdue.ID, TeamID: 1, Body: "sorry"}
+	require.Nil(t, late.Submit(db))
+	assert.True(t, late.Late)
+}
+
+func Test_GradeInjectSubmission(t *testing.T) {
+	prepareTestDatabase(t)
+
+	before, err := TeamsScores(db)
+	require.Nil(t, err)
+
+	assert.Error(t, GradeInjectSubmission(db, 3, 100, 11, ""), "Over the max points")
+	assert.Error(t, GradeInjectSubmission(db, 3, 100, -1, ""), "Negative points")
+	assert.Equal(t, pgx.ErrNoRows, GradeInjectSubmission(db, 1000, 100, 5, ""))
+
+	require.Nil(t, GradeInjectSubmission(db, 3, 100, 8, "good"))
+	sub, err := InjectSubmissionByID(db, 3)
+	require.Nil(t, err)
+	assert.NotNil(t, sub.GradedAt)
+	assert.Equal(t, "good", sub.Feedback)
+
+	require.Nil(t, GradeInjectSubmission(db, 2, 100, 20, "re-graded"))
+	after, err := TeamsScores(db)
+	require.Nil(t, err)
+	assert.Equal(t, before[1].Categories["inject"]+8+5, after[1].Categories["inject"], "Re-grading late work still takes off the penalty")
+	assert.Equal(t, before[1].Score+8+5, after[1].Score)
+}
+
+func Test_GradeInjectSubmission_Regrade(t *testing.T) {
+	prepareTestDatabase(t)
+	// team1's submission 1 was first graded at 09:14, for 16 points
+	asOf, _ := time.Parse(time.RFC3339, "2018-07-29T09:30:00.000-04:00")
+
+	require.Nil(t, GradeInjectSubmission(db, 1, 100, 20, "full marks after all"))
+	sub, err := InjectSubmissionByID(db, 1)
+	require.Nil(t, err)
+	if assert.NotNil(t, sub.GradedAt) {
+		assert.True(t, sub.GradedAt.Before(asOf), "Re-grading keeps when the submission was first graded")
+	}
+	if assert.NotNil(t, sub.RegradedAt) {
+		assert.True(t, sub.RegradedAt.After(asOf))
+	}
+
+	scores, err := TeamsScoresAsOf(db, asOf)
+	require.Nil(t, err)
+	for _, s := range scores {
+		if s.TeamID == 1 {
+			assert.Equal(t, 20, s.Categories["inject"], "The new points count from the first grading")
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+)
+
+// LedgerEntry is one thing that changed a team's score, with why.
+// A run of passing checks on a service is summed up as a single entry.
+type LedgerEntry struct {
+	At          time.Time  `json:"at"`              // when the points were awarded (the latest check of a streak)
+	Since       *time.Time `json:"since,omitempty"` // the first check of a service streak, if there's more than one
+	Category    string     `json:"category"`        // score_category.name
+	Points      float32    `json:"points"`          // points, before the category's weight
+	Weighted    float32    `json:"weighted"`        // points, as they count toward the score
+	Description string     `json:"description"`
+	Note        string     `json:"note"` // from the staff, e.g. a judge's feedback
+}
+
+// LedgerFilter narrows down a team's ledger. Zero values match everything.
+type LedgerFilter struct {
+	Category string     // score_category.name
+	Until    *time.Time // inclusive, e.g. when the scoreboard froze
+	Limit    int
+	Offset   int
+}
+
+// TeamLedger lists every scoring event for a team, newest first.
+// Revoking a bonus is its own entry, taking the points back out.
+func TeamLedger(db DB, teamID int, f LedgerFilter) ([]LedgerEntry, error) {
+	const sqlstr = `WITH checks AS (
+		SELECT sc.created_at, sc.service_id, sc.status = 'pass' AS pass,
+			row_number() OVER (PARTITION BY sc.service_id ORDER BY sc.created_at)
+			- row_number() OVER (PARTITION BY sc.service_id, sc.status = 'pass' ORDER BY sc.created_at) AS streak
+		FROM service_check AS sc
+		WHERE sc.team_id = $1 AND ($2::timestamptz IS NULL OR sc.created_at <= $2)
+	), entries (at, since, category, points, description, note) AS (
+		SELECT max(c.created_at), CASE WHEN count(*) > 1 THEN min(c.created_at) END, 'service', (count(*) * COALESCE(s.points, 0))::real,
+			format('%s: %s passing check%s', s.name, count(*), CASE WHEN count(*) > 1 THEN 's' ELSE '' END), ''
+		FROM checks AS c
+			JOIN service AS s ON c.service_id = s.id
+		WHERE c.pass
+		GROUP BY s.id, c.streak
+		UNION ALL
+		SELECT cs.created_at, NULL, 'ctf', ch.total, 'Captured the flag for ' || ch.name, ''
+		FROM ctf_solve AS cs
+			JOIN challenge AS ch ON cs.challenge_id = ch.id
+		WHERE cs.team_id = $1 AND ($2::timestamptz IS NULL OR cs.created_at <= $2)
+		UNION ALL
+		SELECT ir.scored_at, NULL, 'incident', COALESCE(sum(s.points), 0), 'Incident report: ' || ir.title, ir.feedback
+		FROM incident_report AS ir
+			LEFT JOIN incident_rubric_score AS s ON ir.id = s.report_id
+		WHERE ir.team_id = $1 AND ir.scored_at IS NOT NULL AND ($2::timestamptz IS NULL OR ir.scored_at <= $2)
+		GROUP BY ir.id
+		UNION ALL
+		SELECT s.graded_at, NULL, 'inject',
+			CASE WHEN s.late THEN s.points * (1 - inject.late_penalty) ELSE s.points END,
+			'Inject: ' || inject.title || CASE WHEN s.late THEN ' (late)' ELSE '' END, s.feedback
+		FROM inject_submission AS s
+			JOIN inject ON s.inject_id = inject.id
+		WHERE s.team_id = $1 AND s.graded_at IS NOT NULL AND ($2::timestamptz IS NULL OR s.graded_at <= $2)
+		UNION ALL
+		SELECT o.created_at, NULL, o.category, o.points, o.reason, o.note
+		FROM other_points AS o
+		WHERE o.team_id = $1 AND ($2::timestamptz IS NULL OR o.created_at <= $2)
+		UNION ALL
+		SELECT o.revoked_at, NULL, o.category, -o.points, 'Revoked: ' || o.reason, o.note
+		FROM other_points AS o
+		WHERE o.team_id = $1 AND o.revoked_at IS NOT NULL AND ($2::timestamptz IS NULL OR o.revoked_at <= $2)
+	)
+	SELECT e.at, e.since, e.category, e.points, (e.points * sc.weight)::real, e.description, e.note
+	FROM entries AS e
+		JOIN score_category AS sc ON e.category = sc.name
+	WHERE ($3::text = '' OR e.category = $3)
+	ORDER BY e.at DESC, sc.position, e.description
+	LIMIT $4 OFFSET $5`
+
+	if f.Limit <= 0 {
+		f.Limit = 500
+	}
+	rows, err := db.Query(sqlstr, teamID, f.Until, f.Category, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []LedgerEntry{}
+	for rows.Next() {
+		x := LedgerEntry{}
+		if err = rows.Scan(&x.At, &x.Since, &x.Category, &x.Points, &x.Weighted, &x.Description, &x.Note); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TeamLedger(t *testing.T) {
+	prepareTestDatabase(t)
+
+	entries, err := TeamLedger(db, 1, LedgerFilter{})
+	if assert.Nil(t, err) && assert.Equal(t, 4, len(entries)) {
+		descriptions := []string{}
+		for _, e := range entries {
+			descriptions = append(descriptions, e.Description)
+		}
+		assert.Equal(t, []string{
+			"ping: 2 passing checks",
+			"Inject: Password policy",
+			"Captured the flag for Totally Rad Challenge",
+			"took a shower",
+		}, descriptions, "Newest first, with the passing checks summed up as a streak")
+
+		streak := entries[0]
+		assert.Equal(t, "service", streak.Category)
+		assert.InDelta(t, 4.4, streak.Points, 0.001)
+		started, _ := time.Parse(time.RFC3339, "2018-07-29T09:00:00-04:00")
+		if assert.NotNil(t, streak.Since) {
+			assert.True(t, started.Equal(*streak.Since), "The streak started with the first check")
+		}
+		assert.Equal(t, "Thanks for keeping the room pleasant", entries[3].Note)
+	}
+
+	ctf, err := TeamLedger(db, 1, LedgerFilter{Category: "ctf"})
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(ctf)) {
+		assert.Equal(t, float32(5), ctf[0].Points)
+	}
+
+	paged, err := TeamLedger(db, 1, LedgerFilter{Limit: 2, Offset: 1})
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(paged)) {
+		assert.Equal(t, "inject", paged[0].Category)
+		assert.Equal(t, "ctf", paged[1].Category)
+	}
+
+	until, _ := time.Parse(time.RFC3339, "2018-07-29T09:05:00-04:00")
+	frozen, err := TeamLedger(db, 1, LedgerFilter{Until: &until})
+	if assert.Nil(t, err) && assert.Equal(t, 3, len(frozen)) {
+		assert.Equal(t, "ping: 1 passing check", frozen[0].Description)
+		assert.Nil(t, frozen[0].Since)
+	}
+}
+
+func Test_TeamLedger_Weights(t *testing.T) {
+	prepareTestDatabase(t)
+
+	ctf, err := ScoreCategoryByName(db, "ctf")
+	require.Nil(t, err)
+	ctf.Weight = 2
+	require.Nil(t, ctf.Update(db))
+
+	op := &OtherPoints{TeamID: 2, Points: 3, Reason: "helped out", Note: "thanks!"}
+	require.Nil(t, op.Insert(db))
+	require.Nil(t, RevokeBonusPoints(db, op.ID, 100))
+
+	entries, err := TeamLedger(db, 2, LedgerFilter{})
+	require.Nil(t, err)
+	sum := float32(0)
+	for _, e := range entries {
+		if e.Category == "ctf" {
+			assert.Equal(t, 2*e.Points, e.Weighted)
+		}
+		sum += e.Weighted
+	}
+	if assert.True(t, len(entries) >= 2) {
+		assert.Equal(t, "Revoked: helped out", entries[0].Description, "Revoking is its own entry")
+		assert.Equal(t, float32(-3), entries[0].Points)
+	}
+
+	scores, err := TeamsScores(db)
+	require.Nil(t, err)
+	assert.Equal(t, scores[1].Score, int(sum+0.5), "The ledger adds up to the score")
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// SetTeamPassword replaces the password hash of a team's shared login.
+func SetTeamPassword(db DB, teamID int, hash []byte) error {
+	const sqlstr = `UPDATE team SET hash = $2 WHERE id = $1`
+	tag, err := db.Exec(sqlstr, teamID, hash)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+	}
+	return err
+}
+
+// SetPlayerPassword replaces the password hash of a player.
+func SetPlayerPassword(db DB, playerID int, hash []byte) error {
+	const sqlstr = `UPDATE player SET hash = $2 WHERE id = $1`
+	tag, err := db.Exec(sqlstr, playerID, hash)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+	}
+	return err
+}
+
+// PasswordReset represents a row from 'cyboard.password_reset'. Exactly one of
+// TeamID or PlayerID is set, for the account whose password may be reset.
+type PasswordReset struct {
+	TokenHash []byte     `json:"-"`          // token_hash
+	TeamID    *int       `json:"team_id"`    // team_id
+	PlayerID  *int       `json:"player_id"`  // player_id
+	CreatedBy *int       `json:"created_by"` // created_by
+	CreatedAt time.Time  `json:"created_at"` // created_at
+	ExpiresAt time.Time  `json:"expires_at"` // expires_at
+	UsedAt    *time.Time `json:"used_at"`    // used_at
+}
+
+// Insert saves a new reset token. Any unused tokens for the same account are
+// thrown away, so only the most recent one works.
+func (pr *PasswordReset) Insert(db TXer) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const deleteSQL = `DELETE FROM password_reset WHERE used_at IS NULL ` +
+		`AND (team_id = $1 OR player_id = $2)`
+	if _, err = tx.Exec(deleteSQL, pr.TeamID, pr.PlayerID); err != nil {
+		return errors.WithMessage(err, "revoke old reset tokens")
+	}
+
+	const sqlstr = `INSERT INTO password_reset (token_hash, team_id, player_id, created_by, expires_at) ` +
+		`VALUES ($1, $2, $3, $4, $5) RETURNING created_at`
+	err = tx.QueryRow(sqlstr, pr.TokenHash, pr.TeamID, pr.PlayerID, pr.CreatedBy, pr.ExpiresAt).Scan(&pr.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// PasswordResetAccount looks up the name of the account an unused, unexpired
+// reset token is for. Returns pgx.ErrNoRows if the token can't be used.
+func PasswordResetAccount(db DB, tokenHash []byte) (string, error) {
+	const sqlstr = `SELECT coalesce(t.name, p.name)
+	FROM password_reset AS pr
+		LEFT JOIN team AS t ON pr.team_id = t.id
+		LEFT JOIN player AS p ON pr.player_id = p.id
+	WHERE pr.token_hash = $1 AND pr.used_at IS NULL AND pr.expires_at > now()`
+
+	var name string
+	err := db.QueryRow(sqlstr, tokenHash).Scan(&name)
+	return name, err
+}
+
+// RedeemPasswordReset uses up a reset token, setting the password of its account
+// to `newHash`, and revoking the account's sessions. Returns pgx.ErrNoRows if the
+// token is unknown, used, or expired.
+func RedeemPasswordReset(db TXer, tokenHash, newHash []byte) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var teamID, playerID *int
+	const sqlstr = `UPDATE password_reset SET used_at = now() ` +
+		`WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now() ` +
+		`RETURNING team_id, player_id`
+	if err = tx.QueryRow(sqlstr, tokenHash).Scan(&teamID, &playerID); err != nil {
+		return err
+	}
+
+	if err = setPasswordAndLogout(tx, teamID, playerID, newHash); err != nil {
+		return errors.WithMessage(err, "redeem password reset")
+	}
+	return tx.Commit()
+}
+
+// ChangePassword sets the password of a team's shared login (when playerID is nil),
+// or of a player, and revokes the account's sessions, so whoever was logged in with
+// the old password is logged out.
+func ChangePassword(db TXer, teamID int, playerID *int, newHash []byte) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if playerID != nil {
+		err = setPasswordAndLogout(tx, nil, playerID, newHash)
+	} else {
+		err = setPasswordAndLogout(tx, &teamID, nil, newHash)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setPasswordAndLogout sets the password of the team or player given, and logs
+// out whoever was logged in with the old password. Players' sessions on the team
+// are left alone when the team's shared password changes.
+func setPasswordAndLogout(tx DB, teamID, playerID *int, newHash []byte) error {
+	if teamID != nil {
+		if err := SetTeamPassword(tx, *teamID, newHash); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM session WHERE team_id = $1 AND player_id IS NULL`, *teamID)
+		return err
+	}
+	if err := SetPlayerPassword(tx, *playerID, newHash); err != nil {
+		return err
+	}
+	_, err := RevokePlayerSessions(tx, *playerID)
+	return err
+}
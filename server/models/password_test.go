@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SetTeamPassword(t *testing.T) {
+	prepareTestDatabase(t)
+
+	require.NoError(t, SetTeamPassword(db, 1, []byte("newhash")))
+	team, err := TeamByID(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("newhash"), team.Hash)
+
+	assert.Equal(t, pgx.ErrNoRows, SetTeamPassword(db, -1, []byte("newhash")))
+	assert.Equal(t, pgx.ErrNoRows, SetPlayerPassword(db, -1, []byte("newhash")))
+}
+
+func Test_ChangePassword(t *testing.T) {
+	prepareTestDatabase(t)
+	expiry := time.Now().Add(time.Hour)
+	teamID, alice, bob := 1, 1, 2
+	require.NoError(t, SaveSession(db, "team", []byte("{}"), expiry, &teamID, nil))
+	require.NoError(t, SaveSession(db, "alice", []byte("{}"), expiry, &teamID, &alice))
+	require.NoError(t, SaveSession(db, "bob", []byte("{}"), expiry, &teamID, &bob))
+	loggedIn := func(token string) bool {
+		_, found, err := FindSession(db, token)
+		require.NoError(t, err)
+		return found
+	}
+
+	require.NoError(t, ChangePassword(db, teamID, &alice, []byte("newhash")))
+	p, err := PlayerByID(db, alice)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("newhash"), p.Hash)
+	assert.False(t, loggedIn("alice"))
+	assert.True(t, loggedIn("bob"), "Other players stay logged in")
+	assert.True(t, loggedIn("team"))
+
+	require.NoError(t, ChangePassword(db, teamID, nil, []byte("newhash")))
+	assert.False(t, loggedIn("team"))
+	assert.True(t, loggedIn("bob"), "Players have their own passwords")
+
+	assert.Equal(t, pgx.ErrNoRows, ChangePassword(db, -1, nil, []byte("newhash")))
+}
+
+func Test_PasswordReset(t *testing.T) {
+	prepareTestDatabase(t)
+
+	teamID, playerID := 2, 1
+	teamReset := &PasswordReset{TokenHash: []byte("team token"), TeamID: &teamID, ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, teamReset.Insert(db))
+	playerReset := &PasswordReset{TokenHash: []byte("player token"), PlayerID: &playerID, ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, playerReset.Insert(db))
+
+	name, err := PasswordResetAccount(db, []byte("team token"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "team2", name)
+	}
+	name, err = PasswordResetAccount(db, []byte("player token"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice", name)
+	}
+
+	require.NoError(t, RedeemPasswordReset(db, []byte("player token"), []byte("newhash")))
+	p, err := PlayerByID(db, playerID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("newhash"), p.Hash)
+
+	assert.Equal(t, pgx.ErrNoRows, RedeemPasswordReset(db, []byte("player token"), []byte("again")),
+		"Reset tokens only work once")
+	assert.Equal(t, pgx.ErrNoRows, RedeemPasswordReset(db, []byte("bogus"), []byte("newhash")))
+
+	// A newer token replaces the old one
+	newer := &PasswordReset{TokenHash: []byte("newer token"), TeamID: &teamID, ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, newer.Insert(db))
+	_, err = PasswordResetAccount(db, []byte("team token"))
+	assert.Equal(t, pgx.ErrNoRows, err)
+
+	expired := &PasswordReset{TokenHash: []byte("old token"), TeamID: &teamID, ExpiresAt: time.Now().Add(-time.Minute)}
+	require.NoError(t, expired.Insert(db))
+	assert.Equal(t, pgx.ErrNoRows, RedeemPasswordReset(db, []byte("old token"), []byte("newhash")))
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"fmt"
+)
+
+// Permission is the name of something staff may be allowed to do, from 'cyboard.permission'.
+type Permission string
+
+const (
+	PermGrantBonus     Permission = "grant_bonus"
+	PermManageServices Permission = "manage_services"
+	PermRunScripts     Permission = "run_scripts"
+	PermViewLogs       Permission = "view_logs"
+	PermManageCtf      Permission = "manage_ctf"
+	PermViewFlags      Permission = "view_flags"
+	PermManageTickets  Permission = "manage_tickets"
+	PermViewUptime     Permission = "view_uptime"
+	PermViewAuditLog   Permission = "view_audit_log"
+
+	PermViewLiveScoreboard Permission = "view_live_scoreboard"
+	PermIgnoreSchedule     Permission = "ignore_schedule"
+
+	PermFileCompromises   Permission = "file_compromises"
+	PermReviewCompromises Permission = "review_compromises"
+	PermScoreIncidents    Permission = "score_incidents"
+	PermManageInjects     Permission = "manage_injects"
+	PermGradeInjects      Permission = "grade_injects"
+)
+
+// PermissionInfo represents a row from 'cyboard.permission'.
+type PermissionInfo struct {
+	Name        Permission `json:"name"`        // name
+	Description string     `json:"description"` // description
+}
+
+// AllPermissions fetches every permission that can be granted, by name.
+func AllPermissions(db DB) ([]PermissionInfo, error) {
+	const sqlstr = `SELECT name, description FROM permission ORDER BY name`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []PermissionInfo{}
+	for rows.Next() {
+		x := PermissionInfo{}
+		if err = rows.Scan(&x.Name, &x.Description); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// RolePermissions fetches the permissions granted to each role, from 'cyboard.role_permission'.
+// Admins & blue teams never appear, since their permissions are fixed.
+func RolePermissions(db DB) (map[TeamRole][]Permission, error) {
+	const sqlstr = `SELECT role_name, permission FROM role_permission ORDER BY role_name, permission`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := map[TeamRole][]Permission{}
+	for rows.Next() {
+		var (
+			role TeamRole
+			perm Permission
+		)
+		if err = rows.Scan(&role, &perm); err != nil {
+			return nil, err
+		}
+		perms[role] = append(perms[role], perm)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return perms, nil
+}
+
+// SetRolePermissions replaces everything each role in `grants` is allowed to do
+// with its list of permissions. Either every role is saved, or none are.
+func SetRolePermissions(db TXer, grants map[TeamRole][]Permission) error {
+	for role := range grants {
+		if role == TeamRoleAdmin || role == TeamRoleBlueteam || role == TeamRoleUnspecified {
+			return fmt.Errorf("the permissions of role %q can't be changed", role)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const sqlstr = `INSERT INTO role_permission (role_name, permission) VALUES ($1, $2)`
+	for role, perms := range grants {
+		if _, err = tx.Exec(`DELETE FROM role_permission WHERE role_name = $1`, role); err != nil {
+			return err
+		}
+		for _, p := range perms {
+			if _, err = tx.Exec(sqlstr, role, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AllPermissions(t *testing.T) {
+	prepareTestDatabase(t)
+
+	perms, err := AllPermissions(db)
+	require.NoError(t, err)
+	names := []Permission{}
+	for _, p := range perms {
+		names = append(names, p.Name)
+		assert.NotEmpty(t, p.Description)
+	}
+	for _, want := range []Permission{PermGrantBonus, PermManageServices, PermRunScripts, PermViewLogs, PermManageCtf, PermViewFlags} {
+		assert.Contains(t, names, want)
+	}
+}
+
+func Test_SetRolePermissions(t *testing.T) {
+	prepareTestDatabase(t)
+
+	before, err := RolePermissions(db)
+	require.NoError(t, err)
+	assert.Contains(t, before[TeamRoleCtfCreator], PermManageCtf)
+	assert.Contains(t, before[TeamRoleWhiteteam], PermGrantBonus)
+	assert.Empty(t, before[TeamRoleAdmin], "Admins have every permission, without being granted any")
+	defer SetRolePermissions(db, map[TeamRole][]Permission{TeamRoleRedteam: before[TeamRoleRedteam]})
+
+	require.NoError(t, SetRolePermissions(db, map[TeamRole][]Permission{TeamRoleRedteam: {PermViewUptime, PermViewLogs}}))
+	after, err := RolePermissions(db)
+	require.NoError(t, err)
+	assert.Equal(t, []Permission{PermViewLogs, PermViewUptime}, after[TeamRoleRedteam])
+	assert.Equal(t, before[TeamRoleCtfCreator], after[TeamRoleCtfCreator], "Other roles are left alone")
+
+	assert.Error(t, SetRolePermissions(db, map[TeamRole][]Permission{TeamRoleRedteam: {"launch_missiles"}}))
+	after, _ = RolePermissions(db)
+	assert.Equal(t, []Permission{PermViewLogs, PermViewUptime}, after[TeamRoleRedteam], "Nothing changes on error")
+
+	err = SetRolePermissions(db, map[TeamRole][]Permission{
+		TeamRoleRedteam:    {PermViewUptime},
+		TeamRoleCtfCreator: {"launch_missiles"},
+	})
+	assert.Error(t, err)
+	after, _ = RolePermissions(db)
+	assert.Equal(t, []Permission{PermViewLogs, PermViewUptime}, after[TeamRoleRedteam], "One bad role saves none of them")
+	assert.Equal(t, before[TeamRoleCtfCreator], after[TeamRoleCtfCreator])
+
+	assert.Error(t, SetRolePermissions(db, map[TeamRole][]Permission{TeamRoleAdmin: nil}))
+	assert.Error(t, SetRolePermissions(db, map[TeamRole][]Permission{TeamRoleBlueteam: {PermViewFlags}}))
+}
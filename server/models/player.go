@@ -0,0 +1,155 @@
+package models
+
+import (
+	"time"
+)
+
+// Player represents a row from 'cyboard.player'. Players are individual logins,
+// each belonging to a team, and acting with that team's role.
+type Player struct {
+	ID        int       `json:"id"`         // id
+	TeamID    int       `json:"team_id"`    // team_id
+	Name      string    `json:"name"`       // name
+	Hash      []byte    `json:"-"`          // hash
+	Disabled  bool      `json:"disabled"`   // disabled
+	CreatedAt time.Time `json:"created_at"` // created_at
+}
+
+// Insert inserts the Player to the database.
+func (p *Player) Insert(db DB) error {
+	const sqlstr = `INSERT INTO player (team_id, name, hash, disabled) VALUES ($1, $2, $3, $4) ` +
+		`RETURNING id, created_at`
+
+	return db.QueryRow(sqlstr, p.TeamID, p.Name, p.Hash, p.Disabled).Scan(&p.ID, &p.CreatedAt)
+}
+
+// Update updates the Player in the database.
+// If the `Hash` field is not set, then Update will not attempt to change
+// the player's password.
+func (p *Player) Update(db DB) error {
+	var err error
+	if p.Hash == nil {
+		const sqlstr = `UPDATE player SET (team_id, name, disabled) = ($2, $3, $4) WHERE id = $1`
+		_, err = db.Exec(sqlstr, p.ID, p.TeamID, p.Name, p.Disabled)
+	} else {
+		const sqlstr = `UPDATE player SET (team_id, name, disabled, hash) = ($2, $3, $4, $5) WHERE id = $1`
+		_, err = db.Exec(sqlstr, p.ID, p.TeamID, p.Name, p.Disabled, p.Hash)
+	}
+	return err
+}
+
+// Delete deletes the Player from the database. Their solves stay with the team.
+func (p *Player) Delete(db DB) error {
+	const sqlstr = `DELETE FROM player WHERE id = $1`
+	_, err := db.Exec(sqlstr, p.ID)
+	return err
+}
+
+// PlayerByName retrieves a row from 'cyboard.player' as a Player.
+func PlayerByName(db DB, name string) (*Player, error) {
+	const sqlstr = `SELECT ` +
+		`id, team_id, name, hash, disabled, created_at ` +
+		`FROM player ` +
+		`WHERE name = $1`
+	p := Player{}
+	err := db.QueryRow(sqlstr, name).Scan(&p.ID, &p.TeamID, &p.Name, &p.Hash, &p.Disabled, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// PlayerByID retrieves a row from 'cyboard.player' as a Player.
+func PlayerByID(db DB, id int) (*Player, error) {
+	const sqlstr = `SELECT ` +
+		`id, team_id, name, hash, disabled, created_at ` +
+		`FROM player ` +
+		`WHERE id = $1`
+	p := Player{}
+	err := db.QueryRow(sqlstr, id).Scan(&p.ID, &p.TeamID, &p.Name, &p.Hash, &p.Disabled, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// PlayerView is a player, along with the name of their team.
+type PlayerView struct {
+	Player
+	TeamName string   `json:"team_name"` // team.name
+	RoleName TeamRole `json:"role_name"` // team.role_name
+}
+
+// AllPlayers fetches every player, grouped by team.
+// Used by the admin dashboard to view & modify player accounts.
+func AllPlayers(db DB) ([]PlayerView, error) {
+	const sqlstr = `SELECT p.id, p.team_id, p.name, p.disabled, p.created_at, t.name, t.role_name
+	FROM player AS p
+		JOIN team AS t ON p.team_id = t.id
+	ORDER BY t.role_name DESC, t.id, p.name`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ps := []PlayerView{}
+	for rows.Next() {
+		p := PlayerView{}
+		err = rows.Scan(&p.ID, &p.TeamID, &p.Name, &p.Disabled, &p.CreatedAt, &p.TeamName, &p.RoleName)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+// PlayerStats sums up the CTF challenges a player has solved for their team.
+type PlayerStats struct {
+	PlayerID  *int       `json:"player_id"`  // player.id, null for solves using the team's shared login
+	Name      string     `json:"name"`       // player.name
+	Solves    int        `json:"solves"`     // count of ctf_solve
+	Points    int        `json:"points"`     // sum of challenge.total
+	LastSolve *time.Time `json:"last_solve"` // max of ctf_solve.created_at
+}
+
+// TeamPlayerStats tallies the CTF solves of each player on a team, including players
+// that haven't solved anything yet. Solves submitted with the team's shared login
+// are counted together, in a row with no PlayerID, listed last.
+func TeamPlayerStats(db DB, teamID int) ([]PlayerStats, error) {
+	const sqlstr = `SELECT p.id, coalesce(p.name, ''), count(cs.challenge_id),
+		coalesce(sum(ch.total), 0)::INT, max(cs.created_at)
+	FROM (SELECT * FROM ctf_solve WHERE team_id = $1) AS cs
+		FULL JOIN (SELECT * FROM player WHERE team_id = $1) AS p ON cs.player_id = p.id
+		LEFT JOIN challenge AS ch ON cs.challenge_id = ch.id
+	GROUP BY p.id, p.name
+	ORDER BY p.id IS NULL, 4 DESC, p.name`
+
+	rows, err := db.Query(sqlstr, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []PlayerStats{}
+	for rows.Next() {
+		x := PlayerStats{}
+		if err = rows.Scan(&x.PlayerID, &x.Name, &x.Solves, &x.Points, &x.LastSolve); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PlayerByName(t *testing.T) {
+	prepareTestDatabase(t)
+
+	p, err := PlayerByName(db, "alice")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, p.ID)
+		assert.Equal(t, 1, p.TeamID)
+		assert.NotEmpty(t, p.Hash)
+	}
+
+	_, err = PlayerByName(db, "team1")
+	assert.Equal(t, pgx.ErrNoRows, err, "Teams are not players")
+}
+
+func Test_PlayerInsert(t *testing.T) {
+	prepareTestDatabase(t)
+
+	p := &Player{TeamID: 2, Name: "dave", Hash: []byte("hash")}
+	if assert.NoError(t, p.Insert(db)) {
+		assert.NotZero(t, p.ID)
+		assert.False(t, p.CreatedAt.IsZero())
+	}
+
+	taken := &Player{TeamID: 2, Name: "team1", Hash: []byte("hash")}
+	assert.Error(t, taken.Insert(db), "Players can't share a name with a team")
+
+	dupe := &Player{TeamID: 2, Name: "alice", Hash: []byte("hash")}
+	assert.Error(t, dupe.Insert(db))
+}
+
+func Test_TeamNameNotPlayer(t *testing.T) {
+	prepareTestDatabase(t)
+
+	team := &Team{Name: "alice", RoleName: TeamRoleCtfCreator, Hash: []byte("hash")}
+	assert.Error(t, team.Insert(db), "Teams can't share a name with a player")
+
+	team, err := TeamByID(db, 2)
+	require.NoError(t, err)
+	team.Name = "bob"
+	assert.Error(t, team.Update(db), "Nor be renamed to one")
+}
+
+func Test_AllPlayers(t *testing.T) {
+	prepareTestDatabase(t)
+
+	players, err := AllPlayers(db)
+	require.NoError(t, err)
+	if assert.Equal(t, 3, len(players)) {
+		assert.Equal(t, "alice", players[0].Name)
+		assert.Equal(t, "team1", players[0].TeamName)
+		assert.Equal(t, TeamRoleBlueteam, players[0].RoleName)
+	}
+}
+
+func Test_TeamPlayerStats(t *testing.T) {
+	prepareTestDatabase(t)
+
+	stats, err := TeamPlayerStats(db, 1)
+	require.NoError(t, err)
+	if assert.Equal(t, 2, len(stats)) {
+		assert.Equal(t, "alice", stats[0].Name)
+		assert.Equal(t, 1, stats[0].Solves)
+		assert.Equal(t, 5, stats[0].Points)
+		assert.Equal(t, "bob", stats[1].Name)
+		assert.Equal(t, 0, stats[1].Solves)
+		assert.Nil(t, stats[1].LastSolve)
+	}
+
+	// team2's only solve was made with the shared team login
+	stats, err = TeamPlayerStats(db, 2)
+	require.NoError(t, err)
+	if assert.Equal(t, 2, len(stats)) {
+		assert.Equal(t, "carol", stats[0].Name)
+		assert.Nil(t, stats[1].PlayerID)
+		assert.Equal(t, 1, stats[1].Solves)
+		assert.Equal(t, 8, stats[1].Points)
+	}
+}
+
+func Test_CheckFlagSubmission_CreditsPlayer(t *testing.T) {
+	prepareTestDatabase(t)
+
+	team := &Team{ID: 2, Name: "team2"}
+	player := &Player{ID: 3, TeamID: 2, Name: "carol"}
+	guess := &ChallengeGuess{Name: "Totally Rad Challenge", Flag: "flag{its_ok_tobe_rad_sometimes}"}
+
+	flagState, err := CheckFlagSubmission(db, context.Background(), team, player, guess)
+	require.NoError(t, err)
+	assert.Equal(t, ValidFlag, flagState)
+
+	stats, err := TeamPlayerStats(db, 2)
+	require.NoError(t, err)
+	if assert.NotEmpty(t, stats) {
+		assert.Equal(t, "carol", stats[0].Name)
+		assert.Equal(t, 1, stats[0].Solves)
+	}
+}
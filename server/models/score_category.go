@@ -0,0 +1,115 @@
+package models
+
+import (
+	"regexp"
+
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+)
+
+// ScoreCategory represents a row from 'cyboard.score_category'.
+// Points in a category are multiplied by its Weight before being added to a team's score.
+type ScoreCategory struct {
+	ID       int     `json:"id"`       // id
+	Name     string  `json:"name"`     // name
+	Label    string  `json:"label"`    // label
+	Weight   float32 `json:"weight"`   // weight
+	Position int     `json:"position"` // position
+	Builtin  bool    `json:"builtin"`  // builtin
+}
+
+// scoreCategoryName matches the check constraint on 'score_category.name'.
+var scoreCategoryName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Validate checks the category's fields, before they're saved.
+func (sc *ScoreCategory) Validate() error {
+	switch {
+	case !scoreCategoryName.MatchString(sc.Name):
+		return errors.New("a category name must be lowercase letters, digits, and underscores, starting with a letter")
+	case sc.Label == "":
+		return errors.New("a category needs a label")
+	case sc.Weight < 0:
+		return errors.New("weight must not be negative")
+	}
+	return nil
+}
+
+// Insert adds a new, non-builtin category.
+func (sc *ScoreCategory) Insert(db DB) error {
+	const sqlstr = `INSERT INTO score_category (name, label, weight, position) VALUES ($1, $2, $3, $4) RETURNING id`
+	sc.Builtin = false
+	return db.QueryRow(sqlstr, sc.Name, sc.Label, sc.Weight, sc.Position).Scan(&sc.ID)
+}
+
+// Update a category's label, weight, and position. The name can't be changed,
+// since points are filed under it. Returns pgx.ErrNoRows if the category doesn't exist.
+func (sc *ScoreCategory) Update(db DB) error {
+	const sqlstr = `UPDATE score_category SET (label, weight, position) = ($2, $3, $4) WHERE id = $1
+	RETURNING name, builtin`
+	return db.QueryRow(sqlstr, sc.ID, sc.Label, sc.Weight, sc.Position).Scan(&sc.Name, &sc.Builtin)
+}
+
+// Delete a category. Builtin categories can't be deleted, and neither can
+// any category that points have been awarded in.
+func (sc *ScoreCategory) Delete(db DB) error {
+	const sqlstr = `DELETE FROM score_category WHERE id = $1 AND NOT builtin`
+	tag, err := db.Exec(sqlstr, sc.ID)
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23503" { // foreign_key_violation
+		return errors.WithMessage(err, "category still has points awarded in it")
+	} else if err != nil {
+		return err
+	} else if tag.RowsAffected() == 0 {
+		return errors.New("no such category, or it's builtin")
+	}
+	return nil
+}
+
+// ScoreCategoryByID retrieves a category by its id.
+func ScoreCategoryByID(db DB, id int) (*ScoreCategory, error) {
+	const sqlstr = `SELECT id, name, label, weight, position, builtin FROM score_category WHERE id = $1`
+	sc := ScoreCategory{}
+	err := db.QueryRow(sqlstr, id).Scan(&sc.ID, &sc.Name, &sc.Label, &sc.Weight, &sc.Position, &sc.Builtin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sc, nil
+}
+
+// ScoreCategoryByName retrieves a category by its unique name.
+func ScoreCategoryByName(db DB, name string) (*ScoreCategory, error) {
+	const sqlstr = `SELECT id, name, label, weight, position, builtin FROM score_category WHERE name = $1`
+	sc := ScoreCategory{}
+	err := db.QueryRow(sqlstr, name).Scan(&sc.ID, &sc.Name, &sc.Label, &sc.Weight, &sc.Position, &sc.Builtin)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sc, nil
+}
+
+// AllScoreCategories fetches every category, in the order they're displayed.
+func AllScoreCategories(db DB) ([]ScoreCategory, error) {
+	const sqlstr = `SELECT id, name, label, weight, position, builtin
+	FROM score_category ORDER BY position, name`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []ScoreCategory{}
+	for rows.Next() {
+		x := ScoreCategory{}
+		if err = rows.Scan(&x.ID, &x.Name, &x.Label, &x.Weight, &x.Position, &x.Builtin); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ScoreCategory_Validate(t *testing.T) {
+	valid := ScoreCategory{Name: "king_of_the_hill", Label: "King of the Hill", Weight: 1}
+	assert.Nil(t, valid.Validate())
+
+	badName, noLabel, negative := valid, valid, valid
+	badName.Name = "King of the Hill"
+	noLabel.Label = ""
+	negative.Weight = -1
+	assert.Error(t, badName.Validate())
+	assert.Error(t, noLabel.Validate())
+	assert.Error(t, negative.Validate())
+}
+
+func Test_AllScoreCategories(t *testing.T) {
+	prepareTestDatabase(t)
+
+	categories, err := AllScoreCategories(db)
+	if assert.Nil(t, err) && assert.Equal(t, 5, len(categories)) {
+		names := []string{}
+		for _, c := range categories {
+			names = append(names, c.Name)
+			assert.True(t, c.Builtin)
+		}
+		assert.Equal(t, []string{"service", "ctf", "incident", "inject", "other"}, names)
+	}
+}
+
+func Test_ScoreCategory_Weights(t *testing.T) {
+	prepareTestDatabase(t)
+
+	koth := &ScoreCategory{Name: "koth", Label: "King of the Hill", Weight: 2, Position: 6}
+	require.Nil(t, koth.Insert(db))
+	require.Nil(t, (&OtherPoints{TeamID: 2, Points: 3, Reason: "held the hill", Category: "koth"}).Insert(db))
+
+	ctf, err := ScoreCategoryByName(db, "ctf")
+	require.Nil(t, err)
+	ctf.Weight = 1.5
+	require.Nil(t, ctf.Update(db))
+
+	scores, err := TeamsScores(db)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(scores)) {
+		team2 := scores[1]
+		assert.Equal(t, 12, team2.Categories["ctf"], "CTF points count half again")
+		assert.Equal(t, 6, team2.Categories["koth"], "Points in the new category count double")
+		assert.Equal(t, 0, scores[0].Categories["koth"], "Every team gets every category")
+		assert.Equal(t, 31, team2.Score)
+	}
+
+	// Fractional weights leave a half point in two categories; the score still matches the bars
+	require.Nil(t, (&OtherPoints{TeamID: 2, Points: 0.25, Reason: "held it a moment", Category: "koth"}).Insert(db))
+	require.Nil(t, (&OtherPoints{TeamID: 2, Points: 0.5, Reason: "tidy desk"}).Insert(db))
+	scores, err = TeamsScores(db)
+	require.Nil(t, err)
+	for _, s := range scores {
+		sum := 0
+		for _, pts := range s.Categories {
+			sum += pts
+		}
+		assert.Equal(t, sum, s.Score, "Score is the sum of the rounded categories, for team %d", s.TeamID)
+	}
+
+	err = koth.Delete(db)
+	if assert.Error(t, err, "Points were awarded in it") {
+		assert.Contains(t, err.Error(), "still has points")
+	}
+	err = ctf.Delete(db)
+	if assert.Error(t, err, "Builtin categories stay") {
+		assert.NotContains(t, err.Error(), "still has points")
+	}
+
+	empty := &ScoreCategory{Name: "empty", Label: "Empty", Weight: 1}
+	require.Nil(t, empty.Insert(db))
+	assert.Nil(t, empty.Delete(db))
+}
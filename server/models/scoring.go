@@ -1,33 +1,51 @@
 package models
 
-import "time"
+import (
+	"math"
+	"sort"
+	"time"
+)
 
+// TeamsScoresResponse is a team's total score, and its points in each score category,
+// keyed by category name. Category points are weighted; the score is their sum.
+//
+// The last few fields break ties between teams with the same score. Rank is only
+// filled in by RankTeams.
 type TeamsScoresResponse struct {
-	TeamID  int    `json:"team_id"`
-	Name    string `json:"name"`
-	Score   int    `json:"score"`
-	Service int    `json:"service"`
-	Ctf     int    `json:"ctf"`
-	Other   int    `json:"other"`
+	TeamID     int            `json:"team_id"`
+	Name       string         `json:"name"`
+	Rank       int            `json:"rank"`
+	Score      int            `json:"score"`
+	Categories map[string]int `json:"categories"`
+
+	ReachedAt *time.Time `json:"reached_at"` // when the score last changed, or nil if it never has
+	CtfSolves int        `json:"ctf_solves"`
+	Uptime    float64    `json:"uptime"` // percent of service checks passed
 }
 
-// TeamsScores TODO
+// TeamsScores tallies up every blue team's score, in each of the score categories.
 func TeamsScores(db DB) ([]TeamsScoresResponse, error) {
+	return teamsScoresAsOf(db, nil)
+}
+
+// TeamsScoresAsOf is like TeamsScores, but only counts points that were earned
+// on or before the given timestamp. It backs the frozen, public scoreboard.
+func TeamsScoresAsOf(db DB, asOf time.Time) ([]TeamsScoresResponse, error) {
+	return teamsScoresAsOf(db, &asOf)
+}
+
+// teamsScoresAsOf counts every point, if `asOf` is nil.
+func teamsScoresAsOf(db DB, asOf *time.Time) ([]TeamsScoresResponse, error) {
 	const sqlstr = `
-	SELECT
-		team.id AS team_id,
-		team.name AS team_name,
-		round(service_score.points + ctf_score.points + other_score.points) AS score,
-		round(service_score.points) AS service,
-		round(ctf_score.points) AS ctf,
-		round(other_score.points) AS other
-	FROM team
-		JOIN service_score ON team.id = service_score.team_id
-		JOIN ctf_score ON team.id = ctf_score.team_id
-		JOIN other_score ON team.id = other_score.team_id
-	ORDER BY team.id`
-
-	rows, err := db.Query(sqlstr)
+	SELECT team.id, team.name, c.name, (c.weight * COALESCE(sum(pa.points), 0))::float8
+	FROM blueteam AS team
+		CROSS JOIN score_category AS c
+		LEFT JOIN point_award AS pa ON team.id = pa.team_id AND c.name = pa.category
+			AND ($1::timestamptz IS NULL OR pa.created_at <= $1)
+	GROUP BY team.id, team.name, c.id
+	ORDER BY team.id, c.position, c.name`
+
+	rows, err := db.Query(sqlstr, asOf)
 	if err != nil {
 		return nil, err
 	}
@@ -35,19 +53,257 @@ func TeamsScores(db DB) ([]TeamsScoresResponse, error) {
 
 	scores := []TeamsScoresResponse{}
 	for rows.Next() {
-		s := TeamsScoresResponse{}
-		if err = rows.Scan(&s.TeamID, &s.Name, &s.Score, &s.Service, &s.Ctf, &s.Other); err != nil {
+		var (
+			teamID   int
+			name     string
+			category string
+			points   float64
+		)
+		if err = rows.Scan(&teamID, &name, &category, &points); err != nil {
 			return nil, err
 		}
-		scores = append(scores, s)
+		if n := len(scores); n == 0 || scores[n-1].TeamID != teamID {
+			scores = append(scores, TeamsScoresResponse{TeamID: teamID, Name: name, Categories: map[string]int{}})
+		}
+		// Each category is rounded once, and the score is their sum, so the bars add up
+		n := len(scores) - 1
+		scores[n].Categories[category] = int(math.Round(points))
+		scores[n].Score += scores[n].Categories[category]
 	}
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
+	if err = teamsTiebreakStats(db, asOf, scores); err != nil {
+		return nil, err
+	}
 	return scores, nil
 }
 
+// teamsTiebreakStats fills in the stats used to break ties, as of the same time as the scores.
+// Each stat is tallied for every team at once, since this runs on every score broadcast.
+func teamsTiebreakStats(db DB, asOf *time.Time, scores []TeamsScoresResponse) error {
+	const sqlstr = `
+	WITH reached AS (
+		SELECT pa.team_id, max(pa.created_at) AS at
+		FROM point_award AS pa
+			JOIN score_category AS c ON pa.category = c.name
+		WHERE pa.points * c.weight <> 0 AND ($1::timestamptz IS NULL OR pa.created_at <= $1)
+		GROUP BY pa.team_id
+	), solves AS (
+		SELECT cs.team_id, count(*) AS n
+		FROM ctf_solve AS cs
+		WHERE $1::timestamptz IS NULL OR cs.created_at <= $1
+		GROUP BY cs.team_id
+	), uptime AS (
+		SELECT t.team_id, 100.0 * sum(t.checks) FILTER (WHERE t.status = 'pass') / NULLIF(sum(t.checks), 0) AS pct
+		FROM service_check_tally AS t
+		WHERE $1::timestamptz IS NULL OR t.bucket + interval '1 minute' <= $1
+		GROUP BY t.team_id
+	)
+	SELECT team.id, reached.at, COALESCE(solves.n, 0), COALESCE(uptime.pct, 0)::float8
+	FROM blueteam AS team
+		LEFT JOIN reached ON team.id = reached.team_id
+		LEFT JOIN solves ON team.id = solves.team_id
+		LEFT JOIN uptime ON team.id = uptime.team_id`
+
+	rows, err := db.Query(sqlstr, asOf)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byTeam := make(map[int]*TeamsScoresResponse, len(scores))
+	for i := range scores {
+		byTeam[scores[i].TeamID] = &scores[i]
+	}
+	for rows.Next() {
+		var (
+			teamID    int
+			reachedAt *time.Time
+			solves    int
+			uptime    float64
+		)
+		if err = rows.Scan(&teamID, &reachedAt, &solves, &uptime); err != nil {
+			return err
+		}
+		if s, ok := byTeam[teamID]; ok {
+			s.ReachedAt, s.CtfSolves, s.Uptime = reachedAt, solves, uptime
+		}
+	}
+	return rows.Err()
+}
+
+// Tiebreaker is a rule for ordering teams with the same score.
+type Tiebreaker string
+
+const (
+	TiebreakEarliest  Tiebreaker = "earliest"   // reached their score first
+	TiebreakCtfSolves Tiebreaker = "ctf_solves" // solved more ctf challenges
+	TiebreakUptime    Tiebreaker = "uptime"     // passed more of their service checks
+)
+
+// Tiebreakers are all the tiebreak rules, in their default order.
+var Tiebreakers = []Tiebreaker{TiebreakEarliest, TiebreakCtfSolves, TiebreakUptime}
+
+// Valid reports if the tiebreaker is one of the known rules.
+func (tb Tiebreaker) Valid() bool {
+	for _, x := range Tiebreakers {
+		if tb == x {
+			return true
+		}
+	}
+	return false
+}
+
+// compare returns -1 if team `a` wins the tiebreak, 1 if `b` does, or 0 if they're still tied.
+func (tb Tiebreaker) compare(a, b *TeamsScoresResponse) int {
+	switch tb {
+	case TiebreakEarliest:
+		// Never having scored counts as reaching the score right away
+		var at, bt time.Time
+		if a.ReachedAt != nil {
+			at = *a.ReachedAt
+		}
+		if b.ReachedAt != nil {
+			bt = *b.ReachedAt
+		}
+		switch {
+		case at.Before(bt):
+			return -1
+		case bt.Before(at):
+			return 1
+		}
+	case TiebreakCtfSolves:
+		switch {
+		case a.CtfSolves > b.CtfSolves:
+			return -1
+		case a.CtfSolves < b.CtfSolves:
+			return 1
+		}
+	case TiebreakUptime:
+		switch {
+		case a.Uptime > b.Uptime:
+			return -1
+		case a.Uptime < b.Uptime:
+			return 1
+		}
+	}
+	return 0
+}
+
+// RankTeams fills in each team's Rank: highest score first, with ties broken by each
+// of the tiebreakers, in order. Teams still tied after that share a rank, using
+// standard competition ranking ("1224"). The order of the scores isn't changed.
+func RankTeams(scores []TeamsScoresResponse, tiebreakers []Tiebreaker) {
+	compare := func(a, b *TeamsScoresResponse) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		for _, tb := range tiebreakers {
+			if c := tb.compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+
+	order := make([]*TeamsScoresResponse, len(scores))
+	for i := range scores {
+		order[i] = &scores[i]
+	}
+	sort.SliceStable(order, func(i, j int) bool { return compare(order[i], order[j]) < 0 })
+	for i, s := range order {
+		if i > 0 && compare(order[i-1], s) == 0 {
+			s.Rank = order[i-1].Rank
+		} else {
+			s.Rank = i + 1
+		}
+	}
+}
+
+// ScoreHistoryBucket is a team's running score totals as of the end of a time bucket.
+type ScoreHistoryBucket struct {
+	TeamID     int            `json:"team_id"`
+	Name       string         `json:"name"`
+	Time       time.Time      `json:"time"` // start of the bucket
+	Score      int            `json:"score"`
+	Categories map[string]int `json:"categories"`
+}
+
+// TeamsScoreHistory charts each team's cumulative score over the span of [start, end],
+// in buckets of the given width. Every team gets a row for every bucket, even if they
+// didn't score during it. Points earned before `start` are counted in the first bucket.
+//
+// Buckets are aligned with TimescaleDB's `time_bucket`, and passing service checks are
+// read from the one minute `service_check_tally`, so the bucket width should be a whole
+// number of minutes.
+func TeamsScoreHistory(db DB, bucket time.Duration, start, end time.Time) ([]ScoreHistoryBucket, error) {
+	const sqlstr = `
+	WITH buckets AS (
+		SELECT generate_series(time_bucket($1 * interval '1 second', $2::timestamptz),
+			$3::timestamptz, $1 * interval '1 second') AS bucket
+	), service_passes AS (
+		SELECT bucket, team_id, service_id, checks
+		FROM service_check_tally
+		WHERE status = 'pass'
+	), awards AS (
+		SELECT time_bucket($1 * interval '1 second', greatest(sp.bucket, $2)) AS bucket,
+			sp.team_id, 'service' AS category, sum(sp.checks * service.points) AS points
+		FROM service_passes AS sp
+			JOIN service ON sp.service_id = service.id
+		WHERE sp.bucket <= $3
+		GROUP BY 1, 2
+		UNION ALL
+		SELECT time_bucket($1 * interval '1 second', greatest(pa.created_at, $2)) AS bucket,
+			pa.team_id, pa.category, sum(pa.points) AS points
+		FROM point_award AS pa
+		WHERE pa.category <> 'service' AND pa.created_at <= $3
+		GROUP BY 1, 2, 3
+	)
+	SELECT team.id AS team_id, team.name AS team_name, b.bucket, c.name,
+		(c.weight * sum(COALESCE(a.points, 0)) OVER w)::float8 AS points
+	FROM blueteam AS team
+		CROSS JOIN buckets AS b
+		CROSS JOIN score_category AS c
+		LEFT JOIN awards AS a ON team.id = a.team_id AND b.bucket = a.bucket AND c.name = a.category
+	WINDOW w AS (PARTITION BY team.id, c.id ORDER BY b.bucket)
+	ORDER BY team.id, b.bucket, c.position, c.name`
+
+	rows, err := db.Query(sqlstr, int64(bucket/time.Second), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []ScoreHistoryBucket{}
+	for rows.Next() {
+		var (
+			h        ScoreHistoryBucket
+			category string
+			points   float64
+		)
+		if err = rows.Scan(&h.TeamID, &h.Name, &h.Time, &category, &points); err != nil {
+			return nil, err
+		}
+		if n := len(history); n == 0 || history[n-1].TeamID != h.TeamID || !history[n-1].Time.Equal(h.Time) {
+			h.Categories = map[string]int{}
+			history = append(history, h)
+		}
+		n := len(history) - 1
+		history[n].Categories[category] = int(math.Round(points))
+		history[n].Score += history[n].Categories[category]
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
 // LatestScoreChange retrieves the timestamp of the last event that changed any team's score.
 // This is a lightweight way of checking if other pieces of info need updating.
 // If the timestamp changes between calls, then that means other score or status related
@@ -57,6 +313,9 @@ func LatestScoreChange(db DB) (time.Time, error) {
 	UNION ALL SELECT created_at FROM service_check
 	UNION ALL SELECT created_at FROM ctf_solve
 	UNION ALL SELECT created_at FROM other_points
+	UNION ALL SELECT revoked_at FROM other_points WHERE revoked_at IS NOT NULL
+	UNION ALL SELECT scored_at FROM incident_report WHERE scored_at IS NOT NULL
+	UNION ALL SELECT graded_at FROM inject_submission WHERE graded_at IS NOT NULL
 	ORDER BY created_at DESC
 	LIMIT 1`
 	var timestamp time.Time
@@ -7,20 +7,120 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// cats maps points to each of the builtin score categories.
+func cats(service, ctf, incident, inject, other int) map[string]int {
+	return map[string]int{"service": service, "ctf": ctf, "incident": incident, "inject": inject, "other": other}
+}
+
+// assertScores compares scores, checking the time each team's score was reached separately,
+// since the database hands back timestamps in its own time zone.
+func assertScores(t *testing.T, expected []TeamsScoresResponse, reachedAt []string, actual []TeamsScoresResponse) {
+	if !assert.Equal(t, len(expected), len(actual), "Number of scoring teams") {
+		return
+	}
+	for idx := range expected {
+		x := actual[idx]
+		at, _ := time.Parse(time.RFC3339, reachedAt[idx])
+		if assert.NotNil(t, x.ReachedAt, "(team=%v)", x.Name) {
+			assert.True(t, at.Equal(*x.ReachedAt), "Score reached at %v, not %v (team=%v)", *x.ReachedAt, at, x.Name)
+		}
+		x.ReachedAt = nil
+		assert.Equal(t, expected[idx], x, "Scores do not match: (team=%v), (idx=%d)", expected[idx].Name, idx)
+	}
+}
+
 func Test_TeamsScores(t *testing.T) {
 	prepareTestDatabase(t)
+	// team1 passed both service checks. team2 passed one, and was last awarded points for its inject.
+	// Service points are dated at the end of the minute their check was in.
 	expected_scores := []TeamsScoresResponse{
-		{TeamID: 1, Name: "team1", Score: 15, Service: 4, Ctf: 5, Other: 5},
-		{TeamID: 2, Name: "team2", Score: 10, Service: 2, Ctf: 8, Other: 0},
+		{TeamID: 1, Name: "team1", Score: 31, Categories: cats(4, 5, 0, 16, 5), CtfSolves: 1, Uptime: 100},
+		{TeamID: 2, Name: "team2", Score: 21, Categories: cats(2, 8, 6, 5, 0), CtfSolves: 1, Uptime: 50},
 	}
 
 	scores, err := TeamsScores(db)
 	if assert.Nil(t, err) {
-		assert.Equal(t, 2, len(scores), "Number of scoring teams")
+		assertScores(t, expected_scores, []string{"2018-07-29T09:16:00-04:00", "2018-07-29T09:13:00-04:00"}, scores)
+	}
+}
+
+func Test_TeamsScoresAsOf(t *testing.T) {
+	prepareTestDatabase(t)
+	// Only the first round of service checks, team1's solve, and team1's bonus came before this.
+	// team2's incident report, and both teams' injects, weren't graded until later.
+	asOf, _ := time.Parse(time.RFC3339, "2018-07-29T09:02:00.000-04:00")
+	expected_scores := []TeamsScoresResponse{
+		{TeamID: 1, Name: "team1", Score: 12, Categories: cats(2, 5, 0, 0, 5), CtfSolves: 1, Uptime: 100},
+		{TeamID: 2, Name: "team2", Score: 2, Categories: cats(2, 0, 0, 0, 0), CtfSolves: 0, Uptime: 100},
+	}
+
+	scores, err := TeamsScoresAsOf(db, asOf)
+	if assert.Nil(t, err) {
+		assertScores(t, expected_scores, []string{"2018-07-29T09:01:00-04:00", "2018-07-29T09:01:00-04:00"}, scores)
+	}
+
+	live, err := TeamsScores(db)
+	if assert.Nil(t, err) {
+		scores, err = TeamsScoresAsOf(db, time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, live, scores, "Scores as of right now should match the live scores")
+	}
+}
+
+func Test_RankTeams(t *testing.T) {
+	early, late := time.Now().Add(-time.Hour), time.Now()
+	scores := []TeamsScoresResponse{
+		{TeamID: 1, Name: "a", Score: 10, ReachedAt: &late, CtfSolves: 2, Uptime: 90},
+		{TeamID: 2, Name: "b", Score: 30, ReachedAt: &late},
+		{TeamID: 3, Name: "c", Score: 10, ReachedAt: &early, CtfSolves: 1, Uptime: 80},
+		{TeamID: 4, Name: "d", Score: 5, ReachedAt: &early},
+		{TeamID: 5, Name: "e", Score: 10, ReachedAt: &late, CtfSolves: 2, Uptime: 95},
+	}
+	ranks := func() []int {
+		xs := []int{}
+		for _, s := range scores {
+			xs = append(xs, s.Rank)
+		}
+		return xs
+	}
+
+	RankTeams(scores, nil)
+	assert.Equal(t, []int{2, 1, 2, 5, 2}, ranks(), "Without tiebreakers, tied teams share a rank")
+
+	RankTeams(scores, Tiebreakers)
+	assert.Equal(t, []int{4, 1, 2, 5, 3}, ranks(), "Earliest to the score wins, then ctf solves, then uptime")
+
+	RankTeams(scores, []Tiebreaker{TiebreakCtfSolves})
+	assert.Equal(t, []int{2, 1, 4, 5, 2}, ranks(), "Teams tied on every tiebreaker still share a rank")
+
+	assert.Equal(t, "a", scores[0].Name, "Ranking doesn't reorder the scores")
+	assert.False(t, Tiebreaker("coin_flip").Valid())
+}
+
+func Test_TeamsScoreHistory(t *testing.T) {
+	prepareTestDatabase(t)
+	start, _ := time.Parse(time.RFC3339, "2018-07-29T09:00:00.000-04:00")
+	end := start.Add(20 * time.Minute)
+	at := func(mins int) time.Time { return start.Add(time.Duration(mins) * time.Minute) }
+
+	// team1's bonus was granted before the start, so it's counted in the first bucket.
+	// team2's incident report was scored at 09:12, and its late inject (worth half) at 09:13.
+	// team1's inject was graded at 09:14.
+	expected := []ScoreHistoryBucket{
+		{TeamID: 1, Name: "team1", Time: at(0), Score: 12, Categories: cats(2, 5, 0, 0, 5)},
+		{TeamID: 1, Name: "team1", Time: at(10), Score: 31, Categories: cats(4, 5, 0, 16, 5)},
+		{TeamID: 1, Name: "team1", Time: at(20), Score: 31, Categories: cats(4, 5, 0, 16, 5)},
+		{TeamID: 2, Name: "team2", Time: at(0), Score: 10, Categories: cats(2, 8, 0, 0, 0)},
+		{TeamID: 2, Name: "team2", Time: at(10), Score: 21, Categories: cats(2, 8, 6, 5, 0)},
+		{TeamID: 2, Name: "team2", Time: at(20), Score: 21, Categories: cats(2, 8, 6, 5, 0)},
+	}
 
-		for idx, expected := range expected_scores {
-			actual := scores[idx]
-			assert.Equal(t, expected, actual, "Scores do not match: (team=%v), (idx=%d)", expected.Name, idx)
+	history, err := TeamsScoreHistory(db, 10*time.Minute, start, end)
+	if assert.Nil(t, err) && assert.Equal(t, len(expected), len(history)) {
+		for idx, actual := range history {
+			assert.True(t, expected[idx].Time.Equal(actual.Time), "Bucket times differ at idx=%d", idx)
+			actual.Time = expected[idx].Time
+			assert.Equal(t, expected[idx], actual, "History does not match at idx=%d", idx)
 		}
 	}
 }
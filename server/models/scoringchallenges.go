@@ -13,12 +13,13 @@ type CtfSolve struct {
 	CreatedAt   time.Time `json:"created_at"`   // created_at
 	TeamID      int       `json:"team_id"`      // team_id
 	ChallengeID int       `json:"challenge_id"` // challenge_id
+	PlayerID    *int      `json:"player_id"`    // player_id
 }
 
 // Insert a scored flag into the database. Congrats!
 func (cs *CtfSolve) Insert(db DB) error {
-	const sqlstr = `INSERT INTO ctf_solve (team_id, challenge_id) VALUES ($1, $2)`
-	_, err := db.Exec(sqlstr, cs.TeamID, cs.ChallengeID)
+	const sqlstr = `INSERT INTO ctf_solve (team_id, challenge_id, player_id) VALUES ($1, $2, $3)`
+	_, err := db.Exec(sqlstr, cs.TeamID, cs.ChallengeID, cs.PlayerID)
 	return err
 }
 
@@ -47,7 +48,10 @@ type ChallengeGuess struct {
 // CheckFlagSubmission will award the team with a captured flag if their flag string
 // guess is correct. No points will be given on a repeat flag, or obviously if the
 // flag submitted is simply wrong.
-func CheckFlagSubmission(db TXer, ctx context.Context, team *Team, chal *ChallengeGuess) (FlagState, error) {
+//
+// The solve is credited to `player`, who must be on the team, or to no one in
+// particular if the team's shared login was used (player is nil).
+func CheckFlagSubmission(db TXer, ctx context.Context, team *Team, player *Player, chal *ChallengeGuess) (FlagState, error) {
 	var (
 		err         error
 		challengeID int
@@ -89,6 +93,9 @@ func CheckFlagSubmission(db TXer, ctx context.Context, team *Team, chal *Challen
 	}
 
 	award := CtfSolve{ChallengeID: challengeID, TeamID: team.ID}
+	if player != nil {
+		award.PlayerID = &player.ID
+	}
 	if err = award.Insert(tx); err != nil {
 		return InvalidFlag, err
 	}
@@ -257,14 +264,25 @@ type CtfSolveResult struct {
 // team's name & id, and orders them by time. A `cutoffTime` threshold will exclude
 // any solves older than the date.
 func ChallengeCapturesByTime(db DB, cutoffTime time.Time) ([]CtfSolveResult, error) {
+	return challengeCaptures(db, cutoffTime, nil)
+}
+
+// ChallengeCapturesBetween is like ChallengeCapturesByTime, but also excludes any
+// solves newer than `endTime`.
+func ChallengeCapturesBetween(db DB, cutoffTime, endTime time.Time) ([]CtfSolveResult, error) {
+	return challengeCaptures(db, cutoffTime, &endTime)
+}
+
+func challengeCaptures(db DB, cutoffTime time.Time, endTime *time.Time) ([]CtfSolveResult, error) {
 	const sqlstr = `SELECT cs.created_at, t.id, t.name, c.id, c.name, c.category, c.total
 	FROM ctf_solve cs
 		JOIN team t ON cs.team_id = t.id
 		JOIN challenge c ON c.id = cs.challenge_id
 	WHERE cs.created_at > $1
+		AND ($2::timestamptz IS NULL OR cs.created_at <= $2)
 	ORDER BY cs.created_at ASC`
 
-	rows, err := db.Query(sqlstr, cutoffTime)
+	rows, err := db.Query(sqlstr, cutoffTime, endTime)
 	if err != nil {
 		return nil, err
 	}
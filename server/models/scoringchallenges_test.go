@@ -74,7 +74,7 @@ func Test_CheckFlagSubmission(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			prepareTestDatabase(t)
 
-			flagState, err := CheckFlagSubmission(db, context.Background(), tt.team, tt.cg)
+			flagState, err := CheckFlagSubmission(db, context.Background(), tt.team, nil, tt.cg)
 			if assert.Equal(t, tt.err, err, "Expected error/no error did not occur") {
 				assert.Equal(t, tt.fs, flagState, "The guess did not work as expected")
 			} else {
@@ -147,3 +147,15 @@ func Test_GetChallengeCapturesByTime(t *testing.T) {
 				"appearing first, per descending sort order.")
 	}
 }
+
+func Test_GetChallengeCapturesBetween(t *testing.T) {
+	prepareTestDatabase(t)
+	expected := []CtfSolveResult{
+		{Timestamp: time1, TeamID: 1, TeamName: "team1", ChallengeID: 1, Category: "RAD", ChallengeName: "Totally Rad Challenge", Points: 5},
+	}
+
+	capsBetween, err := ChallengeCapturesBetween(db, time1.Add(-time.Second), time1.Add(time.Minute))
+	if assert.Nil(t, err) {
+		assert.Equal(t, expected, capsBetween, "team2's solve came after the end time")
+	}
+}
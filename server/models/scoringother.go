@@ -2,36 +2,116 @@ package models
 
 import (
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // OtherPoints represents a row from 'cyboard.other_points'.
+// Each row is one grant of points to (or deduction from) a team, which can be
+// revoked later on. Revoking keeps the row, so the score history stays intact.
 type OtherPoints struct {
-	CreatedAt time.Time `json:"created_at"` // created_at
-	TeamID    int       `json:"team_id"`    // team_id
-	Points    float32   `json:"points"`     // points
-	Reason    string    `json:"reason"`     // reason
+	ID        int        `json:"id"`         // id
+	CreatedAt time.Time  `json:"created_at"` // created_at
+	TeamID    int        `json:"team_id"`    // team_id
+	Points    float32    `json:"points"`     // points
+	Reason    string     `json:"reason"`     // reason
+	Category  string     `json:"category"`   // category
+	Kind      BonusKind  `json:"kind"`       // kind
+	GranterID *int       `json:"granter_id"` // granter_id
+	Note      string     `json:"note"`       // note, shown to the team
+	RevokedAt *time.Time `json:"revoked_at"` // revoked_at
+	RevokerID *int       `json:"revoker_id"` // revoker_id
+}
+
+// DefaultBonusCategory is the score category bonus points go in, when none is given.
+const DefaultBonusCategory = "other"
+
+func (op *OtherPoints) category() string {
+	if op.Category == "" {
+		return DefaultBonusCategory
+	}
+	return op.Category
+}
+
+// kind defaults to a penalty for deductions, and a bonus otherwise.
+func (op *OtherPoints) kind() BonusKind {
+	switch {
+	case op.Kind != BonusKindUnspecified:
+		return op.Kind
+	case op.Points < 0:
+		return BonusKindPenalty
+	default:
+		return BonusKindBonus
+	}
 }
 
 // Insert a bonus point award/deduction into the database.
 func (op *OtherPoints) Insert(db DB) error {
-	const sqlstr = `INSERT INTO other_points (team_id, points, reason) VALUES ($1, $2, $3)`
-	_, err := db.Exec(sqlstr, op.TeamID, op.Points, op.Reason)
+	const sqlstr = `INSERT INTO other_points (team_id, points, reason, category, kind, granter_id, note) ` +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+	op.Kind = op.kind()
+	return db.QueryRow(sqlstr, op.TeamID, op.Points, op.Reason, op.category(), op.Kind, op.GranterID, op.Note).
+		Scan(&op.ID, &op.CreatedAt)
+}
+
+// BonusPointsByID retrieves a single grant.
+func BonusPointsByID(db DB, id int) (*OtherPoints, error) {
+	const sqlstr = `SELECT id, created_at, team_id, points, reason, category, kind, granter_id, note, ` +
+		`revoked_at, revoker_id ` +
+		`FROM other_points ` +
+		`WHERE id = $1`
+	op := OtherPoints{}
+	err := db.QueryRow(sqlstr, id).Scan(&op.ID, &op.CreatedAt, &op.TeamID, &op.Points, &op.Reason, &op.Category,
+		&op.Kind, &op.GranterID, &op.Note, &op.RevokedAt, &op.RevokerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &op, nil
+}
+
+// ErrRevokeCompromise is returned when revoking a red team deduction, which belongs to
+// an approved compromise report and would leave the report saying the points were docked.
+var ErrRevokeCompromise = errors.New("red team deductions come from approved compromise reports, and can't be revoked")
+
+// RevokeBonusPoints takes back a grant, as of now. The grant stays on record, and still
+// counts toward the team's score up until it was revoked.
+// Returns pgx.ErrNoRows if the grant doesn't exist, or was already revoked,
+// and ErrRevokeCompromise for red team deductions.
+func RevokeBonusPoints(db DB, id, revokerID int) error {
+	const sqlstr = `UPDATE other_points SET (revoked_at, revoker_id) = (CURRENT_TIMESTAMP, $2) ` +
+		`WHERE id = $1 AND revoked_at IS NULL AND kind <> 'redteam'`
+	tag, err := db.Exec(sqlstr, id, revokerID)
+	if err != nil || tag.RowsAffected() > 0 {
+		return err
+	}
+
+	var redteam bool
+	err = db.QueryRow(`SELECT kind = 'redteam' FROM other_points WHERE id = $1 AND revoked_at IS NULL`, id).
+		Scan(&redteam)
+	if err == nil && redteam {
+		err = ErrRevokeCompromise
+	}
 	return err
 }
 
-// OtherPointsView groups together a row of other_points by timestamp
+// OtherPointsView is a grant, with the names of the teams & staff involved.
 type OtherPointsView struct {
-	CreatedAt time.Time `json:"created_at"` // created_at
-	Teams     []string  `json:"teams"`      // array of team names
-	Points    float32   `json:"points"`     // points
-	Reason    string    `json:"reason"`     // reason
+	OtherPoints
+	Team    string  `json:"team"`    // team.name
+	Granter *string `json:"granter"` // granter's team.name
+	Revoker *string `json:"revoker"` // revoker's team.name
 }
 
-// AllBonusPoints returns all the bonus point totals, grouped by timestamp.
+// AllBonusPoints returns every grant, revoked or not, newest first.
 func AllBonusPoints(db DB) ([]OtherPointsView, error) {
-	const sqlstr = `SELECT created_at, ARRAY_AGG(t.name), points, reason
-	FROM other_points JOIN blueteam AS t ON team_id = t.id
-	GROUP BY created_at, points, reason`
+	const sqlstr = `SELECT o.id, o.created_at, o.team_id, o.points, o.reason, o.category, o.kind,
+		o.granter_id, o.note, o.revoked_at, o.revoker_id, t.name, g.name, rv.name
+	FROM other_points AS o
+		JOIN blueteam AS t ON o.team_id = t.id
+		LEFT JOIN team AS g ON o.granter_id = g.id
+		LEFT JOIN team AS rv ON o.revoker_id = rv.id
+	ORDER BY o.created_at DESC, o.id DESC`
 	rows, err := db.Query(sqlstr)
 	if err != nil {
 		return nil, err
@@ -41,7 +121,9 @@ func AllBonusPoints(db DB) ([]OtherPointsView, error) {
 	xs := []OtherPointsView{}
 	for rows.Next() {
 		x := OtherPointsView{}
-		if err = rows.Scan(&x.CreatedAt, &x.Teams, &x.Points, &x.Reason); err != nil {
+		err = rows.Scan(&x.ID, &x.CreatedAt, &x.TeamID, &x.Points, &x.Reason, &x.Category, &x.Kind,
+			&x.GranterID, &x.Note, &x.RevokedAt, &x.RevokerID, &x.Team, &x.Granter, &x.Revoker)
+		if err != nil {
 			return nil, err
 		}
 		xs = append(xs, x)
@@ -56,7 +138,7 @@ func AllBonusPoints(db DB) ([]OtherPointsView, error) {
 // OtherPointsSlice is an array of bonus points, suitable to insert many of at once.
 type OtherPointsSlice []OtherPoints
 
-// Insert many bonus point scores into the database at once.
+// Insert many bonus point scores into the database at once, filling in their ids.
 // The incoming slice should have the CreatedAt field set to the same value on each
 // struct, allowing a batch of bonus points to 'come in' at exactly the same time.
 func (ops OtherPointsSlice) Insert(db TXer) error {
@@ -66,9 +148,13 @@ func (ops OtherPointsSlice) Insert(db TXer) error {
 	}
 	defer tx.Rollback()
 
-	const sqlstr = `INSERT INTO other_points (created_at, team_id, points, reason) VALUES ($1, $2, $3, $4)`
-	for _, op := range ops {
-		_, err := tx.Exec(sqlstr, op.CreatedAt, op.TeamID, op.Points, op.Reason)
+	const sqlstr = `INSERT INTO other_points (created_at, team_id, points, reason, category, kind, granter_id, note) ` +
+		`VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+	for i := range ops {
+		op := &ops[i]
+		op.Kind = op.kind()
+		err := tx.QueryRow(sqlstr, op.CreatedAt, op.TeamID, op.Points, op.Reason, op.category(), op.Kind,
+			op.GranterID, op.Note).Scan(&op.ID)
 		if err != nil {
 			return err
 		}
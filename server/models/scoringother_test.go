@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AllBonusPoints(t *testing.T) {
+	prepareTestDatabase(t)
+
+	bonuses, err := AllBonusPoints(db)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(bonuses)) {
+		b := bonuses[0]
+		assert.Equal(t, 1, b.ID)
+		assert.Equal(t, "team1", b.Team)
+		assert.Equal(t, BonusKindBonus, b.Kind)
+		assert.Equal(t, "Thanks for keeping the room pleasant", b.Note)
+		if assert.NotNil(t, b.Granter) {
+			assert.Equal(t, "bigpoppa", *b.Granter)
+		}
+		assert.Nil(t, b.RevokedAt)
+	}
+}
+
+func Test_OtherPointsSlice_Insert(t *testing.T) {
+	prepareTestDatabase(t)
+
+	now := time.Now()
+	granter := 100
+	ops := OtherPointsSlice{
+		{CreatedAt: now, TeamID: 1, Points: 3, Reason: "found a bug", GranterID: &granter},
+		{CreatedAt: now, TeamID: 2, Points: -3, Reason: "broke the rules", GranterID: &granter},
+	}
+	require.Nil(t, ops.Insert(db))
+	assert.NotZero(t, ops[0].ID)
+	assert.NotEqual(t, ops[0].ID, ops[1].ID, "Each team gets its own grant")
+	assert.Equal(t, BonusKindBonus, ops[0].Kind)
+	assert.Equal(t, BonusKindPenalty, ops[1].Kind, "Deductions default to penalties")
+
+	op, err := BonusPointsByID(db, ops[1].ID)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "broke the rules", op.Reason)
+		assert.Equal(t, DefaultBonusCategory, op.Category)
+	}
+}
+
+func Test_RevokeBonusPoints(t *testing.T) {
+	prepareTestDatabase(t)
+
+	before, err := TeamsScores(db)
+	require.Nil(t, err)
+
+	op := &OtherPoints{TeamID: 2, Points: 4, Reason: "helped another team", Kind: BonusKindInject}
+	require.Nil(t, op.Insert(db))
+	granted, err := TeamsScores(db)
+	require.Nil(t, err)
+	assert.Equal(t, before[1].Score+4, granted[1].Score)
+
+	require.Nil(t, RevokeBonusPoints(db, op.ID, 100))
+	after, err := TeamsScores(db)
+	require.Nil(t, err)
+	assert.Equal(t, before[1].Score, after[1].Score, "Revoked points stop counting")
+
+	revoked, err := BonusPointsByID(db, op.ID)
+	require.Nil(t, err)
+	if assert.NotNil(t, revoked.RevokedAt) && assert.NotNil(t, revoked.RevokerID) {
+		assert.Equal(t, 100, *revoked.RevokerID)
+
+		asOf, err := TeamsScoresAsOf(db, revoked.RevokedAt.Add(-time.Microsecond))
+		if assert.Nil(t, err) {
+			assert.Equal(t, before[1].Score+4, asOf[1].Score, "The points still counted until they were revoked")
+		}
+		latest, err := LatestScoreChange(db)
+		if assert.Nil(t, err) {
+			assert.True(t, latest.Equal(*revoked.RevokedAt), "Revoking changes the score")
+		}
+	}
+
+	assert.Equal(t, pgx.ErrNoRows, RevokeBonusPoints(db, op.ID, 100), "Already revoked")
+	assert.Equal(t, pgx.ErrNoRows, RevokeBonusPoints(db, 1000, 100))
+
+	deduction := &OtherPoints{TeamID: 2, Points: -3, Reason: "Red team compromise #9 (low): shell", Kind: BonusKindRedteam}
+	require.Nil(t, deduction.Insert(db))
+	assert.Equal(t, ErrRevokeCompromise, RevokeBonusPoints(db, deduction.ID, 100),
+		"Red team deductions are undone through their compromise report")
+	deduction, err = BonusPointsByID(db, deduction.ID)
+	require.Nil(t, err)
+	assert.Nil(t, deduction.RevokedAt)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"sort"
 	"time"
 
 	"github.com/jackc/pgx"
@@ -59,6 +60,60 @@ func (sc ServiceCheckSlice) Insert(db DB) error {
 	return err
 }
 
+// InsertOncePerRound inserts the checks that weren't already scored in the same round,
+// that is, less than `minGap` from another check of the team's service. Rounds start
+// an interval apart, so a gap of the interval less the check timeout leaves room for
+// late results, without letting timestamps staggered across a round be scored twice.
+// A resent batch, or a second result for a check in one round, isn't scored twice either.
+// It must be run in a transaction. Returns how many checks were inserted.
+func (sc ServiceCheckSlice) InsertOncePerRound(db DB, minGap time.Duration) (int, error) {
+	// Drop repeats within the batch itself, which the query below can't see
+	type teamService struct{ team, service int }
+	sorted := append(ServiceCheckSlice{}, sc...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.Before(sorted[j].CreatedAt) })
+	last := map[teamService]time.Time{}
+	var (
+		createdAts      []time.Time
+		teamIDs, svcIDs []int32
+		statuses        []string
+		exitCodes       []int16
+	)
+	for _, c := range sorted {
+		key := teamService{c.TeamID, c.ServiceID}
+		if at, ok := last[key]; ok && c.CreatedAt.Sub(at) < minGap {
+			continue
+		}
+		last[key] = c.CreatedAt
+		createdAts = append(createdAts, c.CreatedAt)
+		teamIDs = append(teamIDs, int32(c.TeamID))
+		svcIDs = append(svcIDs, int32(c.ServiceID))
+		statuses = append(statuses, c.Status.String())
+		exitCodes = append(exitCodes, c.ExitCode)
+	}
+	if len(createdAts) == 0 {
+		return 0, nil
+	}
+
+	// Serialize the inserts, so two batches sent at once can't both pass the check
+	if _, err := db.Exec(`SELECT pg_advisory_xact_lock(hashtext('service_check_once_per_round'))`); err != nil {
+		return 0, err
+	}
+
+	const sqlstr = `INSERT INTO service_check (created_at, team_id, service_id, status, exit_code)
+	SELECT c.created_at, c.team_id, c.service_id, c.status::exit_status, c.exit_code
+	FROM unnest($1::timestamptz[], $2::int[], $3::int[], $4::text[], $5::smallint[])
+		AS c (created_at, team_id, service_id, status, exit_code)
+	WHERE NOT EXISTS (SELECT 1 FROM service_check AS sc
+		WHERE sc.team_id = c.team_id AND sc.service_id = c.service_id
+			AND sc.created_at > c.created_at - $6 * interval '1 second'
+			AND sc.created_at < c.created_at + $6 * interval '1 second')`
+	tag, err := db.Exec(sqlstr, createdAts, teamIDs, svcIDs, statuses, exitCodes, minGap.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
 // LatestServiceCheckRun retrieves the timestamp of the last run of the service monitor.
 // See: `LatestScoreChange` in `scoring.go`. This delta check is specific to services.
 func LatestServiceCheckRun(db DB) (time.Time, error) {
@@ -74,6 +129,11 @@ type TeamServiceStatusesView struct {
 	ServiceID   int          `json:"service_id"`
 	ServiceName string       `json:"service_name"`
 	Statuses    []ExitStatus `json:"statuses"`
+
+	// History of each team's recent statuses, oldest to newest, with the zero
+	// ExitStatus for rounds the team wasn't checked in.
+	// Only filled in by TeamServiceStatusesWithHistory.
+	History [][]ExitStatus `json:"history,omitempty"`
 }
 
 // TeamServiceStatuses gets the current service status (pass, fail, timeout)
@@ -111,31 +171,120 @@ func TeamServiceStatuses(db DB) ([]TeamServiceStatusesView, error) {
 	return xs, nil
 }
 
+// TeamServiceStatusesWithHistory is like TeamServiceStatuses, but also includes each team's
+// statuses from the last few runs of the service monitor. History is kept in `rounds` buckets,
+// each one check `interval` wide, going back from the latest check. Buckets are centered on
+// when each round should have run, so a little scheduling jitter doesn't push a check into
+// its neighbor's bucket. A bucket the team wasn't checked in is left as the zero ExitStatus,
+// so a missed round doesn't shift the rest.
+func TeamServiceStatusesWithHistory(db DB, rounds int, interval time.Duration) ([]TeamServiceStatusesView, error) {
+	const sqlstr = `
+	WITH latest AS (
+		SELECT created_at AS at FROM service_check ORDER BY created_at DESC LIMIT 1
+	), recent AS (
+		SELECT sc.service_id, sc.team_id,
+			$1 - 1 - round(extract(epoch FROM latest.at - sc.created_at) / $2)::int AS round,
+			last(sc.status, sc.created_at) AS status
+		FROM service_check AS sc, latest
+		WHERE sc.created_at > latest.at - ($1 - 0.5) * $2 * interval '1 second'
+		GROUP BY sc.service_id, sc.team_id, round
+	), history AS (
+		SELECT service.id AS service_id, team.id AS team_id, jsonb_agg(r.status ORDER BY n) AS history
+		FROM service
+			CROSS JOIN blueteam AS team
+			CROSS JOIN generate_series(0, $1 - 1) AS n
+			LEFT JOIN recent AS r ON r.service_id = service.id AND r.team_id = team.id AND r.round = n
+		GROUP BY service.id, team.id
+	)
+	SELECT ss.service, ss.service_name,
+		jsonb_agg(ss.status ORDER BY ss.team_id) AS statuses,
+		jsonb_agg(COALESCE(h.history, '[]') ORDER BY ss.team_id) AS history
+	FROM (SELECT team.id AS team_id, ss.* FROM blueteam AS team,
+	LATERAL (SELECT id AS service, name AS service_name, COALESCE(last(sc.status, sc.created_at), 'timeout') AS status
+		FROM service
+			LEFT JOIN service_check AS sc ON id = sc.service_id AND team.id = sc.team_id
+		WHERE service.disabled = false AND service.starts_at < current_timestamp
+		GROUP BY service.id, team.id) AS ss) AS ss
+		LEFT JOIN history AS h ON h.service_id = ss.service AND h.team_id = ss.team_id
+	GROUP BY ss.service, ss.service_name
+	ORDER BY ss.service`
+	rows, err := db.Query(sqlstr, rounds, interval.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []TeamServiceStatusesView{}
+	for rows.Next() {
+		x := TeamServiceStatusesView{}
+		if err = rows.Scan(&x.ServiceID, &x.ServiceName, &x.Statuses, &x.History); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
 type MonitorTeamService struct {
 	Team struct { // `cyboard.team` table
-		ID   int    // id
-		Name string // name
-		IP   int16  // blueteam_ip
-	}
+		ID   int    `json:"id"`          // id
+		Name string `json:"name"`        // name
+		IP   int16  `json:"blueteam_ip"` // blueteam_ip
+
+		Hosts map[string]string `json:"hosts"` // `cyboard.team_host` table, role → address
+	} `json:"team"`
 	Service struct { // `cyboard.service` table
-		ID       int       // id
-		Name     string    // name
-		Script   string    // script
-		Args     []string  // args
-		StartsAt time.Time // starts_at
-	}
+		ID       int       `json:"id"`        // id
+		Name     string    `json:"name"`      // name
+		Script   string    `json:"script"`    // script
+		Args     []string  `json:"args"`      // args
+		HostRole *string   `json:"host_role"` // host_role
+		StartsAt time.Time `json:"starts_at"` // starts_at
+	} `json:"service"`
 }
 
 // MonitorTeamsAndServices fetches every active service and blueteam from the
-// database, at the same time. Returns an empty array for no rows, or an error
-// if there is a problem fetching data from postgres.
-func MonitorTeamsAndServices(db DBClient) ([]MonitorTeamService, error) {
+// database, at the same time, along with each team's hosts. Checks that a scoring
+// check runner is assigned are left out, since the runner reports those instead,
+// unless every such runner hasn't checked in within `staleAfter`.
+// Returns an empty array for no rows, or an error if there is a problem fetching data from postgres.
+func MonitorTeamsAndServices(db DBClient, staleAfter time.Duration) ([]MonitorTeamService, error) {
 	const sqlstr = `SELECT
 		t.id, t.name, t.blueteam_ip,
-		s.id, s.name, s.script, s.args, s.starts_at
+		s.id, s.name, s.script, s.args, s.host_role, s.starts_at
 	FROM service AS s CROSS JOIN blueteam AS t
-	WHERE s.disabled = false`
-	rows, err := db.Query(sqlstr)
+	WHERE s.disabled = false
+		AND NOT EXISTS (SELECT 1 FROM check_runner_assignment AS a
+			WHERE a.scoring AND a.team_id = t.id AND a.service_id = s.id
+				AND a.last_seen_at > CURRENT_TIMESTAMP - $1 * interval '1 second')`
+	return queryMonitorTeamsAndServices(db, sqlstr, staleAfter.Seconds())
+}
+
+// RunnerTeamsAndServices is like MonitorTeamsAndServices, but fetches the checks
+// assigned to a check runner.
+func RunnerTeamsAndServices(db DB, runnerID int) ([]MonitorTeamService, error) {
+	const sqlstr = `SELECT
+		t.id, t.name, t.blueteam_ip,
+		s.id, s.name, s.script, s.args, s.host_role, s.starts_at
+	FROM check_runner_assignment AS a
+		JOIN service AS s ON a.service_id = s.id
+		JOIN blueteam AS t ON a.team_id = t.id
+	WHERE a.runner_id = $1
+	ORDER BY t.id, s.id`
+	return queryMonitorTeamsAndServices(db, sqlstr, runnerID)
+}
+
+func queryMonitorTeamsAndServices(db DB, sqlstr string, args ...interface{}) ([]MonitorTeamService, error) {
+	hosts, err := TeamHostMaps(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(sqlstr, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -145,7 +294,122 @@ func MonitorTeamsAndServices(db DBClient) ([]MonitorTeamService, error) {
 	for rows.Next() {
 		x := MonitorTeamService{}
 		err = rows.Scan(&x.Team.ID, &x.Team.Name, &x.Team.IP,
-			&x.Service.ID, &x.Service.Name, &x.Service.Script, &x.Service.Args, &x.Service.StartsAt)
+			&x.Service.ID, &x.Service.Name, &x.Service.Script, &x.Service.Args, &x.Service.HostRole, &x.Service.StartsAt)
+		if err != nil {
+			return nil, err
+		}
+		x.Team.Hosts = hosts[x.Team.ID]
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// ServiceUptime summarizes the history of a team's service over a span of time.
+// An outage is any run of checks that did not pass, lasting until the next passing check.
+type ServiceUptime struct {
+	TeamID      int    `json:"team_id"`      // team.id
+	TeamName    string `json:"team_name"`    // team.name
+	ServiceID   int    `json:"service_id"`   // service.id
+	ServiceName string `json:"service_name"` // service.name
+
+	Checks  int     `json:"checks"`
+	Pass    int     `json:"pass"`
+	Partial int     `json:"partial"`
+	Fail    int     `json:"fail"`
+	Timeout int     `json:"timeout"`
+	Uptime  float64 `json:"uptime"` // percent of checks that passed
+
+	LongestOutageSecs int        `json:"longest_outage_secs"`
+	LastStatus        ExitStatus `json:"last_status"`
+	CurrentStreak     int        `json:"current_streak"` // checks in a row that have been up (or down)
+}
+
+// LongestOutage is LongestOutageSecs, as a Duration.
+func (su *ServiceUptime) LongestOutage() time.Duration {
+	return time.Duration(su.LongestOutageSecs) * time.Second
+}
+
+// Up reports whether the latest check passed.
+func (su *ServiceUptime) Up() bool {
+	return su.LastStatus == ExitStatusPass
+}
+
+// ServiceUptimes calculates the uptime of every team's service, for the checks that
+// ran between `start` and `end`. Only services that are enabled, and have started, are included.
+func ServiceUptimes(db DB, start, end time.Time) ([]ServiceUptime, error) {
+	return serviceUptimes(db, start, end, nil)
+}
+
+// TeamServiceUptimes is like ServiceUptimes, but for a single team.
+func TeamServiceUptimes(db DB, teamID int, start, end time.Time) ([]ServiceUptime, error) {
+	return serviceUptimes(db, start, end, &teamID)
+}
+
+func serviceUptimes(db DB, start, end time.Time, teamID *int) ([]ServiceUptime, error) {
+	// Checks are split into runs of "up" (passing) & "down" (anything else), using the
+	// difference of row numbers trick. Each down run lasts until the next run starts.
+	const sqlstr = `
+	WITH checks AS (
+		SELECT sc.team_id, sc.service_id, sc.created_at, sc.status, sc.status = 'pass' AS up,
+			row_number() OVER (PARTITION BY sc.team_id, sc.service_id ORDER BY sc.created_at)
+			- row_number() OVER (PARTITION BY sc.team_id, sc.service_id, sc.status = 'pass'
+				ORDER BY sc.created_at) AS run
+		FROM service_check AS sc
+		WHERE sc.created_at BETWEEN $1 AND $2
+			AND ($3::int IS NULL OR sc.team_id = $3)
+	), runs AS (
+		SELECT team_id, service_id, up, count(*) AS checks,
+			min(created_at) AS started_at, max(created_at) AS last_at
+		FROM checks
+		GROUP BY team_id, service_id, up, run
+	), spans AS (
+		SELECT team_id, service_id, up, checks,
+			COALESCE(lead(started_at) OVER w, last_at) - started_at AS span,
+			row_number() OVER (PARTITION BY team_id, service_id ORDER BY started_at DESC) AS recency
+		FROM runs
+		WINDOW w AS (PARTITION BY team_id, service_id ORDER BY started_at)
+	), totals AS (
+		SELECT team_id, service_id, count(*) AS checks,
+			count(*) FILTER (WHERE status = 'pass') AS pass,
+			count(*) FILTER (WHERE status = 'partial') AS partial,
+			count(*) FILTER (WHERE status = 'fail') AS fail,
+			count(*) FILTER (WHERE status = 'timeout') AS timeout,
+			last(status, created_at) AS last_status
+		FROM checks
+		GROUP BY team_id, service_id
+	)
+	SELECT team.id, team.name, service.id, service.name,
+		COALESCE(t.checks, 0), COALESCE(t.pass, 0), COALESCE(t.partial, 0),
+		COALESCE(t.fail, 0), COALESCE(t.timeout, 0),
+		COALESCE(100.0 * t.pass / NULLIF(t.checks, 0), 0)::float8 AS uptime,
+		COALESCE((SELECT round(extract(epoch FROM max(s.span)))::int FROM spans AS s
+			WHERE s.team_id = team.id AND s.service_id = service.id AND NOT s.up), 0) AS longest_outage,
+		COALESCE(t.last_status, 'timeout'),
+		COALESCE((SELECT s.checks FROM spans AS s
+			WHERE s.team_id = team.id AND s.service_id = service.id AND s.recency = 1), 0)::int AS current_streak
+	FROM blueteam AS team
+		CROSS JOIN service
+		LEFT JOIN totals AS t ON team.id = t.team_id AND service.id = t.service_id
+	WHERE service.disabled = false AND service.starts_at < current_timestamp
+		AND ($3::int IS NULL OR team.id = $3)
+	ORDER BY team.id, service.id`
+
+	rows, err := db.Query(sqlstr, start, end, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []ServiceUptime{}
+	for rows.Next() {
+		x := ServiceUptime{}
+		err = rows.Scan(&x.TeamID, &x.TeamName, &x.ServiceID, &x.ServiceName,
+			&x.Checks, &x.Pass, &x.Partial, &x.Fail, &x.Timeout, &x.Uptime,
+			&x.LongestOutageSecs, &x.LastStatus, &x.CurrentStreak)
 		if err != nil {
 			return nil, err
 		}
@@ -66,11 +66,126 @@ func Test_TeamServiceStatuses(t *testing.T) {
 	}
 }
 
+func Test_TeamServiceStatusesWithHistory(t *testing.T) {
+	prepareTestDatabase(t)
+	pass, partial, missed := ExitStatusPass, ExitStatusPartial, ExitStatus(0)
+
+	views, err := TeamServiceStatusesWithHistory(db, 1, 15*time.Minute)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(views)) {
+		assert.Equal(t, []ExitStatus{pass, partial}, views[0].Statuses)
+		assert.Equal(t, [][]ExitStatus{{pass}, {partial}}, views[0].History, "Only the latest round")
+	}
+
+	views, err = TeamServiceStatusesWithHistory(db, 3, 15*time.Minute)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(views)) {
+		assert.Equal(t, [][]ExitStatus{{missed, pass, pass}, {missed, pass, partial}}, views[0].History,
+			"Both rounds of checks, oldest first, after a round from before the checks started")
+	}
+
+	// team2 misses a round, which is kept in its place
+	ts := apptest.MustParseTime("2018-07-29T09:30:00.000-04:00")
+	require.Nil(t, ServiceCheckSlice{{CreatedAt: ts, TeamID: 1, ServiceID: 1, Status: ExitStatusPass}}.Insert(db))
+	views, err = TeamServiceStatusesWithHistory(db, 3, 15*time.Minute)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(views)) {
+		assert.Equal(t, [][]ExitStatus{{pass, pass, pass}, {pass, partial, missed}}, views[0].History)
+	}
+}
+
+func Test_ServiceCheckSlice_InsertOncePerRound(t *testing.T) {
+	prepareTestDatabase(t)
+
+	count := func() int {
+		var n int
+		require.Nil(t, db.QueryRow(`SELECT count(*) FROM service_check`).Scan(&n))
+		return n
+	}
+	before := count()
+
+	round, _ := time.Parse(time.RFC3339, "2018-07-29T09:30:00-04:00")
+	batch := ServiceCheckSlice{
+		{CreatedAt: round, TeamID: 1, ServiceID: 1, Status: ExitStatusPass},
+		{CreatedAt: round, TeamID: 2, ServiceID: 1, Status: ExitStatusFail, ExitCode: 1},
+		{CreatedAt: round.Add(10 * time.Second), TeamID: 1, ServiceID: 1, Status: ExitStatusPass}, // same round
+	}
+	insert := func(sc ServiceCheckSlice) int {
+		tx, err := db.Begin()
+		require.Nil(t, err)
+		defer tx.Rollback()
+		n, err := sc.InsertOncePerRound(tx, 50*time.Second) // A minute apart, less a 10s timeout
+		require.Nil(t, err)
+		require.Nil(t, tx.Commit())
+		return n
+	}
+
+	assert.Equal(t, 2, insert(batch), "One result per check each round")
+	assert.Equal(t, before+2, count())
+	assert.Equal(t, 0, insert(batch), "Resending the batch scores nothing")
+	assert.Equal(t, before+2, count())
+
+	next := ServiceCheckSlice{{CreatedAt: round.Add(time.Minute), TeamID: 1, ServiceID: 1, Status: ExitStatusPass}}
+	assert.Equal(t, 1, insert(next), "The next round is scored")
+
+	old := ServiceCheckSlice{{CreatedAt: round.Add(-15 * time.Minute), TeamID: 1, ServiceID: 1, Status: ExitStatusPass}}
+	assert.Equal(t, 0, insert(old), "09:15 was already scored by the fixtures")
+
+	// A runner sending results 35s apart (over half a round) is still only scored about once a round
+	scored := 0
+	for at := round.Add(95 * time.Second); at.Before(round.Add(4 * time.Minute)); at = at.Add(35 * time.Second) {
+		scored += insert(ServiceCheckSlice{{CreatedAt: at, TeamID: 1, ServiceID: 1, Status: ExitStatusPass}})
+	}
+	assert.Equal(t, 2, scored, "Only 02:10 & 03:20 of the five results from 01:35 to 03:55 are scored")
+	staggered := ServiceCheckSlice{
+		{CreatedAt: round.Add(5 * time.Minute), TeamID: 2, ServiceID: 1, Status: ExitStatusPass},
+		{CreatedAt: round.Add(5*time.Minute + 35*time.Second), TeamID: 2, ServiceID: 1, Status: ExitStatusPass},
+		{CreatedAt: round.Add(6*time.Minute + 10*time.Second), TeamID: 2, ServiceID: 1, Status: ExitStatusPass},
+	}
+	assert.Equal(t, 2, insert(staggered), "Staggered results in one batch are scored once a round")
+}
+
 func Benchmark_MonitorTeamsAndServices(b *testing.B) {
 	prepareTestDatabase(b)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		MonitorTeamsAndServices(db)
+		MonitorTeamsAndServices(db, time.Minute)
+	}
+}
+
+func Test_ServiceUptimes(t *testing.T) {
+	prepareTestDatabase(t)
+	start := apptest.MustParseTime("2018-07-29T08:00:00.000-04:00")
+	end := apptest.MustParseTime("2018-07-29T12:00:00.000-04:00")
+
+	uptimes, err := ServiceUptimes(db, start, end)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(uptimes), "One row per team, per enabled service") {
+		assert.Equal(t, ServiceUptime{TeamID: 1, TeamName: "team1", ServiceID: 1, ServiceName: "ping",
+			Checks: 2, Pass: 2, Uptime: 100, LastStatus: ExitStatusPass, CurrentStreak: 2}, uptimes[0])
+		assert.Equal(t, ServiceUptime{TeamID: 2, TeamName: "team2", ServiceID: 1, ServiceName: "ping",
+			Checks: 2, Pass: 1, Partial: 1, Uptime: 50, LastStatus: ExitStatusPartial, CurrentStreak: 1}, uptimes[1])
+	}
+
+	// team1 goes down for half an hour, then comes back up
+	at := func(ts string) time.Time { return apptest.MustParseTime("2018-07-29T" + ts + ":00.000-04:00") }
+	outage := ServiceCheckSlice([]ServiceCheck{
+		{CreatedAt: at("09:30"), TeamID: 1, ServiceID: 1, Status: ExitStatusFail, ExitCode: 1},
+		{CreatedAt: at("09:45"), TeamID: 1, ServiceID: 1, Status: ExitStatusTimeout, ExitCode: 1},
+		{CreatedAt: at("10:00"), TeamID: 1, ServiceID: 1, Status: ExitStatusPass, ExitCode: 0},
+	})
+	require.Nil(t, outage.Insert(db))
+
+	uptimes, err = TeamServiceUptimes(db, 1, start, end)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(uptimes)) {
+		up := uptimes[0]
+		assert.Equal(t, 5, up.Checks)
+		assert.Equal(t, []int{3, 0, 1, 1}, []int{up.Pass, up.Partial, up.Fail, up.Timeout})
+		assert.InDelta(t, 60.0, up.Uptime, 0.01)
+		assert.Equal(t, 30*60, up.LongestOutageSecs)
+		assert.Equal(t, 1, up.CurrentStreak)
+		assert.True(t, up.Up())
+	}
+
+	uptimes, err = TeamServiceUptimes(db, 1, at("09:20"), end)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(uptimes)) {
+		assert.Equal(t, 3, uptimes[0].Checks, "Only checks inside the time window are counted")
 	}
 }
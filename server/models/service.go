@@ -16,6 +16,7 @@ type Service struct {
 	Script      string   `json:"script"`       // script
 	Args        []string `json:"args"`         // args
 	Disabled    bool     `json:"disabled"`     // disabled
+	HostRole    *string  `json:"host_role"`    // host_role
 
 	StartsAt   time.Time `json:"starts_at"`   // starts_at
 	CreatedAt  time.Time `json:"created_at"`  // created_at
@@ -25,22 +26,22 @@ type Service struct {
 // Insert inserts the Service to the database.
 func (s *Service) Insert(db DB) error {
 	const sqlstr = `INSERT INTO service (` +
-		`name, category, description, total_points, points, script, args, disabled, starts_at` +
+		`name, category, description, total_points, points, script, args, disabled, host_role, starts_at` +
 		`) VALUES (` +
-		`$1, $2, $3, $4, $5, $6, $7, $8, $9` +
+		`$1, $2, $3, $4, $5, $6, $7, $8, $9, $10` +
 		`) RETURNING id`
 
-	return db.QueryRow(sqlstr, s.Name, s.Category, s.Description, s.TotalPoints, s.Points, s.Script, s.Args, s.Disabled, s.StartsAt).Scan(&s.ID)
+	return db.QueryRow(sqlstr, s.Name, s.Category, s.Description, s.TotalPoints, s.Points, s.Script, s.Args, s.Disabled, s.HostRole, s.StartsAt).Scan(&s.ID)
 }
 
 // Update updates the Service in the database.
 func (s *Service) Update(db DB) error {
 	const sqlstr = `UPDATE service SET (` +
-		`name, category, description, total_points, points, script, args, disabled, starts_at` +
+		`name, category, description, total_points, points, script, args, disabled, host_role, starts_at` +
 		`) = ( ` +
-		`$2, $3, $4, $5, $6, $7, $8, $9, $10` +
+		`$2, $3, $4, $5, $6, $7, $8, $9, $10, $11` +
 		`) WHERE id = $1`
-	_, err := db.Exec(sqlstr, s.ID, s.Name, s.Category, s.Description, s.TotalPoints, s.Points, s.Script, s.Args, s.Disabled, s.StartsAt)
+	_, err := db.Exec(sqlstr, s.ID, s.Name, s.Category, s.Description, s.TotalPoints, s.Points, s.Script, s.Args, s.Disabled, s.HostRole, s.StartsAt)
 	return err
 }
 
@@ -54,11 +55,11 @@ func (s *Service) Delete(db DB) error {
 // ServiceByName retrieves a row from 'cyboard.service' as a Service.
 func ServiceByName(db DB, name string) (*Service, error) {
 	const sqlstr = `SELECT ` +
-		`id, name, category, description, total_points, points, script, args, disabled, starts_at, created_at, modified_at ` +
+		`id, name, category, description, total_points, points, script, args, disabled, host_role, starts_at, created_at, modified_at ` +
 		`FROM service ` +
 		`WHERE name = $1`
 	s := Service{}
-	err := db.QueryRow(sqlstr, name).Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt)
+	err := db.QueryRow(sqlstr, name).Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.HostRole, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -69,11 +70,11 @@ func ServiceByName(db DB, name string) (*Service, error) {
 // ServiceByID retrieves a row from 'cyboard.service' as a Service.
 func ServiceByID(db DB, id int) (*Service, error) {
 	const sqlstr = `SELECT ` +
-		`id, name, category, description, total_points, points, script, args, disabled, starts_at, created_at, modified_at ` +
+		`id, name, category, description, total_points, points, script, args, disabled, host_role, starts_at, created_at, modified_at ` +
 		`FROM service ` +
 		`WHERE id = $1`
 	s := Service{}
-	err := db.QueryRow(sqlstr, id).Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt)
+	err := db.QueryRow(sqlstr, id).Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.HostRole, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +85,7 @@ func ServiceByID(db DB, id int) (*Service, error) {
 // AllServices retrieves all monitored services from 'cyboard.service'.
 func AllServices(db DB) ([]Service, error) {
 	const sqlstr = `
-	SELECT id, name, category, description, total_points, points, script, args, disabled, starts_at, created_at, modified_at
+	SELECT id, name, category, description, total_points, points, script, args, disabled, host_role, starts_at, created_at, modified_at
 	FROM service
 	ORDER BY starts_at, id`
 
@@ -97,7 +98,7 @@ func AllServices(db DB) ([]Service, error) {
 	ss := []Service{}
 	for rows.Next() {
 		s := Service{}
-		if err = rows.Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt); err != nil {
+		if err = rows.Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.HostRole, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt); err != nil {
 			return nil, err
 		}
 		ss = append(ss, s)
@@ -112,7 +113,7 @@ func AllServices(db DB) ([]Service, error) {
 // AllActiveServices retrieves all monitored services from 'cyboard.service'.
 func AllActiveServices(db DB) ([]Service, error) {
 	const sqlstr = `
-	SELECT id, name, category, description, total_points, points, script, args, disabled, starts_at, created_at, modified_at
+	SELECT id, name, category, description, total_points, points, script, args, disabled, host_role, starts_at, created_at, modified_at
 	FROM service
 	WHERE disabled = false`
 
@@ -125,7 +126,7 @@ func AllActiveServices(db DB) ([]Service, error) {
 	ss := []Service{}
 	for rows.Next() {
 		s := Service{}
-		if err = rows.Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt); err != nil {
+		if err = rows.Scan(&s.ID, &s.Name, &s.Category, &s.Description, &s.TotalPoints, &s.Points, &s.Script, &s.Args, &s.Disabled, &s.HostRole, &s.StartsAt, &s.CreatedAt, &s.ModifiedAt); err != nil {
 			return nil, err
 		}
 		ss = append(ss, s)
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jackc/pgx"
+)
+
+// FindSession retrieves the data of an unexpired login session.
+func FindSession(db DB, token string) ([]byte, bool, error) {
+	const sqlstr = `SELECT data FROM session WHERE token = $1 AND expiry > now()`
+	var data []byte
+	err := db.QueryRow(sqlstr, token).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// SaveSession creates or updates a login session, along with who it's logged in as
+// (either of which may be nil).
+func SaveSession(db DB, token string, data []byte, expiry time.Time, teamID, playerID *int) error {
+	const sqlstr = `INSERT INTO session (token, data, expiry, team_id, player_id) ` +
+		`VALUES ($1, $2, $3, $4, $5) ` +
+		`ON CONFLICT (token) DO UPDATE SET ` +
+		`(data, expiry, team_id, player_id) = (EXCLUDED.data, EXCLUDED.expiry, EXCLUDED.team_id, EXCLUDED.player_id)`
+	_, err := db.Exec(sqlstr, token, data, expiry, teamID, playerID)
+	return err
+}
+
+// DeleteSession removes a login session, e.g. when the user logs out.
+func DeleteSession(db DB, token string) error {
+	const sqlstr = `DELETE FROM session WHERE token = $1`
+	_, err := db.Exec(sqlstr, token)
+	return err
+}
+
+// DeleteExpiredSessions cleans out sessions that can't be used anymore.
+func DeleteExpiredSessions(db DB) (int64, error) {
+	const sqlstr = `DELETE FROM session WHERE expiry <= now()`
+	tag, err := db.Exec(sqlstr)
+	return tag.RowsAffected(), err
+}
+
+// SessionView is an active login session, as shown to admins. The token is left out on purpose.
+type SessionView struct {
+	ID         int       `json:"id"`          // id
+	TeamID     int       `json:"team_id"`     // team_id
+	PlayerID   *int      `json:"player_id"`   // player_id
+	PlayerName *string   `json:"player_name"` // player.name
+	CreatedAt  time.Time `json:"created_at"`  // created_at
+	ModifiedAt time.Time `json:"modified_at"` // modified_at
+	Expiry     time.Time `json:"expiry"`      // expiry
+}
+
+// TeamSessions lists the unexpired sessions logged in to a team, either with the
+// team's shared login or as one of its players. Most recently used first.
+func TeamSessions(db DB, teamID int) ([]SessionView, error) {
+	const sqlstr = `SELECT s.id, s.team_id, s.player_id, p.name, s.created_at, s.modified_at, s.expiry
+	FROM session AS s
+		LEFT JOIN player AS p ON s.player_id = p.id
+	WHERE s.team_id = $1 AND s.expiry > now()
+	ORDER BY s.modified_at DESC`
+
+	rows, err := db.Query(sqlstr, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []SessionView{}
+	for rows.Next() {
+		x := SessionView{}
+		err = rows.Scan(&x.ID, &x.TeamID, &x.PlayerID, &x.PlayerName, &x.CreatedAt, &x.ModifiedAt, &x.Expiry)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// RevokeSession logs out a single session.
+func RevokeSession(db DB, id int) error {
+	const sqlstr = `DELETE FROM session WHERE id = $1`
+	tag, err := db.Exec(sqlstr, id)
+	if err == nil && tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+	}
+	return err
+}
+
+// RevokeTeamSessions logs out everyone on a team, players included.
+func RevokeTeamSessions(db DB, teamID int) (int64, error) {
+	const sqlstr = `DELETE FROM session WHERE team_id = $1`
+	tag, err := db.Exec(sqlstr, teamID)
+	return tag.RowsAffected(), err
+}
+
+// RevokePlayerSessions logs out a player everywhere.
+func RevokePlayerSessions(db DB, playerID int) (int64, error) {
+	const sqlstr = `DELETE FROM session WHERE player_id = $1`
+	tag, err := db.Exec(sqlstr, playerID)
+	return tag.RowsAffected(), err
+}
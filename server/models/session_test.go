@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SaveSession(t *testing.T) {
+	prepareTestDatabase(t)
+
+	expiry := time.Now().Add(time.Hour)
+	require.NoError(t, SaveSession(db, "token1", []byte("data"), expiry, nil, nil))
+
+	data, found, err := FindSession(db, "token1")
+	if assert.NoError(t, err) && assert.True(t, found) {
+		assert.Equal(t, []byte("data"), data)
+	}
+
+	teamID := 1
+	require.NoError(t, SaveSession(db, "token1", []byte("logged in"), expiry, &teamID, nil))
+	data, _, _ = FindSession(db, "token1")
+	assert.Equal(t, []byte("logged in"), data, "Saving again updates the session")
+
+	require.NoError(t, DeleteSession(db, "token1"))
+	_, found, err = FindSession(db, "token1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, SaveSession(db, "old", []byte("data"), time.Now().Add(-time.Minute), &teamID, nil))
+	_, found, _ = FindSession(db, "old")
+	assert.False(t, found, "Expired sessions can't be used")
+	n, err := DeleteExpiredSessions(db)
+	assert.NoError(t, err)
+	assert.True(t, n >= 1)
+}
+
+func Test_RevokeSessions(t *testing.T) {
+	prepareTestDatabase(t)
+
+	teamID, playerID := 1, 1
+	expiry := time.Now().Add(time.Hour)
+	require.NoError(t, SaveSession(db, "team login", []byte("data"), expiry, &teamID, nil))
+	require.NoError(t, SaveSession(db, "alice", []byte("data"), expiry, &teamID, &playerID))
+
+	sessions, err := TeamSessions(db, teamID)
+	require.NoError(t, err)
+	if assert.Equal(t, 2, len(sessions)) {
+		names := []string{}
+		for _, s := range sessions {
+			if s.PlayerName != nil {
+				names = append(names, *s.PlayerName)
+			}
+		}
+		assert.Equal(t, []string{"alice"}, names)
+	}
+
+	n, err := RevokePlayerSessions(db, playerID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	sessions, err = TeamSessions(db, teamID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(sessions), "Only the team login is left")
+	assert.Nil(t, sessions[0].PlayerID)
+
+	require.NoError(t, RevokeSession(db, sessions[0].ID))
+	sessions, err = TeamSessions(db, teamID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func Test_DisablingTeamRevokesSessions(t *testing.T) {
+	prepareTestDatabase(t)
+
+	team, err := TeamByID(db, 2)
+	require.NoError(t, err)
+	require.NoError(t, SaveSession(db, "team2", []byte("data"), time.Now().Add(time.Hour), &team.ID, nil))
+
+	team.Hash = nil
+	team.Disabled = true
+	require.NoError(t, team.Update(db))
+
+	_, found, err := FindSession(db, "team2")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
@@ -173,3 +173,30 @@ func AllBlueteams(db DB) ([]BlueteamView, error) {
 
 	return bmvs, nil
 }
+
+// AllStaff fetches every non-disabled staff member (anyone who isn't a blueteam).
+func AllStaff(db DB) ([]Team, error) {
+	const sqlstr = `SELECT id, name, role_name, disabled FROM team ` +
+		`WHERE role_name != 'blueteam' AND disabled = false ` +
+		`ORDER BY name`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ts := []Team{}
+	for rows.Next() {
+		t := Team{}
+		if err = rows.Scan(&t.ID, &t.Name, &t.RoleName, &t.Disabled); err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// TeamHost represents a row from 'cyboard.team_host': one of a team's hosts on the
+// competition network, filling in the `{HOST:<role>}` argument of service checks.
+type TeamHost struct {
+	ID      int    `json:"id"`      // id
+	TeamID  int    `json:"team_id"` // team_id
+	Role    string `json:"role"`    // role
+	Address string `json:"address"` // address
+}
+
+var (
+	// hostRole matches the check constraints on 'team_host.role' & 'service.host_role'.
+	hostRole = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+	// hostName is a loose check for a DNS name: dot-separated labels of letters, digits, and dashes.
+	hostName = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*\.?$`)
+)
+
+// ValidHostRole reports whether role can name a team's host.
+func ValidHostRole(role string) bool {
+	return hostRole.MatchString(role)
+}
+
+// ValidHostAddress reports whether addr is an IPv4 or IPv6 address, or a hostname.
+func ValidHostAddress(addr string) bool {
+	return net.ParseIP(addr) != nil || (len(addr) <= 253 && hostName.MatchString(addr))
+}
+
+// Validate checks the host's fields, before they're saved.
+func (th *TeamHost) Validate() error {
+	switch {
+	case !ValidHostRole(th.Role):
+		return errors.New("a host role must be lowercase letters, digits, dashes, and underscores, starting with a letter")
+	case !ValidHostAddress(th.Address):
+		return errors.Errorf("host address %q is not an IP address or hostname", th.Address)
+	}
+	return nil
+}
+
+// Insert adds a host to a team's inventory.
+func (th *TeamHost) Insert(db DB) error {
+	const sqlstr = `INSERT INTO team_host (team_id, role, address) VALUES ($1, $2, $3) RETURNING id`
+	return db.QueryRow(sqlstr, th.TeamID, th.Role, th.Address).Scan(&th.ID)
+}
+
+// Update a host's role & address. Returns pgx.ErrNoRows if the host doesn't exist.
+func (th *TeamHost) Update(db DB) error {
+	const sqlstr = `UPDATE team_host SET (role, address) = ($2, $3) WHERE id = $1 RETURNING team_id`
+	return db.QueryRow(sqlstr, th.ID, th.Role, th.Address).Scan(&th.TeamID)
+}
+
+// Delete removes a host from its team's inventory.
+func (th *TeamHost) Delete(db DB) error {
+	const sqlstr = `DELETE FROM team_host WHERE id = $1`
+	_, err := db.Exec(sqlstr, th.ID)
+	return err
+}
+
+// TeamHostByID retrieves a host by its id.
+func TeamHostByID(db DB, id int) (*TeamHost, error) {
+	const sqlstr = `SELECT id, team_id, role, address FROM team_host WHERE id = $1`
+	th := TeamHost{}
+	err := db.QueryRow(sqlstr, id).Scan(&th.ID, &th.TeamID, &th.Role, &th.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &th, nil
+}
+
+// TeamHostView is a TeamHost, with the name of the team it belongs to.
+type TeamHostView struct {
+	TeamHost
+	Team string `json:"team"` // team.name
+}
+
+// AllTeamHosts fetches every team's hosts, ordered by team, then role.
+func AllTeamHosts(db DB) ([]TeamHostView, error) {
+	const sqlstr = `SELECT h.id, h.team_id, h.role, h.address, t.name
+	FROM team_host AS h
+		JOIN team AS t ON h.team_id = t.id
+	ORDER BY h.team_id, h.role`
+
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []TeamHostView{}
+	for rows.Next() {
+		x := TeamHostView{}
+		if err = rows.Scan(&x.ID, &x.TeamID, &x.Role, &x.Address, &x.Team); err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// TeamHostMaps fetches every team's hosts, as a map of team id to its role → address inventory.
+func TeamHostMaps(db DB) (map[int]map[string]string, error) {
+	hosts, err := AllTeamHosts(db)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[int]map[string]string{}
+	for _, h := range hosts {
+		if m[h.TeamID] == nil {
+			m[h.TeamID] = map[string]string{}
+		}
+		m[h.TeamID][h.Role] = h.Address
+	}
+	return m, nil
+}
+
+// SetTeamHosts replaces a team's whole host inventory.
+func SetTeamHosts(db DB, teamID int, hosts map[string]string) error {
+	if _, err := db.Exec(`DELETE FROM team_host WHERE team_id = $1`, teamID); err != nil {
+		return err
+	}
+	for role, addr := range hosts {
+		th := &TeamHost{TeamID: teamID, Role: role, Address: addr}
+		if err := th.Insert(db); err != nil {
+			return errors.WithMessage(err, fmt.Sprintf("host %q", role))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TeamHost_Validate(t *testing.T) {
+	for _, addr := range []string{"10.0.1.5", "fd00:1::10", "www.team1.example", "dc01"} {
+		assert.Nil(t, (&TeamHost{Role: "web", Address: addr}).Validate(), addr)
+	}
+
+	assert.Error(t, (&TeamHost{Role: "Web Server", Address: "10.0.1.5"}).Validate())
+	assert.Error(t, (&TeamHost{Role: "web", Address: ""}).Validate())
+	assert.Error(t, (&TeamHost{Role: "web", Address: "http://10.0.1.5/"}).Validate())
+	assert.Error(t, (&TeamHost{Role: "web", Address: "-bad.example"}).Validate())
+}
+
+func Test_AllTeamHosts(t *testing.T) {
+	prepareTestDatabase(t)
+
+	hosts, err := AllTeamHosts(db)
+	if assert.Nil(t, err) && assert.Equal(t, 3, len(hosts)) {
+		assert.Equal(t, "team1", hosts[0].Team)
+		assert.Equal(t, []string{"mail", "web", "web"}, []string{hosts[0].Role, hosts[1].Role, hosts[2].Role},
+			"By team, then role")
+	}
+
+	dupe := &TeamHost{TeamID: 2, Role: "web", Address: "10.0.2.6"}
+	assert.Error(t, dupe.Insert(db), "One host per role, per team")
+}
+
+func Test_MonitorTeamsAndServices_Hosts(t *testing.T) {
+	prepareTestDatabase(t)
+
+	ping, err := ServiceByID(db, 1)
+	require.Nil(t, err)
+	role := "mail"
+	ping.HostRole = &role
+	require.Nil(t, ping.Update(db))
+
+	tass, err := MonitorTeamsAndServices(db, time.Minute)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(tass)) {
+		hosts := map[int]map[string]string{}
+		for _, tas := range tass {
+			hosts[tas.Team.ID] = tas.Team.Hosts
+			if assert.NotNil(t, tas.Service.HostRole) {
+				assert.Equal(t, "mail", *tas.Service.HostRole)
+			}
+		}
+		assert.Equal(t, map[string]string{"web": "10.0.1.5", "mail": "mail.team1.example"}, hosts[1])
+		assert.Equal(t, map[string]string{"web": "fd00:2::5"}, hosts[2])
+	}
+}
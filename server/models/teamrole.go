@@ -21,6 +21,12 @@ const (
 
 	// TeamRoleBlueteam is the 'blueteam' TeamRole.
 	TeamRoleBlueteam = TeamRole(3)
+
+	// TeamRoleWhiteteam is the 'whiteteam' TeamRole.
+	TeamRoleWhiteteam = TeamRole(4)
+
+	// TeamRoleRedteam is the 'redteam' TeamRole.
+	TeamRoleRedteam = TeamRole(5)
 )
 
 // String returns the string value of the TeamRole.
@@ -36,6 +42,12 @@ func (tr TeamRole) String() string {
 
 	case TeamRoleBlueteam:
 		enumVal = "blueteam"
+
+	case TeamRoleWhiteteam:
+		enumVal = "whiteteam"
+
+	case TeamRoleRedteam:
+		enumVal = "redteam"
 	}
 
 	return enumVal
@@ -58,6 +70,12 @@ func (tr *TeamRole) UnmarshalText(text []byte) error {
 	case "blueteam":
 		*tr = TeamRoleBlueteam
 
+	case "whiteteam":
+		*tr = TeamRoleWhiteteam
+
+	case "redteam":
+		*tr = TeamRoleRedteam
+
 	default:
 		return fmt.Errorf("invalid TeamRole %q", text)
 	}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Ticket represents a row from 'cyboard.ticket'.
+type Ticket struct {
+	ID         int            `json:"id"`          // id
+	TeamID     int            `json:"team_id"`     // team_id
+	Category   TicketCategory `json:"category"`    // category
+	Subject    string         `json:"subject"`     // subject
+	AssigneeID *int           `json:"assignee_id"` // assignee_id
+
+	CreatedAt  time.Time  `json:"created_at"`  // created_at
+	ModifiedAt time.Time  `json:"modified_at"` // modified_at
+	ClosedAt   *time.Time `json:"closed_at"`   // closed_at
+}
+
+// TicketMessage represents a row from 'cyboard.ticket_message'.
+type TicketMessage struct {
+	ID        int       `json:"id"`         // id
+	TicketID  int       `json:"ticket_id"`  // ticket_id
+	AuthorID  int       `json:"author_id"`  // author_id
+	Body      string    `json:"body"`       // body
+	CreatedAt time.Time `json:"created_at"` // created_at
+}
+
+// Closed reports whether staff has closed the ticket.
+func (t *Ticket) Closed() bool {
+	return t.ClosedAt != nil
+}
+
+// Open inserts a new ticket along with its first message, written by the
+// ticket's team. Both the ticket and message will have their IDs filled in.
+func (t *Ticket) Open(db TXer, msg *TicketMessage) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const sqlstr = `INSERT INTO ticket (team_id, category, subject) VALUES ($1, $2, $3) ` +
+		`RETURNING id, created_at, modified_at`
+	err = tx.QueryRow(sqlstr, t.TeamID, t.Category, t.Subject).Scan(&t.ID, &t.CreatedAt, &t.ModifiedAt)
+	if err != nil {
+		return errors.WithMessage(err, "insert ticket")
+	}
+
+	msg.TicketID, msg.AuthorID = t.ID, t.TeamID
+	if err = msg.Insert(tx); err != nil {
+		return errors.WithMessage(err, "insert ticket's first message")
+	}
+	return tx.Commit()
+}
+
+// Insert adds a reply to a ticket's thread.
+func (tm *TicketMessage) Insert(db DB) error {
+	const sqlstr = `INSERT INTO ticket_message (ticket_id, author_id, body) VALUES ($1, $2, $3) ` +
+		`RETURNING id, created_at`
+	return db.QueryRow(sqlstr, tm.TicketID, tm.AuthorID, tm.Body).Scan(&tm.ID, &tm.CreatedAt)
+}
+
+// TicketByID retrieves a row from 'cyboard.ticket' as a Ticket.
+func TicketByID(db DB, id int) (*Ticket, error) {
+	const sqlstr = `SELECT ` +
+		`id, team_id, category, subject, assignee_id, created_at, modified_at, closed_at ` +
+		`FROM ticket ` +
+		`WHERE id = $1`
+	t := Ticket{}
+	err := db.QueryRow(sqlstr, id).Scan(&t.ID, &t.TeamID, &t.Category, &t.Subject, &t.AssigneeID,
+		&t.CreatedAt, &t.ModifiedAt, &t.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// AssignTicket hands a ticket off to a staff member. A nil assignee unassigns the ticket.
+func AssignTicket(db DB, ticketID int, assigneeID *int) error {
+	const sqlstr = `UPDATE ticket SET assignee_id = $2 WHERE id = $1`
+	_, err := db.Exec(sqlstr, ticketID, assigneeID)
+	return err
+}
+
+// CloseTicket marks a ticket as resolved. Closing an already closed ticket
+// leaves the original close time alone.
+func CloseTicket(db DB, ticketID int) error {
+	const sqlstr = `UPDATE ticket SET closed_at = CURRENT_TIMESTAMP WHERE id = $1 AND closed_at IS NULL`
+	_, err := db.Exec(sqlstr, ticketID)
+	return err
+}
+
+// ReopenTicket puts a closed ticket back into the queue.
+func ReopenTicket(db DB, ticketID int) error {
+	const sqlstr = `UPDATE ticket SET closed_at = NULL WHERE id = $1`
+	_, err := db.Exec(sqlstr, ticketID)
+	return err
+}
+
+// TicketView is a ticket as listed in a queue, with the names of the team
+// and assignee, and a summary of the messages in the ticket's thread.
+type TicketView struct {
+	Ticket
+	TeamName     string    `json:"team_name"`     // team.name
+	AssigneeName *string   `json:"assignee_name"` // team.name
+	Messages     int       `json:"messages"`      // count of ticket_message rows
+	LastActivity time.Time `json:"last_activity"` // most recent ticket_message.created_at
+}
+
+const ticketViewSelect = `SELECT ` +
+	`tk.id, tk.team_id, tk.category, tk.subject, tk.assignee_id, tk.created_at, tk.modified_at, tk.closed_at, ` +
+	`team.name, assignee.name, COUNT(msg.id), COALESCE(MAX(msg.created_at), tk.created_at) ` +
+	`FROM ticket AS tk ` +
+	`JOIN team ON tk.team_id = team.id ` +
+	`LEFT JOIN team AS assignee ON tk.assignee_id = assignee.id ` +
+	`LEFT JOIN ticket_message AS msg ON tk.id = msg.ticket_id `
+
+const ticketViewGroupBy = `GROUP BY tk.id, team.name, assignee.name `
+
+func queryTicketViews(db DB, sqlstr string, args ...interface{}) ([]TicketView, error) {
+	rows, err := db.Query(sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []TicketView{}
+	for rows.Next() {
+		x := TicketView{}
+		err = rows.Scan(&x.ID, &x.TeamID, &x.Category, &x.Subject, &x.AssigneeID,
+			&x.CreatedAt, &x.ModifiedAt, &x.ClosedAt,
+			&x.TeamName, &x.AssigneeName, &x.Messages, &x.LastActivity)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
+
+// AllTickets fetches the staff ticket queue. Open tickets come first, oldest
+// first, so the teams waiting the longest get helped first. Closed tickets are
+// only included when asked for, and are listed after, most recently closed first.
+func AllTickets(db DB, includeClosed bool) ([]TicketView, error) {
+	const sqlstr = ticketViewSelect +
+		`WHERE $1 OR tk.closed_at IS NULL ` +
+		ticketViewGroupBy +
+		`ORDER BY tk.closed_at DESC NULLS FIRST, tk.created_at, tk.id`
+	return queryTicketViews(db, sqlstr, includeClosed)
+}
+
+// TeamTickets fetches every ticket opened by a team, newest first.
+func TeamTickets(db DB, teamID int) ([]TicketView, error) {
+	const sqlstr = ticketViewSelect +
+		`WHERE tk.team_id = $1 ` +
+		ticketViewGroupBy +
+		`ORDER BY tk.created_at DESC, tk.id DESC`
+	return queryTicketViews(db, sqlstr, teamID)
+}
+
+// TicketMessageView is a message in a ticket's thread, along with who wrote it.
+type TicketMessageView struct {
+	TicketMessage
+	AuthorName string   `json:"author_name"` // team.name
+	AuthorRole TeamRole `json:"author_role"` // team.role_name
+}
+
+// TicketMessages fetches a ticket's thread, in the order it was written.
+func TicketMessages(db DB, ticketID int) ([]TicketMessageView, error) {
+	const sqlstr = `SELECT msg.id, msg.ticket_id, msg.author_id, msg.body, msg.created_at, ` +
+		`team.name, team.role_name ` +
+		`FROM ticket_message AS msg ` +
+		`JOIN team ON msg.author_id = team.id ` +
+		`WHERE msg.ticket_id = $1 ` +
+		`ORDER BY msg.created_at, msg.id`
+
+	rows, err := db.Query(sqlstr, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	xs := []TicketMessageView{}
+	for rows.Next() {
+		x := TicketMessageView{}
+		err = rows.Scan(&x.ID, &x.TicketID, &x.AuthorID, &x.Body, &x.CreatedAt,
+			&x.AuthorName, &x.AuthorRole)
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return xs, nil
+}
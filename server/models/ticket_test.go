@@ -0,0 +1,72 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/* ticket.yml has two tickets:
+Ticket 1 was opened by team1, is assigned to bigpoppa (id=100), and has a staff reply.
+Ticket 2 was opened by team2, and has already been closed.
+*/
+
+func Test_AllTickets(t *testing.T) {
+	prepareTestDatabase(t)
+
+	open, err := AllTickets(db, false)
+	if assert.Nil(t, err) && assert.Equal(t, 1, len(open), "Only open tickets by default") {
+		assert.Equal(t, 1, open[0].ID)
+		assert.Equal(t, "team1", open[0].TeamName)
+		assert.Equal(t, 2, open[0].Messages)
+		if assert.NotNil(t, open[0].AssigneeName) {
+			assert.Equal(t, "bigpoppa", *open[0].AssigneeName)
+		}
+	}
+
+	all, err := AllTickets(db, true)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(all)) {
+		assert.Equal(t, []int{1, 2}, []int{all[0].ID, all[1].ID}, "Open tickets sort before closed ones")
+		assert.True(t, all[1].Closed())
+	}
+}
+
+func Test_Ticket_Open(t *testing.T) {
+	prepareTestDatabase(t)
+
+	ticket := &Ticket{TeamID: 2, Category: TicketCategoryBrokenChallenge, Subject: "flag doesn't work"}
+	msg := &TicketMessage{Body: "tried flag{rad}, no dice"}
+	require.Nil(t, ticket.Open(db, msg))
+	assert.Equal(t, ticket.ID, msg.TicketID)
+	assert.Equal(t, 2, msg.AuthorID, "First message is written by the team")
+
+	tickets, err := TeamTickets(db, 2)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(tickets)) {
+		assert.Equal(t, ticket.ID, tickets[0].ID, "Newest ticket listed first")
+		assert.Equal(t, 1, tickets[0].Messages)
+	}
+
+	reply := &TicketMessage{TicketID: ticket.ID, AuthorID: 101, Body: "fixed, try again"}
+	require.Nil(t, reply.Insert(db))
+
+	thread, err := TicketMessages(db, ticket.ID)
+	if assert.Nil(t, err) && assert.Equal(t, 2, len(thread)) {
+		assert.Equal(t, "team2", thread[0].AuthorName)
+		assert.Equal(t, TeamRoleCtfCreator, thread[1].AuthorRole)
+	}
+}
+
+func Test_CloseTicket(t *testing.T) {
+	prepareTestDatabase(t)
+
+	require.Nil(t, CloseTicket(db, 1))
+	ticket, err := TicketByID(db, 1)
+	require.Nil(t, err)
+	assert.True(t, ticket.Closed())
+
+	require.Nil(t, ReopenTicket(db, 1))
+	ticket, err = TicketByID(db, 1)
+	require.Nil(t, err)
+	assert.False(t, ticket.Closed())
+}
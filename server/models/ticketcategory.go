@@ -0,0 +1,98 @@
+// Package models contains the types for schema 'cyboard'.
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// TicketCategory is the 'ticket_category' enum type from schema 'cyboard'.
+type TicketCategory uint16
+
+const (
+	// TicketCategoryUnspecified is an invalid TicketCategory, likely bad user input.
+	TicketCategoryUnspecified = TicketCategory(0)
+
+	// TicketCategoryBoxReset is the 'box_reset' TicketCategory.
+	TicketCategoryBoxReset = TicketCategory(1)
+
+	// TicketCategoryBrokenChallenge is the 'broken_challenge' TicketCategory.
+	TicketCategoryBrokenChallenge = TicketCategory(2)
+
+	// TicketCategoryCheckDispute is the 'check_dispute' TicketCategory.
+	TicketCategoryCheckDispute = TicketCategory(3)
+
+	// TicketCategoryOther is the 'other' TicketCategory.
+	TicketCategoryOther = TicketCategory(4)
+)
+
+// TicketCategories lists every valid TicketCategory, in the order they're offered to teams.
+var TicketCategories = []TicketCategory{
+	TicketCategoryBoxReset,
+	TicketCategoryBrokenChallenge,
+	TicketCategoryCheckDispute,
+	TicketCategoryOther,
+}
+
+// String returns the string value of the TicketCategory.
+func (tc TicketCategory) String() string {
+	var enumVal string
+
+	switch tc {
+	case TicketCategoryBoxReset:
+		enumVal = "box_reset"
+
+	case TicketCategoryBrokenChallenge:
+		enumVal = "broken_challenge"
+
+	case TicketCategoryCheckDispute:
+		enumVal = "check_dispute"
+
+	case TicketCategoryOther:
+		enumVal = "other"
+	}
+
+	return enumVal
+}
+
+// MarshalText marshals TicketCategory into text.
+func (tc TicketCategory) MarshalText() ([]byte, error) {
+	return []byte(tc.String()), nil
+}
+
+// UnmarshalText unmarshals TicketCategory from text.
+func (tc *TicketCategory) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "box_reset":
+		*tc = TicketCategoryBoxReset
+
+	case "broken_challenge":
+		*tc = TicketCategoryBrokenChallenge
+
+	case "check_dispute":
+		*tc = TicketCategoryCheckDispute
+
+	case "other":
+		*tc = TicketCategoryOther
+
+	default:
+		return fmt.Errorf("invalid TicketCategory %q", text)
+	}
+
+	return nil
+}
+
+// Value satisfies the sql/driver.Valuer interface for TicketCategory.
+func (tc TicketCategory) Value() (driver.Value, error) {
+	return tc.String(), nil
+}
+
+// Scan satisfies the database/sql.Scanner interface for TicketCategory.
+func (tc *TicketCategory) Scan(src interface{}) error {
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("invalid TicketCategory '%v'", src)
+	}
+
+	return tc.UnmarshalText([]byte(str))
+}
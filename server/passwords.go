@@ -0,0 +1,301 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/jackc/pgx"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password management:
+//
+// * Anyone logged in can change their own password, given their current one.
+// * Admins can make a one-time reset link for a team or player who's forgotten theirs.
+// * Admins can regenerate every blue team's password at once, and print them out.
+
+const (
+	minPasswordLength     = 8
+	passwordResetLifetime = 24 * time.Hour
+)
+
+// checkNewPassword makes sure a new password is acceptable, and hashes it.
+func checkNewPassword(password, confirm string) ([]byte, error) {
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("the new password must be at least %d characters", minPasswordLength)
+	} else if password != confirm {
+		return nil, errors.New("the new passwords do not match")
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+// ShowChangePassword displays the form to change the password of whoever is logged in.
+func ShowChangePassword(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "account_password", "Change Password")
+	page.Data = make(map[string]interface{})
+	renderTemplate(w, page)
+}
+
+// ChangePassword changes the password of the player logged in, or of their team's
+// shared login if they used that. The current password must be given. The account
+// is logged out everywhere else.
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "account_password", "Change Password")
+	page.Data = make(map[string]interface{})
+
+	team, player := getCtxTeam(r), getCtxPlayer(r)
+	current := team.Hash
+	if player != nil {
+		current = player.Hash
+	}
+
+	err := bcrypt.CompareHashAndPassword(current, []byte(r.FormValue("current_password")))
+	if err != nil {
+		if err != bcrypt.ErrMismatchedHashAndPassword {
+			page.checkErr(err, "compare current password")
+		}
+		page.Data["Problem"] = "The current password is incorrect."
+		renderTemplate(w, page)
+		return
+	}
+
+	hash, err := checkNewPassword(r.FormValue("password"), r.FormValue("confirm_password"))
+	if err != nil {
+		page.Data["Problem"] = err.Error()
+		renderTemplate(w, page)
+		return
+	}
+
+	var playerID *int
+	if player != nil {
+		playerID = &player.ID
+	}
+	// Every session on the account is logged out, then this one is saved again under a new token
+	err = models.ChangePassword(db, team.ID, playerID, hash)
+	if err == nil {
+		err = sessionManager.Load(r).RenewToken(w)
+	}
+	page.checkErr(err, "set password")
+	if err == nil {
+		entry := Logger.WithField("team", team.Name)
+		if player != nil {
+			entry = entry.WithField("player", player.Name)
+		}
+		entry.Info("Password changed")
+		page.Data["Changed"] = true
+	}
+	renderTemplate(w, page)
+}
+
+// newResetToken makes a random token to hand out, and the hash of it to save in the database.
+func newResetToken() (string, []byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashResetToken(token), nil
+}
+
+func hashResetToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// PasswordResetResponse is a new reset link, for an admin to pass along.
+type PasswordResetResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// createPasswordReset makes a reset link for the team or player given, and responds with it.
+func createPasswordReset(w http.ResponseWriter, r *http.Request, pr *models.PasswordReset) {
+	token, hash, err := newResetToken()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	pr.TokenHash = hash
+	pr.CreatedBy = &getCtxTeam(r).ID
+	pr.ExpiresAt = time.Now().Add(passwordResetLifetime)
+
+	if err = pr.Insert(db); err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	resp := &PasswordResetResponse{
+		URL:       fmt.Sprintf("%s://%s/reset_password?token=%s", scheme, r.Host, token),
+		ExpiresAt: pr.ExpiresAt,
+	}
+	w.WriteHeader(http.StatusCreated)
+	render.JSON(w, r, resp)
+}
+
+// CreateTeamPasswordReset makes a one-time link to reset a team's shared password.
+func CreateTeamPasswordReset(w http.ResponseWriter, r *http.Request) {
+	id := getCtxIdParam(r)
+	if _, err := models.TeamByID(db, id); err != nil {
+		ApiQuery(w, r, nil, err)
+		return
+	}
+	createPasswordReset(w, r, &models.PasswordReset{TeamID: &id})
+}
+
+// CreatePlayerPasswordReset makes a one-time link to reset a player's password.
+func CreatePlayerPasswordReset(w http.ResponseWriter, r *http.Request) {
+	id := getCtxIdParam(r)
+	if _, err := models.PlayerByID(db, id); err != nil {
+		ApiQuery(w, r, nil, err)
+		return
+	}
+	createPasswordReset(w, r, &models.PasswordReset{PlayerID: &id})
+}
+
+// ShowPasswordReset displays the form for a reset link. Anyone with the link can use it.
+func ShowPasswordReset(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "password_reset", "Reset Password")
+	token := r.URL.Query().Get("token")
+	page.Data = M{"Token": token}
+
+	name, err := models.PasswordResetAccount(db, hashResetToken(token))
+	if err == pgx.ErrNoRows {
+		page.Data["Problem"] = "This reset link is invalid, expired, or was already used."
+	} else if err != nil {
+		page.Error = err
+		Logger.WithError(err).Error("ShowPasswordReset: failed to look up token")
+	}
+	page.Data["Account"] = name
+	renderTemplate(w, page)
+}
+
+// SubmitPasswordReset sets a new password using a reset token.
+func SubmitPasswordReset(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "password_reset", "Reset Password")
+	token := r.FormValue("token")
+	page.Data = M{"Token": token}
+
+	// Look up the account first, since the token can't be found once it's used
+	name, _ := models.PasswordResetAccount(db, hashResetToken(token))
+	page.Data["Account"] = name
+
+	hash, err := checkNewPassword(r.FormValue("password"), r.FormValue("confirm_password"))
+	if err != nil {
+		page.Data["Problem"] = err.Error()
+	} else if err = models.RedeemPasswordReset(db, hashResetToken(token), hash); err == pgx.ErrNoRows {
+		page.Data["Problem"] = "This reset link is invalid, expired, or was already used."
+	} else if err != nil {
+		page.Error = err
+		Logger.WithError(err).Error("SubmitPasswordReset: failed to redeem token")
+	} else {
+		Logger.WithField("account", name).Info("Password reset with a reset link")
+		page.Data["Changed"] = true
+	}
+	renderTemplate(w, page)
+}
+
+// CredentialSheet is a blue team's new logins, to be printed and handed out.
+type CredentialSheet struct {
+	Team       string
+	BlueteamIP int16
+	Address    string // The team's address on the competition network
+	Logins     []Credential
+}
+
+// Credential is a login name, and its freshly generated password.
+type Credential struct {
+	Name     string
+	Password string
+	IsPlayer bool
+}
+
+// RegenerateBlueteamPasswords gives every active blue team a new random password,
+// and their players too, if `withPlayers` is set. It's all or nothing.
+func RegenerateBlueteamPasswords(db models.DBClient, withPlayers bool) ([]CredentialSheet, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	teams, err := models.AllBlueteams(tx)
+	if err != nil {
+		return nil, err
+	}
+	var players []models.PlayerView
+	if withPlayers {
+		if players, err = models.AllPlayers(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	sheets := make([]CredentialSheet, len(teams))
+	for i, t := range teams {
+		sheet := CredentialSheet{Team: t.Name, BlueteamIP: t.BlueteamIP,
+			Address: appCfg.ServiceMonitor.TeamAddress(t.ID, t.BlueteamIP)}
+
+		pass, hash, err := generatePassword()
+		if err != nil {
+			return nil, err
+		}
+		if err = models.SetTeamPassword(tx, t.ID, hash); err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("set password (team=%q)", t.Name))
+		}
+		sheet.Logins = append(sheet.Logins, Credential{Name: t.Name, Password: pass})
+
+		for _, p := range players {
+			if p.TeamID != t.ID || p.Disabled {
+				continue
+			}
+			pass, hash, err := generatePassword()
+			if err != nil {
+				return nil, err
+			}
+			if err = models.SetPlayerPassword(tx, p.ID, hash); err != nil {
+				return nil, errors.WithMessage(err, fmt.Sprintf("set password (player=%q)", p.Name))
+			}
+			sheet.Logins = append(sheet.Logins, Credential{Name: p.Name, Password: pass, IsPlayer: true})
+		}
+		sheets[i] = sheet
+	}
+
+	return sheets, tx.Commit()
+}
+
+// ShowCredentialSheets explains the bulk password reset, with a button to go ahead with it.
+func ShowCredentialSheets(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_credentials", "Blue Team Credentials")
+	page.Data = make(map[string]interface{})
+	renderTemplate(w, page)
+}
+
+// GenerateCredentialSheets resets every blue team's password, and shows the new
+// ones in a printable sheet. The passwords can't be seen again after this.
+func GenerateCredentialSheets(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_credentials", "Blue Team Credentials")
+	page.Data = make(map[string]interface{})
+
+	withPlayers := r.FormValue("with_players") != ""
+	sheets, err := RegenerateBlueteamPasswords(db, withPlayers)
+	page.checkErr(err, "regenerate blueteam passwords")
+	if err == nil {
+		Logger.WithField("admin", page.T.Name).WithField("teams", len(sheets)).
+			Warn("Regenerated all blue team passwords")
+		page.Data["Sheets"] = sheets
+	}
+
+	// Don't let the browser hang on to the passwords
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplate(w, page)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pereztr5/cyboard/server/apptest"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func Test_checkNewPassword(t *testing.T) {
+	_, err := checkNewPassword("short", "short")
+	assert.Error(t, err)
+
+	_, err = checkNewPassword("long enough", "doesn't match")
+	assert.Error(t, err)
+
+	hash, err := checkNewPassword("long enough", "long enough")
+	if assert.NoError(t, err) {
+		assert.NoError(t, bcrypt.CompareHashAndPassword(hash, []byte("long enough")))
+	}
+}
+
+func Test_RegenerateBlueteamPasswords(t *testing.T) {
+	apptest.PrepDatabase(t)
+
+	sheets, err := RegenerateBlueteamPasswords(db, true)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(sheets), "Only active blue teams get new passwords")
+
+	team1 := sheets[0]
+	assert.Equal(t, "team1", team1.Team)
+	if assert.Equal(t, 3, len(team1.Logins), "The team login, plus both players") {
+		team, err := models.TeamByName(db, "team1")
+		require.NoError(t, err)
+		assert.NoError(t, bcrypt.CompareHashAndPassword(team.Hash, []byte(team1.Logins[0].Password)))
+
+		assert.True(t, team1.Logins[1].IsPlayer)
+		player, err := models.PlayerByName(db, team1.Logins[1].Name)
+		require.NoError(t, err)
+		assert.NoError(t, bcrypt.CompareHashAndPassword(player.Hash, []byte(team1.Logins[1].Password)))
+	}
+
+	assert.Equal(t, 1, len(sheets[1].Logins), "Disabled players are left alone")
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/pereztr5/cyboard/server/models"
+)
+
+// Permissions:
+//
+// * Admins can do anything.
+// * Every other staff role (ctf_creator, whiteteam, redteam) can do what it's been
+//   granted in the `role_permission` table. Admins can change that while the event runs.
+// * Blue teams have no permissions. What they can do depends only on being a blue team.
+
+// staffRoles are the roles whose permissions can be changed, in the order they're shown.
+var staffRoles = []models.TeamRole{models.TeamRoleCtfCreator, models.TeamRoleWhiteteam, models.TeamRoleRedteam}
+
+// rolePermissions caches what each staff role may do. It's loaded on first use, and
+// dropped whenever an admin changes it.
+var rolePermissions = struct {
+	sync.RWMutex
+	perms map[models.TeamRole][]models.Permission
+}{}
+
+func getRolePermissions() (map[models.TeamRole][]models.Permission, error) {
+	rolePermissions.RLock()
+	perms := rolePermissions.perms
+	rolePermissions.RUnlock()
+	if perms != nil {
+		return perms, nil
+	}
+
+	rolePermissions.Lock()
+	defer rolePermissions.Unlock()
+	if rolePermissions.perms == nil {
+		var err error
+		if rolePermissions.perms, err = models.RolePermissions(db); err != nil {
+			return nil, err
+		}
+	}
+	return rolePermissions.perms, nil
+}
+
+// resetRolePermissions makes the next permission check reload them from the database.
+func resetRolePermissions() {
+	rolePermissions.Lock()
+	rolePermissions.perms = nil
+	rolePermissions.Unlock()
+}
+
+// hasPermission checks if the team is allowed to do something.
+func hasPermission(t *models.Team, p models.Permission) bool {
+	if t == nil || t.RoleName == models.TeamRoleBlueteam {
+		return false
+	} else if t.RoleName == models.TeamRoleAdmin {
+		return true
+	}
+
+	perms, err := getRolePermissions()
+	if err != nil {
+		Logger.WithError(err).Error("failed to load role permissions")
+		return false
+	}
+	for _, granted := range perms[t.RoleName] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission only lets through teams with the permission given.
+func RequirePermission(p models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if hasPermission(getCtxTeam(r), p) {
+				next.ServeHTTP(w, r)
+			} else {
+				render.Render(w, r, ErrForbidden)
+			}
+		})
+	}
+}
+
+// PermissionsResponse lists the permissions there are, and which staff roles have them.
+type PermissionsResponse struct {
+	Permissions []models.PermissionInfo                 `json:"permissions"`
+	Roles       map[models.TeamRole][]models.Permission `json:"roles"`
+}
+
+// GetPermissions lists every permission, and which staff roles have them.
+func GetPermissions(w http.ResponseWriter, r *http.Request) {
+	all, err := models.AllPermissions(db)
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	}
+	roles, err := models.RolePermissions(db)
+	if err != nil {
+		RenderQueryErr(w, r, err)
+		return
+	}
+	for _, role := range staffRoles {
+		if roles[role] == nil {
+			roles[role] = []models.Permission{}
+		}
+	}
+	render.JSON(w, r, PermissionsResponse{Permissions: all, Roles: roles})
+}
+
+// parseStaffRole reads a role that can have its permissions changed.
+func parseStaffRole(s string) (models.TeamRole, error) {
+	var role models.TeamRole
+	if err := role.UnmarshalText([]byte(s)); err != nil {
+		return role, err
+	}
+	for _, r := range staffRoles {
+		if r == role {
+			return role, nil
+		}
+	}
+	return role, fmt.Errorf("the permissions of role %q can't be changed", s)
+}
+
+// UpdateRolePermissions replaces the permissions of a staff role with
+// the list of permission names sent.
+func UpdateRolePermissions(w http.ResponseWriter, r *http.Request) {
+	role, err := parseStaffRole(chi.URLParam(r, "role"))
+	if err != nil {
+		render.Render(w, r, ErrInvalidBecause(err.Error()))
+		return
+	}
+	var perms []models.Permission
+	if err = render.DecodeJSON(r.Body, &perms); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	err = models.SetRolePermissions(db, map[models.TeamRole][]models.Permission{role: perms})
+	resetRolePermissions()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	render.JSON(w, r, perms)
+}
+
+// ShowPermissionsConfig displays which staff roles have each permission, as a grid of checkboxes.
+func ShowPermissionsConfig(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_permissions", "Permissions")
+	renderPermissionsConfig(w, page)
+}
+
+func renderPermissionsConfig(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	perms, err := models.AllPermissions(db)
+	page.checkErr(err, "all permissions")
+	granted, err := models.RolePermissions(db)
+	page.checkErr(err, "role permissions")
+
+	// Granted[role][permission] is true if the role has it
+	grid := map[models.TeamRole]map[models.Permission]bool{}
+	for _, role := range staffRoles {
+		grid[role] = map[models.Permission]bool{}
+		for _, p := range granted[role] {
+			grid[role][p] = true
+		}
+	}
+
+	page.Data["Permissions"] = perms
+	page.Data["Roles"] = staffRoles
+	page.Data["Granted"] = grid
+	renderTemplate(w, page)
+}
+
+// SavePermissionsConfig saves the permissions grid. Each staff role's checked
+// permissions are sent as a list of form values, under the role's name.
+func SavePermissionsConfig(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_permissions", "Permissions")
+	page.Data = make(map[string]interface{})
+
+	if err := r.ParseForm(); err != nil {
+		page.Data["Problem"] = err.Error()
+		renderPermissionsConfig(w, page)
+		return
+	}
+	grants := map[models.TeamRole][]models.Permission{}
+	for _, role := range staffRoles {
+		grants[role] = []models.Permission{}
+		for _, p := range r.PostForm[role.String()] {
+			grants[role] = append(grants[role], models.Permission(p))
+		}
+	}
+	page.checkErr(models.SetRolePermissions(db, grants), "set role permissions")
+	resetRolePermissions()
+
+	if page.Error == nil {
+		Logger.WithField("admin", page.T.Name).Info("Role permissions changed")
+		page.Data["Saved"] = true
+	}
+	renderPermissionsConfig(w, page)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/pereztr5/cyboard/server/apptest"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_hasPermission(t *testing.T) {
+	apptest.PrepDatabase(t)
+	resetRolePermissions()
+
+	role := func(r models.TeamRole) *models.Team { return &models.Team{Name: r.String(), RoleName: r} }
+
+	cases := []struct {
+		team *models.Team
+		perm models.Permission
+		can  bool
+	}{
+		{role(models.TeamRoleAdmin), models.PermGrantBonus, true},
+		{role(models.TeamRoleAdmin), "anything_at_all", true},
+		{role(models.TeamRoleCtfCreator), models.PermManageCtf, true},
+		{role(models.TeamRoleCtfCreator), models.PermGrantBonus, false},
+		{role(models.TeamRoleWhiteteam), models.PermGrantBonus, true},
+		{role(models.TeamRoleWhiteteam), models.PermRunScripts, false},
+		{role(models.TeamRoleRedteam), models.PermViewFlags, false},
+		{role(models.TeamRoleBlueteam), models.PermViewUptime, false},
+		{nil, models.PermViewUptime, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.can, hasPermission(c.team, c.perm), "%v: %s", c.team, c.perm)
+	}
+}
+
+func Test_parseStaffRole(t *testing.T) {
+	role, err := parseStaffRole("whiteteam")
+	assert.NoError(t, err)
+	assert.Equal(t, models.TeamRoleWhiteteam, role)
+
+	for _, bad := range []string{"admin", "blueteam", "nope", ""} {
+		_, err = parseStaffRole(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,362 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+)
+
+// ReportFormats are the file formats a results report can be written in.
+var ReportFormats = []string{"html", "csv", "json"}
+
+// EventReport holds the final results of the event, for the awards ceremony & the records.
+type EventReport struct {
+	Title       string    `json:"title"`
+	GeneratedAt time.Time `json:"generated_at"`
+	EventStart  time.Time `json:"event_start"`
+	EventEnd    time.Time `json:"event_end"`
+
+	Categories  []models.ScoreCategory          `json:"categories"` // the columns of the rankings
+	Rankings    []models.TeamsScoresResponse    `json:"rankings"`
+	Uptimes     []models.ServiceUptime          `json:"uptimes"`
+	Solves      []models.TeamCapturedChallenges `json:"solves"`
+	Bonuses     []models.OtherPointsView        `json:"bonuses"`
+	FirstBloods []FirstBlood                    `json:"first_bloods"`
+}
+
+// FirstBlood is the first team to solve a ctf challenge.
+type FirstBlood struct {
+	Challenge string    `json:"challenge"`
+	Category  string    `json:"category"`
+	Designer  string    `json:"designer"`
+	Team      string    `json:"team"`
+	SolvedAt  time.Time `json:"solved_at"`
+	Solves    int       `json:"solves"` // total number of teams that solved it
+}
+
+// rankScores fills in each team's rank, using the configured tiebreakers.
+// Meant to wrap a query for the scores.
+func rankScores(scores []models.TeamsScoresResponse, err error) ([]models.TeamsScoresResponse, error) {
+	if err == nil {
+		models.RankTeams(scores, appCfg.Event.Tiebreakers)
+	}
+	return scores, err
+}
+
+// rankTeams orders teams by rank, best first. Teams sharing a rank are listed by team id.
+func rankTeams(scores []models.TeamsScoresResponse) []models.TeamsScoresResponse {
+	ranked := append([]models.TeamsScoresResponse(nil), scores...)
+	models.RankTeams(ranked, appCfg.Event.Tiebreakers)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Rank < ranked[j].Rank })
+	return ranked
+}
+
+// bonusesOldestFirst puts grants in the order they were given, for reading top to bottom.
+func bonusesOldestFirst(bonuses []models.OtherPointsView) {
+	sort.Slice(bonuses, func(i, j int) bool {
+		if !bonuses[i].CreatedAt.Equal(bonuses[j].CreatedAt) {
+			return bonuses[i].CreatedAt.Before(bonuses[j].CreatedAt)
+		}
+		return bonuses[i].ID < bonuses[j].ID
+	})
+}
+
+// BuildEventReport gathers up the results of the event, so far.
+func BuildEventReport(db models.DB, cfg *Configuration) (*EventReport, error) {
+	now := time.Now()
+	rep := &EventReport{
+		Title:       cfg.Server.Appname,
+		GeneratedAt: now,
+		EventStart:  cfg.Event.Start,
+		EventEnd:    cfg.Event.End,
+	}
+	if rep.Title == "" {
+		rep.Title = "Cyboard"
+	}
+
+	scores, err := models.TeamsScores(db)
+	if err != nil {
+		return nil, errors.WithMessage(err, "report rankings")
+	}
+	rep.Rankings = rankTeams(scores)
+	if rep.Categories, err = models.AllScoreCategories(db); err != nil {
+		return nil, errors.WithMessage(err, "report score categories")
+	}
+
+	end := cfg.Event.End
+	if now.Before(end) {
+		end = now
+	}
+	if rep.Uptimes, err = models.ServiceUptimes(db, cfg.Event.Start, end); err != nil {
+		return nil, errors.WithMessage(err, "report uptimes")
+	}
+	if rep.Solves, err = models.ChallengeCapturesPerTeam(db); err != nil {
+		return nil, errors.WithMessage(err, "report ctf solves")
+	}
+	if rep.Bonuses, err = models.AllBonusPoints(db); err != nil {
+		return nil, errors.WithMessage(err, "report bonuses")
+	}
+	bonusesOldestFirst(rep.Bonuses)
+
+	captures, err := models.ChallengeCapturesPerFlag(db)
+	if err != nil {
+		return nil, errors.WithMessage(err, "report first bloods")
+	}
+	rep.FirstBloods = []FirstBlood{}
+	for _, c := range captures {
+		if c.FirstTeam != nil {
+			rep.FirstBloods = append(rep.FirstBloods, FirstBlood{
+				Challenge: c.Name, Category: c.Category, Designer: c.Designer,
+				Team: *c.FirstTeam, SolvedAt: *c.Timestamp, Solves: c.Count,
+			})
+		}
+	}
+	sort.Slice(rep.FirstBloods, func(i, j int) bool { return rep.FirstBloods[i].SolvedAt.Before(rep.FirstBloods[j].SolvedAt) })
+
+	return rep, nil
+}
+
+// Write outputs the report in one of the ReportFormats.
+func (rep *EventReport) Write(w io.Writer, format string) error {
+	switch format {
+	case "html":
+		tmpl, ok := templates["event_report"]
+		if !ok {
+			return errors.New("the report's html template isn't loaded from ui/tmpl")
+		}
+		return tmpl.ExecuteTemplate(w, "event-report", rep)
+	case "csv":
+		return rep.writeCSV(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	return fmt.Errorf("unknown report format %q (must be one of %v)", format, ReportFormats)
+}
+
+// writeCSV writes each section of the report as its own table, one after the other,
+// each with a title row & a header row, and a blank row in between.
+func (rep *EventReport) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	ts := func(t time.Time) string { return t.Format(time.RFC3339) }
+	num := func(x float32) string { return strconv.FormatFloat(float64(x), 'f', -1, 32) }
+
+	cw.Write([]string{"Rankings"})
+	header := []string{"rank", "team", "score"}
+	for _, c := range rep.Categories {
+		header = append(header, c.Name)
+	}
+	cw.Write(header)
+	for _, t := range rep.Rankings {
+		row := []string{strconv.Itoa(t.Rank), t.Name, strconv.Itoa(t.Score)}
+		for _, c := range rep.Categories {
+			row = append(row, strconv.Itoa(t.Categories[c.Name]))
+		}
+		cw.Write(row)
+	}
+
+	cw.Write(nil)
+	cw.Write([]string{"Service Uptime"})
+	cw.Write([]string{"team", "service", "uptime_percent", "checks", "pass", "partial", "fail", "timeout", "longest_outage_secs"})
+	for _, u := range rep.Uptimes {
+		cw.Write([]string{u.TeamName, u.ServiceName, strconv.FormatFloat(u.Uptime, 'f', 2, 64),
+			strconv.Itoa(u.Checks), strconv.Itoa(u.Pass), strconv.Itoa(u.Partial), strconv.Itoa(u.Fail),
+			strconv.Itoa(u.Timeout), strconv.Itoa(u.LongestOutageSecs)})
+	}
+
+	cw.Write(nil)
+	cw.Write([]string{"CTF Solves"})
+	cw.Write([]string{"team", "challenge", "category", "designer", "solved_at"})
+	for _, team := range rep.Solves {
+		for _, c := range team.Challenges {
+			cw.Write([]string{team.Team, c.Name, c.Category, c.Designer, ts(c.Timestamp)})
+		}
+	}
+
+	cw.Write(nil)
+	cw.Write([]string{"Bonuses & Deductions"})
+	cw.Write([]string{"id", "time", "team", "points", "category", "kind", "reason", "note", "revoked_at"})
+	for _, b := range rep.Bonuses {
+		revoked := ""
+		if b.RevokedAt != nil {
+			revoked = ts(*b.RevokedAt)
+		}
+		cw.Write([]string{strconv.Itoa(b.ID), ts(b.CreatedAt), b.Team, num(b.Points), b.Category, b.Kind.String(),
+			b.Reason, b.Note, revoked})
+	}
+
+	cw.Write(nil)
+	cw.Write([]string{"First Bloods"})
+	cw.Write([]string{"challenge", "category", "designer", "team", "solved_at", "total_solves"})
+	for _, c := range rep.FirstBloods {
+		cw.Write([]string{c.Challenge, c.Category, c.Designer, c.Team, ts(c.SolvedAt), strconv.Itoa(c.Solves)})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// TeamScorecard is a single blue team's take-home summary of the event.
+type TeamScorecard struct {
+	models.TeamsScoresResponse
+	Teams           int                         // number of teams ranked
+	ScoreCategories []models.ScoreCategory      // the breakdown of the score
+	History         []models.ScoreHistoryBucket // the team's score at the start of each hour
+	MaxScore        int                         // highest score in the history, to scale the chart by
+	Uptimes         []models.ServiceUptime
+	Solves          []models.CapturedChallenge
+	Bonuses         []bonusListing
+}
+
+// scorecardBucket is how often the score history on a scorecard is sampled.
+const scorecardBucket = time.Hour
+
+// BuildTeamScorecards gathers up the scorecards for the given blue teams, or for
+// every blue team, if `teamID` is nil. The rankings & score history stop at
+// `scoresAsOf`, so teams can't see past a frozen scoreboard.
+func BuildTeamScorecards(db models.DB, teamID *int, scoresAsOf time.Time) ([]TeamScorecard, error) {
+	start := appCfg.Event.Start
+	end := scoresAsOf
+	if end.After(appCfg.Event.End) {
+		end = appCfg.Event.End
+	}
+
+	categories, err := models.AllScoreCategories(db)
+	if err != nil {
+		return nil, errors.WithMessage(err, "scorecard score categories")
+	}
+	scores, err := models.TeamsScoresAsOf(db, end)
+	if err != nil {
+		return nil, errors.WithMessage(err, "scorecard rankings")
+	}
+	history, err := models.TeamsScoreHistory(db, scorecardBucket, start, end)
+	if err != nil {
+		return nil, errors.WithMessage(err, "scorecard history")
+	}
+	uptimes, err := models.ServiceUptimes(db, start, end)
+	if err != nil {
+		return nil, errors.WithMessage(err, "scorecard uptimes")
+	}
+	solves, err := models.ChallengeCapturesPerTeam(db)
+	if err != nil {
+		return nil, errors.WithMessage(err, "scorecard ctf solves")
+	}
+	bonuses, err := models.AllBonusPoints(db)
+	if err != nil {
+		return nil, errors.WithMessage(err, "scorecard bonuses")
+	}
+	bonusesOldestFirst(bonuses)
+
+	ranked := rankTeams(scores)
+	cards := []TeamScorecard{}
+	for _, rt := range ranked {
+		if teamID != nil && rt.TeamID != *teamID {
+			continue
+		}
+		card := TeamScorecard{TeamsScoresResponse: rt, Teams: len(ranked), ScoreCategories: categories}
+
+		for _, h := range history {
+			if h.TeamID == rt.TeamID {
+				card.History = append(card.History, h)
+				if h.Score > card.MaxScore {
+					card.MaxScore = h.Score
+				}
+			}
+		}
+		for _, u := range uptimes {
+			if u.TeamID == rt.TeamID {
+				card.Uptimes = append(card.Uptimes, u)
+			}
+		}
+		for _, s := range solves {
+			if s.Team == rt.Name {
+				card.Solves = s.Challenges
+			}
+		}
+		for _, b := range bonuses {
+			if b.TeamID == rt.TeamID {
+				card.Bonuses = append(card.Bonuses, bonusListing{OtherPointsView: b, Files: listFiles(bonusDir(b.ID))})
+			}
+		}
+		cards = append(cards, card)
+	}
+
+	// The admin's stack of scorecards is handed out in team order
+	sort.Slice(cards, func(i, j int) bool { return cards[i].TeamID < cards[j].TeamID })
+	return cards, nil
+}
+
+// reportContentTypes maps each of the ReportFormats to its mime type.
+var reportContentTypes = map[string]string{
+	"html": "text/html; charset=utf-8",
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json; charset=utf-8",
+}
+
+// GetEventReport downloads the final results report. The format is picked
+// with the `format` query param (html, csv, or json), defaulting to html.
+func GetEventReport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	contentType, ok := reportContentTypes[format]
+	if !ok {
+		render.Render(w, r, ErrInvalidBecause(fmt.Sprintf("unknown report format %q (must be one of %v)", format, ReportFormats)))
+		return
+	}
+
+	rep, err := BuildEventReport(db, &appCfg)
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if r.URL.Query().Get("download") != "" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="cyboard-report.%s"`, format))
+	}
+	if err = rep.Write(w, format); err != nil {
+		Logger.WithError(err).Error("GetEventReport: failed to write report")
+	}
+}
+
+// WriteReport generates the results report from the command line.
+// An empty `dest` writes to stdout.
+func WriteReport(cfg *Configuration, dest, format string) error {
+	if _, ok := reportContentTypes[format]; !ok {
+		return fmt.Errorf("unknown report format %q (must be one of %v)", format, ReportFormats)
+	}
+	SetupToolLogger(&cfg.Log)
+	SetupPostgres(cfg.Database.URI)
+	if format == "html" {
+		ensureAppTemplates()
+	}
+
+	rep, err := BuildEventReport(db, cfg)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if dest != "" {
+		f, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	return rep.Write(out, format)
+}
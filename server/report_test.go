@@ -0,0 +1,103 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pereztr5/cyboard/server/apptest"
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rankTeams(t *testing.T) {
+	scores := []models.TeamsScoresResponse{
+		{TeamID: 1, Name: "a", Score: 10},
+		{TeamID: 2, Name: "b", Score: 30},
+		{TeamID: 3, Name: "c", Score: 10},
+		{TeamID: 4, Name: "d", Score: 5},
+	}
+	ranked := rankTeams(scores)
+
+	names, ranks := []string{}, []int{}
+	for _, r := range ranked {
+		names = append(names, r.Name)
+		ranks = append(ranks, r.Rank)
+	}
+	assert.Equal(t, []string{"b", "a", "c", "d"}, names)
+	assert.Equal(t, []int{1, 2, 2, 4}, ranks, "Tied teams share a rank")
+}
+
+func Test_BuildEventReport(t *testing.T) {
+	apptest.PrepDatabase(t)
+
+	cfg := &Configuration{}
+	cfg.Event.Start = time.Date(2018, 7, 29, 8, 0, 0, 0, time.Local)
+	cfg.Event.End = time.Date(2018, 7, 29, 18, 0, 0, 0, time.Local)
+
+	rep, err := BuildEventReport(db, cfg)
+	require.NoError(t, err)
+
+	if assert.Equal(t, 2, len(rep.Rankings)) {
+		assert.Equal(t, "team1", rep.Rankings[0].Name)
+		assert.Equal(t, 1, rep.Rankings[0].Rank)
+	}
+	if assert.Equal(t, 2, len(rep.FirstBloods)) {
+		assert.Equal(t, "team1", rep.FirstBloods[0].Team, "Earliest solve listed first")
+	}
+	assert.Equal(t, 1, len(rep.Bonuses))
+
+	// The html report is rendered from ui/tmpl, at the root of the repo
+	require.NoError(t, os.Chdir(".."))
+	ensureAppTemplates()
+	require.NoError(t, os.Chdir("server"))
+
+	for _, format := range ReportFormats {
+		buf := &bytes.Buffer{}
+		if assert.NoError(t, rep.Write(buf, format), format) {
+			assert.Contains(t, buf.String(), "team1", format)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, rep.Write(buf, "csv"))
+	cr := csv.NewReader(buf)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Rankings"}, records[0])
+	assert.Equal(t, []string{"rank", "team", "score", "service", "ctf", "incident", "inject", "other"}, records[1],
+		"A column for each score category")
+	assert.Equal(t, []string{"1", "team1", "31", "4", "5", "0", "16", "5"}, records[2])
+
+	assert.Error(t, rep.Write(buf, "pdf"))
+}
+
+func Test_BuildTeamScorecards(t *testing.T) {
+	apptest.PrepDatabase(t)
+
+	prevCfg := appCfg
+	defer func() { appCfg = prevCfg }()
+	appCfg.Event.Start = time.Date(2018, 7, 29, 8, 0, 0, 0, time.Local)
+	appCfg.Event.End = time.Date(2018, 7, 29, 18, 0, 0, 0, time.Local)
+
+	cards, err := BuildTeamScorecards(db, nil, appCfg.Event.End)
+	require.NoError(t, err)
+	if assert.Equal(t, 2, len(cards)) {
+		assert.True(t, cards[0].TeamID < cards[1].TeamID, "Scorecards are in team order")
+		for _, c := range cards {
+			assert.Equal(t, 2, c.Teams)
+			assert.NotEmpty(t, c.History)
+		}
+	}
+
+	teamID := cards[0].TeamID
+	cards, err = BuildTeamScorecards(db, &teamID, appCfg.Event.End)
+	require.NoError(t, err)
+	if assert.Equal(t, 1, len(cards)) {
+		assert.Equal(t, teamID, cards[0].TeamID)
+	}
+}
@@ -31,13 +31,9 @@ func setupResponder(logger *logrus.Logger) {
 			}
 			logmsg.Error("error during request")
 
-			team := getCtxTeam(r)
-			if team != nil {
-				switch team.RoleName {
-				// Only expose the error to staff
-				case models.TeamRoleAdmin, models.TeamRoleCtfCreator:
-					err.ErrorText = err.Err.Error()
-				}
+			// Only expose the error to staff who can read the logs anyway
+			if hasPermission(getCtxTeam(r), models.PermViewLogs) {
+				err.ErrorText = err.Err.Error()
 			}
 			render.DefaultResponder(w, r, err)
 			return
@@ -102,8 +98,9 @@ func ErrInternal(err error) render.Renderer {
 }
 
 var (
-	ErrNotFound  = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found"}
-	ErrForbidden = &ErrResponse{HTTPStatusCode: 403, StatusText: "Forbidden"}
+	ErrNotFound     = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found"}
+	ErrForbidden    = &ErrResponse{HTTPStatusCode: 403, StatusText: "Forbidden"}
+	ErrUnauthorized = &ErrResponse{HTTPStatusCode: 401, StatusText: "Unauthorized"}
 )
 
 func ErrForbiddenBecause(reason string) render.Renderer {
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi"
+	"github.com/pereztr5/cyboard/server/models"
 	"github.com/urfave/negroni"
 )
 
@@ -14,7 +15,7 @@ import (
 // a blueteam can hit that endpoint at most once per second, full-stop.
 const MaxReqsPerSec = 1
 
-func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router {
+func CreateWebRouter(teamScoreUpdater, publicScoreUpdater, servicesUpdater *broadcastHub) chi.Router {
 	router := chi.NewRouter()
 
 	// Split off static asset handler, so that none of the other standard middleware gets run for static assets.
@@ -44,6 +45,19 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 	pages.Get("/login", ShowLogin)
 	MaybeRateLimit(pages, MaxReqsPerSec).Post("/login", SubmitLogin)
 	pages.Get("/logout", Logout)
+	pages.Get("/reset_password", ShowPasswordReset)
+	MaybeRateLimit(pages, MaxReqsPerSec).Post("/reset_password", SubmitPasswordReset)
+
+	// Account settings, for anyone logged in
+	pages.Group(func(account chi.Router) {
+		account.Use(RequireLogin)
+		account.Get("/account/password", ShowChangePassword)
+		MaybeRateLimit(account, MaxReqsPerSec).Post("/account/password", ChangePassword)
+	})
+
+	// Compromise report evidence, for the red team, white team, and the blue team it's against
+	pages.With(RequireLogin, RequireIdParam).Get("/compromises/{id}/evidence/{name}", GetCompromiseEvidence)
+	pages.With(RequireLogin, RequireIdParam).Get("/bonus/{id}/files/{name}", GetBonusAttachment)
 
 	pages.Group(func(r chi.Router) {
 		r.Use(RequireEventStarted)
@@ -55,24 +69,101 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 	pages.Group(func(authed chi.Router) {
 		authed.Use(RequireLogin, RequireEventStarted)
 		authed.Get("/dashboard", ShowTeamDashboard)
+		authed.Get("/dashboard/report", ShowTeamReport)
+		MaybeRateLimit(authed, MaxReqsPerSec).With(RequireEventNotOver).
+			Post("/dashboard/incidents", SubmitIncidentReport)
 		authed.Get("/challenges", ShowChallenges)
+
+		authed.Get("/injects", ShowInjects)
+		authed.Route("/injects/{id}", func(r chi.Router) {
+			r.Use(RequireIdParam)
+			MaybeRateLimit(r, MaxReqsPerSec).With(RequireEventNotOver).Post("/", SubmitInject)
+			r.Get("/files/{name}", GetInjectAttachment)
+			r.Get("/submission/{name}", GetInjectSubmissionFile)
+		})
 	})
 
 	// Pages for admins (configuration, analytic dashboards)
 	pages.Route("/admin", func(admin chi.Router) {
-		admin.Use(RequireLogin, RequireAdmin)
-		admin.Get("/bonuses", ShowBonusPage)
-		admin.Get("/teams", ShowTeamsConfig)
-		admin.Get("/services", ShowServicesConfig)
-		admin.Get("/services/scripts", ShowServiceScriptsConfig)
+		admin.Use(RequireLogin, RequireStaff, AuditLog)
+		admin.With(RequirePermission(models.PermGrantBonus)).Get("/bonuses", ShowBonusPage)
+		admin.With(RequirePermission(models.PermManageServices)).Get("/services", ShowServicesConfig)
+		admin.With(RequirePermission(models.PermRunScripts)).Get("/services/scripts", ShowServiceScriptsConfig)
+
+		admin.Group(func(r chi.Router) {
+			r.Use(RequireAdmin)
+			r.Get("/reports", ShowAllTeamReports)
+			r.Get("/teams", ShowTeamsConfig)
+			r.Get("/players", ShowPlayersConfig)
+			r.Get("/credentials", ShowCredentialSheets)
+			r.Post("/credentials", GenerateCredentialSheets)
+			r.Get("/permissions", ShowPermissionsConfig)
+			r.Post("/permissions", SavePermissionsConfig)
+			r.Get("/scoring", ShowScoringConfig)
+			r.Post("/scoring", AddScoreCategoryFromForm)
+			r.With(RequireIdParam).Post("/scoring/{id}", UpdateScoreCategoryFromForm)
+			r.With(RequireIdParam).Post("/scoring/{id}/delete", DeleteScoreCategoryFromForm)
+			r.Get("/hosts", ShowTeamHostsConfig)
+			r.Post("/hosts", AddTeamHostFromForm)
+			r.With(RequireIdParam).Post("/hosts/{id}", UpdateTeamHostFromForm)
+			r.With(RequireIdParam).Post("/hosts/{id}/delete", DeleteTeamHostFromForm)
+			r.Get("/runners", ShowCheckRunnersConfig)
+			r.Post("/runners", AddCheckRunnerFromForm)
+			r.With(RequireIdParam).Post("/runners/{id}", UpdateCheckRunnerFromForm)
+			r.With(RequireIdParam).Post("/runners/{id}/token", RotateCheckRunnerTokenFromForm)
+			r.With(RequireIdParam).Post("/runners/{id}/delete", DeleteCheckRunnerFromForm)
+		})
 	})
 
-	// Pages for ctf creators
+	// Pages for the rest of the staff
 	pages.Route("/staff", func(staff chi.Router) {
-		staff.Use(RequireLogin, RequireCtfStaff)
-		staff.Get("/ctf", ShowCtfConfig)
-		staff.Get("/ctf_dashboard", ShowCtfDashboard)
-		staff.Get("/log_files", ShowLogViewer)
+		staff.Use(RequireLogin, RequireStaff, AuditLog)
+		staff.With(RequirePermission(models.PermManageCtf)).Get("/ctf", ShowCtfConfig)
+		staff.With(RequirePermission(models.PermViewFlags)).Get("/ctf_dashboard", ShowCtfDashboard)
+		staff.With(RequirePermission(models.PermViewLogs)).Get("/log_files", ShowLogViewer)
+		staff.With(RequirePermission(models.PermManageTickets)).Get("/tickets", ShowTicketQueue)
+		staff.With(RequirePermission(models.PermViewUptime)).Get("/uptime", ShowServiceUptime)
+		staff.With(RequirePermission(models.PermViewAuditLog)).Get("/audit", ShowAuditLog)
+
+		staff.Route("/redteam", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermFileCompromises))
+			r.Get("/", ShowRedteamPage)
+			r.Post("/", FileCompromiseReport)
+		})
+
+		staff.Route("/compromises", func(r chi.Router) {
+			reviewCompromises := RequirePermission(models.PermReviewCompromises)
+			r.With(reviewCompromises).Get("/", ShowCompromiseQueue)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Use(RequireIdParam)
+				r.With(reviewCompromises).Post("/review", ReviewCompromiseReport)
+			})
+		})
+
+		staff.Route("/incidents", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermScoreIncidents))
+			r.Get("/", ShowIncidentQueue)
+			r.With(RequireIdParam).Post("/{id}/score", ScoreIncident)
+		})
+
+		staff.Route("/injects", func(r chi.Router) {
+			manageInjects := RequirePermission(models.PermManageInjects)
+			r.With(manageInjects).Get("/", ShowInjectsConfig)
+			r.With(manageInjects).Post("/", AddInjectFromForm)
+			r.With(manageInjects, RequireIdParam).Post("/{id}", UpdateInjectFromForm)
+
+			r.Route("/submissions", func(r chi.Router) {
+				r.Use(RequirePermission(models.PermGradeInjects))
+				r.Get("/", ShowInjectSubmissions)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(RequireIdParam)
+					r.Post("/grade", GradeInject)
+					r.Get("/files/{name}", GetInjectSubmissionFileByID)
+				})
+			})
+		})
 	})
 
 	api := chi.NewRouter()
@@ -81,7 +172,12 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 	api.Route("/public", func(public chi.Router) {
 		public.Get("/scores", GetScores)
 		public.Get("/services", GetServicesStatuses)
-		public.Handle("/scores/live", teamScoreUpdater.ServeWs())
+		public.Get("/services/history", GetServicesHistory)
+		public.Get("/services/uptime", GetServiceUptimes)
+		public.Get("/scores/history", GetScoreHistory)
+		public.Get("/scores/categories", GetScoreCategories)
+		public.Get("/scores/freeze", GetScoreboardFreeze)
+		public.Handle("/scores/live", LiveOrPublic(teamScoreUpdater.ServeWs(), publicScoreUpdater.ServeWs()))
 		public.Handle("/services/live", servicesUpdater.ServeWs())
 
 		public.Get("/ctf/solves", GetChallengeCapturesByTime)
@@ -91,6 +187,12 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 	api.Route("/blue", func(blue chi.Router) {
 		blue.Use(RequireLogin, RequireEventStarted)
 		blue.Get("/challenges", GetPublicChallenges)
+		blue.Get("/services/uptime", GetTeamServiceUptimes)
+		blue.Get("/ledger", GetTeamLedger)
+		blue.Get("/compromises", GetTeamCompromiseReports)
+		blue.Get("/incidents", GetTeamIncidentReports)
+		blue.Get("/injects", GetReleasedInjects)
+		blue.Get("/injects/submissions", GetTeamInjectSubmissions)
 		MaybeRateLimit(blue, MaxReqsPerSec).With(RequireNotOnBreak(), RequireEventNotOver).
 			Post("/challenges", SubmitFlag)
 
@@ -100,22 +202,95 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 			r.Get("/files", CtfFileMgr.GetFileList)
 			r.Get("/files/{name}", CtfFileMgr.GetFile)
 		})
+
+		blue.Route("/tickets", func(r chi.Router) {
+			r.Get("/", GetTeamTickets)
+			MaybeRateLimit(r, MaxReqsPerSec).Post("/", OpenTicket)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Use(RequireIdParam)
+				r.Get("/", GetTicketThread)
+				MaybeRateLimit(r, MaxReqsPerSec).Post("/messages", ReplyToTicket)
+			})
+		})
+	})
+
+	// Check Runner API, for remote service monitors, which authenticate with a token
+	api.Route("/runner", func(runner chi.Router) {
+		runner.Use(RequireCheckRunner)
+		runner.Get("/checks", GetRunnerChecks)
+		runner.Post("/results", SubmitRunnerResults)
 	})
 
 	// Staff API to view & edit the CTF event
 	api.Route("/staff", func(staff chi.Router) {
-		staff.Use(RequireLogin, RequireCtfStaff)
+		staff.Use(RequireLogin, RequireStaff, AuditLog)
 
 		staff.Get("/event_config", GetEventConfig)
+		staff.With(RequirePermission(models.PermViewAuditLog)).Get("/audit", GetAuditLog)
+		staff.With(RequirePermission(models.PermReviewCompromises)).Get("/compromises", GetCompromiseReports)
+		staff.With(RequirePermission(models.PermScoreIncidents)).Get("/incidents", GetIncidentReports)
+
+		staff.Route("/incident_rubric", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermScoreIncidents))
+			r.Get("/", GetIncidentRubric)
+			r.Post("/", AddIncidentRubric)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Use(RequireIdParam)
+				r.Put("/", UpdateIncidentRubric)
+				r.Delete("/", DeleteIncidentRubric)
+			})
+		})
+
+		staff.With(RequirePermission(models.PermGradeInjects)).Get("/inject_submissions", GetInjectSubmissions)
+
+		staff.Route("/injects", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermManageInjects))
+			r.Get("/", GetAllInjects)
+			r.Post("/", AddInject)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Use(RequireIdParam)
+				r.Get("/", GetInjectByID)
+				r.Put("/", UpdateInject)
+				r.Delete("/", DeleteInject)
+
+				r.Route("/files", func(r chi.Router) {
+					r.Get("/", InjectFileMgr.GetFileList)
+					r.Post("/", InjectFileMgr.SaveFile)
+					r.Get("/{name}", InjectFileMgr.GetFile)
+					r.Delete("/{name}", InjectFileMgr.DeleteFile)
+				})
+			})
+		})
+
+		staff.Route("/tickets", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermManageTickets))
+			r.Get("/", GetTicketQueue)
+
+			r.Route("/{id}", func(r chi.Router) {
+				r.Use(RequireIdParam)
+				r.Get("/", GetTicketThread)
+				r.Post("/messages", ReplyToTicket)
+				r.Put("/assign", AssignTicket)
+				r.Post("/close", CloseTicket)
+				r.Post("/reopen", ReopenTicket)
+			})
+		})
 	})
 
 	// Staff API to view & edit the CTF event
 	api.Route("/ctf", func(ctfStaff chi.Router) {
-		ctfStaff.Use(RequireLogin, RequireCtfStaff)
-		ctfStaff.Get("/stats/subs_per_flag", GetBreakdownOfSubmissionsPerFlag)
-		ctfStaff.Get("/stats/teams_flags", GetEachTeamsCapturedFlags)
+		ctfStaff.Use(RequireLogin, RequireStaff, AuditLog)
+		viewFlags := RequirePermission(models.PermViewFlags)
+		manageCtf := RequirePermission(models.PermManageCtf)
+
+		ctfStaff.With(viewFlags).Get("/stats/subs_per_flag", GetBreakdownOfSubmissionsPerFlag)
+		ctfStaff.With(viewFlags).Get("/stats/teams_flags", GetEachTeamsCapturedFlags)
 
 		ctfStaff.Route("/logs", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermViewLogs))
 			r.Get("/", LogReadOnlyMgr.GetFileList)
 			r.Get("/{name}", LogReadOnlyMgr.GetFile)
 			r.Get("/{name}/tail", WsTailFile)
@@ -124,29 +299,29 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 		// TODO / HACK: Needed a place for a late-added "add one" challenge
 		// This should be in the /flags namespace below, and the
 		// insert many should be separate, or a query param toggle
-		ctfStaff.Post("/new_flag", AddFlag)
+		ctfStaff.With(manageCtf).Post("/new_flag", AddFlag)
 
-		ctfStaff.Get("/flag", GetFlagByName)
+		ctfStaff.With(viewFlags).Get("/flag", GetFlagByName)
 
 		ctfStaff.Route("/flags", func(r chi.Router) {
-			r.Get("/", GetAllFlags)
-			r.Post("/", AddFlags) // Insert many ctf challenges
+			r.With(viewFlags).Get("/", GetAllFlags)
+			r.With(manageCtf).Post("/", AddFlags) // Insert many ctf challenges
 
 			r.Route("/{id}", func(r chi.Router) {
 				r.Use(RequireIdParam)
-				r.Get("/", GetFlagByID)
-				r.Put("/", UpdateFlag)
-				r.Delete("/", DeleteFlag)
+				r.With(viewFlags).Get("/", GetFlagByID)
+				r.With(manageCtf).Put("/", UpdateFlag)
+				r.With(manageCtf).Delete("/", DeleteFlag)
 
-				r.Post("/activate", EnableCTFChallenge)
+				r.With(manageCtf).Post("/activate", EnableCTFChallenge)
 
 				// `<host>/api/ctf/flags/4/files/suspicious.pdf`
 				r.Route("/files", func(r chi.Router) {
-					r.Get("/", CtfFileMgr.GetFileList)
-					r.Post("/", CtfFileMgr.SaveFile)
+					r.With(viewFlags).Get("/", CtfFileMgr.GetFileList)
+					r.With(manageCtf).Post("/", CtfFileMgr.SaveFile)
 
-					r.Get("/{name}", CtfFileMgr.GetFile)
-					r.Delete("/{name}", CtfFileMgr.DeleteFile)
+					r.With(viewFlags).Get("/{name}", CtfFileMgr.GetFile)
+					r.With(manageCtf).Delete("/{name}", CtfFileMgr.DeleteFile)
 				})
 			})
 		})
@@ -154,31 +329,24 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 
 	// Admin API
 	api.Route("/admin", func(admin chi.Router) {
-		admin.Use(RequireLogin, RequireAdmin)
-
-		admin.Get("/all_bonus", GetBonusPoints)
-		admin.Post("/grant_bonus", GrantBonusPoints)
+		admin.Use(RequireLogin, RequireStaff, AuditLog)
 
-		admin.Get("/team/{name}", GetTeamByName)
+		admin.Group(func(r chi.Router) {
+			r.Use(RequirePermission(models.PermGrantBonus))
+			r.Get("/all_bonus", GetBonusPoints)
+			r.Post("/grant_bonus", GrantBonusPoints)
 
-		admin.Route("/teams", func(r chi.Router) {
-			r.Get("/", GetAllTeams)
-			r.Post("/", AddTeam)
-
-			r.Route("/{id}", func(r chi.Router) {
+			r.Route("/bonus/{id}", func(r chi.Router) {
 				r.Use(RequireIdParam)
-				r.Get("/", GetTeamByID)
-				r.Put("/", UpdateTeam)
-				r.Delete("/", DeleteTeam)
+				r.Post("/revoke", RevokeBonusPoints)
+				r.Get("/files", BonusFileMgr.GetFileList)
+				r.Post("/files", BonusFileMgr.SaveFile)
+				r.Delete("/files/{name}", BonusFileMgr.DeleteFile)
 			})
 		})
 
-		admin.Route("/blueteams", func(r chi.Router) {
-			r.Get("/", GetBlueteams)  // Get all non-disabled blueteams
-			r.Post("/", AddBlueteams) // Insert many blueteams
-		})
-
 		admin.Route("/services", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermManageServices))
 			r.Get("/", GetAllServices)
 			r.Post("/", AddService) // Insert one service
 
@@ -191,6 +359,7 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 		})
 
 		admin.Route("/scripts", func(r chi.Router) {
+			r.Use(RequirePermission(models.PermRunScripts))
 			r.Get("/", ScriptMgr.GetFileList)
 			//r.Post("/", ScriptMgr.SaveFile)
 
@@ -201,6 +370,95 @@ func CreateWebRouter(teamScoreUpdater, servicesUpdater *broadcastHub) chi.Router
 				r.Post("/run", RunScriptTest)
 			})
 		})
+
+		// Everything else is for admins only
+		admin.Group(func(admin chi.Router) {
+			admin.Use(RequireAdmin)
+
+			admin.Post("/scoreboard/unfreeze", UnfreezeScoreboard)
+			admin.Post("/scoreboard/refreeze", RefreezeScoreboard)
+
+			admin.Get("/report", GetEventReport)
+
+			admin.Get("/permissions", GetPermissions)
+			admin.Put("/permissions/{role}", UpdateRolePermissions)
+
+			admin.Route("/score_categories", func(r chi.Router) {
+				r.Get("/", GetScoreCategories)
+				r.Post("/", AddScoreCategory)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(RequireIdParam)
+					r.Put("/", UpdateScoreCategory)
+					r.Delete("/", DeleteScoreCategory)
+				})
+			})
+
+			admin.Route("/hosts", func(r chi.Router) {
+				r.Get("/", GetTeamHosts)
+				r.Post("/", AddTeamHost)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(RequireIdParam)
+					r.Put("/", UpdateTeamHost)
+					r.Delete("/", DeleteTeamHost)
+				})
+			})
+
+			admin.Route("/runners", func(r chi.Router) {
+				r.Get("/", GetCheckRunners)
+				r.Post("/", AddCheckRunner)
+				r.Get("/disagreements", GetRunnerDisagreements)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(RequireIdParam)
+					r.Put("/", UpdateCheckRunner)
+					r.Delete("/", DeleteCheckRunner)
+					r.Post("/token", RotateCheckRunnerToken)
+				})
+			})
+
+			admin.Get("/team/{name}", GetTeamByName)
+
+			admin.Route("/teams", func(r chi.Router) {
+				r.Get("/", GetAllTeams)
+				r.Post("/", AddTeam)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(RequireIdParam)
+					r.Get("/", GetTeamByID)
+					r.Put("/", UpdateTeam)
+					r.Delete("/", DeleteTeam)
+					r.Post("/reset_token", CreateTeamPasswordReset)
+					r.Get("/sessions", GetTeamSessions)
+					r.Delete("/sessions", RevokeTeamSessions)
+				})
+			})
+
+			admin.Route("/players", func(r chi.Router) {
+				r.Get("/", GetAllPlayers)
+				r.Post("/", AddPlayer)
+
+				r.Route("/{id}", func(r chi.Router) {
+					r.Use(RequireIdParam)
+					r.Get("/", GetPlayerByID)
+					r.Put("/", UpdatePlayer)
+					r.Delete("/", DeletePlayer)
+					r.Post("/reset_token", CreatePlayerPasswordReset)
+					r.Delete("/sessions", RevokePlayerSessions)
+				})
+			})
+
+			admin.Route("/sessions/{id}", func(r chi.Router) {
+				r.Use(RequireIdParam)
+				r.Delete("/", RevokeSession)
+			})
+
+			admin.Route("/blueteams", func(r chi.Router) {
+				r.Get("/", GetBlueteams)  // Get all non-disabled blueteams
+				r.Post("/", AddBlueteams) // Insert many blueteams
+			})
+		})
 	})
 
 	root.Mount("/api/", api)
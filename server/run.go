@@ -31,6 +31,14 @@ func Run(cfg *Configuration) {
 
 	// Postgres setup
 	SetupPostgres(cfg.Database.URI)
+	if pending, err := PendingMigrations(db); err != nil {
+		Logger.WithError(err).Error("Failed to check for pending schema migrations")
+	} else if pending > 0 {
+		Logger.Warnf("Database is %d schema migration(s) behind, run `cyboard migrate up`", pending)
+	}
+	if err := loadScoreboardUnfrozen(db); err != nil {
+		Logger.WithError(err).Error("Failed to load whether the scoreboard was unfrozen")
+	}
 
 	// Web Server Setup
 	isHTTPS := cfg.Server.CertPath != "" && cfg.Server.CertKeyPath != ""
@@ -39,7 +47,8 @@ func Run(cfg *Configuration) {
 	EnsureAdmin(db)
 
 	teamScoreUpdater, servicesUpdater := TeamScoreWsServer(), ServiceStatusWsServer()
-	app := CreateWebRouter(teamScoreUpdater, servicesUpdater)
+	publicScoreUpdater := PublicTeamScoreWsServer()
+	app := CreateWebRouter(teamScoreUpdater, publicScoreUpdater, servicesUpdater)
 
 	// Setup http(s) server
 	sc := &cfg.Server
@@ -54,6 +63,7 @@ func Run(cfg *Configuration) {
 		TLSConfig:    tlsConfig(), // Ignored if only serving http
 	}
 	server.RegisterOnShutdown(teamScoreUpdater.Stop)
+	server.RegisterOnShutdown(publicScoreUpdater.Stop)
 	server.RegisterOnShutdown(servicesUpdater.Stop)
 	shutdownComplete := shutdownWatcher(server)
 
@@ -5,13 +5,11 @@ import (
 	"testing"
 
 	"github.com/alexedwards/scs"
-	"github.com/alexedwards/scs/stores/cookiestore"
 	"github.com/pereztr5/cyboard/server/apptest"
 )
 
 func createTestLoginStore() {
-	testSessionKey := []byte("WO-OOAH BLACK BETTY AMBERLAMPS!!")
-	sessionManager = scs.NewManager(cookiestore.New(testSessionKey))
+	sessionManager = scs.NewManager(newPGSessionStore(apptest.DB))
 	sessionManager.Name("cyboard")
 }
 
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/pereztr5/cyboard/server/models"
+)
+
+// runnerRetryInterval is how long a check runner waits to ask the server for its checks again,
+// when it couldn't reach the server.
+const runnerRetryInterval = 15 * time.Second
+
+// collector is a check runner's connection to the server, which hands out the
+// checks, and collects the results.
+type collector struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newCollector(rs *RunnerSettings) (*collector, error) {
+	tlsConfig := &tls.Config{}
+	if rs.CACert != "" {
+		pem, err := ioutil.ReadFile(rs.CACert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in runner.ca_cert=%q", rs.CACert)
+		}
+	}
+
+	return &collector{
+		url:   strings.TrimSuffix(rs.CollectorURL, "/") + "/api/runner",
+		token: rs.Token,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// collectorRejection is a request the server turned down, which won't go any better if retried.
+type collectorRejection struct {
+	err error
+}
+
+func (cr *collectorRejection) Error() string {
+	return cr.err.Error()
+}
+
+// do sends a request to the server's check runner API, decoding the JSON response into v.
+func (c *collector) do(method, path string, body, v interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		err = fmt.Errorf("%s %s: server responded %q: %s", method, path, res.Status, bytes.TrimSpace(msg))
+		if res.StatusCode < 500 {
+			return &collectorRejection{err}
+		}
+		return err
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// Checks fetches the checks the runner is assigned.
+func (c *collector) Checks() (*RunnerChecksResponse, error) {
+	assigned := &RunnerChecksResponse{}
+	return assigned, c.do(http.MethodGet, "/checks", nil, assigned)
+}
+
+// SendResults reports a round of check results to the server.
+func (c *collector) SendResults(results []models.ServiceCheck) (*RunnerResultsResponse, error) {
+	saved := &RunnerResultsResponse{}
+	return saved, c.do(http.MethodPost, "/results", &RunnerResultsRequest{Results: results}, saved)
+}
+
+// sameRunnerChecks reports whether the server's assignment is unchanged,
+// so the checks don't have to be prepared again.
+func sameRunnerChecks(a, b *RunnerChecksResponse) bool {
+	return a.AddressTemplate == b.AddressTemplate && reflect.DeepEqual(a.Checks, b.Checks)
+}
+
+// runnerRound runs every started check once, and sends the results to the server.
+func runnerRound(c *collector, checks []Check, assigned *RunnerChecksResponse, rando *rand.Rand, log *logrus.Entry) {
+	now := time.Now()
+
+	// Add unpredictability to the service checking, like the central service monitor.
+	freeTime := Int64Max(int64(assigned.Intervals-assigned.Timeout), 1)
+	jitter := time.Duration(rando.Int63n(freeTime))
+	<-time.After(jitter)
+
+	status := make(chan models.ServiceCheck)
+	started := 0
+	for i := range checks {
+		if now.After(checks[i].Service.StartsAt) {
+			go runCmd(&checks[i], now, assigned.Timeout, status)
+			started++
+		}
+	}
+	if started == 0 {
+		return
+	}
+
+	log.Infof("Running [%d] Checks. Started +jitter = %s +%v",
+		started, now.Format(time.RFC3339), jitter.Truncate(time.Millisecond))
+	results := make([]models.ServiceCheck, started)
+	for i := range results {
+		results[i] = <-status
+	}
+
+	send := func() error {
+		saved, err := c.SendResults(results)
+		if err == nil && saved.Ignored > 0 {
+			log.WithField("ignored", saved.Ignored).Warn("server ignored some results, the checks may have been reassigned")
+		}
+		return err
+	}
+	err := send()
+	if _, rejected := err.(*collectorRejection); err != nil && !rejected {
+		// Try *really hard* to not lose unrecoverable scoring data.
+		err = monitorRetryWithBackoff(send)
+	}
+	if err != nil {
+		log.WithError(err).Error("failed to send check results to the server!")
+	}
+}
+
+// RunnerRun is the `cyboard runner` command. It runs the checks the server assigns it,
+// from wherever it's started, and sends the results back to the server. The server
+// decides when checks run, so the runner keeps to the event's schedule & breaks,
+// and stops once the event is over.
+func RunnerRun(cfg *Configuration) {
+	SetupCheckServiceLogger(&cfg.Log)
+	log := Logger.WithField("thread", "runner")
+
+	c, err := newCollector(&cfg.Runner)
+	if err != nil {
+		log.WithError(err).Fatal("failed to set up the connection to the server")
+		return
+	}
+
+	sigtermC := make(chan os.Signal, 1)
+	signal.Notify(sigtermC, os.Interrupt)
+
+	rando := rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
+	var (
+		assigned *RunnerChecksResponse
+		checks   []Check
+	)
+
+	for {
+		roundStart := time.Now()
+		wait := runnerRetryInterval
+
+		latest, err := c.Checks()
+		switch {
+		case err != nil:
+			log.WithError(err).Error("failed to fetch checks from the server")
+		case latest.Over:
+			log.Info("Event is over. Done Checking Services")
+			return
+		default:
+			if assigned == nil || !sameRunnerChecks(assigned, latest) {
+				log.WithField("runner", latest.Runner).Infof("Assigned [%d] Checks", len(latest.Checks))
+				checks = prepareChecks(latest.Checks, cfg.ServiceMonitor.ChecksDir, latest.AddressTemplate)
+				if len(checks) == 0 {
+					log.Warn("No checks assigned, waiting for an admin to assign some")
+				}
+			}
+			assigned = latest
+			wait = assigned.Intervals
+
+			if assigned.Underway {
+				runnerRound(c, checks, assigned, rando, log)
+			} else {
+				log.Debug("Event isn't underway, waiting")
+			}
+		}
+
+		select {
+		case <-time.After(time.Until(roundStart.Add(wait))):
+		case <-sigtermC:
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_collector(t *testing.T) {
+	var posted RunnerResultsRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"status":"Unauthorized"}`))
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/runner/checks":
+			w.Write([]byte(`{"runner":"r1","checks":[{"team":{"id":1,"name":"team1","blueteam_ip":5,"hosts":{"web":"10.0.1.5"}},
+				"service":{"id":2,"name":"web","script":"http.sh","args":["{HOST}"],"host_role":"web"}}],
+				"address_template":"10.{TEAM_ID}.1.5","intervals":15000000000,"timeout":5000000000,"underway":true}`))
+		case "POST /api/runner/results":
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&posted))
+			w.Write([]byte(`{"scored":1,"cross_checked":0,"ignored":0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := newCollector(&RunnerSettings{CollectorURL: srv.URL + "/", Token: "tok"})
+	require.NoError(t, err)
+
+	assigned, err := c.Checks()
+	require.NoError(t, err)
+	assert.Equal(t, "r1", assigned.Runner)
+	assert.Equal(t, 15*time.Second, assigned.Intervals)
+	assert.True(t, assigned.Underway)
+	if assert.Equal(t, 1, len(assigned.Checks)) {
+		tas := assigned.Checks[0]
+		assert.Equal(t, "10.0.1.5", tas.Team.Hosts["web"])
+		assert.Equal(t, []string{"{HOST}"}, tas.Service.Args)
+		if assert.NotNil(t, tas.Service.HostRole) {
+			assert.Equal(t, "web", *tas.Service.HostRole)
+		}
+	}
+
+	results := []models.ServiceCheck{{CreatedAt: time.Now().UTC().Truncate(time.Second), TeamID: 1, ServiceID: 2,
+		Status: models.ExitStatusPartial, ExitCode: 1}}
+	saved, err := c.SendResults(results)
+	require.NoError(t, err)
+	assert.Equal(t, 1, saved.Scored)
+	assert.Equal(t, results, posted.Results)
+
+	c.token = "wrong"
+	_, err = c.Checks()
+	if assert.Error(t, err) {
+		assert.IsType(t, &collectorRejection{}, err, "Bad tokens aren't worth retrying")
+		assert.Contains(t, err.Error(), "401")
+	}
+}
+
+func Test_sameRunnerChecks(t *testing.T) {
+	a := &RunnerChecksResponse{AddressTemplate: "10.{TEAM_ID}.1.5", Checks: make([]models.MonitorTeamService, 1), Underway: true}
+	b := &RunnerChecksResponse{AddressTemplate: "10.{TEAM_ID}.1.5", Checks: make([]models.MonitorTeamService, 1)}
+	assert.True(t, sameRunnerChecks(a, b), "Only the checks matter")
+
+	b.Checks[0].Service.Args = []string{"{IP}"}
+	assert.False(t, sameRunnerChecks(a, b))
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+/* Score Categories API */
+
+type ScoreCategoryRequest struct {
+	*models.ScoreCategory
+}
+
+func (sr *ScoreCategoryRequest) Bind(r *http.Request) error {
+	if sr.ScoreCategory == nil {
+		return errors.New(`missing required 'score category' fields`)
+	}
+	return sr.Validate()
+}
+
+// GetScoreCategories lists the categories, in the order the scoreboard shows them.
+func GetScoreCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := models.AllScoreCategories(db)
+	ApiQuery(w, r, categories, err)
+}
+
+func AddScoreCategory(w http.ResponseWriter, r *http.Request) {
+	ApiCreate(w, r, &ScoreCategoryRequest{})
+}
+
+func UpdateScoreCategory(w http.ResponseWriter, r *http.Request) {
+	ApiUpdate(w, r, &ScoreCategoryRequest{})
+}
+
+func DeleteScoreCategory(w http.ResponseWriter, r *http.Request) {
+	ApiDelete(w, r, &models.ScoreCategory{})
+}
+
+/* Admin Page */
+
+// scoreCategoryFrom reads a category from the admin page's form.
+func scoreCategoryFrom(r *http.Request) (*models.ScoreCategory, error) {
+	sc := &models.ScoreCategory{
+		Name:  strings.TrimSpace(r.FormValue("name")),
+		Label: strings.TrimSpace(r.FormValue("label")),
+	}
+
+	weight, err := strconv.ParseFloat(r.FormValue("weight"), 32)
+	if err != nil {
+		return nil, errors.New("weight must be a number")
+	}
+	sc.Weight = float32(weight)
+	if s := r.FormValue("position"); s != "" {
+		if sc.Position, err = strconv.Atoi(s); err != nil {
+			return nil, errors.New("position must be a whole number")
+		}
+	}
+
+	if err = sc.Validate(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// ShowScoringConfig is where admins weight the score categories, and add their own.
+func ShowScoringConfig(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_scoring", "Scoring")
+	renderScoringConfig(w, page)
+}
+
+func renderScoringConfig(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	categories, err := models.AllScoreCategories(db)
+	page.checkErr(err, "score categories")
+	page.Data["Categories"] = categories
+	page.Data["NewCategory"] = models.ScoreCategory{Weight: 1, Position: len(categories) + 1}
+
+	renderTemplate(w, page)
+}
+
+// saveScoreCategory adds a new category, or updates the one in the url, from the admin page's form.
+func saveScoreCategory(w http.ResponseWriter, r *http.Request, update bool) {
+	page := getPage(r, "admin_scoring", "Scoring")
+	page.Data = make(map[string]interface{})
+
+	sc, err := scoreCategoryFrom(r)
+	if err != nil {
+		page.Data["Problem"] = err.Error()
+		renderScoringConfig(w, page)
+		return
+	}
+
+	if update {
+		sc.ID = getCtxIdParam(r)
+		err = sc.Update(db)
+	} else {
+		err = sc.Insert(db)
+	}
+	if err != nil {
+		page.checkErr(err, "save score category")
+		renderScoringConfig(w, page)
+		return
+	}
+
+	Logger.WithFields(logrus.Fields{"admin": page.T.Name, "category": sc.Name, "weight": sc.Weight}).
+		Info("Score category saved")
+	page.Data["Saved"] = sc.Label
+	renderScoringConfig(w, page)
+}
+
+// AddScoreCategoryFromForm adds a new category from the admin page.
+func AddScoreCategoryFromForm(w http.ResponseWriter, r *http.Request) {
+	saveScoreCategory(w, r, false)
+}
+
+// UpdateScoreCategoryFromForm re-labels or re-weights a category from the admin page.
+func UpdateScoreCategoryFromForm(w http.ResponseWriter, r *http.Request) {
+	saveScoreCategory(w, r, true)
+}
+
+// DeleteScoreCategoryFromForm removes a category from the admin page.
+// Only categories without any points in them can go.
+func DeleteScoreCategoryFromForm(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_scoring", "Scoring")
+	page.Data = make(map[string]interface{})
+
+	sc := &models.ScoreCategory{ID: getCtxIdParam(r)}
+	if err := sc.Delete(db); err != nil {
+		page.Data["Problem"] = err.Error()
+	} else {
+		Logger.WithFields(logrus.Fields{"admin": page.T.Name, "category": sc.ID}).Info("Score category deleted")
+	}
+	renderScoringConfig(w, page)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/pereztr5/cyboard/server/models"
+)
+
+// sessionCleanupInterval is how often expired sessions get deleted from postgres.
+const sessionCleanupInterval = 5 * time.Minute
+
+// pgSessionStore keeps login sessions in postgres, so they can be revoked.
+// It satisfies the scs.Store interface.
+type pgSessionStore struct {
+	db models.DB
+}
+
+func newPGSessionStore(db models.DB) *pgSessionStore {
+	return &pgSessionStore{db: db}
+}
+
+func (s *pgSessionStore) Find(token string) ([]byte, bool, error) {
+	return models.FindSession(s.db, token)
+}
+
+func (s *pgSessionStore) Save(token string, b []byte, expiry time.Time) error {
+	teamID, playerID := sessionOwner(b)
+	return models.SaveSession(s.db, token, b, expiry, teamID, playerID)
+}
+
+func (s *pgSessionStore) Delete(token string) error {
+	return models.DeleteSession(s.db, token)
+}
+
+// cleanup deletes expired sessions every so often, forever.
+func (s *pgSessionStore) cleanup(interval time.Duration) {
+	for range time.Tick(interval) {
+		if n, err := models.DeleteExpiredSessions(s.db); err != nil {
+			Logger.WithError(err).Error("session cleanup: failed to delete expired sessions")
+		} else if n > 0 {
+			Logger.WithField("count", n).Debug("session cleanup: deleted expired sessions")
+		}
+	}
+}
+
+// sessionOwner picks the team & player IDs out of encoded session data. scs saves
+// sessions as JSON, in the form `{"data": {"id": 1, "player": 3}, "deadline": ...}`.
+// Sessions that aren't logged in (or can't be read) belong to no one.
+func sessionOwner(b []byte) (teamID, playerID *int) {
+	var session struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&session); err != nil {
+		return nil, nil
+	}
+
+	getInt := func(key string) *int {
+		num, ok := session.Data[key].(json.Number)
+		if !ok {
+			return nil
+		}
+		n, err := num.Int64()
+		if err != nil {
+			return nil
+		}
+		i := int(n)
+		return &i
+	}
+	return getInt(sessionIDKey), getInt(sessionPlayerKey)
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sessionOwner(t *testing.T) {
+	teamID, playerID := sessionOwner([]byte(`{"data":{"id":1,"player":3},"deadline":1546300800000000000}`))
+	if assert.NotNil(t, teamID) && assert.NotNil(t, playerID) {
+		assert.Equal(t, 1, *teamID)
+		assert.Equal(t, 3, *playerID)
+	}
+
+	teamID, playerID = sessionOwner([]byte(`{"data":{"id":2,"flash":"hi"},"deadline":1546300800000000000}`))
+	if assert.NotNil(t, teamID) {
+		assert.Equal(t, 2, *teamID)
+	}
+	assert.Nil(t, playerID)
+
+	teamID, playerID = sessionOwner([]byte(`{"data":{},"deadline":1546300800000000000}`))
+	assert.Nil(t, teamID, "Not logged in")
+	assert.Nil(t, playerID)
+
+	teamID, _ = sessionOwner([]byte(`not json`))
+	assert.Nil(t, teamID)
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pereztr5/cyboard/server/models"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+/* Team Hosts API */
+
+type TeamHostRequest struct {
+	*models.TeamHost
+}
+
+func (hr *TeamHostRequest) Bind(r *http.Request) error {
+	if hr.TeamHost == nil {
+		return errors.New(`missing required 'team host' fields`)
+	}
+	return hr.Validate()
+}
+
+// GetTeamHosts lists every team's hosts, by team, then role.
+func GetTeamHosts(w http.ResponseWriter, r *http.Request) {
+	hosts, err := models.AllTeamHosts(db)
+	ApiQuery(w, r, hosts, err)
+}
+
+func AddTeamHost(w http.ResponseWriter, r *http.Request) {
+	ApiCreate(w, r, &TeamHostRequest{})
+}
+
+func UpdateTeamHost(w http.ResponseWriter, r *http.Request) {
+	ApiUpdate(w, r, &TeamHostRequest{})
+}
+
+func DeleteTeamHost(w http.ResponseWriter, r *http.Request) {
+	ApiDelete(w, r, &models.TeamHost{})
+}
+
+/* Admin Page */
+
+// teamHostFrom reads a host from the admin page's form.
+func teamHostFrom(r *http.Request) (*models.TeamHost, error) {
+	th := &models.TeamHost{
+		Role:    strings.TrimSpace(r.FormValue("role")),
+		Address: strings.TrimSpace(r.FormValue("address")),
+	}
+	if s := r.FormValue("team_id"); s != "" {
+		var err error
+		if th.TeamID, err = strconv.Atoi(s); err != nil {
+			return nil, errors.New("pick a team")
+		}
+	}
+
+	if err := th.Validate(); err != nil {
+		return nil, err
+	}
+	return th, nil
+}
+
+// ShowTeamHostsConfig is where admins keep each team's host inventory, which
+// fills in the `{HOST:<role>}` arguments of the service checks.
+func ShowTeamHostsConfig(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_hosts", "Team Hosts")
+	renderTeamHostsConfig(w, page)
+}
+
+func renderTeamHostsConfig(w http.ResponseWriter, page *Page) {
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+
+	hosts, err := models.AllTeamHosts(db)
+	page.checkErr(err, "team hosts")
+	page.Data["Hosts"] = hosts
+
+	teams, err := models.AllBlueteams(db)
+	page.checkErr(err, "blueteams")
+	page.Data["Teams"] = teams
+
+	services, err := models.AllServices(db)
+	page.checkErr(err, "services")
+	page.Data["Missing"] = missingTeamHosts(hosts, teams, services)
+
+	renderTemplate(w, page)
+}
+
+// missingHosts are the teams that won't be checked for a service, because they
+// don't have a host in the role it targets.
+type missingHosts struct {
+	Service string
+	Role    string
+	Teams   []string
+}
+
+func missingTeamHosts(hosts []models.TeamHostView, teams []models.BlueteamView, services []models.Service) []missingHosts {
+	has := map[int]map[string]bool{}
+	for _, h := range hosts {
+		if has[h.TeamID] == nil {
+			has[h.TeamID] = map[string]bool{}
+		}
+		has[h.TeamID][h.Role] = true
+	}
+
+	missing := []missingHosts{}
+	for _, s := range services {
+		if s.HostRole == nil || s.Disabled {
+			continue
+		}
+		m := missingHosts{Service: s.Name, Role: *s.HostRole}
+		for _, t := range teams {
+			if !has[t.ID][m.Role] {
+				m.Teams = append(m.Teams, t.Name)
+			}
+		}
+		if len(m.Teams) > 0 {
+			missing = append(missing, m)
+		}
+	}
+	return missing
+}
+
+// saveTeamHost adds a new host, or updates the one in the url, from the admin page's form.
+func saveTeamHost(w http.ResponseWriter, r *http.Request, update bool) {
+	page := getPage(r, "admin_hosts", "Team Hosts")
+	page.Data = make(map[string]interface{})
+
+	th, err := teamHostFrom(r)
+	if err == nil && !update && th.TeamID == 0 {
+		err = errors.New("pick a team")
+	}
+	if err != nil {
+		page.Data["Problem"] = err.Error()
+		renderTeamHostsConfig(w, page)
+		return
+	}
+
+	if update {
+		th.ID = getCtxIdParam(r)
+		err = th.Update(db)
+	} else {
+		err = th.Insert(db)
+	}
+	if err != nil {
+		page.checkErr(err, "save team host")
+		renderTeamHostsConfig(w, page)
+		return
+	}
+
+	Logger.WithFields(logrus.Fields{"admin": page.T.Name, "team": th.TeamID, "role": th.Role, "address": th.Address}).
+		Info("Team host saved")
+	page.Data["Saved"] = th.Role
+	renderTeamHostsConfig(w, page)
+}
+
+// AddTeamHostFromForm adds a host to a team's inventory from the admin page.
+func AddTeamHostFromForm(w http.ResponseWriter, r *http.Request) {
+	saveTeamHost(w, r, false)
+}
+
+// UpdateTeamHostFromForm changes a host's role or address from the admin page.
+func UpdateTeamHostFromForm(w http.ResponseWriter, r *http.Request) {
+	saveTeamHost(w, r, true)
+}
+
+// DeleteTeamHostFromForm removes a host from the admin page.
+func DeleteTeamHostFromForm(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_hosts", "Team Hosts")
+	page.Data = make(map[string]interface{})
+
+	th := &models.TeamHost{ID: getCtxIdParam(r)}
+	if err := th.Delete(db); err != nil {
+		page.checkErr(err, "delete team host")
+	} else {
+		Logger.WithFields(logrus.Fields{"admin": page.T.Name, "host": th.ID}).Info("Team host deleted")
+	}
+	renderTeamHostsConfig(w, page)
+}
@@ -20,10 +20,13 @@ func buildHelperMap() template.FuncMap {
 		"fmtDuration":  fmtDuration,
 		"fmtDateInput": fmtDateForInputField,
 		"fmtTimeInput": fmtTimeForInputField,
+		"deref":        derefInt,
+		"percent":      percentOf,
 
 		// App-specific helpers
 		"isAdmin":    isAdmin,
-		"isCtfStaff": isCtfStaff,
+		"isStaff":    isStaff,
+		"can":        canDo,
 		"isBlueteam": isBlueteam,
 	}
 }
@@ -48,21 +51,36 @@ func fmtTimeForInputField(t time.Time) string {
 	return t.Format("15:04")
 }
 
+func derefInt(i *int) int {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+// percentOf is x as a percent of max, clamped to [0, 100]. Used to size bar charts.
+func percentOf(x, max int) int {
+	if max <= 0 || x <= 0 {
+		return 0
+	} else if x >= max {
+		return 100
+	}
+	return x * 100 / max
+}
+
 func isAdmin(t *models.Team) bool {
 	return t != nil && t.RoleName == models.TeamRoleAdmin
 }
 
-func isCtfStaff(t *models.Team) bool {
-	if t == nil {
-		return false
-	}
+// isStaff is true for anyone running the event, in any role but blueteam.
+// It only picks which pages to show; what a role may do is up to hasPermission.
+func isStaff(t *models.Team) bool {
+	return t != nil && t.RoleName != models.TeamRoleBlueteam
+}
 
-	switch t.RoleName {
-	case models.TeamRoleAdmin, models.TeamRoleCtfCreator:
-		return true
-	default:
-		return false
-	}
+// canDo checks a team's permission by name, for templates: `{{ if can .T "view_logs" }}`
+func canDo(t *models.Team, permission string) bool {
+	return hasPermission(t, models.Permission(permission))
 }
 
 func isBlueteam(t *models.Team) bool {
@@ -2,6 +2,7 @@ package server
 
 import (
 	"html/template"
+	"net"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -12,10 +13,11 @@ import (
 )
 
 type Page struct {
-	File  string       // Template file (with suffix trimmed)
-	Title string       // Visible page name
-	T     *models.Team // Viewer Context
-	Error error        // Cause, if any, of rendering failure
+	File  string         // Template file (with suffix trimmed)
+	Title string         // Visible page name
+	T     *models.Team   // Viewer Context
+	P     *models.Player // Viewer's player account, if they didn't use the team login
+	Error error          // Cause, if any, of rendering failure
 
 	Data map[string]interface{} // Page-specific data
 }
@@ -25,6 +27,7 @@ func getPage(r *http.Request, templateFile, title string) *Page {
 	page := &Page{File: templateFile, Title: title}
 	if team != nil {
 		page.T = team
+		page.P = getCtxPlayer(r)
 	}
 	return page
 }
@@ -109,13 +112,61 @@ func ShowLogin(w http.ResponseWriter, r *http.Request) {
 
 func ShowTeamDashboard(w http.ResponseWriter, r *http.Request) {
 	page := getPage(r, "dashboard", "Dashboard")
+	renderTeamDashboard(w, r, page)
+}
+
+func renderTeamDashboard(w http.ResponseWriter, r *http.Request, page *Page) {
 	team := page.T
-	page.Data = make(map[string]interface{})
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
 
 	var err error
 	if isBlueteam(page.T) {
 		page.Data["ctfProgress"], err = models.GetTeamCTFProgress(db, team.ID)
 		page.checkErr(err, "ctf progress")
+
+		page.Data["Tickets"], err = models.TeamTickets(db, team.ID)
+		page.checkErr(err, "team tickets")
+		page.Data["TicketCategories"] = models.TicketCategories
+
+		page.Data["Uptimes"], err = models.TeamServiceUptimes(db, team.ID, appCfg.Event.Start, time.Now())
+		page.checkErr(err, "team service uptimes")
+
+		page.Data["PlayerStats"], err = models.TeamPlayerStats(db, team.ID)
+		page.checkErr(err, "player stats")
+
+		compromises, err := models.TeamCompromiseReports(db, team.ID)
+		page.checkErr(err, "team compromise reports")
+		page.Data["Compromises"] = withEvidence(compromises)
+
+		page.Data["Incidents"], err = models.TeamIncidentReports(db, team.ID)
+		page.checkErr(err, "team incident reports")
+		page.Data["IncidentRubric"], err = models.AllIncidentRubric(db)
+		page.checkErr(err, "incident rubric")
+
+		page.Data["Ledger"], err = teamLedgerSection(r, team.ID)
+		page.checkErr(err, "team score ledger")
+	} else if isAdmin(page.T) {
+		page.Data["ScoreboardFreeze"] = getScoreboardFreezeStatus()
+	}
+
+	renderTemplate(w, page)
+}
+
+// ShowTeamReport is a printable summary of the event for the logged in blue team.
+func ShowTeamReport(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "team_report", "Team Report")
+	page.Data = make(map[string]interface{})
+
+	if isBlueteam(page.T) {
+		asOf := time.Now()
+		if scoreboardFrozenFor(r) {
+			asOf = appCfg.Event.FreezeAt
+		}
+		cards, err := BuildTeamScorecards(db, &page.T.ID, asOf)
+		page.checkErr(err, "team scorecard")
+		page.Data["Scorecards"] = cards
 	}
 
 	renderTemplate(w, page)
@@ -141,14 +192,20 @@ func ShowScoreboard(w http.ResponseWriter, r *http.Request) {
 		page = getPage(r, "noscript_scoreboard", "Scoreboard")
 		page.Data = make(map[string]interface{})
 
-		page.Data["TeamsScores"], err = models.TeamsScores(db)
+		page.Data["TeamsScores"], err = teamsScoresFor(r)
 		page.checkErr(err, "team scores")
+		page.Data["Categories"], err = models.AllScoreCategories(db)
+		page.checkErr(err, "score categories")
+	}
+
+	if scoreboardFrozenFor(r) {
+		page.Data["FrozenAt"] = appCfg.Event.FreezeAt
 	}
 
 	page.Data["Teams"], err = models.AllBlueteams(db)
 	page.checkErr(err, "all blue teams")
 
-	page.Data["Statuses"], err = models.TeamServiceStatuses(db)
+	page.Data["Statuses"], err = models.TeamServiceStatusesWithHistory(db, serviceHistoryRounds, appCfg.ServiceMonitor.Intervals)
 	page.checkErr(err, "all teams' service statuses")
 
 	renderTemplate(w, page)
@@ -162,7 +219,7 @@ func ShowServices(w http.ResponseWriter, r *http.Request) {
 	page.Data["Teams"], err = models.AllBlueteams(db)
 	page.checkErr(err, "all blue teams")
 
-	page.Data["Statuses"], err = models.TeamServiceStatuses(db)
+	page.Data["Statuses"], err = models.TeamServiceStatusesWithHistory(db, serviceHistoryRounds, appCfg.ServiceMonitor.Intervals)
 	page.checkErr(err, "all teams' service statuses")
 
 	renderTemplate(w, page)
@@ -202,6 +259,50 @@ func ShowLogViewer(w http.ResponseWriter, r *http.Request) {
 	renderTemplate(w, page)
 }
 
+func ShowTicketQueue(w http.ResponseWriter, r *http.Request) {
+	var err error
+	page := getPage(r, "staff_tickets", "Support Tickets")
+	page.Data = make(map[string]interface{})
+
+	page.Data["Tickets"], err = models.AllTickets(db, true)
+	page.checkErr(err, "all tickets")
+	page.Data["Staff"], err = models.AllStaff(db)
+	page.checkErr(err, "all staff")
+
+	renderTemplate(w, page)
+}
+
+func ShowServiceUptime(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_uptime", "Service Uptime")
+	page.Data = make(map[string]interface{})
+
+	start, end, err := parseTimeWindow(r)
+	page.checkErr(err, "time window")
+	if err == nil {
+		page.Data["Uptimes"], err = models.ServiceUptimes(db, start, end)
+		page.checkErr(err, "all service uptimes")
+	}
+	page.Data["Start"], page.Data["End"] = start, end
+
+	renderTemplate(w, page)
+}
+
+// ShowAuditLog lists the changes staff have made, filtered by the query params.
+func ShowAuditLog(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "staff_audit", "Audit Log")
+	page.Data = make(map[string]interface{})
+
+	f, err := auditFilterFrom(r)
+	page.checkErr(err, "audit log filter")
+	if err == nil {
+		page.Data["Entries"], err = models.AuditEntries(db, f)
+		page.checkErr(err, "audit log entries")
+	}
+	page.Data["Filter"] = f
+
+	renderTemplate(w, page)
+}
+
 /* Admin Pages */
 
 func ShowTeamsConfig(w http.ResponseWriter, r *http.Request) {
@@ -209,7 +310,31 @@ func ShowTeamsConfig(w http.ResponseWriter, r *http.Request) {
 
 	teams, err := models.AllTeams(db)
 	page.checkErr(err, "all teams")
-	page.Data = M{"Teams": teams}
+
+	// Each blue team's address, as the service monitor will resolve it for the {IP} check arg.
+	// Teams the template doesn't give a valid address are flagged, as their {IP} checks are skipped.
+	mon := &appCfg.ServiceMonitor
+	addresses, badAddresses := map[int]string{}, map[int]bool{}
+	for _, t := range teams {
+		if t.BlueteamIP != nil {
+			addresses[t.ID] = mon.TeamAddress(t.ID, *t.BlueteamIP)
+			badAddresses[t.ID] = net.ParseIP(addresses[t.ID]) == nil
+		}
+	}
+	page.Data = M{"Teams": teams, "Addresses": addresses, "BadAddresses": badAddresses,
+		"AddressTemplate": mon.TeamAddressTemplate()}
+	renderTemplate(w, page)
+}
+
+func ShowPlayersConfig(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "admin_players_cfg", "Admin Players")
+	page.Data = make(map[string]interface{})
+
+	var err error
+	page.Data["Players"], err = models.AllPlayers(db)
+	page.checkErr(err, "all players")
+	page.Data["Teams"], err = models.AllTeams(db)
+	page.checkErr(err, "all teams")
 	renderTemplate(w, page)
 }
 
@@ -242,6 +367,18 @@ func ShowServiceScriptsConfig(w http.ResponseWriter, r *http.Request) {
 	renderTemplate(w, page)
 }
 
+// ShowAllTeamReports prints every blue team's report, one per page, to hand out.
+func ShowAllTeamReports(w http.ResponseWriter, r *http.Request) {
+	page := getPage(r, "team_report", "Team Reports")
+	page.Data = make(map[string]interface{})
+
+	cards, err := BuildTeamScorecards(db, nil, time.Now())
+	page.checkErr(err, "all team scorecards")
+	page.Data["Scorecards"] = cards
+
+	renderTemplate(w, page)
+}
+
 func ShowBonusPage(w http.ResponseWriter, r *http.Request) {
 	var err error
 	page := getPage(r, "staff_bonus", "Bonuses")
@@ -249,8 +386,12 @@ func ShowBonusPage(w http.ResponseWriter, r *http.Request) {
 
 	page.Data["Blueteams"], err = models.AllBlueteams(db)
 	page.checkErr(err, "all blue teams")
-	page.Data["Bonus"], err = models.AllBonusPoints(db)
+	page.Data["Categories"], err = models.AllScoreCategories(db)
+	page.checkErr(err, "score categories")
+	page.Data["Kinds"] = models.BonusKinds
+	bonuses, err := models.AllBonusPoints(db)
 	page.checkErr(err, "all bonus points")
+	page.Data["Bonus"] = bonusListings(bonuses)
 
 	renderTemplate(w, page)
 }
@@ -19,6 +19,9 @@ const (
 
 	// Send pings to clients with this period. If missed, the client is dropped.
 	pingPeriod = 10 * time.Second
+
+	// Number of service monitor runs to include in the service status history.
+	serviceHistoryRounds = 12
 )
 
 type timeCheckFn func(db models.DB) (time.Time, error)
@@ -205,7 +208,7 @@ func ServiceStatusWsServer() *broadcastHub {
 		"service status",
 		models.LatestServiceCheckRun,
 		func(db models.DB) (interface{}, error) {
-			res, err := models.TeamServiceStatuses(db)
+			res, err := models.TeamServiceStatusesWithHistory(db, serviceHistoryRounds, appCfg.ServiceMonitor.Intervals)
 			return res, err
 		},
 	)
@@ -214,13 +217,36 @@ func ServiceStatusWsServer() *broadcastHub {
 }
 
 // TeamScoreWsServer is a hub suitable for updating the Scoreboard charts & tables.
+// It always sends the live scores, so it should only be served to staff while the
+// scoreboard is frozen. See PublicTeamScoreWsServer.
 func TeamScoreWsServer() *broadcastHub {
 	b := NewBroadcastHub(
 		"team scores",
 		models.LatestScoreChange,
 		func(db models.DB) (interface{}, error) {
-			res, err := models.TeamsScores(db)
-			return res, err
+			return rankScores(models.TeamsScores(db))
+		},
+	)
+	go b.Start()
+	return b
+}
+
+// PublicTeamScoreWsServer is like TeamScoreWsServer, but holds the scores still
+// while the scoreboard is frozen. Once unfrozen, the live scores are sent out.
+func PublicTeamScoreWsServer() *broadcastHub {
+	b := NewBroadcastHub(
+		"public team scores",
+		func(db models.DB) (time.Time, error) {
+			if scoreboardFrozen() {
+				return appCfg.Event.FreezeAt, nil
+			}
+			return models.LatestScoreChange(db)
+		},
+		func(db models.DB) (interface{}, error) {
+			if scoreboardFrozen() {
+				return rankScores(models.TeamsScoresAsOf(db, appCfg.Event.FreezeAt))
+			}
+			return rankScores(models.TeamsScores(db))
 		},
 	)
 	go b.Start()
@@ -1,21 +1,51 @@
-const SCOREBOARD_CATEGORIES = ["Service", "CTF", "Other"];
+// The score categories (name, label, weight), in display order. Admins can add more,
+// so they're fetched from `/api/public/scores/categories` when the page loads.
+let scoreboard_categories = [];
 
-function build_hc_series(scores, categories = SCOREBOARD_CATEGORIES) {
+// Set when the scoreboard is frozen (see `event.freeze_at` in the server config).
+// Staff always get live scores, so this is never set for them.
+let scoreboard_frozen_at = $('#hc_scoreboard').data('frozen-at');
+
+function scoreboard_subtitle() {
+    if (scoreboard_frozen_at) {
+        const at = new Date(scoreboard_frozen_at).toLocaleTimeString();
+        return { text: `Scoreboard frozen at ${at}. Final results at the awards!` };
+    }
+    return { text: '(Updates automatically)' };
+}
+
+function build_hc_series(scores, categories = scoreboard_categories) {
     return categories.map(cat => {
-        const cat_l = cat.toLowerCase();
         return {
-            id: cat,
-            name: cat,
-            data: scores.map(row => row[cat_l]),
+            id: cat.name,
+            name: cat.label,
+            data: scores.map(row => row.categories[cat.name] || 0),
         };
     });
 }
 
+// Label each team's column with its rank, which takes the tiebreakers into account.
+function team_labels(scores) {
+    return scores.map(row => `#${row.rank} ${row.name}`);
+}
+
+// Set each category's bars, adding any category the chart doesn't have yet.
+function set_hc_series(chart, scores) {
+    build_hc_series(scores).forEach(series => {
+        const existing = chart.get(series.id);
+        if (existing) {
+            existing.setData(series.data, false);
+        } else {
+            chart.addSeries(series, false);
+        }
+    });
+}
+
 function build_hc_cfg(series, teams) {
     return {
         chart: { type: 'column' },
         title: { text: 'Team Scores' },
-        subtitle: { text: '(Updates automatically)' },
+        subtitle: scoreboard_subtitle(),
         // xAxis is the team names along the bottom
         xAxis: {
             type: 'category',
@@ -141,9 +171,10 @@ function hc_xax_fsize(em) {
 
 // Get initial chart data, set up columns for teams
 function init_scoreboard() {
-    $.getJSON( '/api/public/scores' )
-    .done(function(scores) {
-        const teams = [...new Set(scores.map(row => row.name))];
+    $.when($.getJSON('/api/public/scores/categories'), $.getJSON('/api/public/scores'))
+    .done(function([categories], [scores]) {
+        scoreboard_categories = categories;
+        const teams = team_labels(scores);
         const hc_series = build_hc_series(scores);
 
         const hc_cfg = build_hc_cfg(hc_series, teams);
@@ -159,7 +190,19 @@ function init_scoreboard_updater_ws() {
     const conn = new WebSocket(endpoint);
     conn.onmessage = function(evt) {
         const results = JSON.parse(evt.data);
-        sync_scoreboard(results);
+        if (!scoreboard_frozen_at) {
+            sync_scoreboard(results);
+            return;
+        }
+
+        // The frozen feed only sends scores again once an admin unfreezes it
+        $.getJSON('/api/public/scores/freeze').done(status => {
+            if (status.frozen) {
+                sync_scoreboard(results);
+            } else {
+                reveal_scoreboard(results);
+            }
+        });
     };
     conn.onclose = function(evt) {
         const chart = $('#hc_scoreboard').highcharts();
@@ -173,12 +216,30 @@ function init_scoreboard_updater_ws() {
 
 function sync_scoreboard(res) {
     const chart = $('#hc_scoreboard').highcharts();
-    build_hc_series(res).forEach(series => {
-        chart.get(series.id).setData(series.data, false);
-    });
+    chart.xAxis[0].setCategories(team_labels(res), false);
+    set_hc_series(chart, res);
     chart.redraw();
 }
 
+// Drumroll please: drop every bar to zero, then slowly raise them to the final scores.
+function reveal_scoreboard(res) {
+    const chart = $('#hc_scoreboard').highcharts();
+    scoreboard_frozen_at = undefined;
+
+    chart.series.forEach(series => {
+        series.setData(series.data.map(() => 0), false);
+    });
+    chart.setSubtitle({ text: 'And the final scores are...' }, false);
+    chart.redraw({ duration: 1000 });
+
+    setTimeout(() => {
+        chart.xAxis[0].setCategories(team_labels(res), false);
+        set_hc_series(chart, res);
+        chart.setSubtitle({ text: 'Final Scores!' }, false);
+        chart.redraw({ duration: 6000, easing: 'easeOutBounce' });
+    }, 3000);
+}
+
 $(function () {
     init_scoreboard();
     init_scoreboard_updater_ws();
@@ -0,0 +1,5 @@
+// Inject descriptions are written in markdown
+$('.inject-description').each(function() {
+    const $desc = $(this);
+    $desc.html(marked($desc.text()));
+});
@@ -48,6 +48,7 @@ function syncServices(data) {
         "service_id":1,
         "service_name":"WWW Content",
         "statuses": ["partial", "pass", ...],
+        "history": [["pass", "fail", "partial"], ["pass", "", "pass"], ...], // "" is a missed round
      },
      {...}, ...]
     */
@@ -67,14 +68,14 @@ function syncServices(data) {
 
     let i, j;
     for (i=j=0; i < $serviceRows.length && j < data.length;) {
-        const {service_id, service_name, statuses} = data[j];
+        const {service_id, service_name, statuses, history} = data[j];
 
         const $sRow = $($serviceRows[i]);
         const domServiceID = $sRow.data('check');
 
         if (domServiceID > service_id) {
             // insert service row
-            const $newService = newServiceRow(service_id, service_name, statuses);
+            const $newService = newServiceRow(service_id, service_name, statuses, history);
             $sRow.before($newService);
             j++;
         } else if (domServiceID < service_id) {
@@ -84,6 +85,7 @@ function syncServices(data) {
         } else {
             // update service row
             updateServiceStatusBoxes($sRow, statuses);
+            updateServiceHistory($sRow, history);
             i++; j++;
         }
     }
@@ -96,14 +98,14 @@ function syncServices(data) {
     }
 
     for (; j < data.length; j++) {
-        const {service_id, service_name, statuses} = data[j];
+        const {service_id, service_name, statuses, history} = data[j];
         $serviceDisplay.append(
-            newServiceRow(service_id, service_name, statuses)
+            newServiceRow(service_id, service_name, statuses, history)
         );
     }
 }
 
-function newServiceRow(id, name, statuses) {
+function newServiceRow(id, name, statuses, history) {
     const $row = $(`<div class="sv-row"></div>`).attr('data-check', id);
     $row.append( $(`<div class="sq sq-label sq-service"></div>`).text(name) );
 
@@ -114,11 +116,27 @@ function newServiceRow(id, name, statuses) {
     });
     $row.append($boxes);
     updateServiceStatusBoxes($row, statuses);
+    updateServiceHistory($row, history);
     return $row;
 }
 
+function updateServiceHistory($serviceRow, history) {
+    if (!history) {
+        return;
+    }
+    $serviceRow.children('.sq').not('.sq-label').each((idx, sq) => {
+        let $strip = $(sq).children('.sv-history');
+        if ($strip.length === 0) {
+            $strip = $(`<div class="sv-history"></div>`).appendTo(sq);
+        }
+        $strip.empty().append((history[idx] || []).map(status =>
+            $(`<span class="sv-tick"></span>`).attr('data-status', status)
+        ));
+    });
+}
+
 function updateServiceStatusBoxes($serviceRow, statuses) {
-    const $statusBoxes = $serviceRow.find("span[data-status]");
+    const $statusBoxes = $serviceRow.find("span.fa[data-status]");
     statuses.forEach((status, idx) => {
         const $statusBox = $($statusBoxes[idx]);
 
@@ -8,18 +8,49 @@ $bonusAwardPanel.children('form').on('submit', function submitBonusPoints(event)
 
     // Parse form data into JSON
     const data = {};
-    ["points", "reason"].forEach(field => {
+    ["points", "reason", "note"].forEach(field => {
         data[field] = findInput(field).val();
     });
     data.points = parseInt(data.points, 10);
+    data.category = $form.find(`select[name=category]`).val();
+    const kind = $form.find(`select[name=kind]`).val();
+    if (kind) {
+        data.kind = kind;
+    }
     data.teams = $form.find(`select[name=teams]`).val().map(pts => parseInt(pts, 10));
 
+    // Attachments are uploaded to each grant, once they've been made
+    const files = findInput('upload').get(0).files;
+    const attach = (id) => {
+        const upload = new FormData();
+        $.each(files, (_, f) => upload.append('upload', f));
+        return $.ajax({
+            url: `/api/admin/bonus/${id}/files`,
+            data: upload,
+            method: "POST",
+            contentType: false,
+            processData: false,
+        });
+    };
+
     const url = `/api/admin/grant_bonus`;
-    ajaxJSON('POST', url, data).done(() => {
+    ajaxJSON('POST', url, data).then((ids) => {
+        return files.length ? $.when(...ids.map(attach)) : ids;
+    }).done(() => {
         $form.trigger('reset');
-        const msg = `${data.teams.length} teams awarded '${data.points}' points!`;
-        alert(msg);
+        alert(`${data.teams.length} teams awarded '${data.points}' points! Page will reload.`);
+        window.location.reload();
     }).fail((xhr) => {
         alert(getXhrErr(xhr));
     });
 });
+
+/* Revoke a grant. It stays listed, but stops counting. */
+$('.bonus-list').on('click', '.btn-revoke', function revokeBonusPoints(event) {
+    const $row = $(event.currentTarget).closest('tr');
+    const id = $row.data('id');
+    if (!confirm(`Revoke grant #${id}? The team's score drops by its points, from now on.`)) {
+        return;
+    }
+    ajaxAndReload('POST', `/api/admin/bonus/${id}/revoke`, null, `Grant #${id} revoked.`);
+});
@@ -0,0 +1,126 @@
+// Global DOM references to the players table and editor modal.
+// The modal gets reused for both adding a new player and editing an existing one.
+const $modal = $('#player-edit-modal');
+const $cfgTable = $('.player-config-table');
+
+/* Show modal editor */
+$cfgTable.on('click', '.btn-edit', function showPlayerEditorModal(event) {
+    const $row = $(event.currentTarget).parentsUntil('tr').parent();
+
+    const $form = $modal.find('form');
+    const findInput = (name) => $form.find(`input[name=${name}]`);
+
+    const $cells = $row.children();
+    const cellText = (idx) => $cells.eq(idx).text();
+
+    $modal.find('.modal-title').text(`Edit "${cellText(1)}"`);
+
+    findInput("id").val(cellText(0));
+    findInput("name").val(cellText(1));
+    $form.find("select[name=team_id]").val($row.data('team-id'));
+
+    const isDisabled = $cells.eq(4).children().length > 0;
+    findInput("disabled").prop('checked', isDisabled);
+
+    // Always reset the password field.
+    findInput("password").val('');
+
+    $modal.modal('show');
+});
+
+/* When showing the modal, hide the "Delete" button if the modal is being used to create. */
+$modal.on('show.bs.modal', function(event) {
+    const isNewPlayer = $(event.relatedTarget).hasClass("btn-add-player");
+    $modal.find('form').find('.delete-player, .reset-link-player, .logout-player').toggle(!isNewPlayer);
+});
+
+/* Parse the modal form into a PlayerModRequest for the server. */
+function playerFormAsJson($form) {
+    const findInput = (name) => $form.find(`input[name=${name}], select[name=${name}]`);
+    const strInput = (name) => findInput(name).val();
+    const intInput = (name) => parseInt(findInput(name).val(), 10);
+
+    const data = {
+        id: intInput("id"),
+        name: strInput("name"),
+        team_id: intInput("team_id"),
+        disabled: findInput("disabled").prop("checked"),
+    };
+
+    // "password" field should only be included if it's meant to change.
+    // Empty passwords are rejected by the server.
+    const pass = strInput("password");
+    if(pass !== "") {
+        data.password = pass;
+    }
+
+    return data;
+}
+
+/* Submit new/editted Player */
+$modal.find('form').on('submit', function savePlayer(event) {
+    event.preventDefault();
+    const data = playerFormAsJson($(this));
+
+    const isNewPlayer = data.id === -1;
+    if(isNewPlayer) {
+        delete data.id;
+        ajaxAndReload('POST', `/api/admin/players`, data, `${data.name} created!`);
+    } else {
+        ajaxAndReload('PUT', `/api/admin/players/${data.id}`, data, `${data.name} updated!`);
+    }
+});
+
+/* Delete Player */
+$modal.find('form').on('click', '.delete-player', function deletePlayer(event) {
+    const $form = $(event.delegateTarget);
+    const id = $form.find("input[name=id]").val();
+    const name = $form.find("input[name=name]").val();
+
+    if(confirm(`Are you sure you want to delete "${name}"? Their solves stay with the team.`)) {
+        ajaxJSON('DELETE', `/api/admin/players/${id}`).then(() => {
+            $cfgTable.find(`tbody [data-player-id=${id}]`).remove();
+            $modal.modal('hide');
+        }).catch((xhr) => {
+            alert(getXhrErr(xhr));
+        });
+    }
+});
+
+/* Make a one-time password reset link */
+$modal.find('form').on('click', '.reset-link-player', function createResetLink(event) {
+    const $form = $(event.delegateTarget);
+    const id = $form.find("input[name=id]").val();
+    const name = $form.find("input[name=name]").val();
+
+    ajaxJSON('POST', `/api/admin/players/${id}/reset_token`).then((resp) => {
+        const expires = new Date(resp.expires_at).toLocaleString();
+        prompt(`Password reset link for "${name}" (works once, until ${expires}):`, resp.url);
+    }).catch((xhr) => {
+        alert(getXhrErr(xhr));
+    });
+});
+
+/* Log the player out everywhere */
+$modal.find('form').on('click', '.logout-player', function revokePlayerSessions(event) {
+    const $form = $(event.delegateTarget);
+    const id = $form.find("input[name=id]").val();
+    const name = $form.find("input[name=name]").val();
+
+    if(confirm(`Log "${name}" out everywhere?`)) {
+        ajaxJSON('DELETE', `/api/admin/players/${id}/sessions`).then((resp) => {
+            alert(`Logged out ${resp.revoked} session(s).`);
+        }).catch((xhr) => {
+            alert(getXhrErr(xhr));
+        });
+    }
+});
+
+/* Add new player, button below the table */
+$('.btn-add-player').on('click', function showPlayerAddModal(event) {
+    const $form = $modal.find('form');
+    $form.trigger('reset');
+
+    $modal.find('.modal-title').text("Add new player");
+    $modal.find('input[name=id]').val("-1");
+});
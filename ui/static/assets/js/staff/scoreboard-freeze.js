@@ -0,0 +1,8 @@
+// Reveal (or re-hide) the final scores on the public scoreboard.
+$('.btn-scoreboard-freeze').on('click', function(event) {
+    const action = $(event.currentTarget).data('action');
+    if (action === 'unfreeze' && !confirm("Reveal the final scores on the public scoreboard?")) {
+        return;
+    }
+    ajaxAndReload('POST', `/api/admin/scoreboard/${action}`, undefined, `Scoreboard ${action} complete.`);
+});
@@ -30,6 +30,7 @@ $cfgTable.on('click', '.btn-edit', function showServiceEditorModal(event) {
         // Quote all args because there's only one text input and this is
         // the safest way to maintain the hacky arg parsing.
         findInput("args").val(srv.args.map(s => `"${s}"`).join(" "));
+        findInput("host_role").val(srv.host_role || "");
         findInput("disabled").prop('checked', srv.disabled);
 
         // Decompose start time into two separate inputs, because between
@@ -70,6 +71,7 @@ function serviceFormAsJson($form) {
         total_points: floatInput("total_points"),
         script: strInput("script"),
         args: splitArgs(strInput("args")),
+        host_role: strInput("host_role").trim() || null,
         disabled: findInput("disabled").prop("checked"),
     };
 
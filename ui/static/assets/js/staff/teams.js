@@ -31,7 +31,7 @@ $cfgTable.on('click', '.btn-edit', function showTeamEditorModal(event) {
     findInput("name").val(cellText(1));
     findInput("blueteam_ip").val(cellText(3));
 
-    const isDisabled = $cells.eq(4).children().length > 0;
+    const isDisabled = $cells.eq(5).children().length > 0;
     findInput("disabled").prop('checked', isDisabled);
 
     // Always reset the password field.
@@ -54,7 +54,7 @@ $modal.find('form').on('change', 'select[name=role_name]', function(event) {
 /* When showing the modal, hide the "Delete" button if the modal is being used to create. */
 $modal.on('show.bs.modal', function(event) {
     const isNewTeam = $(event.relatedTarget).hasClass("btn-add-team");
-    $modal.find('form').find('.delete-team').toggle(!isNewTeam);
+    $modal.find('form').find('.delete-team, .reset-link-team, .sessions-team').toggle(!isNewTeam);
 });
 
 
@@ -122,6 +122,46 @@ $modal.find('form').on('click', '.delete-team', function deleteTeam(event) {
 });
 
 
+/* Make a one-time password reset link */
+$modal.find('form').on('click', '.reset-link-team', function createResetLink(event) {
+    const $form = $(event.delegateTarget);
+    const id = $form.find("input[name=id]").val();
+    const name = $form.find("input[name=name]").val();
+
+    ajaxJSON('POST', `/api/admin/teams/${id}/reset_token`).then((resp) => {
+        const expires = new Date(resp.expires_at).toLocaleString();
+        prompt(`Password reset link for "${name}" (works once, until ${expires}):`, resp.url);
+    }).catch((xhr) => {
+        alert(getXhrErr(xhr));
+    });
+});
+
+/* List the team's active sessions, and offer to log them all out */
+$modal.find('form').on('click', '.sessions-team', function revokeTeamSessions(event) {
+    const $form = $(event.delegateTarget);
+    const id = $form.find("input[name=id]").val();
+    const name = $form.find("input[name=name]").val();
+    const url = `/api/admin/teams/${id}/sessions`;
+
+    $.getJSON(url).then((sessions) => {
+        if(sessions.length === 0) {
+            alert(`Nobody is logged in to "${name}".`);
+            return;
+        }
+        const lines = sessions.map(s => {
+            const who = s.player_name || "(team login)";
+            return `  ${who}, last active ${new Date(s.modified_at).toLocaleString()}`;
+        });
+        if(confirm(`"${name}" has ${sessions.length} active session(s):\n${lines.join('\n')}\n\nLog them all out?`)) {
+            return ajaxJSON('DELETE', url).then((resp) => {
+                alert(`Logged out ${resp.revoked} session(s).`);
+            });
+        }
+    }).catch((xhr) => {
+        alert(getXhrErr(xhr));
+    });
+});
+
 /* Add new team, button below the table */
 $('.btn-add-team').on('click', function showTeamAddModal(event) {
     const $form = $modal.find('form');
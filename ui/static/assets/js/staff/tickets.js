@@ -0,0 +1,32 @@
+// Staff controls for the ticket queue: assignment, closing, and reopening.
+// Reading and replying to threads is handled in `assets/js/tickets.js`.
+const $ticketTable = $('.ticket-table');
+const ticketRowID = ($el) => $el.closest('tr').data('ticket-id');
+
+(function initClosedTicketToggle() {
+    const $toggle = $('#show-closed-tickets');
+    const apply = () => $ticketTable.find('tr.ticket-closed').toggle($toggle.prop('checked'));
+    $toggle.on('change', apply);
+    apply();
+})();
+
+$ticketTable.on('change', '.ticket-assignee', function assignTicket(event) {
+    const $select = $(event.currentTarget);
+    const id = ticketRowID($select);
+    const assignee = $select.val();
+    const data = { assignee_id: assignee === "" ? null : parseInt(assignee, 10) };
+
+    ajaxJSON('PUT', `/api/staff/tickets/${id}/assign`, data).fail((xhr) => {
+        alert(getXhrErr(xhr));
+    });
+});
+
+$ticketTable.on('click', '.btn-close-ticket', function closeTicket(event) {
+    const id = ticketRowID($(event.currentTarget));
+    ajaxAndReload('POST', `/api/staff/tickets/${id}/close`, undefined, `Ticket #${id} closed.`);
+});
+
+$ticketTable.on('click', '.btn-reopen', function reopenTicket(event) {
+    const id = ticketRowID($(event.currentTarget));
+    ajaxAndReload('POST', `/api/staff/tickets/${id}/reopen`, undefined, `Ticket #${id} reopened.`);
+});
@@ -0,0 +1,72 @@
+// Support ticket threads, shared by the blue team dashboard and the staff ticket queue.
+// The thread modal's `data-api` attribute decides which API the page talks to.
+const $ticketModal = $('#ticket-thread-modal');
+const ticketApi = $ticketModal.data('api');
+
+function sendTicketJSON(method, url, data) {
+    return $.ajax({
+        url,
+        method,
+        data: JSON.stringify(data),
+        contentType: "application/json; charset=utf-8",
+    });
+}
+
+const ticketXhrErr = xhr => {
+    if (xhr.status === 0) {
+        return "Network error!";
+    }
+    return xhr.responseJSON ? xhr.responseJSON.status : xhr.responseText;
+};
+
+function renderTicketMessage(msg) {
+    const fromStaff = msg.author_role !== "blueteam";
+    const $msg = $(`<div class="card p-2 mb-2"></div>`).toggleClass('border-info', fromStaff);
+    const when = new Date(msg.created_at).toLocaleTimeString();
+    $msg.append($(`<div class="small text-muted"></div>`).text(`${msg.author_name} @ ${when}`));
+    $msg.append($(`<div style="white-space: pre-wrap;"></div>`).text(msg.body));
+    return $msg;
+}
+
+function showTicketThread(id) {
+    $.getJSON(`${ticketApi}/${id}`).done(thread => {
+        $ticketModal.data('ticket-id', thread.id);
+        $ticketModal.find('.modal-title').text(`#${thread.id}: ${thread.subject}`);
+
+        const opened = new Date(thread.created_at).toLocaleString();
+        const status = thread.closed_at ? "closed" : "open";
+        $ticketModal.find('.ticket-meta').text(`${thread.category} | opened ${opened} | ${status}`);
+
+        $ticketModal.find('.ticket-messages').empty().append(thread.messages.map(renderTicketMessage));
+        $ticketModal.modal('show');
+    }).fail(xhr => alert(ticketXhrErr(xhr)));
+}
+
+$('.ticket-table').on('click', 'tbody a', function(event) {
+    event.preventDefault();
+    showTicketThread($(this).closest('tr').data('ticket-id'));
+});
+
+$ticketModal.find('.ticket-reply-form').on('submit', function replyToTicket(event) {
+    event.preventDefault();
+    const $form = $(this);
+    const id = $ticketModal.data('ticket-id');
+    const data = { body: $form.find('textarea[name=body]').val() };
+
+    sendTicketJSON('POST', `${ticketApi}/${id}/messages`, data).done(msg => {
+        $ticketModal.find('.ticket-messages').append(renderTicketMessage(msg));
+        $form.trigger('reset');
+    }).fail(xhr => alert(ticketXhrErr(xhr)));
+});
+
+$('.ticket-open-form').on('submit', function openTicket(event) {
+    event.preventDefault();
+    const $form = $(this);
+    const field = (name) => $form.find(`[name=${name}]`).val();
+    const data = { category: field("category"), subject: field("subject"), message: field("message") };
+
+    sendTicketJSON('POST', ticketApi, data).done(() => {
+        alert("Ticket opened! Staff will get back to you here. Page will reload.");
+        window.location.reload();
+    }).fail(xhr => alert(ticketXhrErr(xhr)));
+});